@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"leakcheck/internal/reporter"
+	"leakcheck/internal/resultsdb"
+)
+
+// Badge colors, as used by shields.io
+const (
+	badgeGreen  = "#4c1"
+	badgeYellow = "#dfb317"
+	badgeRed    = "#e05d44"
+)
+
+// runBadge implements `leakcheck badge -o badge.svg [--db=leaks.db | report.json]`
+func runBadge(args []string) int {
+	fs := flag.NewFlagSet("badge", flag.ContinueOnError)
+	var output string
+	fs.StringVar(&output, "output", "", "Write the SVG to this file instead of stdout")
+	fs.StringVar(&output, "o", "", "Shorthand for --output")
+	dbFlag := fs.String("db", "", "Use the latest run recorded in this results database (written by --output-db)")
+	labelFlag := fs.String("label", "memory leaks", "Text on the left side of the badge")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck badge [-o badge.svg] (--db=leaks.db | report.json)\n\n")
+		fs.PrintDefaults()
+	}
+	inputs, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if (*dbFlag == "") == (len(inputs) != 1) {
+		fs.Usage()
+		return 1
+	}
+
+	var summary reporter.Summary
+	if *dbFlag != "" {
+		summary, err = latestRunSummary(*dbFlag)
+	} else {
+		summary, err = reportSummary(inputs[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	out := io.Writer(os.Stdout)
+	if output != "" {
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	message, color := badgeStatus(summary)
+	if _, err := io.WriteString(out, badgeSVG(*labelFlag, message, color)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing badge: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// latestRunSummary counts the findings of the newest run in a results
+// database
+func latestRunSummary(path string) (reporter.Summary, error) {
+	db, err := resultsdb.Open(path)
+	if err != nil {
+		return reporter.Summary{}, err
+	}
+	defer db.Close()
+	runs, err := db.Runs(context.Background())
+	if err != nil {
+		return reporter.Summary{}, err
+	}
+	if len(runs) == 0 {
+		return reporter.Summary{}, fmt.Errorf("%s: no runs recorded", path)
+	}
+	run := runs[len(runs)-1]
+	return reporter.Summary{TotalIssues: run.Total(), Errors: run.Errors, Warnings: run.Warnings, Info: run.Info}, nil
+}
+
+// reportSummary counts the findings of a JSON report
+func reportSummary(path string) (reporter.Summary, error) {
+	leaks, err := reporter.ReadJSONReport(path)
+	if err != nil {
+		return reporter.Summary{}, err
+	}
+	return reporter.Summarize(leaks), nil
+}
+
+// badgeStatus returns the badge message and color: errors in red, else
+// warnings in yellow, else 0 in green. Informational findings don't count.
+func badgeStatus(s reporter.Summary) (string, string) {
+	plural := func(n int, word string) string {
+		if n == 1 {
+			return "1 " + word
+		}
+		return fmt.Sprintf("%d %ss", n, word)
+	}
+	switch {
+	case s.Errors > 0:
+		return plural(s.Errors, "error"), badgeRed
+	case s.Warnings > 0:
+		return plural(s.Warnings, "warning"), badgeYellow
+	}
+	return "0", badgeGreen
+}
+
+// badgeSVG renders a flat shields-style badge. Text widths are estimated
+// from the average width of Verdana 11px, which is close enough for the
+// short ASCII strings badges carry.
+func badgeSVG(label, message, color string) string {
+	textWidth := func(s string) int { return len(s)*7 + 10 }
+	lw, mw := textWidth(label), textWidth(message)
+	label, message = template.HTMLEscapeString(label), template.HTMLEscapeString(message)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`+"\n", lw+mw, label, message)
+	fmt.Fprintf(&sb, `<title>%s: %s</title>`+"\n", label, message)
+	sb.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>` + "\n")
+	fmt.Fprintf(&sb, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`+"\n", lw+mw)
+	fmt.Fprintf(&sb, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`+"\n",
+		lw, lw, mw, color, lw+mw)
+	sb.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">` + "\n")
+	for _, part := range []struct {
+		x    int
+		text string
+	}{{lw / 2, label}, {lw + mw/2, message}} {
+		fmt.Fprintf(&sb, `<text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`+"\n", part.x, part.text, part.x, part.text)
+	}
+	sb.WriteString("</g>\n</svg>\n")
+	return sb.String()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"time"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+	"leakcheck/internal/scanner"
+)
+
+// benchReport is the result of `leakcheck bench`. Timings are medians over
+// the runs.
+type benchReport struct {
+	Version      string                 `json:"version"`
+	GoVersion    string                 `json:"go_version"`
+	Platform     string                 `json:"platform"`
+	Corpus       string                 `json:"corpus"`
+	Runs         int                    `json:"runs"`
+	Files        int                    `json:"files"`
+	Lines        int                    `json:"lines"`
+	Tokens       int                    `json:"tokens"`
+	Classes      int                    `json:"classes"`
+	Findings     int                    `json:"findings"`
+	DurationMS   float64                `json:"duration_ms"`
+	FilesPerSec  float64                `json:"files_per_sec"`
+	TokensPerSec float64                `json:"tokens_per_sec"`
+	PeakRSSBytes int64                  `json:"peak_rss_bytes,omitempty"` // 0 where the platform does not report it
+	Phases       []reporter.PhaseTiming `json:"phases"`
+}
+
+// runBench implements `leakcheck bench [--corpus=dir]`, timing the full
+// scan, parse, merge and analyze pipeline over a corpus
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	corpusFlag := fs.String("corpus", "testdata", "Directory of C++ sources to benchmark (default: the repository's testdata)")
+	runsFlag := fs.Int("runs", 5, "Number of timed runs; timings are the median")
+	jsonFlag := fs.Bool("json", false, "Print the results as JSON for comparison across releases")
+	var output string
+	fs.StringVar(&output, "output", "", "Write the results to this file instead of stdout")
+	fs.StringVar(&output, "o", "", "Shorthand for --output")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck bench [--corpus=dir] [--runs=N] [--json] [-o file]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 || *runsFlag < 1 {
+		fs.Usage()
+		return 1
+	}
+
+	report, err := bench(*corpusFlag, *runsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	out := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	if *jsonFlag {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(report)
+	} else {
+		err = writeBench(out, report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// bench runs the pipeline over corpus the given number of times
+func bench(corpus string, runs int) (*benchReport, error) {
+	ctx := context.Background()
+	report := &benchReport{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Corpus:    corpus,
+		Runs:      runs,
+	}
+
+	files, err := scanner.NewScanner(nil).ScanPaths(ctx, []string{corpus})
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no C++ files found in %s", corpus)
+	}
+	report.Files = len(files)
+	for _, file := range files {
+		tokens, err := countTokens(file)
+		if err != nil {
+			return nil, err
+		}
+		report.Tokens += tokens
+	}
+
+	phases := []string{"scan", "parse", "merge", "analyze"}
+	samples := make(map[string][]time.Duration)
+	var totals []time.Duration
+	for range runs {
+		runStart := time.Now()
+
+		start := time.Now()
+		files, err := scanner.NewScanner(nil).ScanPaths(ctx, []string{corpus})
+		if err != nil {
+			return nil, err
+		}
+		samples["scan"] = append(samples["scan"], time.Since(start))
+
+		start = time.Now()
+		registry := parser.NewClassRegistry()
+		lines := 0
+		for _, file := range files {
+			classes, n, err := parser.ParseFileLines(ctx, file)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s: %w", file, err)
+			}
+			lines += n
+			registry.AddClasses(classes)
+		}
+		samples["parse"] = append(samples["parse"], time.Since(start))
+
+		start = time.Now()
+		classes := registry.MergeClasses()
+		samples["merge"] = append(samples["merge"], time.Since(start))
+
+		start = time.Now()
+		a := analyzer.NewAnalyzer()
+		a.AddClasses(classes)
+		leaks, err := a.Analyze(ctx)
+		if err != nil {
+			return nil, err
+		}
+		samples["analyze"] = append(samples["analyze"], time.Since(start))
+
+		totals = append(totals, time.Since(runStart))
+		report.Lines, report.Classes, report.Findings = lines, len(classes), len(leaks)
+	}
+
+	for _, name := range phases {
+		report.Phases = append(report.Phases, reporter.PhaseTiming{
+			Name:       name,
+			DurationMS: milliseconds(median(samples[name])),
+		})
+	}
+	total := median(totals)
+	report.DurationMS = milliseconds(total)
+	if seconds := total.Seconds(); seconds > 0 {
+		report.FilesPerSec = float64(report.Files) / seconds
+		report.TokensPerSec = float64(report.Tokens) / seconds
+	}
+	report.PeakRSSBytes = peakRSS()
+	return report, nil
+}
+
+// countTokens returns the number of tokens the lexer produces for a file
+func countTokens(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	lexer := parser.NewReaderLexer(f)
+	tokens := lexer.Tokenize()
+	return len(tokens) - 1, lexer.Err() // the EOF token is not counted
+}
+
+// median returns the middle of the samples
+func median(samples []time.Duration) time.Duration {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// writeBench prints the benchmark results as text
+func writeBench(w io.Writer, r *benchReport) error {
+	fmt.Fprintf(w, "leakcheck %s, %s, %s\n", r.Version, r.GoVersion, r.Platform)
+	fmt.Fprintf(w, "Corpus %s: %d file(s), %d line(s), %d token(s), %d class(es), %d finding(s)\n\n",
+		r.Corpus, r.Files, r.Lines, r.Tokens, r.Classes, r.Findings)
+	fmt.Fprintf(w, "  %-16s %10.1f ms (median of %d)\n", "Total:", r.DurationMS, r.Runs)
+	for _, p := range r.Phases {
+		fmt.Fprintf(w, "  %-16s %10.1f ms\n", p.Name+":", p.DurationMS)
+	}
+	fmt.Fprintf(w, "  %-16s %10.0f\n", "Files/sec:", r.FilesPerSec)
+	fmt.Fprintf(w, "  %-16s %10.0f\n", "Tokens/sec:", r.TokensPerSec)
+	if r.PeakRSSBytes > 0 {
+		fmt.Fprintf(w, "  %-16s %10.1f MB\n", "Peak RSS:", float64(r.PeakRSSBytes)/(1<<20))
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+	"leakcheck/internal/telemetry"
+)
+
+// boundedSource parses files one at a time and hands each class to the
+// analyzer as soon as every file that may define it has been parsed, so only
+// classes still waiting on files are held in memory. A prepass over the
+// tokens learns which files mention each class. Classes are merged in
+// registry, which must be empty. Free functions and globals are added to a
+// as each file is parsed.
+func boundedSource(ctx context.Context, a *analyzer.Analyzer, files []string, registry *parser.ClassRegistry, fileTimeout time.Duration, stats *reporter.Stats, span *telemetry.Span) analyzer.ClassSource {
+	return func(yield func(parser.Class) bool) error {
+		fileClasses := make([][]string, len(files))
+		pending := make(map[string]int)
+		for i, file := range files {
+			names, err := parser.ClassNames(ctx, file)
+			if interrupted(err) {
+				return err
+			}
+			fileClasses[i] = names
+			for _, name := range names {
+				pending[name]++
+			}
+		}
+
+		release := func(class *parser.Class) bool {
+			stats.ClassesFound++
+			if slices.ContainsFunc(class.Members, func(m parser.Member) bool { return m.IsPointer }) {
+				stats.ClassesWithPointers++
+			}
+			return yield(*class)
+		}
+
+		for i, file := range files {
+			fileStart := time.Now()
+			unit, err := parseFile(ctx, file, fileTimeout)
+			traceParse(span, file, fileStart, unit, false, err)
+			if interrupted(err) {
+				return err
+			}
+			if errors.Is(err, errFileTimeout) {
+				stats.FilesTimedOut++
+			}
+			if err != nil {
+				slog.Warn("cannot parse file", "file", file, "err", err)
+			} else {
+				slog.Info("parsed", "file", file, "classes", len(unit.Classes), "lines", unit.Lines, "duration", time.Since(fileStart))
+				stats.LinesTokenized += unit.Lines
+				registry.AddClasses(unit.Classes)
+				a.AddFunctions(unit.Functions)
+				a.AddGlobals(unit.Globals)
+			}
+
+			for _, name := range fileClasses[i] {
+				if pending[name]--; pending[name] > 0 {
+					continue
+				}
+				delete(pending, name)
+				for _, class := range registry.Release(name) {
+					if !release(class) {
+						return nil
+					}
+				}
+			}
+		}
+
+		// Classes the prepass attributed to no file
+		for _, class := range registry.Merged() {
+			if !release(class) {
+				return nil
+			}
+		}
+		return nil
+	}
+}
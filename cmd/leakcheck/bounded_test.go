@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+)
+
+// analyzeBounded analyzes files written under a temporary directory through
+// boundedSource, with the opt-in rules enabled, and returns the findings as
+// rule: variable pairs
+func analyzeBounded(t *testing.T, rules []string, files map[string]string) []string {
+	t.Helper()
+	dir := t.TempDir()
+	var paths []string
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	ctx := context.Background()
+	a := analyzer.NewAnalyzer()
+	a.DisabledRules = make(map[string]bool)
+	for _, id := range rules {
+		a.DisabledRules[id] = false
+	}
+	var stats reporter.Stats
+	a.AddSource(boundedSource(ctx, a, paths, parser.NewClassRegistry(), 0, &stats, nil))
+	leaks, err := a.Analyze(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, leak := range leaks {
+		if slices.Contains(rules, leak.RuleID) {
+			got = append(got, leak.RuleID+": "+leak.VarName)
+		}
+	}
+	slices.Sort(got)
+	return got
+}
+
+func TestBoundedFunctions(t *testing.T) {
+	got := analyzeBounded(t, []string{analyzer.RuleRawFactory}, map[string]string{
+		"widget.h": "class Widget {};\n",
+		"factory.cpp": `Widget* makeWidget() { return new Widget; }
+std::unique_ptr<Widget> makeOwned() { return std::make_unique<Widget>(); }
+`,
+	})
+	want := []string{analyzer.RuleRawFactory + ": makeWidget"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got findings %q, want %q", got, want)
+	}
+}
+
+func TestBoundedGlobals(t *testing.T) {
+	got := analyzeBounded(t, []string{analyzer.RuleGlobalLeak}, map[string]string{
+		"config.cpp": `class Widget {};
+Widget* g_config = new Widget;
+Widget* g_cache = new Widget;
+`,
+		"cache.cpp": `class Cache {
+public:
+    ~Cache() { delete g_cache; }
+    Widget* instance() {
+        static Widget* shared = new Widget;
+        return shared;
+    }
+};
+`,
+	})
+	want := []string{analyzer.RuleGlobalLeak + ": g_config", analyzer.RuleGlobalLeak + ": shared"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got findings %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"leakcheck/internal/parser"
+)
+
+// runContext returns a context cancelled on Ctrl-C or SIGTERM, or once
+// timeout elapses when it is positive. After the first signal the default
+// handling is restored, so a second Ctrl-C exits immediately.
+func runContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
+	}
+}
+
+// interrupted reports whether err comes from the run being cancelled or
+// timing out
+func interrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// errFileTimeout reports a file given up on after --timeout-per-file
+var errFileTimeout = errors.New("parsing exceeded the per-file timeout")
+
+// parseFile parses one file, with its --cmake build flags. With a positive
+// limit it gives up on the file after that long with errFileTimeout, leaving
+// the rest of the run going.
+func parseFile(ctx context.Context, file string, limit time.Duration) (*parser.Unit, error) {
+	if limit <= 0 {
+		return parser.ParseUnitFlags(ctx, file, fileBuildFlags[file])
+	}
+	fileCtx, cancel := context.WithTimeoutCause(ctx, limit, errFileTimeout)
+	defer cancel()
+	unit, err := parser.ParseUnitFlags(fileCtx, file, fileBuildFlags[file])
+	if err != nil && ctx.Err() == nil && errors.Is(context.Cause(fileCtx), errFileTimeout) {
+		return nil, errFileTimeout
+	}
+	return unit, err
+}
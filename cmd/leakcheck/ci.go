@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+)
+
+// ciModes lists the supported --ci values
+var ciModes = []string{"github"}
+
+// githubSARIF is where --ci=github writes a SARIF log unless a --format
+// already asks for one
+const githubSARIF = "leakcheck.sarif"
+
+// validateCI checks a --ci mode name; "" means no CI integration
+func validateCI(mode string) error {
+	for _, m := range ciModes {
+		if m == mode {
+			return nil
+		}
+	}
+	if mode != "" {
+		return fmt.Errorf("unknown CI mode %q (supported: %s)", mode, strings.Join(ciModes, ", "))
+	}
+	return nil
+}
+
+// ciFormats adds the reports a CI integration produces to the requested
+// --format values: for GitHub, annotations on stdout and a SARIF log
+func ciFormats(mode string, formats []string) []string {
+	if mode != "github" {
+		return formats
+	}
+	formats = append(formats, "github")
+	for _, f := range formats {
+		if name, _, _ := strings.Cut(f, ":"); name == "sarif" {
+			return formats
+		}
+	}
+	return append(formats, "sarif:"+githubSARIF)
+}
+
+// writeGitHubResults appends a markdown report to the job summary and the
+// finding counts and SARIF path to the step outputs, when running in
+// GitHub Actions
+func writeGitHubResults(specs []outputSpec, leaks []parser.Leak, opts reporter.Options) error {
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		if err := appendFile(path, func(f *os.File) error {
+			return (&reporter.MarkdownFormatter{BaseDir: opts.BaseDir, GroupBy: opts.GroupBy, SortBy: opts.SortBy}).Format(f, leaks)
+		}); err != nil {
+			return fmt.Errorf("step summary: %w", err)
+		}
+	}
+
+	if path := os.Getenv("GITHUB_OUTPUT"); path != "" {
+		summary := reporter.Summarize(leaks)
+		sarif := ""
+		for _, spec := range specs {
+			if spec.Format == "sarif" && spec.Path != "" {
+				sarif = spec.Path
+			}
+		}
+		if err := appendFile(path, func(f *os.File) error {
+			_, err := fmt.Fprintf(f, "errors=%d\nwarnings=%d\ninfo=%d\ntotal=%d\nsarif=%s\n",
+				summary.Errors, summary.Warnings, summary.Info, summary.TotalIssues, sarif)
+			return err
+		}); err != nil {
+			return fmt.Errorf("step outputs: %w", err)
+		}
+	}
+	return nil
+}
+
+// appendFile opens path for appending, creating it when missing, and runs
+// write on it
+func appendFile(path string, write func(f *os.File) error) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
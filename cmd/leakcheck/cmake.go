@@ -0,0 +1,29 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"leakcheck/internal/cmake"
+	"leakcheck/internal/parser"
+)
+
+// fileBuildFlags are the compiler options of the files to parse, by
+// absolute path, when --cmake names a build directory
+var fileBuildFlags map[string]*parser.BuildFlags
+
+// cmakeFiles returns the files of a CMake project that lie beneath one of
+// paths
+func cmakeFiles(project *cmake.Project, paths []string) []string {
+	var files []string
+	for _, file := range project.Files {
+		for _, path := range paths {
+			root, _ := filepath.Abs(path)
+			if rel, err := filepath.Rel(root, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				files = append(files, file)
+				break
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,17 @@
+package main
+
+// subcommands maps subcommand names to their entry points; anything else on
+// the command line is treated as a path to scan
+var subcommands = map[string]func(args []string) int{
+	"badge":        runBadge,
+	"bench":        runBench,
+	"dashboard":    runDashboard,
+	"dump-model":   runDumpModel,
+	"dump-tokens":  runDumpTokens,
+	"explain":      runExplain,
+	"init":         runInit,
+	"install-hook": runInstallHook,
+	"merge":        runMerge,
+	"rules":        runRules,
+	"scan-repo":    runScanRepo,
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/config"
+	"leakcheck/internal/script"
+)
+
+// loadConfig reads the file named by --config, or the .leakcheck.yml
+// discovered from the first scan root, and registers its rule scripts. It
+// returns nil when there is none.
+func loadConfig(path string, paths []string) (*config.Config, error) {
+	if path == "" && len(paths) > 0 {
+		path = config.Find(scanRoot(paths[0]))
+	}
+	if path == "" {
+		return nil, nil
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Rules.Scripts != "" {
+		if err := registerScripts(cfg.Rules.Scripts); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.ValidateRules(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// registerScripts loads the Starlark rules in dir and adds them to the
+// analyzer. Errors raised while a script checks a class are printed as
+// warnings and skip that class.
+func registerScripts(dir string) error {
+	rules, err := script.LoadDir(dir, func(err error) {
+		slog.Warn("rule script failed", "err", err)
+	})
+	if err != nil {
+		return fmt.Errorf("loading rule scripts: %w", err)
+	}
+	for _, rule := range rules {
+		if _, exists := analyzer.LookupRule(rule.ID()); exists {
+			return fmt.Errorf("%s: rule %s is already defined", rule.Path, rule.ID())
+		}
+		analyzer.Register(rule, rule.Info)
+	}
+	return nil
+}
+
+// scanRoot returns the directory of a scan path argument
+func scanRoot(path string) string {
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return filepath.Dir(path)
+	}
+	return path
+}
+
+// configureAnalyzer applies rule selection, severity overrides, framework
+// profiles, ownership sinks and allocator pairs from the configuration
+func configureAnalyzer(a *analyzer.Analyzer, cfg *config.Config) error {
+	a.DisabledRules = cfg.DisabledRules()
+	a.SeverityOverrides = cfg.Severity
+	a.Allocators = cfg.Allocators
+	if len(cfg.Profiles)+len(cfg.Sinks) > 0 && a.Summaries == nil {
+		a.Summaries = make(analyzer.FunctionSummaries)
+	}
+	for _, name := range cfg.Profiles {
+		if err := a.Summaries.AddProfile(name); err != nil {
+			return err
+		}
+	}
+	for _, sink := range cfg.Sinks {
+		sink.TakesOwnership = true
+		a.Summaries.Add(sink)
+	}
+	return nil
+}
+
+// explicitFlags returns the names of flags set on the command line, which
+// take precedence over configuration file values
+func explicitFlags() map[string]bool {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
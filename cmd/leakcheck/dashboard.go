@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"leakcheck/internal/resultsdb"
+)
+
+// maxChartDirectories is how many directories the per-directory chart shows
+const maxChartDirectories = 8
+
+// runDashboard implements `leakcheck dashboard --db=leaks.db --listen :8090`
+func runDashboard(args []string) int {
+	fs := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	dbFlag := fs.String("db", "leaks.db", "Results database written by --output-db")
+	listenFlag := fs.String("listen", "localhost:8090", "Address to serve the dashboard on, e.g. :8090")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck dashboard [--db=leaks.db] [--listen=localhost:8090]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	db, err := resultsdb.Open(*dbFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening results database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	d := &dashboard{db: db}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.overview)
+	mux.HandleFunc("GET /run", d.run)
+	mux.HandleFunc("GET /source", d.source)
+	fmt.Fprintf(os.Stderr, "Serving the dashboard for %s on http://%s/\n", *dbFlag, dashboardHost(*listenFlag))
+	if err := http.ListenAndServe(*listenFlag, mux); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// dashboardHost returns the host to print for a listen address, which may
+// omit the host entirely
+func dashboardHost(listen string) string {
+	if strings.HasPrefix(listen, ":") {
+		return "localhost" + listen
+	}
+	return listen
+}
+
+// dashboard serves pages built from a results database
+type dashboard struct {
+	db *resultsdb.DB
+}
+
+// overview shows the trend charts and the list of runs
+func (d *dashboard) overview(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	runs, err := d.db.Runs(ctx)
+	if err != nil {
+		d.fail(w, err)
+		return
+	}
+	byRule, err := d.db.CountsByRule(ctx)
+	if err != nil {
+		d.fail(w, err)
+		return
+	}
+	byDir, err := d.db.CountsByDirectory(ctx)
+	if err != nil {
+		d.fail(w, err)
+		return
+	}
+
+	labels := make([]string, len(runs))
+	severities := []chartSeries{{Name: "errors"}, {Name: "warnings"}, {Name: "info"}}
+	for i, run := range runs {
+		labels[i] = run.Started.Local().Format("Jan 2 15:04")
+		severities[0].Values = append(severities[0].Values, run.Errors)
+		severities[1].Values = append(severities[1].Values, run.Warnings)
+		severities[2].Values = append(severities[2].Values, run.Info)
+	}
+	var latest int64
+	if len(runs) > 0 {
+		latest = runs[len(runs)-1].ID
+	}
+	newestFirst := slices.Clone(runs)
+	slices.Reverse(newestFirst)
+	page := struct {
+		Runs        []resultsdb.RunSummary
+		Latest      int64
+		Severities  []chartSeries
+		Rules       []chartSeries
+		Directories []chartSeries
+		Labels      []string
+	}{
+		Runs:        newestFirst,
+		Latest:      latest,
+		Severities:  severities,
+		Rules:       groupSeries(runs, byRule, 0),
+		Directories: groupSeries(runs, byDir, maxChartDirectories),
+		Labels:      labels,
+	}
+	d.render(w, "overview", page)
+}
+
+// run lists the findings of one run, optionally limited to a rule or a
+// directory
+func (d *dashboard) run(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid run ID", http.StatusBadRequest)
+		return
+	}
+	run, err := d.db.Run(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	rule, dir := r.URL.Query().Get("rule"), r.URL.Query().Get("dir")
+	findings, err := d.db.Findings(ctx, id, rule, dir)
+	if err != nil {
+		d.fail(w, err)
+		return
+	}
+	d.render(w, "run", struct {
+		Run      resultsdb.RunSummary
+		Rule     string
+		Dir      string
+		Findings []resultsdb.Finding
+	}{run, rule, dir, findings})
+}
+
+// source shows a file with findings of a run, reading it from the run's
+// base directory. Only files with findings in the run are served.
+func (d *dashboard) source(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, err := strconv.ParseInt(r.URL.Query().Get("run"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid run ID", http.StatusBadRequest)
+		return
+	}
+	file := r.URL.Query().Get("file")
+	run, err := d.db.Run(ctx, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	findings, err := d.db.Findings(ctx, id, "", "")
+	if err != nil {
+		d.fail(w, err)
+		return
+	}
+	marked := make(map[int][]resultsdb.Finding)
+	for _, f := range findings {
+		if f.File == file {
+			marked[f.Line] = append(marked[f.Line], f)
+		}
+	}
+	if len(marked) == 0 {
+		http.Error(w, "no findings in "+file+" for this run", http.StatusNotFound)
+		return
+	}
+
+	lines, err := readSourceLines(run.BaseDir, file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	type sourceLine struct {
+		Number   int
+		Text     string
+		Findings []resultsdb.Finding
+	}
+	page := struct {
+		Run   resultsdb.RunSummary
+		File  string
+		Lines []sourceLine
+	}{Run: run, File: file}
+	for i, text := range lines {
+		page.Lines = append(page.Lines, sourceLine{Number: i + 1, Text: text, Findings: marked[i+1]})
+	}
+	d.render(w, "source", page)
+}
+
+// readSourceLines reads a file recorded relative to baseDir
+func readSourceLines(baseDir, file string) ([]string, error) {
+	path := filepath.FromSlash(file)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.FromSlash(baseDir), path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func (d *dashboard) render(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplates.ExecuteTemplate(w, name, data); err != nil {
+		slog.Error("rendering dashboard page", "page", name, "err", err)
+	}
+}
+
+func (d *dashboard) fail(w http.ResponseWriter, err error) {
+	slog.Error("reading results database", "err", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// chartSeries is one line of a trend chart, with a value per run
+type chartSeries struct {
+	Name   string
+	Values []int
+}
+
+// groupSeries turns per-run counts into one series per group, the groups
+// with the most findings in the latest run first; limit > 0 keeps only
+// that many
+func groupSeries(runs []resultsdb.RunSummary, counts map[int64]map[string]int, limit int) []chartSeries {
+	groups := make(map[string]bool)
+	for _, byGroup := range counts {
+		for name := range byGroup {
+			groups[name] = true
+		}
+	}
+	var latest map[string]int
+	if len(runs) > 0 {
+		latest = counts[runs[len(runs)-1].ID]
+	}
+	names := slices.Sorted(maps.Keys(groups))
+	slices.SortStableFunc(names, func(a, b string) int { return latest[b] - latest[a] })
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+	}
+
+	series := make([]chartSeries, len(names))
+	for i, name := range names {
+		series[i].Name = name
+		for _, run := range runs {
+			series[i].Values = append(series[i].Values, counts[run.ID][name])
+		}
+	}
+	return series
+}
+
+// chartColors are the colors of successive chart series
+var chartColors = []string{"#c0392b", "#e67e22", "#2980b9", "#27ae60", "#8e44ad", "#16a085", "#d35400", "#7f8c8d", "#2c3e50", "#f1c40f"}
+
+// trendChart draws series over runs as an SVG line chart
+func trendChart(labels []string, series []chartSeries) template.HTML {
+	const width, height, pad = 720, 220, 30
+	top := 1
+	for _, s := range series {
+		for _, v := range s.Values {
+			top = max(top, v)
+		}
+	}
+	x := func(i int) float64 {
+		if len(labels) < 2 {
+			return width / 2
+		}
+		return pad + float64(i)*float64(width-2*pad)/float64(len(labels)-1)
+	}
+	y := func(v int) float64 {
+		return height - pad - float64(v)*float64(height-2*pad)/float64(top)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg class="chart" viewBox="0 0 %d %d" role="img">`, width, height)
+	fmt.Fprintf(&sb, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#bbb"/>`, pad, height-pad, width-pad, height-pad)
+	fmt.Fprintf(&sb, `<text x="4" y="%d" font-size="10">%d</text><text x="4" y="%d" font-size="10">0</text>`, pad, top, height-pad)
+	if len(labels) > 0 {
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="10">%s</text>`, pad, height-8, template.HTMLEscapeString(labels[0]))
+		fmt.Fprintf(&sb, `<text x="%d" y="%d" font-size="10" text-anchor="end">%s</text>`, width-pad, height-8, template.HTMLEscapeString(labels[len(labels)-1]))
+	}
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		var points []string
+		for j, v := range s.Values {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x(j), y(v)))
+		}
+		fmt.Fprintf(&sb, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, color, strings.Join(points, " "))
+		for j, v := range s.Values {
+			fmt.Fprintf(&sb, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s, %s: %d</title></circle>`,
+				x(j), y(v), color, template.HTMLEscapeString(s.Name), template.HTMLEscapeString(labels[j]), v)
+		}
+	}
+	sb.WriteString(`</svg><div class="legend">`)
+	for i, s := range series {
+		fmt.Fprintf(&sb, `<span><i style="background:%s"></i>%s</span>`, chartColors[i%len(chartColors)], template.HTMLEscapeString(s.Name))
+	}
+	sb.WriteString(`</div>`)
+	return template.HTML(sb.String())
+}
+
+// dashboardSource links a finding to its line in the source view
+func dashboardSource(run int64, f resultsdb.Finding) string {
+	return fmt.Sprintf("/source?run=%d&file=%s#L%d", run, template.URLQueryEscaper(f.File), f.Line)
+}
+
+var dashboardTemplates = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"chart":  trendChart,
+	"source": dashboardSource,
+}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.}} - leakcheck</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 1100px; color: #222; }
+a { color: #2471a3; }
+table { border-collapse: collapse; width: 100%; font-size: 14px; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #eee; vertical-align: top; }
+.chart { width: 100%; max-width: 720px; }
+.legend span { margin-right: 1em; font-size: 13px; }
+.legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; }
+.error { color: #c0392b; } .warning { color: #d68910; } .info { color: #2980b9; }
+pre { margin: 0; }
+.src td { border: none; padding: 0 8px; font-family: ui-monospace, monospace; font-size: 13px; white-space: pre; }
+.src tr.hit { background: #fdebd0; }
+.src .note { font-family: system-ui, sans-serif; white-space: normal; }
+</style></head><body>
+<p><a href="/">leakcheck dashboard</a></p>{{end}}
+
+{{define "overview"}}{{template "head" "Dashboard"}}
+<h1>Findings over time</h1>
+{{if not .Runs}}<p>No runs recorded yet. Run <code>leakcheck --output-db=leaks.db ./src</code> to add one.</p>{{else}}
+{{chart .Labels .Severities}}
+<h2>Per rule</h2>
+{{chart .Labels .Rules}}
+<p>{{range .Rules}}<a href="/run?id={{$.Latest}}&rule={{.Name}}">{{.Name}}</a> {{end}}</p>
+<h2>Per directory</h2>
+{{chart .Labels .Directories}}
+<p>{{range .Directories}}<a href="/run?id={{$.Latest}}&dir={{.Name}}">{{.Name}}</a> {{end}}</p>
+<h2>Runs</h2>
+<table><tr><th>Run</th><th>Started</th><th>Version</th><th>Files</th><th>Errors</th><th>Warnings</th><th>Info</th><th></th></tr>
+{{range .Runs}}<tr><td><a href="/run?id={{.ID}}">#{{.ID}}</a></td><td>{{.Started.Local.Format "2006-01-02 15:04"}}</td>
+<td>{{.ToolVersion}}</td><td>{{.Files}}</td><td class="error">{{.Errors}}</td><td class="warning">{{.Warnings}}</td><td class="info">{{.Info}}</td>
+<td>{{if not .Complete}}partial{{end}}</td></tr>
+{{end}}</table>{{end}}
+</body></html>{{end}}
+
+{{define "run"}}{{template "head" "Run"}}
+<h1>Run #{{.Run.ID}}</h1>
+<p>{{.Run.Started.Local.Format "2006-01-02 15:04"}} in <code>{{.Run.BaseDir}}</code>: {{.Run.Total}} finding(s){{if .Rule}}, showing rule {{.Rule}}{{end}}{{if .Dir}}, showing directory {{.Dir}}{{end}}.
+{{if or .Rule .Dir}}<a href="/run?id={{.Run.ID}}">Show all</a>{{end}}</p>
+<table><tr><th>Severity</th><th>Rule</th><th>Location</th><th>Class</th><th>Variable</th><th>Reason</th></tr>
+{{range .Findings}}<tr><td class="{{.Severity}}">{{.Severity}}</td>
+<td><a href="/run?id={{$.Run.ID}}&rule={{.Rule}}">{{.Rule}}</a></td>
+<td><a href="{{source $.Run.ID .}}">{{.File}}:{{.Line}}</a></td><td>{{.Class}}</td><td>{{.Variable}}</td>
+<td>{{.Reason}}<br><small>{{.Recommendation}}</small></td></tr>
+{{end}}</table>
+</body></html>{{end}}
+
+{{define "source"}}{{template "head" .File}}
+<h1>{{.File}}</h1>
+<p>Run <a href="/run?id={{.Run.ID}}">#{{.Run.ID}}</a></p>
+<table class="src">{{range .Lines}}<tr id="L{{.Number}}"{{if .Findings}} class="hit"{{end}}><td>{{.Number}}</td><td>{{.Text}}</td></tr>
+{{range .Findings}}<tr class="hit"><td></td><td class="note"><span class="{{.Severity}}">{{.Rule}}</span> {{.Reason}}</td></tr>
+{{end}}{{end}}</table>
+</body></html>{{end}}
+`))
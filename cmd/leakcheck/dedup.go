@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"leakcheck/internal/parser"
+)
+
+// contentCache maps file content hashes to the classes parsed from them, so
+// byte-identical copies of a file (generated code, vendored headers) are
+// parsed once
+type contentCache map[[sha256.Size]byte]cachedFile
+
+type cachedFile struct {
+	path    string // absolute path of the copy that was parsed
+	classes []parser.Class
+}
+
+// fileHash hashes what parsing a file depends on: its content, its build
+// flags and the path and content of every header it includes, so copies
+// next to different headers, or built with different flags, are told apart
+func fileHash(file string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return sum, err
+	}
+	abs, _ := filepath.Abs(file)
+	flags := fileBuildFlags[file]
+	h := sha256.New()
+	h.Write(data)
+	if flags != nil {
+		fmt.Fprintf(h, "\x00%q %q %q", flags.IncludeDirs, flags.Defines, flags.Undefines)
+	}
+	for _, header := range parser.IncludedHeaders(abs, data, flags) {
+		content, err := os.ReadFile(header)
+		if err != nil {
+			return sum, err
+		}
+		fmt.Fprintf(h, "\x00%s\x00%d\x00", header, len(content))
+		h.Write(content)
+	}
+	h.Sum(sum[:0])
+	return sum, nil
+}
+
+// store records the classes parsed from file
+func (c contentCache) store(key [sha256.Size]byte, file string, classes []parser.Class) {
+	abs, _ := filepath.Abs(file)
+	c[key] = cachedFile{path: abs, classes: classes}
+}
+
+// relocated copies the cached classes as if they were parsed from an
+// identical file at another path. Merged with the originals, each class
+// records both paths while its findings are reported once.
+func (f cachedFile) relocated(file string) []parser.Class {
+	to, _ := filepath.Abs(file)
+	result := make([]parser.Class, len(f.classes))
+	for i, class := range f.classes {
+		if class.File == f.path {
+			class.File = to
+		}
+		if class.DefinitionFile == f.path {
+			class.DefinitionFile = to
+		}
+		class.Locations = nil
+		for _, loc := range f.classes[i].Locations {
+			if loc.File == f.path {
+				loc.File = to
+			}
+			class.Locations = append(class.Locations, loc)
+		}
+		class.Constructor = f.relocateFunction(class.Constructor, to)
+		class.Destructor = f.relocateFunction(class.Destructor, to)
+		class.MoveConstructor = f.relocateFunction(class.MoveConstructor, to)
+		class.MoveAssignment = f.relocateFunction(class.MoveAssignment, to)
+		class.Methods = slices.Clone(class.Methods)
+		for j := range class.Methods {
+			class.Methods[j] = *f.relocateFunction(&class.Methods[j], to)
+		}
+		result[i] = class
+	}
+	return result
+}
+
+// relocateFunction copies fn, with its allocations and deallocations, as
+// if it were parsed from the copy at to
+func (f cachedFile) relocateFunction(fn *parser.Function, to string) *parser.Function {
+	if fn == nil {
+		return nil
+	}
+	moved := *fn
+	if moved.File == f.path {
+		moved.File = to
+	}
+	moved.Allocations = slices.Clone(fn.Allocations)
+	for i := range moved.Allocations {
+		if moved.Allocations[i].File == f.path {
+			moved.Allocations[i].File = to
+		}
+	}
+	moved.Deallocations = slices.Clone(fn.Deallocations)
+	for i := range moved.Deallocations {
+		if moved.Deallocations[i].File == f.path {
+			moved.Deallocations[i].File = to
+		}
+	}
+	return &moved
+}
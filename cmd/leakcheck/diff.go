@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"leakcheck/internal/gitdiff"
+	"leakcheck/internal/parser"
+)
+
+// diffFiles returns the files --diff has to parse: the changed ones, and
+// those sharing a class with them, such as the header declaring a class
+// whose methods a changed source file implements or the other source files
+// of a class a changed header declares. Only names declared as a class in
+// some scanned file relate files, so namespaces such as std do not. A file
+// the pre-pass cannot read is kept and left for the parser to report.
+func diffFiles(ctx context.Context, files []string, changes gitdiff.Changes) ([]string, error) {
+	names := make([][]string, len(files))
+	unread := make([]bool, len(files))
+	classes := make(map[string]bool) // declared in some scanned file
+	for i, file := range files {
+		declared, qualified, err := parser.ClassReferences(ctx, file)
+		if interrupted(err) {
+			return nil, err
+		}
+		if err != nil {
+			unread[i] = true
+			continue
+		}
+		for _, name := range declared {
+			classes[name] = true
+		}
+		names[i] = append(declared, qualified...)
+	}
+
+	touched := make(map[string]bool) // classes of the changed files
+	for i, file := range files {
+		if _, changed := changes[file]; changed {
+			for _, name := range names[i] {
+				if classes[name] {
+					touched[name] = true
+				}
+			}
+		}
+	}
+	var kept []string
+	for i, file := range files {
+		_, changed := changes[file]
+		if changed || unread[i] || slices.ContainsFunc(names[i], func(name string) bool { return touched[name] }) {
+			kept = append(kept, file)
+		}
+	}
+	slog.Info("analyzing changed files and files sharing their classes", "files", len(kept), "skipped", len(files)-len(kept))
+	return kept, nil
+}
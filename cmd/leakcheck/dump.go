@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"leakcheck/internal/parser"
+	"leakcheck/internal/scanner"
+)
+
+// runDumpModel implements `leakcheck dump-model [--json] file.cpp`, printing
+// the class model the analyzer sees for the given files
+func runDumpModel(args []string) int {
+	fs := flag.NewFlagSet("dump-model", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "Print the model as JSON")
+	noMergeFlag := fs.Bool("no-merge", false, "Show each file's classes as parsed, without merging headers and implementations")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck dump-model [--json] [--no-merge] <file> [files...]\n\n")
+		fs.PrintDefaults()
+	}
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	ctx := context.Background()
+	files, err := scanner.NewScanner(nil).ScanPaths(ctx, paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error scanning paths: %v\n", err)
+		return 1
+	}
+	registry := parser.NewClassRegistry()
+	var classes []parser.Class
+	for _, file := range files {
+		parsed, err := parser.ParseFile(ctx, file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", file, err)
+			return 1
+		}
+		classes = append(classes, parsed...)
+		registry.AddClasses(parsed)
+	}
+	if !*noMergeFlag {
+		classes = registry.MergeClasses()
+	}
+	sort.SliceStable(classes, func(i, j int) bool {
+		if classes[i].File != classes[j].File {
+			return classes[i].File < classes[j].File
+		}
+		return classes[i].StartLine < classes[j].StartLine
+	})
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false) // keep template arguments and -> readable
+		if classes == nil {
+			classes = []parser.Class{}
+		}
+		err = encoder.Encode(map[string][]parser.Class{"classes": classes})
+	} else {
+		err = writeModel(os.Stdout, classes)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeModel prints an indented outline of the classes
+func writeModel(w io.Writer, classes []parser.Class) error {
+	var b strings.Builder
+	for i, class := range classes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "class %s", class.QualifiedName())
+		for j, base := range class.Bases {
+			sep := ", "
+			if j == 0 {
+				sep = " : "
+			}
+			virtual := ""
+			if base.Virtual {
+				virtual = "virtual "
+			}
+			fmt.Fprintf(&b, "%s%s %s%s", sep, base.Access, virtual, base.Name)
+		}
+		fmt.Fprintf(&b, " (%s:%d-%d)\n", class.File, class.StartLine, class.EndLine)
+		if len(class.Locations) > 1 {
+			for _, loc := range class.Locations {
+				fmt.Fprintf(&b, "  %s %s:%d-%d\n", loc.Kind, loc.File, loc.StartLine, loc.EndLine)
+			}
+		}
+		for _, m := range class.Members {
+			var traits []string
+			if m.IsPointer {
+				traits = append(traits, "pointer")
+			}
+			if m.IsSmartPointer {
+				traits = append(traits, "smart pointer")
+			}
+			if m.PointerContainer {
+				traits = append(traits, "container of pointers")
+			}
+			if m.IsArray {
+				traits = append(traits, "array")
+			}
+			if m.Ownership != parser.OwnershipUnknown {
+				traits = append(traits, m.Ownership.String())
+			}
+			fmt.Fprintf(&b, "  member %s, line %d", m.Name, m.Line)
+			if m.Type != "" {
+				traits = append([]string{"type " + m.Type}, traits...)
+			}
+			if len(traits) > 0 {
+				fmt.Fprintf(&b, " [%s]", strings.Join(traits, ", "))
+			}
+			b.WriteString("\n")
+		}
+		for _, f := range class.Friends {
+			kind := "class"
+			if f.Function {
+				kind = "function"
+			}
+			fmt.Fprintf(&b, "  friend %s %s, line %d\n", kind, f.Name, f.Line)
+		}
+		writeFunction(&b, "constructor", class.Constructor)
+		writeFunction(&b, "destructor", class.Destructor)
+		writeFunction(&b, "move constructor", class.MoveConstructor)
+		writeFunction(&b, "move assignment", class.MoveAssignment)
+		for j := range class.Methods {
+			writeFunction(&b, "method", &class.Methods[j])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFunction prints a function and what it allocates, releases, calls
+// and aliases
+func writeFunction(b *strings.Builder, kind string, fn *parser.Function) {
+	if fn == nil {
+		return
+	}
+	if fn.Defaulted {
+		fmt.Fprintf(b, "  %s %s = default, line %d\n", kind, fn.Name, fn.StartLine)
+		return
+	}
+	fmt.Fprintf(b, "  %s %s, lines %d-%d\n", kind, fn.Name, fn.StartLine, fn.EndLine)
+	for _, a := range fn.Allocations {
+		op := "new"
+		if a.Allocator != "" {
+			op = a.Allocator
+		} else if a.IsArray {
+			op = "new[]"
+		}
+		target := a.VarName
+		switch {
+		case a.PassedTo != "":
+			target = fmt.Sprintf("argument %d of %s", a.ArgIndex, a.PassedTo)
+		case a.Discarded:
+			target = "discarded"
+		case a.Managed:
+			target += " (smart pointer)"
+		case a.Container != "":
+			target = "element of " + a.Container
+		}
+		fmt.Fprintf(b, "    line %d: %s %s -> %s\n", a.Line, op, a.Type, target)
+	}
+	for _, d := range fn.Deallocations {
+		op := "delete"
+		if d.IsArray {
+			op = "delete[]"
+		}
+		if d.Container != "" && d.Container != d.VarName {
+			fmt.Fprintf(b, "    line %d: %s %s (element of %s)\n", d.Line, op, d.VarName, d.Container)
+			continue
+		}
+		fmt.Fprintf(b, "    line %d: %s %s\n", d.Line, op, d.VarName)
+	}
+	for _, alias := range fn.Aliases {
+		fmt.Fprintf(b, "    line %d: alias %s = %s\n", alias.Line, alias.TargetVar, alias.SourceVar)
+	}
+	for _, call := range fn.Calls {
+		fmt.Fprintf(b, "    line %d: call %s(%s)\n", call.Line, call.Name, strings.Join(call.Args, ", "))
+	}
+}
+
+// runDumpTokens implements `leakcheck dump-tokens file.cpp`, printing the
+// lexer's token stream for triaging tokenization problems
+func runDumpTokens(args []string) int {
+	fs := flag.NewFlagSet("dump-tokens", flag.ContinueOnError)
+	jsonFlag := fs.Bool("json", false, "Print tokens and comments as JSON")
+	commentsFlag := fs.Bool("comments", false, "Include comments retained by the lexer")
+	preprocessedFlag := fs.Bool("preprocessed", false, "Print the tokens the parser sees, after directives are applied and macros expanded")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck dump-tokens [--json] [--comments] [--preprocessed] <file> [files...]\n\n")
+		fs.PrintDefaults()
+	}
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	type tokenDump struct {
+		File     string           `json:"file"`
+		Tokens   []parser.Token   `json:"tokens"`
+		Comments []parser.Comment `json:"comments,omitempty"`
+	}
+	var dumps []tokenDump
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		lexer := parser.NewReaderLexer(f)
+		dump := tokenDump{File: file, Tokens: lexer.Tokenize()}
+		f.Close()
+		if err := lexer.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file, err)
+			return 1
+		}
+		if *preprocessedFlag {
+			abs, _ := filepath.Abs(file)
+			dump.Tokens = parser.NewPreprocessor(abs).Process(dump.Tokens)
+		}
+		if *commentsFlag {
+			dump.Comments = lexer.Comments()
+		}
+		dumps = append(dumps, dump)
+	}
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false) // keep template arguments and -> readable
+		err = encoder.Encode(map[string][]tokenDump{"files": dumps})
+	} else {
+		var b strings.Builder
+		for i, dump := range dumps {
+			if len(dumps) > 1 {
+				if i > 0 {
+					b.WriteString("\n")
+				}
+				fmt.Fprintf(&b, "== %s\n", dump.File)
+			}
+			writeTokens(&b, dump.Tokens, dump.Comments)
+		}
+		_, err = io.WriteString(os.Stdout, b.String())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeTokens prints one token per line as line:column, type and quoted
+// value, with comments interleaved at their positions
+func writeTokens(b *strings.Builder, tokens []parser.Token, comments []parser.Comment) {
+	for _, tok := range tokens {
+		for len(comments) > 0 && (comments[0].Line < tok.Line || comments[0].Line == tok.Line && comments[0].Column < tok.Column) {
+			c := comments[0]
+			fmt.Fprintf(b, "%-9s %-8s %q\n", fmt.Sprintf("%d:%d", c.Line, c.Column), "COMMENT", c.Text)
+			comments = comments[1:]
+		}
+		fmt.Fprintf(b, "%-9s %-8s %q\n", fmt.Sprintf("%d:%d", tok.Line, tok.Column), tok.Type, tok.Value)
+	}
+}
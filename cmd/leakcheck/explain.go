@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"leakcheck/internal/analyzer"
+)
+
+// runExplain implements `leakcheck explain <rule>`
+func runExplain(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: leakcheck explain <rule>   (e.g., leakcheck explain LC003)")
+		return 1
+	}
+
+	rule, ok := findRule(args[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Error: unknown rule %q\n", args[0])
+		return 1
+	}
+	explainRule(os.Stdout, rule)
+	return 0
+}
+
+// findRule looks a rule up by ID or name, ignoring case
+func findRule(key string) (analyzer.RuleInfo, bool) {
+	for _, r := range analyzer.Rules {
+		if strings.EqualFold(r.ID, key) || strings.EqualFold(r.Name, key) {
+			return r, true
+		}
+	}
+	return analyzer.RuleInfo{}, false
+}
+
+func explainRule(w io.Writer, r analyzer.RuleInfo) {
+	fmt.Fprintf(w, "%s %s (%s)\n\n", r.ID, r.Name, r.Severity)
+	fmt.Fprintf(w, "%s.\n\n", r.Summary)
+	fmt.Fprintf(w, "%s\n", wrap(r.Description, 78, ""))
+
+	if r.Example != "" {
+		fmt.Fprintf(w, "\nExample:\n\n%s\n", indent(r.Example, "    "))
+	}
+	if r.Fixed != "" {
+		fmt.Fprintf(w, "\nFixed:\n\n%s\n", indent(r.Fixed, "    "))
+	}
+	if len(r.FalsePositives) > 0 {
+		fmt.Fprintln(w, "\nKnown false positives:")
+		for _, fp := range r.FalsePositives {
+			fmt.Fprintf(w, "  - %s\n", wrap(fp, 74, "    "))
+		}
+	}
+
+	fmt.Fprintln(w, "\nSuppressing:")
+	fmt.Fprintf(w, "  - Waive one finding in a suppressions file: {rule: %s, class: ..., variable: ..., reason: ...}\n", r.ID)
+	fmt.Fprintf(w, "  - Disable the rule in .leakcheck.yml: rules: {disable: [%s]}\n", r.ID)
+	fmt.Fprintln(w, "  - Mark a member that does not own its pointer: // leakcheck:non-owning")
+	fmt.Fprintln(w, "  - Accept all current findings with --write-baseline and --baseline")
+}
+
+// wrap breaks text into lines of at most width characters, prefixing
+// continuation lines with prefix
+func wrap(text string, width int, prefix string) string {
+	var sb strings.Builder
+	lineLen := 0
+	for _, word := range strings.Fields(text) {
+		if lineLen > 0 && lineLen+1+len(word) > width {
+			sb.WriteString("\n" + prefix)
+			lineLen = 0
+		} else if lineLen > 0 {
+			sb.WriteByte(' ')
+			lineLen++
+		}
+		sb.WriteString(word)
+		lineLen += len(word)
+	}
+	return sb.String()
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/gitdiff"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+	"leakcheck/internal/suppress"
+)
+
+// findingFilter drops findings outside the --diff changes, waived by the
+// suppressions file or recorded in the baseline, counting what it drops.
+// Findings can be filtered one at a time as they are streamed.
+type findingFilter struct {
+	changes      gitdiff.Changes
+	linesOnly    bool
+	suppressions *suppress.File
+	baseDir      string
+	now          time.Time
+	inBaseline   func(parser.Leak) bool
+	// displayPath maps the file a finding was analyzed in to the path to
+	// report, e.g. for files extracted from an archive
+	displayPath func(string) string
+
+	suppressed int // waived by the suppressions file
+	baselined  int // present in the baseline
+}
+
+// keep reports whether a finding should be reported
+func (f *findingFilter) keep(leak parser.Leak) bool {
+	if f.changes != nil && !f.changes.Contains(leak, f.linesOnly) {
+		slog.Debug("finding outside the diff", "rule", leak.RuleID, "file", leak.File, "line", leak.Line)
+		return false
+	}
+	if f.suppressions != nil && f.suppressions.Waives(leak, f.baseDir, f.now) {
+		slog.Debug("finding suppressed", "rule", leak.RuleID, "class", leak.ClassName, "variable", leak.VarName)
+		f.suppressed++
+		return false
+	}
+	if f.inBaseline != nil && f.inBaseline(leak) {
+		slog.Debug("finding in baseline", "rule", leak.RuleID, "class", leak.ClassName, "variable", leak.VarName)
+		f.baselined++
+		return false
+	}
+	return true
+}
+
+// relabel rewrites the file of a finding to the path to report
+func (f *findingFilter) relabel(leak parser.Leak) parser.Leak {
+	if f.displayPath != nil {
+		leak.File = f.displayPath(leak.File)
+		if len(leak.Related) > 0 {
+			leak.Related = slices.Clone(leak.Related)
+			for i := range leak.Related {
+				leak.Related[i].File = f.displayPath(leak.Related[i].File)
+			}
+		}
+	}
+	return leak
+}
+
+// apply relabels and filters a complete list of findings
+func (f *findingFilter) apply(leaks []parser.Leak) []parser.Leak {
+	var kept []parser.Leak
+	for _, leak := range leaks {
+		leak = f.relabel(leak)
+		if f.keep(leak) {
+			kept = append(kept, leak)
+		}
+	}
+	return kept
+}
+
+// runStream analyzes class by class, writing each kept finding to stdout as
+// a JSON line as soon as it is found, and returns the exit status. When the
+// run is interrupted the summary line is only written with partial.
+func runStream(ctx context.Context, a *analyzer.Analyzer, filter *findingFilter, policy failurePolicy, partial bool) int {
+	stream := reporter.NewStreamWriter(os.Stdout)
+	err := a.AnalyzeEach(ctx, func(leak parser.Leak) error {
+		leak = filter.relabel(leak)
+		if !filter.keep(leak) {
+			return nil
+		}
+		return stream.Write(leak)
+	})
+	if interrupted(err) {
+		if !partial {
+			fmt.Fprintf(os.Stderr, "Error: analysis interrupted: %v\n", err)
+			return 1
+		}
+		slog.Warn("analysis interrupted, the summary covers the findings gathered so far", "err", err)
+		if err := stream.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		}
+		return 1
+	}
+	if err == nil {
+		err = stream.Close()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		return 1
+	}
+	if policy.shouldFail(stream.Summary()) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/fix"
+	"leakcheck/internal/parser"
+)
+
+// runFix prints unified diffs for the fixable findings, or applies them
+// when dryRun is false
+func runFix(a *analyzer.Analyzer, classes []parser.Class, leaks []parser.Leak, baseDir string, dryRun bool) error {
+	patches, err := fix.Plan(a, classes, leaks)
+	if err != nil {
+		return err
+	}
+
+	fixed := 0
+	for _, patch := range patches {
+		fixed += patch.Fixed()
+		if dryRun {
+			if err := patch.WriteDiff(os.Stdout, relativePath(baseDir, patch.File)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := patch.Apply(); err != nil {
+			return err
+		}
+	}
+
+	verb := "Fixed"
+	if dryRun {
+		verb = "Would fix"
+	}
+	progressf("%s %d of %d finding(s) in %d file(s)", verb, fixed, len(leaks), len(patches))
+	return nil
+}
+
+// relativePath returns file relative to baseDir when it lies beneath it
+func relativePath(baseDir, file string) string {
+	if rel, err := filepath.Rel(baseDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(file)
+}
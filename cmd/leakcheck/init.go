@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"leakcheck/internal/config"
+)
+
+// maxInspectedFiles bounds how many sources `leakcheck init` reads when
+// looking for framework includes
+const maxInspectedFiles = 500
+
+// generatedDirs are directory names that usually hold build output or
+// third-party code
+var generatedDirs = map[string]bool{
+	"vendor": true, "third_party": true, "thirdparty": true, "external": true, "extern": true,
+	"build": true, "out": true, "bin": true, "obj": true, "_deps": true, "node_modules": true,
+}
+
+// sourceExtensions are the extensions init recognizes as C++ sources
+var sourceExtensions = map[string]bool{
+	".cpp": true, ".cc": true, ".cxx": true, ".c++": true, ".cu": true,
+	".h": true, ".hpp": true, ".hxx": true, ".hh": true, ".ipp": true, ".inl": true, ".tpp": true,
+}
+
+// projectSurvey is what init learned about the directory
+type projectSurvey struct {
+	Excludes   []string
+	Extensions []string
+	Profiles   []string
+	Boost      bool
+	Git        bool
+}
+
+// runInit implements `leakcheck init`
+func runInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	force := fs.Bool("force", false, "Overwrite an existing .leakcheck.yml")
+	preCommit := fs.Bool("pre-commit", false, "Also add a leakcheck hook to .pre-commit-config.yaml")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck init [--force] [--pre-commit]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	path := config.FileNames[0]
+	if _, err := os.Stat(path); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists (use --force to overwrite)\n", path)
+		return 1
+	}
+
+	survey, err := surveyProject(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(starterConfig(survey)), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Wrote %s\n", path)
+
+	if *preCommit {
+		if err := writePreCommitHook(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// surveyProject walks dir looking for generated directories, source
+// extensions and framework includes
+func surveyProject(dir string) (projectSurvey, error) {
+	var survey projectSurvey
+	extensions := make(map[string]bool)
+	inspected := 0
+
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	survey.Git = err == nil
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			if strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			if generatedDirs[strings.ToLower(name)] || strings.HasPrefix(name, "cmake-build-") {
+				survey.Excludes = append(survey.Excludes, name)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(name))
+		if !sourceExtensions[ext] {
+			return nil
+		}
+		extensions[ext] = true
+		if inspected < maxInspectedFiles {
+			inspected++
+			survey.noteIncludes(path)
+		}
+		return nil
+	})
+	if err != nil {
+		return survey, err
+	}
+
+	for ext := range extensions {
+		survey.Extensions = append(survey.Extensions, ext)
+	}
+	sort.Strings(survey.Extensions)
+	survey.Excludes = uniqueSorted(survey.Excludes)
+	survey.Profiles = uniqueSorted(survey.Profiles)
+	return survey, nil
+}
+
+// noteIncludes records frameworks included by a source file
+func (s *projectSurvey) noteIncludes(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		include, ok := strings.CutPrefix(line, "#include")
+		if !ok {
+			continue
+		}
+		include = strings.Trim(strings.TrimSpace(include), "<>\"")
+		switch {
+		case isQtHeader(include):
+			s.Profiles = append(s.Profiles, "qt")
+		case strings.HasPrefix(include, "wx/"):
+			s.Profiles = append(s.Profiles, "wxwidgets")
+		case strings.HasPrefix(include, "boost/"):
+			s.Boost = true
+		}
+	}
+}
+
+// isQtHeader matches Qt class headers such as QWidget and module paths
+// such as QtCore/QObject
+func isQtHeader(include string) bool {
+	return strings.HasPrefix(include, "Qt") ||
+		len(include) > 1 && include[0] == 'Q' && include[1] >= 'A' && include[1] <= 'Z'
+}
+
+func uniqueSorted(items []string) []string {
+	sort.Strings(items)
+	var result []string
+	for i, item := range items {
+		if i == 0 || item != items[i-1] {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// starterConfig renders a commented .leakcheck.yml for the survey
+func starterConfig(s projectSurvey) string {
+	var sb strings.Builder
+	sb.WriteString("# leakcheck configuration. Command line flags override these values.\n")
+	sb.WriteString("# Run `leakcheck rules` to list rules and `leakcheck explain <rule>` for details.\n\n")
+
+	sb.WriteString("# Directories or glob patterns to skip\n")
+	if len(s.Excludes) > 0 {
+		fmt.Fprintf(&sb, "exclude: [%s]\n", strings.Join(s.Excludes, ", "))
+	} else {
+		sb.WriteString("# exclude: [vendor, build]\n")
+	}
+	if s.Git {
+		sb.WriteString("respect_gitignore: true\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("# File extensions to analyze\n")
+	if len(s.Extensions) > 0 {
+		fmt.Fprintf(&sb, "extensions: [%s]\n\n", strings.Join(s.Extensions, ", "))
+	} else {
+		sb.WriteString("# extensions: [.cpp, .h, .hpp]\n\n")
+	}
+
+	sb.WriteString("# Framework ownership profiles (qt, wxwidgets)\n")
+	if len(s.Profiles) > 0 {
+		fmt.Fprintf(&sb, "profiles: [%s]\n", strings.Join(s.Profiles, ", "))
+	} else {
+		sb.WriteString("# profiles: [qt]\n")
+	}
+	if s.Boost {
+		sb.WriteString("# Boost smart pointers (scoped_ptr, shared_ptr, intrusive_ptr, ...) are recognized automatically\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(`# Turn rules off, or run only some of them
+# rules:
+#   disable: [LC007]
+
+# Override default severities (error, warning or info)
+# severity:
+#   LC003: error
+
+# Custom allocation functions tracked like new/delete
+# allocators:
+#   - alloc: pool_alloc
+#     free: pool_free
+
+# summaries: leakcheck-summaries.json
+# suppressions: leakcheck-suppressions.yml
+# baseline: .leakcheck-baseline.json
+
+# Reports to produce on every run
+# format: [console, sarif:build/leakcheck.sarif]
+`)
+	return sb.String()
+}
+
+const preCommitFile = ".pre-commit-config.yaml"
+
+const preCommitHook = `  - repo: local
+    hooks:
+      - id: leakcheck
+        name: leakcheck
+        entry: leakcheck --hook
+        language: system
+        files: \.(cpp|cc|cxx|h|hpp|hxx)$
+        pass_filenames: true
+        require_serial: true
+`
+
+// writePreCommitHook creates .pre-commit-config.yaml with a leakcheck hook,
+// or prints the stanza when the file already exists
+func writePreCommitHook() error {
+	if _, err := os.Stat(preCommitFile); err == nil {
+		fmt.Printf("%s exists; add this entry under repos:\n\n%s", preCommitFile, preCommitHook)
+		return nil
+	}
+	if err := os.WriteFile(preCommitFile, []byte("repos:\n"+preCommitHook), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", preCommitFile)
+	return nil
+}
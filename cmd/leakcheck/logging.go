@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// quietOutput is set by --quiet and silences progressf
+var quietOutput bool
+
+// setupLogging sends structured logs to stderr, keeping stdout for reports.
+// Warnings are shown by default; --quiet leaves only errors, --verbose adds
+// per-file progress, class merge decisions, rule timings and skipped
+// constructs, and --debug adds rule decisions.
+func setupLogging(quiet, verbose, debug bool) error {
+	if quiet && (verbose || debug) {
+		return errors.New("--quiet cannot be combined with --verbose or --debug")
+	}
+	quietOutput = quiet
+	level := slog.LevelWarn
+	switch {
+	case quiet:
+		level = slog.LevelError
+	case debug:
+		level = slog.LevelDebug
+	case verbose:
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) == 0 && attr.Key == slog.TimeKey {
+				return slog.Attr{} // timestamps add noise to CI logs
+			}
+			return attr
+		},
+	})))
+	return nil
+}
+
+// progressf prints a progress line such as "Scanning 12 file(s)..." to
+// stderr, so it never ends up in piped reports. --quiet suppresses it.
+func progressf(format string, args ...any) {
+	if quietOutput {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
@@ -1,15 +1,30 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"leakcheck/internal/analyzer"
+	"leakcheck/internal/baseline"
+	"leakcheck/internal/cmake"
+	"leakcheck/internal/gitdiff"
+	"leakcheck/internal/notify"
 	"leakcheck/internal/parser"
 	"leakcheck/internal/reporter"
+	"leakcheck/internal/resultsdb"
 	"leakcheck/internal/scanner"
+	"leakcheck/internal/suppress"
+	"leakcheck/internal/upload"
 )
 
 var (
@@ -17,14 +32,91 @@ var (
 )
 
 func main() {
+	setupLogging(false, false, false)
+	if i := slices.Index(os.Args, "--persistent_worker"); i > 0 {
+		os.Exit(runWorker(slices.Delete(slices.Clone(os.Args[1:]), i-1, i)))
+	}
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+	runScan()
+	exit(0)
+}
+
+// runScan implements the default command, scanning the paths given on the
+// command line. It exits through exit unless the run succeeds.
+func runScan() {
 	// Define flags
-	excludeFlag := flag.String("exclude", "", "Comma-separated list of directories to exclude (e.g., vendor,build,third_party)")
-	jsonFlag := flag.Bool("json", false, "Output results in JSON format")
+	configFlag := flag.String("config", "", "Project configuration file (default: .leakcheck.yml found from the scan root)")
+	excludeFlag := flag.String("exclude", "", "Comma-separated names or glob patterns to exclude (e.g., vendor,build,'**/generated/**')")
+	includeFlag := flag.String("include", "", "Comma-separated glob patterns of files to scan (e.g., 'src/**/*.cpp')")
+	maxFileSizeFlag := flag.String("max-file-size", "5MB", "Skip files larger than this, e.g. 512KB or 20MB (0 means no limit)")
+	gitignoreFlag := flag.Bool("respect-gitignore", false, "Skip files and directories ignored by .gitignore")
+	cmakeFlag := flag.String("cmake", "", "Scan the sources a CMake build directory compiles, with their include directories and definitions (needs CMAKE_EXPORT_COMPILE_COMMANDS=ON)")
+	stdinFlag := flag.Bool("stdin", false, "Analyze one file read from standard input instead of scanning paths, e.g. an unsaved editor buffer")
+	stdinFilenameFlag := flag.String("stdin-filename", "stdin.cpp", "With --stdin, the file name to report findings under and to look up configuration from")
+	diffFlag := flag.String("diff", "", "Report only findings in files changed relative to this git ref (e.g., origin/main)")
+	diffLinesFlag := flag.Bool("diff-lines", false, "With --diff, report only findings on changed lines")
+	uploadFlag := flag.String("upload", "", "Store the JSON, SARIF and HTML reports with run metadata under s3://bucket/prefix, gs://bucket/prefix or azblob://container/prefix")
+	webhookFlag := flag.String("webhook", "", "POST the JSON report to this URL when the analysis completes (headers come from notify.webhook in the config)")
+	ciFlag := flag.String("ci", "", "CI integration: github (annotations, job summary, step outputs and a SARIF log in one run)")
+	hookFlag := flag.Bool("hook", false, "Pre-commit mode: scan the files staged in git (or the paths given) and print only compact file:line:col findings")
+	jsonFlag := flag.Bool("json", false, "Output results in JSON format (same as --format=json)")
+	var formatFlags formatList
+	flag.Var(&formatFlags, "format", "Output format, optionally with a destination as format:path; repeatable ("+strings.Join(reporter.Formats, ", ")+")")
+	var outputFlag string
+	flag.StringVar(&outputFlag, "output", "", "Write reports without an explicit path to this file instead of stdout")
+	flag.StringVar(&outputFlag, "o", "", "Shorthand for --output")
+	colorFlag := flag.String("color", "auto", "Colorize console output: "+strings.Join(reporter.ColorModes, ", "))
+	contextFlag := flag.Int("context", -1, "Show source snippets with N lines of context in console output (-1 disables)")
+	groupByFlag := flag.String("group-by", "file", "Group console and markdown findings by: "+strings.Join(reporter.GroupModes, ", "))
+	sortFlag := flag.String("sort", "line", "Order findings within a group by: "+strings.Join(reporter.SortModes, ", "))
+	summariesFlag := flag.String("summaries", "", "JSON file describing ownership behaviour of external functions")
+	suppressionsFlag := flag.String("suppressions", "", "YAML file of waived findings by file glob, class, variable and rule")
+	baselineFlag := flag.String("baseline", "", "Suppress findings recorded in this baseline file and report only new ones")
+	writeBaselineFlag := flag.String("write-baseline", "", "Write current findings to this baseline file and exit")
+	outputDBFlag := flag.String("output-db", "", "Append findings, fingerprints, run metadata and timings to this SQLite database, e.g. leaks.db")
+	var policy failurePolicy
+	flag.StringVar(&policy.FailOn, "fail-on", "warning", "Lowest severity that fails the run: error, warning or never")
+	flag.IntVar(&policy.MaxErrors, "max-errors", 0, "Number of errors tolerated before the run fails")
+	flag.IntVar(&policy.MaxWarnings, "max-warnings", 0, "Number of warnings tolerated before the run fails (with --fail-on=warning)")
+	fixFlag := flag.Bool("fix", false, "Insert missing deletes and destructors and correct delete/delete[] mismatches in place")
+	fixDryRunFlag := flag.Bool("fix-dry-run", false, "Print the --fix changes as unified diffs without modifying files")
+	wholeProgramFlag := flag.Bool("whole-program", false, "Resolve calls into other classes and free functions across all files, so members released or adopted elsewhere are not reported and cross-file double frees are")
+	boundedFlag := flag.Bool("bounded-memory", false, "Analyze each class as soon as all files defining it are parsed instead of holding every class, for very large repositories")
+	streamFlag := flag.Bool("stream", false, "Write findings as JSON lines while analysis runs, followed by a summary line")
+	timeoutFlag := flag.Duration("timeout", 0, "Stop the run after this long, e.g. 10m (0 means no limit)")
+	fileTimeoutFlag := flag.Duration("timeout-per-file", 0, "Give up on a file that takes longer than this to parse, e.g. 30s, and continue with the rest (0 means no limit)")
+	partialFlag := flag.Bool("partial", false, "When interrupted or timed out during analysis, report the findings gathered so far")
+	summaryFlag := flag.String("summary", "", "Print a table per file or class (classes, owning members, errors, warnings) instead of each finding: "+strings.Join(reporter.SummaryModes, ", "))
+	statsFlag := flag.Bool("stats", false, "Report analysis statistics (files, lines, classes, rules, time per phase)")
+	quietFlag := flag.Bool("quiet", false, "Print nothing but findings and errors")
+	flag.BoolVar(quietFlag, "q", false, "Shorthand for --quiet")
+	verboseFlag := flag.Bool("verbose", false, "Log per-file progress, class merge decisions, rule timings and skipped constructs to stderr")
+	flag.BoolVar(verboseFlag, "v", false, "Shorthand for --verbose")
+	debugFlag := flag.Bool("debug", false, "Also log rule decisions to stderr")
+	cpuProfileFlag := flag.String("cpuprofile", "", "Write a CPU profile of the run to this file (for go tool pprof)")
+	memProfileFlag := flag.String("memprofile", "", "Write a heap profile taken at the end of the run to this file")
+	traceFlag := flag.String("trace", "", "Write an execution trace of the run to this file (for go tool trace)")
 	versionFlag := flag.Bool("version", false, "Print version and exit")
 	helpFlag := flag.Bool("help", false, "Show help message")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: leakcheck [options] <path> [paths...]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck [options] <path> [paths...]\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck [options] --stdin [--stdin-filename=file.cpp] < file.cpp\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck scan-repo [options] <url>[#ref]\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck explain <rule>\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck rules [--json]\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck init [--pre-commit]\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck install-hook [--force]\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck merge -o combined.json a.json b.json\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck dashboard [--db=leaks.db] [--listen=:8090]\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck badge [-o badge.svg] (--db=leaks.db | report.json)\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck dump-model [--json] <file>\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck dump-tokens [--json] <file>\n")
+		fmt.Fprintf(os.Stderr, "       leakcheck bench [--corpus=dir] [--json]\n\n")
 		fmt.Fprintf(os.Stderr, "C++ Memory Leak Detector - Static analysis tool to detect potential memory leaks\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
@@ -32,86 +124,561 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  leakcheck ./src                    Scan all C++ files in ./src\n")
 		fmt.Fprintf(os.Stderr, "  leakcheck --exclude=vendor ./      Scan all files, excluding vendor directory\n")
 		fmt.Fprintf(os.Stderr, "  leakcheck --json ./src > out.json  Output results as JSON\n")
+		fmt.Fprintf(os.Stderr, "  leakcheck --format=sarif ./src     Output a SARIF 2.1.0 log for code scanning\n")
+		fmt.Fprintf(os.Stderr, "  leakcheck --format=console --format=sarif:build/leaks.sarif ./src\n")
+		fmt.Fprintf(os.Stderr, "                                     Print to the console and write SARIF in one run\n")
 	}
 
-	flag.Parse()
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		// Only reached in a persistent worker; the command line exits itself
+		if errors.Is(err, flag.ErrHelp) {
+			exit(0)
+		}
+		exit(2)
+	}
+
+	// File names given on the command line are relative to where leakcheck
+	// was started, also when scan-repo runs inside a clone
+	for _, path := range []*string{configFlag, &outputFlag, summariesFlag, suppressionsFlag,
+		baselineFlag, writeBaselineFlag, cpuProfileFlag, memProfileFlag, traceFlag, stdinFilenameFlag} {
+		*path = userPath(*path)
+	}
+	for i, value := range formatFlags {
+		if name, path, ok := strings.Cut(value, ":"); ok && path != "" {
+			formatFlags[i] = name + ":" + userPath(path)
+		}
+	}
 
 	if *helpFlag {
 		flag.Usage()
-		os.Exit(0)
+		exit(0)
 	}
 
 	if *versionFlag {
 		fmt.Printf("leakcheck version %s\n", version)
-		os.Exit(0)
+		exit(0)
+	}
+
+	if *hookFlag && !*verboseFlag && !*debugFlag {
+		*quietFlag = true
+	}
+	if err := setupLogging(*quietFlag, *verboseFlag, *debugFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+
+	runSpan := startTelemetry(os.Args[1:])
+
+	if err := startProfiles(*cpuProfileFlag, *memProfileFlag, *traceFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting profile: %v\n", err)
+		exit(1)
+	}
+
+	if err := policy.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
 	}
 
 	// Get paths to scan
 	paths := flag.Args()
+	if *stdinFlag {
+		if len(paths) > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --stdin cannot be combined with paths")
+			exit(1)
+		}
+		// Configuration applies as if the file were saved under its name
+		paths = []string{filepath.Dir(*stdinFilenameFlag)}
+	}
+	var project *cmake.Project
+	if *cmakeFlag != "" {
+		if *stdinFlag {
+			fmt.Fprintln(os.Stderr, "Error: --cmake cannot be combined with --stdin")
+			exit(1)
+		}
+		var err error
+		if project, err = cmake.Load(userPath(*cmakeFlag)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		fileBuildFlags = project.Flags
+		if len(paths) == 0 {
+			paths = []string{cmp.Or(project.SourceDir, ".")}
+		}
+	}
+	if *hookFlag && len(paths) == 0 {
+		staged, err := gitdiff.Staged(".")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+		paths = existingFiles(staged)
+		if len(paths) == 0 {
+			exit(0)
+		}
+	}
 	if len(paths) == 0 {
 		fmt.Fprintln(os.Stderr, "Error: No paths specified")
 		fmt.Fprintln(os.Stderr, "Run 'leakcheck --help' for usage")
-		os.Exit(1)
+		exit(1)
 	}
 
-	// Parse exclude patterns
-	var excludes []string
-	if *excludeFlag != "" {
-		excludes = strings.Split(*excludeFlag, ",")
-		for i := range excludes {
-			excludes[i] = strings.TrimSpace(excludes[i])
+	// Configuration file values apply unless overridden on the command line
+	cfg, err := loadConfig(*configFlag, paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		exit(1)
+	}
+	if cfg != nil {
+		set := explicitFlags()
+		if !set["exclude"] && len(cfg.Exclude) > 0 {
+			*excludeFlag = strings.Join(cfg.Exclude, ",")
+		}
+		if !set["include"] && len(cfg.Include) > 0 {
+			*includeFlag = strings.Join(cfg.Include, ",")
+		}
+		if !set["format"] && !set["json"] {
+			formatFlags = append(formatFlags, cfg.Format...)
+		}
+		if !set["respect-gitignore"] {
+			*gitignoreFlag = cfg.RespectGitignore
+		}
+		if !set["summaries"] {
+			*summariesFlag = cfg.Summaries
+		}
+		if !set["suppressions"] {
+			*suppressionsFlag = cfg.Suppressions
 		}
+		if !set["baseline"] {
+			*baselineFlag = cfg.Baseline
+		}
+		if !set["webhook"] {
+			*webhookFlag = cfg.Notify.Webhook.URL
+		}
+	}
+
+	if *diffLinesFlag && *diffFlag == "" {
+		fmt.Fprintln(os.Stderr, "Error: --diff-lines requires --diff")
+		exit(1)
+	}
+	if *streamFlag && (*fixFlag || *fixDryRunFlag || *writeBaselineFlag != "" || *outputDBFlag != "" || *webhookFlag != "" || *uploadFlag != "") {
+		fmt.Fprintln(os.Stderr, "Error: --stream cannot be combined with --fix, --fix-dry-run, --write-baseline, --output-db, --webhook or --upload")
+		exit(1)
+	}
+	var uploadDest upload.Destination
+	if *uploadFlag != "" {
+		if uploadDest, err = upload.Parse(*uploadFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
+		}
+	}
+
+	if *boundedFlag && (*fixFlag || *fixDryRunFlag) {
+		fmt.Fprintln(os.Stderr, "Error: --bounded-memory cannot be combined with --fix or --fix-dry-run")
+		exit(1)
+	}
+	if *boundedFlag && *wholeProgramFlag {
+		fmt.Fprintln(os.Stderr, "Error: --bounded-memory cannot be combined with --whole-program")
+		exit(1)
 	}
 
+	if *hookFlag && !explicitFlags()["format"] && !*jsonFlag {
+		formatFlags = []string{"gcc"}
+	}
+	if *jsonFlag {
+		formatFlags = append(formatFlags, "json")
+	}
+	if err := validateCI(*ciFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	formatFlags = ciFormats(*ciFlag, formatFlags)
+	if len(formatFlags) == 0 {
+		formatFlags = append(formatFlags, "console")
+	}
+	outputs, err := parseOutputs(formatFlags, outputFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	if _, err := reporter.UseColor(*colorFlag, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	if err := reporter.ValidateLayout(*groupByFlag, *sortFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	if err := reporter.ValidateSummary(*summaryFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	cwd, _ := os.Getwd()
+	reportOpts := reporter.Options{
+		ToolVersion: version,
+		BaseDir:     cwd,
+		Context:     *contextFlag,
+		GroupBy:     *groupByFlag,
+		SortBy:      *sortFlag,
+	}
+	// Progress lines are only mixed into human-readable output
+	console := writesConsoleToStdout(outputs) && !*fixFlag && !*fixDryRunFlag && !*streamFlag
+
+	// Parse include and exclude patterns
+	excludes := splitList(*excludeFlag)
+	includes := splitList(*includeFlag)
+
+	var stats reporter.Stats
+	// Ctrl-C, SIGTERM and --timeout cancel the run
+	ctx, cancel := runContext(*timeoutFlag)
+	defer cancel()
+
+	phaseStart := time.Now()
+	started := phaseStart
+
 	// Scan for C++ files
 	s := scanner.NewScanner(excludes)
-	files, err := s.ScanPaths(paths)
+	s.Includes = includes
+	s.RespectGitignore = *gitignoreFlag
+	if s.MaxFileSize, err = parseSize(*maxFileSizeFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --max-file-size: %v\n", err)
+		exit(1)
+	}
+	if cfg != nil && len(cfg.Extensions) > 0 {
+		s.Extensions = cfg.Extensions
+	}
+	atExit(func() { s.Close() }) // extracted archives and standard input
+	scanSpan := tracer.Start(runSpan, "scan")
+	var files []string
+	if *stdinFlag {
+		files, err = s.ScanReader(os.Stdin, *stdinFilenameFlag)
+	} else if project != nil {
+		files, err = s.ScanPaths(ctx, cmakeFiles(project, paths))
+	} else {
+		files, err = s.ScanPaths(ctx, paths)
+	}
+	scanSpan.SetAttributes("leakcheck.files", len(files))
+	scanSpan.SetError(err)
+	scanSpan.End()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error scanning paths: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+	if s.Virtual() && (*fixFlag || *fixDryRunFlag) {
+		fmt.Fprintln(os.Stderr, "Error: --fix and --fix-dry-run cannot change files inside archives or read from standard input")
+		exit(1)
 	}
 
 	if len(files) == 0 {
-		fmt.Fprintln(os.Stderr, "No C++ files found")
-		os.Exit(0)
+		if !*hookFlag {
+			fmt.Fprintln(os.Stderr, "No C++ files found")
+		}
+		exit(0)
+	}
+
+	// With --diff only the changed files and those sharing their classes
+	// are parsed; findings elsewhere would be dropped anyway
+	var changes gitdiff.Changes
+	if *diffFlag != "" {
+		changes, err = gitdiff.Load(scanRoot(paths[0]), *diffFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading changes: %v\n", err)
+			exit(1)
+		}
+		if files, err = diffFiles(ctx, files, changes); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: interrupted while scanning: %v\n", err)
+			exit(1)
+		}
 	}
 
-	if !*jsonFlag {
-		fmt.Printf("Scanning %d file(s)...\n", len(files))
+	stats.FilesScanned = len(files)
+	stats.AddPhase("scan", time.Since(phaseStart))
+
+	if console {
+		progressf("Scanning %d file(s)...", len(files))
 	}
 
-	// Parse all files and register classes
+	// Parse all files and register classes. With --bounded-memory parsing
+	// happens during analysis instead, one class at a time.
+	var allClasses []parser.Class
+	var functions []parser.Function // free functions, for --whole-program and function rules
+	var globals []parser.Global     // file-scope pointers, for the global rules
 	registry := parser.NewClassRegistry()
-	for _, file := range files {
-		classes, err := parser.ParseFile(file)
+	if cfg != nil {
+		registry.Strategy = cfg.MergeStrategy
+	}
+	if !*boundedFlag {
+		phaseStart = time.Now()
+		parseSpan := tracer.Start(runSpan, "parse")
+		cache := make(contentCache)
+		for _, file := range files {
+			key, hashErr := fileHash(file)
+			if cached, ok := cache[key]; ok && hashErr == nil {
+				slog.Info("identical to a parsed file, not parsed again", "file", file, "original", cached.path)
+				stats.FilesDeduplicated++
+				traceParse(parseSpan, file, time.Now(), nil, true, nil)
+				registry.AddClasses(cached.relocated(file))
+				continue
+			}
+
+			fileStart := time.Now()
+			unit, reused, err := parseFileCached(file, key, hashErr, func() (*parser.Unit, error) {
+				return parseFile(ctx, file, *fileTimeoutFlag)
+			})
+			traceParse(parseSpan, file, fileStart, unit, reused, err)
+			if interrupted(err) {
+				fmt.Fprintf(os.Stderr, "Error: interrupted while parsing: %v\n", err)
+				exit(1)
+			}
+			if errors.Is(err, errFileTimeout) {
+				stats.FilesTimedOut++
+			}
+			if err != nil {
+				slog.Warn("cannot parse file", "file", file, "err", err)
+				continue
+			}
+			if !reused {
+				slog.Info("parsed", "file", file, "classes", len(unit.Classes), "lines", unit.Lines, "duration", time.Since(fileStart))
+			}
+			stats.LinesTokenized += unit.Lines
+			registry.AddClasses(unit.Classes)
+			functions = append(functions, unit.Functions...)
+			globals = append(globals, unit.Globals...)
+			if hashErr == nil {
+				cache.store(key, file, unit.Classes)
+			}
+		}
+
+		parseSpan.End()
+
+		// Merge classes from headers and implementations
+		mergeSpan := tracer.Start(runSpan, "merge")
+		allClasses = registry.MergeClasses()
+		mergeSpan.SetAttributes("leakcheck.classes", len(allClasses))
+		mergeSpan.End()
+		stats.ClassesFound = len(allClasses)
+		stats.ClassesWithPointers = countClassesWithPointers(allClasses)
+		stats.AddPhase("parse", time.Since(phaseStart))
+
+		if console {
+			progressf("Found %d class(es) with pointer members", stats.ClassesWithPointers)
+		}
+	}
+
+	// Analyze for leaks
+	analyzeSpan := tracer.Start(runSpan, "analyze")
+	a := analyzer.NewAnalyzer()
+	a.ClassDone = traceClasses(analyzeSpan)
+	if *summariesFlag != "" {
+		summaries, err := analyzer.LoadFunctionSummaries(*summariesFlag)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: Error parsing %s: %v\n", file, err)
-			continue
+			fmt.Fprintf(os.Stderr, "Error loading function summaries: %v\n", err)
+			exit(1)
+		}
+		a.Summaries = summaries
+	}
+	if cfg != nil {
+		if err := configureAnalyzer(a, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exit(1)
 		}
-		registry.AddClasses(classes)
+	}
+	a.AddClasses(allClasses)
+	a.AddFunctions(functions)
+	a.AddGlobals(globals)
+	a.WholeProgram = *wholeProgramFlag
+	if *boundedFlag {
+		a.AddSource(boundedSource(ctx, a, files, registry, *fileTimeoutFlag, &stats, analyzeSpan))
 	}
 
-	// Merge classes from headers and implementations
-	allClasses := registry.MergeClasses()
+	// Findings outside the diff, suppressed or already in the baseline are dropped
+	filter := &findingFilter{baseDir: cwd, now: time.Now(), linesOnly: *diffLinesFlag, displayPath: s.DisplayPath, changes: changes}
+	if *suppressionsFlag != "" {
+		filter.suppressions, err = suppress.Load(*suppressionsFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading suppressions: %v\n", err)
+			exit(1)
+		}
+		for _, e := range filter.suppressions.Expired(filter.now) {
+			slog.Warn("suppression expired and no longer applies", "expires", e.Expires, "reason", e.Reason)
+		}
+	}
+	// A baseline being written records everything, including findings an
+	// older baseline would hide
+	baselineSize := 0
+	if *baselineFlag != "" && *writeBaselineFlag == "" {
+		b, err := baseline.Load(*baselineFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading baseline: %v\n", err)
+			exit(1)
+		}
+		filter.inBaseline = b.Matcher()
+		baselineSize = len(b.Findings)
+	}
 
-	if !*jsonFlag {
-		fmt.Printf("Found %d class(es) with pointer members\n", countClassesWithPointers(allClasses))
+	if *streamFlag {
+		code := runStream(ctx, a, filter, policy, *partialFlag)
+		analyzeSpan.End()
+		exit(code)
 	}
 
-	// Analyze for leaks
-	leaks := analyzer.AnalyzeClasses(allClasses)
+	phaseStart = time.Now()
+	leaks, err := a.Analyze(ctx)
+	incomplete := err != nil
+	if incomplete {
+		// A partial baseline or fix would be mistaken for a complete one
+		if !*partialFlag || *writeBaselineFlag != "" || *fixFlag || *fixDryRunFlag {
+			fmt.Fprintf(os.Stderr, "Error: analysis interrupted: %v\n", err)
+			exit(1)
+		}
+		slog.Warn("analysis interrupted, reporting the findings gathered so far", "err", err)
+	}
+	leaks = filter.apply(leaks)
+	for _, r := range analyzer.Rules {
+		if analyzer.RuleEnabled(r.ID, a.DisabledRules) {
+			stats.RulesExecuted++
+		}
+	}
+	stats.AddPhase("analyze", time.Since(phaseStart))
+	analyzeSpan.SetAttributes("leakcheck.findings", len(leaks), "leakcheck.incomplete", incomplete)
+	analyzeSpan.SetError(err)
+	analyzeSpan.End()
+	traceFindings(leaks)
+
+	if console && filter.suppressions != nil {
+		progressf("Suppressed %d finding(s) listed in %s", filter.suppressed, *suppressionsFlag)
+	}
+
+	if *writeBaselineFlag != "" {
+		if err := baseline.New(leaks, cwd).Write(*writeBaselineFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing baseline: %v\n", err)
+			exit(1)
+		}
+		progressf("Wrote %d finding(s) to baseline %s", len(leaks), *writeBaselineFlag)
+		exit(0)
+	}
+
+	if console && filter.inBaseline != nil {
+		progressf("Suppressed %d finding(s) present in baseline", filter.baselined)
+	}
+
+	if *fixFlag || *fixDryRunFlag {
+		if err := runFix(a, allClasses, leaks, cwd, *fixDryRunFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error fixing findings: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+
+	if *outputDBFlag != "" {
+		run := resultsdb.Run{
+			Started:     started,
+			Finished:    time.Now(),
+			ToolVersion: version,
+			BaseDir:     cwd,
+			Args:        os.Args[1:],
+			Complete:    !incomplete,
+			Stats:       stats,
+		}
+		// The run context may be cancelled already when reporting partially
+		if err := resultsdb.Append(context.Background(), *outputDBFlag, run, leaks); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing results database: %v\n", err)
+			exit(1)
+		}
+	}
+
+	if *statsFlag {
+		reportOpts.Stats = &stats
+	}
+	reportOpts.Conflicts = registry.Conflicts()
+	if *summaryFlag != "" {
+		reportOpts.Summary = *summaryFlag
+		reportOpts.Classes = allClasses
+	}
 
 	// Report results
-	r := reporter.NewReporter(os.Stdout, *jsonFlag)
-	if err := r.Report(leaks); err != nil {
+	reportSpan := tracer.Start(runSpan, "report")
+	if err := writeReports(outputs, leaks, reportOpts, *colorFlag); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+	if *uploadFlag != "" {
+		uploaded, err := uploadReports(uploadDest, leaks, reportOpts, started, !incomplete)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error uploading reports: %v\n", err)
+			exit(1)
+		}
+		progressf("Uploaded reports to %s", uploaded)
+	}
+	if *webhookFlag != "" {
+		webhook := notify.Webhook{URL: *webhookFlag}
+		if cfg != nil {
+			webhook.Headers = cfg.Notify.Webhook.Headers
+		}
+		if err := postReport(webhook, leaks, reportOpts.Stats); err != nil {
+			fmt.Fprintf(os.Stderr, "Error posting report: %v\n", err)
+			exit(1)
+		}
+	}
+	if cfg != nil && (cfg.Notify.Slack != "" || cfg.Notify.Teams != "" || cfg.Notify.Email.Host != "") {
+		fixed := -1
+		if filter.inBaseline != nil {
+			fixed = baselineSize - filter.baselined
+		}
+		digest := notify.NewDigest(leaks, fixed, filter.baselined, cwd, os.ExpandEnv(cfg.Notify.ReportURL))
+		if err := notifyChat(cfg.Notify, digest); err != nil {
+			fmt.Fprintf(os.Stderr, "Error sending notification: %v\n", err)
+			exit(1)
+		}
+		if err := emailReport(cfg.Notify.Email, digest, leaks, reportOpts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error sending email: %v\n", err)
+			exit(1)
+		}
+	}
+	if *ciFlag == "github" {
+		if err := writeGitHubResults(outputs, leaks, reportOpts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing GitHub results: %v\n", err)
+			exit(1)
+		}
 	}
 
-	// Exit with error code if leaks found
-	if len(leaks) > 0 {
-		os.Exit(1)
+	reportSpan.End()
+
+	// Exit with error code if leaks exceed the failure policy or the report
+	// is incomplete
+	if incomplete || policy.shouldFail(reporter.Summarize(leaks)) {
+		exit(1)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// parseSize parses a byte count with an optional KB, MB or GB suffix
+func parseSize(value string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		bytes  int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if number, ok := strings.CutSuffix(upper, unit.suffix); ok {
+			upper, multiplier = strings.TrimSpace(number), unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q is not a size such as 512KB or 5MB", value)
 	}
+	return n * multiplier, nil
 }
 
 func countClassesWithPointers(classes []parser.Class) int {
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+)
+
+// runMerge implements `leakcheck merge a.json b.json -o combined.json`
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	var output string
+	fs.StringVar(&output, "output", "", "Write the merged report to this file instead of stdout")
+	fs.StringVar(&output, "o", "", "Shorthand for --output")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck merge [-o combined.json] report.json [reports...]\n\n")
+		fs.PrintDefaults()
+	}
+	inputs, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(inputs) == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	var reports [][]parser.Leak
+	for _, path := range inputs {
+		leaks, err := reporter.ReadJSONReport(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading report: %v\n", err)
+			return 1
+		}
+		reports = append(reports, leaks)
+	}
+	merged := mergeFindings(reports)
+
+	out := os.Stdout
+	if output != "" {
+		if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := reporter.NewReporter(out, &reporter.JSONFormatter{}).Report(merged); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+		return 1
+	}
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Merged %d report(s) into %d finding(s) in %s\n", len(inputs), len(merged), output)
+	}
+	return 0
+}
+
+// mergeFindings combines reports, deduplicating by fingerprint. A finding
+// present in several shards is kept as often as the report that contains
+// it most often, so genuinely repeated findings survive.
+func mergeFindings(reports [][]parser.Leak) []parser.Leak {
+	kept := make(map[string]int)
+	var merged []parser.Leak
+	for _, leaks := range reports {
+		seen := make(map[string]int)
+		for _, leak := range leaks {
+			fp := analyzer.Fingerprint(leak)
+			seen[fp]++
+			if seen[fp] > kept[fp] {
+				kept[fp] = seen[fp]
+				merged = append(merged, leak)
+			}
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].File != merged[j].File {
+			return merged[i].File < merged[j].File
+		}
+		return merged[i].Line < merged[j].Line
+	})
+	return merged
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// positional arguments and returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
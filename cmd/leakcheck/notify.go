@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"leakcheck/internal/config"
+	"leakcheck/internal/notify"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+)
+
+// postReport sends the JSON report, as --format=json writes it, to a
+// webhook
+func postReport(webhook notify.Webhook, leaks []parser.Leak, stats *reporter.Stats) error {
+	var body bytes.Buffer
+	if err := (&reporter.JSONFormatter{Stats: stats}).Format(&body, leaks); err != nil {
+		return err
+	}
+	// The run context may be cancelled already when reporting partially
+	return webhook.Post(context.Background(), body.Bytes())
+}
+
+// notifyChat posts the digest to the configured Slack and Teams webhooks
+// when the new findings reach the configured threshold
+func notifyChat(cfg config.Notify, digest notify.Digest) error {
+	minNew := cfg.MinNew
+	if minNew == 0 {
+		minNew = 1
+	}
+	if !digest.Exceeds(cfg.On, minNew) {
+		return nil
+	}
+	ctx := context.Background()
+	if cfg.Slack != "" {
+		if err := notify.PostSlack(ctx, os.ExpandEnv(cfg.Slack), digest); err != nil {
+			return fmt.Errorf("slack: %w", err)
+		}
+	}
+	if cfg.Teams != "" {
+		if err := notify.PostTeams(ctx, os.ExpandEnv(cfg.Teams), digest); err != nil {
+			return fmt.Errorf("teams: %w", err)
+		}
+	}
+	return nil
+}
+
+// emailReport mails the digest with the HTML report attached, when
+// notify.email names a server
+func emailReport(cfg config.Email, digest notify.Digest, leaks []parser.Leak, opts reporter.Options) error {
+	if cfg.Host == "" {
+		return nil
+	}
+	var html bytes.Buffer
+	if err := (&reporter.HTMLFormatter{BaseDir: opts.BaseDir, GroupBy: opts.GroupBy, SortBy: opts.SortBy}).Format(&html, leaks); err != nil {
+		return err
+	}
+	email := notify.Email{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: os.ExpandEnv(cfg.Username),
+		Password: os.ExpandEnv(cfg.Password),
+		From:     cfg.From,
+		To:       cfg.To,
+		Subject:  cfg.Subject,
+	}
+	return email.Send(digest, html.Bytes())
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+)
+
+// formatList collects repeated --format flags
+type formatList []string
+
+func (f *formatList) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *formatList) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// outputSpec is one requested report: a format and where to write it
+type outputSpec struct {
+	Format string
+	Path   string // empty for stdout
+}
+
+// parseOutputs resolves --format values of the form name or name:path into
+// output specs. Formats without a path are written to defaultPath, or to
+// stdout when defaultPath is empty.
+func parseOutputs(formats []string, defaultPath string) ([]outputSpec, error) {
+	var specs []outputSpec
+	seen := make(map[string]string)
+
+	for _, value := range formats {
+		name, path, _ := strings.Cut(value, ":")
+		if path == "" {
+			path = defaultPath
+		}
+		if _, err := reporter.NewFormatter(name, reporter.Options{}); err != nil {
+			return nil, err
+		}
+
+		dest := path
+		if dest == "" {
+			dest = "stdout"
+		}
+		if other, clash := seen[dest]; clash {
+			return nil, fmt.Errorf("formats %s and %s both write to %s", other, name, dest)
+		}
+		seen[dest] = name
+
+		specs = append(specs, outputSpec{Format: name, Path: path})
+	}
+	return specs, nil
+}
+
+// writeReports renders the findings once per output spec
+func writeReports(specs []outputSpec, leaks []parser.Leak, opts reporter.Options, colorMode string) error {
+	for _, spec := range specs {
+		if err := writeReport(spec, leaks, opts, colorMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeReport(spec outputSpec, leaks []parser.Leak, opts reporter.Options, colorMode string) error {
+	out := os.Stdout
+	if spec.Path != "" {
+		if err := os.MkdirAll(filepath.Dir(spec.Path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.Create(spec.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	color, err := reporter.UseColor(colorMode, out)
+	if err != nil {
+		return err
+	}
+	opts.Color = color
+
+	formatter, err := reporter.NewFormatter(spec.Format, opts)
+	if err != nil {
+		return err
+	}
+	if err := reporter.NewReporter(out, formatter).Report(leaks); err != nil {
+		return fmt.Errorf("%s: %w", spec.Format, err)
+	}
+	if spec.Path != "" {
+		return out.Close()
+	}
+	return nil
+}
+
+// writesConsoleToStdout reports whether human-readable output goes to
+// stdout, in which case progress lines may be mixed into it
+func writesConsoleToStdout(specs []outputSpec) bool {
+	for _, spec := range specs {
+		if spec.Path == "" && (spec.Format == "console" || spec.Format == "text" || spec.Format == "") {
+			return true
+		}
+	}
+	return false
+}
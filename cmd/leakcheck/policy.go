@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"leakcheck/internal/reporter"
+)
+
+// failurePolicy decides the exit status from the findings
+type failurePolicy struct {
+	FailOn      string // "error", "warning" or "never"
+	MaxErrors   int    // errors tolerated before failing
+	MaxWarnings int    // warnings tolerated before failing (fail-on=warning only)
+}
+
+func (p failurePolicy) validate() error {
+	switch p.FailOn {
+	case "error", "warning", "never":
+	default:
+		return fmt.Errorf("invalid --fail-on value %q (use error, warning or never)", p.FailOn)
+	}
+	if p.MaxErrors < 0 || p.MaxWarnings < 0 {
+		return fmt.Errorf("--max-errors and --max-warnings must not be negative")
+	}
+	return nil
+}
+
+// shouldFail reports whether the run should exit with a non-zero status.
+// Informational findings never fail a run.
+func (p failurePolicy) shouldFail(summary reporter.Summary) bool {
+	switch p.FailOn {
+	case "never":
+		return false
+	case "error":
+		return summary.Errors > p.MaxErrors
+	}
+	return summary.Errors > p.MaxErrors || summary.Warnings > p.MaxWarnings
+}
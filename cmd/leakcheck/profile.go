@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// exitHooks run, last registered first, when the main run exits
+var exitHooks []func()
+
+// atExit registers f to run when the main run exits
+func atExit(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+// exit runs the exit hooks and exits; the main run uses it instead of
+// os.Exit so profiles are written and temporary files removed however the
+// run ends
+func exit(code int) {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	if inWorker {
+		exitHooks = nil
+		panic(workerExit(code))
+	}
+	os.Exit(code)
+}
+
+// startProfiles starts a CPU profile and an execution trace and arranges
+// for a heap profile to be written when the run ends. Empty paths are
+// skipped.
+func startProfiles(cpuPath, memPath, tracePath string) error {
+	var stops []func() error
+	stopProfiles := func() {
+		for _, stop := range stops {
+			if err := stop(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing profile: %v\n", err)
+			}
+		}
+		stops = nil
+	}
+	atExit(stopProfiles)
+
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		stops = append(stops, func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		})
+	}
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err != nil {
+			stopProfiles()
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stopProfiles()
+			return err
+		}
+		stops = append(stops, func() error {
+			trace.Stop()
+			return f.Close()
+		})
+	}
+	if memPath != "" {
+		stops = append(stops, func() error {
+			f, err := os.Create(memPath)
+			if err != nil {
+				return err
+			}
+			runtime.GC() // report up-to-date live objects
+			return errors.Join(pprof.WriteHeapProfile(f), f.Close())
+		})
+	}
+	return nil
+}
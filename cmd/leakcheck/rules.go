@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"leakcheck/internal/analyzer"
+)
+
+// ruleStatus is a rule as configured for the current project
+type ruleStatus struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Severity        string `json:"severity"`
+	DefaultSeverity string `json:"default_severity"`
+	Enabled         bool   `json:"enabled"`
+	Summary         string `json:"summary"`
+}
+
+// runRules implements `leakcheck rules`
+func runRules(args []string) int {
+	fs := flag.NewFlagSet("rules", flag.ContinueOnError)
+	configFlag := fs.String("config", "", "Project configuration file (default: .leakcheck.yml found from the current directory)")
+	jsonFlag := fs.Bool("json", false, "Output the rule list as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck rules [--json] [--config file]\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*configFlag, []string{"."})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return 1
+	}
+	disabled := map[string]bool{}
+	severities := map[string]string{}
+	if cfg != nil {
+		disabled = cfg.DisabledRules()
+		severities = cfg.Severity
+	}
+
+	rules := make([]ruleStatus, 0, len(analyzer.Rules))
+	for _, r := range analyzer.Rules {
+		severity := r.Severity
+		if s, ok := severities[r.ID]; ok {
+			severity = s
+		}
+		rules = append(rules, ruleStatus{
+			ID:              r.ID,
+			Name:            r.Name,
+			Severity:        severity,
+			DefaultSeverity: r.Severity,
+			Enabled:         analyzer.RuleEnabled(r.ID, disabled),
+			Summary:         r.Summary,
+		})
+	}
+
+	if *jsonFlag {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(struct {
+			Rules []ruleStatus `json:"rules"`
+		}{rules}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSEVERITY\tENABLED\tSUMMARY")
+	for _, r := range rules {
+		enabled := "yes"
+		if !r.Enabled {
+			enabled = "no"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.ID, r.Name, r.Severity, enabled, r.Summary)
+	}
+	if err := tw.Flush(); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// invocationDir is the directory leakcheck was started in when the scan runs
+// elsewhere, as with scan-repo; empty otherwise
+var invocationDir string
+
+// userPath resolves a file name given on the command line against the
+// directory leakcheck was started in
+func userPath(path string) string {
+	if invocationDir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(invocationDir, path)
+}
+
+// runScanRepo implements `leakcheck scan-repo [options] <url>[#ref]`: it
+// shallow-clones the repository into a temporary directory and scans it
+// from there with the given options, so the repository's own
+// .leakcheck.yml applies. The clone is removed when the run ends.
+func runScanRepo(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[len(args)-1], "-") {
+		fmt.Fprintf(os.Stderr, "Usage: leakcheck scan-repo [options] <url>[#ref]\n\n")
+		fmt.Fprintf(os.Stderr, "Options are those of a normal scan, e.g. --format=sarif:leaks.sarif.\n")
+		return 1
+	}
+	url, ref, _ := strings.Cut(args[len(args)-1], "#")
+
+	dir, err := os.MkdirTemp("", "leakcheck-repo-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	atExit(func() { os.RemoveAll(dir) })
+
+	ctx, cancel := runContext(0)
+	err = cloneRepo(ctx, url, ref, dir)
+	cancel()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error cloning %s: %v\n", url, err)
+		exit(1)
+	}
+
+	invocationDir, _ = os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		exit(1)
+	}
+	os.Args = append(append([]string{os.Args[0]}, args[:len(args)-1]...), ".")
+	runScan()
+	exit(0)
+	return 0
+}
+
+// cloneRepo fetches the tip of ref (a branch, tag or commit; the default
+// branch when empty) from url into dir without history
+func cloneRepo(ctx context.Context, url, ref, dir string) error {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth=1", "--", url, ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return fmt.Errorf("git %s: %s", args[0], msg)
+			}
+			return fmt.Errorf("git %s: %w", args[0], err)
+		}
+	}
+	return nil
+}
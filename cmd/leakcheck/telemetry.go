@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"leakcheck/internal/parser"
+	"leakcheck/internal/telemetry"
+)
+
+// tracer records the run for OpenTelemetry; nil, and a no-op, unless an
+// OTLP endpoint is configured in the environment
+var tracer *telemetry.Tracer
+
+// serverMetrics accumulates metrics across the requests of
+// --persistent_worker for its /metrics endpoint; nil, and a no-op, otherwise
+var serverMetrics *telemetry.Registry
+
+// count increases a counter of the run and of the worker
+func count(name, description string, value int64, attrs ...any) {
+	tracer.Add(name, description, value, attrs...)
+	serverMetrics.Add(name, description, value, attrs...)
+}
+
+// observe records a duration in a histogram of the run and of the worker
+func observe(name, description string, d time.Duration, attrs ...any) {
+	tracer.Observe(name, description, d, attrs...)
+	serverMetrics.Observe(name, description, d, attrs...)
+}
+
+// startTelemetry configures the tracer from the environment and starts the
+// span of the whole run. The span ends, and everything recorded is
+// exported, when the run exits.
+func startTelemetry(args []string) *telemetry.Span {
+	tracer = telemetry.FromEnv("leakcheck", version)
+	if tracer == nil {
+		return nil
+	}
+	run := tracer.Start(nil, "leakcheck", "process.command_args", args)
+	atExit(func() {
+		run.End()
+		if err := tracer.Flush(context.Background()); err != nil {
+			slog.Warn("cannot export telemetry", "err", err)
+		}
+	})
+	return run
+}
+
+// traceParse records the parse of one file, started at start, as a span
+// under parent and in the parse metrics. reused is set when the result of
+// an earlier parse of the same content was used instead.
+func traceParse(parent *telemetry.Span, file string, start time.Time, unit *parser.Unit, reused bool, err error) {
+	span := tracer.StartAt(parent, "parse file", start, "code.filepath", file, "leakcheck.reused", reused)
+	outcome := "parsed"
+	switch {
+	case err != nil:
+		outcome = "failed"
+		span.SetError(err)
+	case reused:
+		outcome = "reused"
+	default:
+		span.SetAttributes("leakcheck.classes", len(unit.Classes), "leakcheck.lines", unit.Lines)
+	}
+	span.End()
+	count("leakcheck.files", "Files processed, by outcome", 1, "outcome", outcome)
+	if outcome == "parsed" {
+		observe("leakcheck.parse.duration", "Time to parse one file", time.Since(start))
+	}
+}
+
+// traceClasses returns an analyzer.ClassDone hook recording the analysis of
+// each class as a span under parent and in the analysis metrics
+func traceClasses(parent *telemetry.Span) func(*parser.Class, time.Time, int) {
+	if tracer == nil && serverMetrics == nil {
+		return nil
+	}
+	return func(class *parser.Class, start time.Time, findings int) {
+		span := tracer.StartAt(parent, "analyze class", start,
+			"leakcheck.class", class.Name, "code.filepath", class.File, "leakcheck.findings", findings)
+		span.End()
+		observe("leakcheck.analyze.duration", "Time to analyze one class", time.Since(start))
+	}
+}
+
+// traceFindings counts the reported findings by rule and severity
+func traceFindings(leaks []parser.Leak) {
+	for _, leak := range leaks {
+		count("leakcheck.findings", "Findings reported, by rule and severity", 1, "rule", leak.RuleID, "severity", leak.Severity)
+	}
+}
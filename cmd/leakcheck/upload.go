@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"leakcheck/internal/gitdiff"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+	"leakcheck/internal/upload"
+)
+
+// uploadedReports are the formats --upload stores, with their object names
+// and content types
+var uploadedReports = []struct{ format, name, contentType string }{
+	{"json", "leakcheck.json", "application/json"},
+	{"sarif", "leakcheck.sarif", "application/sarif+json"},
+	{"html", "leakcheck.html", "text/html; charset=utf-8"},
+}
+
+// ciEnvironment lists the variables identifying a CI job that are recorded
+// in the run metadata when set
+var ciEnvironment = []string{
+	"GITHUB_REPOSITORY", "GITHUB_RUN_ID", "GITHUB_RUN_ATTEMPT", "GITHUB_SHA", "GITHUB_REF",
+	"CI_PROJECT_PATH", "CI_PIPELINE_ID", "CI_JOB_ID", "CI_COMMIT_SHA", "CI_COMMIT_REF_NAME",
+	"BUILD_REPOSITORY_NAME", "BUILD_BUILDID", "BUILD_SOURCEVERSION", "BUILD_SOURCEBRANCH",
+	"JENKINS_URL", "BUILD_URL", "BUILD_NUMBER", "GIT_COMMIT", "GIT_BRANCH",
+}
+
+// runMetadata describes an uploaded run; it is stored as metadata.json
+// next to the reports
+type runMetadata struct {
+	ToolVersion string            `json:"tool_version"`
+	Started     time.Time         `json:"started"`
+	Finished    time.Time         `json:"finished"`
+	BaseDir     string            `json:"base_dir"`
+	Args        []string          `json:"args"`
+	Complete    bool              `json:"complete"`
+	Commit      string            `json:"commit,omitempty"`
+	Branch      string            `json:"branch,omitempty"`
+	CI          map[string]string `json:"ci,omitempty"`
+	Summary     reporter.Summary  `json:"summary"`
+	Reports     []string          `json:"reports"`
+}
+
+// uploadReports stores the JSON, SARIF and HTML reports and the run
+// metadata under <prefix>/<run>/, where run is the start time followed by
+// the short commit hash when scanning a git checkout. It returns the
+// destination of the run.
+func uploadReports(dest upload.Destination, leaks []parser.Leak, opts reporter.Options, started time.Time, complete bool) (string, error) {
+	meta := runMetadata{
+		ToolVersion: opts.ToolVersion,
+		Started:     started.UTC(),
+		Finished:    time.Now().UTC(),
+		BaseDir:     opts.BaseDir,
+		Args:        os.Args[1:],
+		Complete:    complete,
+		Summary:     reporter.Summarize(leaks),
+	}
+	run := meta.Started.Format("20060102T150405Z")
+	if commit, branch, err := gitdiff.Head(opts.BaseDir); err == nil {
+		meta.Commit, meta.Branch = commit, branch
+		run += "-" + commit[:min(len(commit), 12)]
+	}
+	for _, name := range ciEnvironment {
+		if value := os.Getenv(name); value != "" {
+			if meta.CI == nil {
+				meta.CI = make(map[string]string)
+			}
+			meta.CI[name] = value
+		}
+	}
+	dest.Prefix = dest.Key(run)
+
+	// The run context may be cancelled already when reporting partially
+	ctx := context.Background()
+	for _, report := range uploadedReports {
+		formatter, err := reporter.NewFormatter(report.format, opts)
+		if err != nil {
+			return "", err
+		}
+		var body bytes.Buffer
+		if err := formatter.Format(&body, leaks); err != nil {
+			return "", err
+		}
+		if err := dest.Put(ctx, report.name, body.Bytes(), report.contentType); err != nil {
+			return "", err
+		}
+		meta.Reports = append(meta.Reports, report.name)
+	}
+
+	// metadata.json goes last, so its presence marks a complete upload
+	body, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := dest.Put(ctx, "metadata.json", append(body, '\n'), "application/json"); err != nil {
+		return "", err
+	}
+	return dest.String(), nil
+}
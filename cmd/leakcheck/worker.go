@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"leakcheck/internal/parser"
+	"leakcheck/internal/telemetry"
+)
+
+// inWorker is set while --persistent_worker runs a request; exit then
+// unwinds back to the worker loop instead of ending the process
+var inWorker bool
+
+// workerExit carries the exit code of a request through a panic
+type workerExit int
+
+// workerUnits keeps parsed files between worker requests, keyed by absolute
+// path; nil outside --persistent_worker
+var workerUnits map[string]workerUnit
+
+type workerUnit struct {
+	hash [sha256.Size]byte
+	unit *parser.Unit
+}
+
+// workRequest and workResponse are Bazel's worker protocol messages
+// (src/main/protobuf/worker_protocol.proto), in their JSON form
+type workRequest struct {
+	Arguments  []string `json:"arguments"`
+	RequestID  int      `json:"requestId"`
+	Cancel     bool     `json:"cancel"`
+	SandboxDir string   `json:"sandboxDir"`
+}
+
+type workResponse struct {
+	ExitCode  int    `json:"exitCode"`
+	Output    string `json:"output"`
+	RequestID int    `json:"requestId"`
+}
+
+// runWorker implements Bazel's persistent worker protocol: it reads work
+// requests from stdin and answers each on stdout, running the request's
+// arguments as a scan. Parsed files stay in memory between requests, so
+// unchanged files are not parsed again. Requests are accepted as
+// length-delimited protobuf or, with requires-worker-protocol=json, as JSON
+// lines; the first request decides. startup are the arguments leakcheck was
+// started with besides --persistent_worker, prepended to every request,
+// except --metrics-addr, which serves Prometheus metrics of all requests
+// at /metrics on the given address.
+func runWorker(startup []string) int {
+	in := bufio.NewReader(os.Stdin)
+	out := os.Stdout
+	workerUnits = make(map[string]workerUnit)
+
+	addr, startup := metricsAddr(startup)
+	if addr != "" {
+		if err := serveMetrics(addr); err != nil {
+			return workerDone(err)
+		}
+	}
+
+	isJSON, err := isJSONProtocol(in)
+	if err != nil {
+		return workerDone(err)
+	}
+	decoder := json.NewDecoder(in)
+	for {
+		var req workRequest
+		if isJSON {
+			err = decoder.Decode(&req)
+		} else {
+			req, err = readProtoRequest(in)
+		}
+		if err != nil {
+			return workerDone(err)
+		}
+		if req.Cancel {
+			continue // requests run one at a time and cannot be cancelled
+		}
+
+		args, err := expandFlagFiles(append(append([]string(nil), startup...), req.Arguments...))
+		resp := workResponse{ExitCode: 1, RequestID: req.RequestID}
+		if err != nil {
+			resp.Output = fmt.Sprintf("Error: %v\n", err)
+		} else {
+			start := time.Now()
+			resp.ExitCode, resp.Output = runWorkRequest(args, req.SandboxDir)
+			count("leakcheck.analyses", "Work requests run, by exit code", 1, "exit_code", resp.ExitCode)
+			observe("leakcheck.request.duration", "Time to run one work request", time.Since(start))
+		}
+
+		if isJSON {
+			err = json.NewEncoder(out).Encode(resp)
+		} else {
+			err = writeProtoResponse(out, resp)
+		}
+		if err != nil {
+			return workerDone(err)
+		}
+	}
+}
+
+// metricsAddr removes --metrics-addr=addr or --metrics-addr addr from the
+// worker's startup arguments, returning the address
+func metricsAddr(startup []string) (string, []string) {
+	var addr string
+	var rest []string
+	for i := 0; i < len(startup); i++ {
+		arg := strings.TrimPrefix(startup[i], "-")
+		if value, ok := strings.CutPrefix(arg, "-metrics-addr="); ok {
+			addr = value
+		} else if arg == "-metrics-addr" && i+1 < len(startup) {
+			addr = startup[i+1]
+			i++
+		} else {
+			rest = append(rest, startup[i])
+		}
+	}
+	return addr, rest
+}
+
+// serveMetrics starts serving the worker's metrics at /metrics on addr
+func serveMetrics(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	serverMetrics = telemetry.NewRegistry()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", serverMetrics)
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			slog.Warn("metrics server stopped", "err", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Serving metrics at http://%s/metrics\n", listener.Addr())
+	return nil
+}
+
+// workerDone ends the worker loop: cleanly when Bazel closes stdin
+func workerDone(err error) int {
+	if errors.Is(err, io.EOF) {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "Error: persistent worker: %v\n", err)
+	return 1
+}
+
+// isJSONProtocol tells JSON requests, which start with `{"`, from
+// length-delimited protobuf ones
+func isJSONProtocol(in *bufio.Reader) (bool, error) {
+	start, err := in.Peek(2)
+	if err != nil {
+		return false, err
+	}
+	return start[0] == '{' && (start[1] == '"' || start[1] == '}'), nil
+}
+
+// runWorkRequest runs one scan in-process with stdout and stderr captured,
+// returning its exit code and output
+func runWorkRequest(args []string, sandboxDir string) (code int, output string) {
+	capture, err := os.CreateTemp("", "leakcheck-worker-*")
+	if err != nil {
+		return 1, fmt.Sprintf("Error: %v\n", err)
+	}
+	defer os.Remove(capture.Name())
+	defer capture.Close()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return 1, fmt.Sprintf("Error: %v\n", err)
+	}
+	defer devNull.Close()
+
+	stdin, stdout, stderr, logger := os.Stdin, os.Stdout, os.Stderr, slog.Default()
+	os.Stdin, os.Stdout, os.Stderr = devNull, capture, capture
+	savedArgs, savedFlags, savedUsage := os.Args, flag.CommandLine, flag.Usage
+	workDir, _ := os.Getwd()
+	defer func() {
+		os.Stdin, os.Stdout, os.Stderr = stdin, stdout, stderr
+		os.Args, flag.CommandLine, flag.Usage = savedArgs, savedFlags, savedUsage
+		slog.SetDefault(logger)
+		os.Chdir(workDir)
+		inWorker = false
+		fileBuildFlags = nil
+		exitHooks = nil
+		data, _ := os.ReadFile(capture.Name())
+		output = string(data)
+	}()
+
+	if sandboxDir != "" {
+		if err := os.Chdir(sandboxDir); err != nil {
+			fmt.Fprintf(capture, "Error: %v\n", err)
+			return 1, ""
+		}
+	}
+	os.Args = append([]string{savedArgs[0]}, args...)
+	flag.CommandLine = flag.NewFlagSet(savedArgs[0], flag.ContinueOnError)
+	flag.CommandLine.Usage = func() { flag.Usage() }
+	inWorker = true
+	return runCaptured(), ""
+}
+
+// runCaptured runs the scan, turning the exit it ends with into a code
+func runCaptured() (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			c, ok := r.(workerExit)
+			if !ok {
+				panic(r)
+			}
+			code = int(c)
+		}
+	}()
+	runScan()
+	exit(0)
+	return 0
+}
+
+// parseFileCached runs parse, unless an earlier worker request parsed the
+// file with the same content; reused reports the latter
+func parseFileCached(file string, hash [sha256.Size]byte, hashErr error, parse func() (*parser.Unit, error)) (unit *parser.Unit, reused bool, err error) {
+	if workerUnits == nil || hashErr != nil {
+		unit, err = parse()
+		return unit, false, err
+	}
+	abs, _ := filepath.Abs(file)
+	if cached, ok := workerUnits[abs]; ok && cached.hash == hash {
+		slog.Info("unchanged since an earlier request, not parsed again", "file", file)
+		return cached.unit, true, nil
+	}
+	unit, err = parse()
+	if err == nil {
+		workerUnits[abs] = workerUnit{hash: hash, unit: unit}
+	}
+	return unit, false, err
+}
+
+// expandFlagFiles replaces @file and --flagfile=file arguments with the
+// lines of the file, as Bazel passes long argument lists
+func expandFlagFiles(args []string) ([]string, error) {
+	var expanded []string
+	for _, arg := range args {
+		path, ok := strings.CutPrefix(arg, "--flagfile=")
+		if !ok {
+			path, ok = strings.CutPrefix(arg, "@")
+		}
+		if !ok {
+			expanded = append(expanded, arg)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				expanded = append(expanded, line)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// Protobuf field numbers of WorkRequest and WorkResponse, and the wire
+// types they use
+const (
+	fieldArguments  = 1
+	fieldRequestID  = 3
+	fieldCancel     = 4
+	fieldSandboxDir = 6
+
+	fieldExitCode   = 1
+	fieldOutput     = 2
+	fieldResponseID = 3
+
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// maxWorkRequestMessage bounds the size of a protobuf work request
+const maxWorkRequestMessage = 64 << 20
+
+// readProtoRequest reads one varint length-delimited WorkRequest. Fields
+// leakcheck has no use for, such as inputs and verbosity, are skipped.
+func readProtoRequest(in *bufio.Reader) (workRequest, error) {
+	var req workRequest
+	size, err := binary.ReadUvarint(in)
+	if err != nil {
+		return req, err
+	}
+	if size > maxWorkRequestMessage {
+		return req, fmt.Errorf("work request of %d bytes", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(in, msg); err != nil {
+		return req, err
+	}
+
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return req, errors.New("malformed work request")
+		}
+		msg = msg[n:]
+		field, wire := key>>3, key&7
+		switch wire {
+		case wireVarint:
+			v, n := binary.Uvarint(msg)
+			if n <= 0 {
+				return req, errors.New("malformed work request")
+			}
+			msg = msg[n:]
+			switch field {
+			case fieldRequestID:
+				req.RequestID = int(int32(v))
+			case fieldCancel:
+				req.Cancel = v != 0
+			}
+		case wireBytes:
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || length > uint64(len(msg)-n) {
+				return req, errors.New("malformed work request")
+			}
+			value := string(msg[n : n+int(length)])
+			msg = msg[n+int(length):]
+			switch field {
+			case fieldArguments:
+				req.Arguments = append(req.Arguments, value)
+			case fieldSandboxDir:
+				req.SandboxDir = value
+			}
+		default:
+			return req, fmt.Errorf("unsupported wire type %d in work request", wire)
+		}
+	}
+	return req, nil
+}
+
+// writeProtoResponse writes a varint length-delimited WorkResponse
+func writeProtoResponse(w io.Writer, resp workResponse) error {
+	var msg []byte
+	if resp.ExitCode != 0 {
+		msg = binary.AppendUvarint(msg, fieldExitCode<<3|wireVarint)
+		msg = binary.AppendUvarint(msg, uint64(int64(resp.ExitCode)))
+	}
+	if resp.Output != "" {
+		msg = binary.AppendUvarint(msg, fieldOutput<<3|wireBytes)
+		msg = binary.AppendUvarint(msg, uint64(len(resp.Output)))
+		msg = append(msg, resp.Output...)
+	}
+	if resp.RequestID != 0 {
+		msg = binary.AppendUvarint(msg, fieldResponseID<<3|wireVarint)
+		msg = binary.AppendUvarint(msg, uint64(int64(resp.RequestID)))
+	}
+	_, err := w.Write(append(binary.AppendUvarint(nil, uint64(len(msg))), msg...))
+	return err
+}
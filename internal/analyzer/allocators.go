@@ -0,0 +1,192 @@
+package analyzer
+
+import (
+	"leakcheck/internal/parser"
+	"slices"
+	"strings"
+)
+
+// AllocatorPair is a custom allocation function and the function that
+// releases its memory, e.g. g_malloc and g_free
+type AllocatorPair struct {
+	Alloc string `json:"alloc" yaml:"alloc"`
+	Free  string `json:"free" yaml:"free"`
+	// Out marks allocators that store the memory through a pointer passed
+	// as their first argument, e.g. cudaMalloc(&ptr, size)
+	Out bool `json:"out,omitempty" yaml:"out"`
+}
+
+// StringAllocators are the C library functions returning strings that must
+// be released with free, tracked in classes of every language. asprintf
+// and vasprintf store the string through their first argument.
+var StringAllocators = []AllocatorPair{
+	{Alloc: "strdup", Free: "free"},
+	{Alloc: "strndup", Free: "free"},
+	{Alloc: "wcsdup", Free: "free"},
+	{Alloc: "_strdup", Free: "free"},
+	{Alloc: "_wcsdup", Free: "free"},
+	{Alloc: "asprintf", Free: "free", Out: true},
+	{Alloc: "vasprintf", Free: "free", Out: true},
+}
+
+// LanguageAllocators are the allocators tracked in classes implemented in
+// a language other than C++, on top of the configured ones
+var LanguageAllocators = map[parser.Language][]AllocatorPair{
+	parser.LanguageC: {
+		{Alloc: "malloc", Free: "free"},
+		{Alloc: "calloc", Free: "free"},
+		{Alloc: "realloc", Free: "free"},
+	},
+	parser.LanguageCUDA: {
+		{Alloc: "cudaMalloc", Free: "cudaFree", Out: true},
+		{Alloc: "cudaMallocManaged", Free: "cudaFree", Out: true},
+		{Alloc: "cudaMallocHost", Free: "cudaFreeHost", Out: true},
+		{Alloc: "cudaHostAlloc", Free: "cudaFreeHost", Out: true},
+	},
+}
+
+// withAllocators returns a copy of the class in which calls to custom
+// allocators assigned to a pointer are recorded as allocations and calls to
+// the matching release functions as deallocations
+func (a *Analyzer) withAllocators(class parser.Class) parser.Class {
+	allocators := slices.Concat(a.Allocators, StringAllocators, LanguageAllocators[class.Language])
+	if !slices.ContainsFunc(classFunctions(&class), func(fn *parser.Function) bool {
+		return slices.ContainsFunc(fn.Calls, func(call parser.Call) bool {
+			return slices.ContainsFunc(allocators, func(pair AllocatorPair) bool { return call.Name == pair.Alloc || call.Name == pair.Free })
+		})
+	}) {
+		return class
+	}
+
+	pointerMembers := make(map[string]bool)
+	for _, m := range class.Members {
+		pointerMembers[m.Name] = m.IsPointer
+	}
+
+	class.Methods = slices.Clone(class.Methods)
+	for _, fn := range []**parser.Function{&class.Constructor, &class.Destructor, &class.MoveConstructor, &class.MoveAssignment} {
+		if *fn != nil {
+			clone := **fn
+			*fn = &clone
+		}
+	}
+
+	for _, fn := range classFunctions(&class) {
+		fn.Allocations = slices.Clone(fn.Allocations)
+		fn.Deallocations = slices.Clone(fn.Deallocations)
+		frees := make(map[string]bool) // release functions shared by several allocators
+		for _, pair := range allocators {
+			for _, call := range fn.Calls {
+				if !pair.Out || call.Name != pair.Alloc || len(call.Args) == 0 {
+					continue
+				}
+				if target := addressOf(call.Args[0]); pointerMembers[target] {
+					fn.Allocations = append(fn.Allocations, parser.Allocation{
+						VarName:     target,
+						Allocator:   pair.Alloc,
+						Deallocator: pair.Free,
+						File:        fn.File,
+						Line:        call.Line,
+					})
+				}
+			}
+			for _, assign := range fn.Assignments {
+				if pair.Out || !pointerMembers[assign.Target] || !callsFunction(assign.Value, pair.Alloc) {
+					continue
+				}
+				fn.Allocations = append(fn.Allocations, parser.Allocation{
+					VarName:     assign.Target,
+					Allocator:   pair.Alloc,
+					Deallocator: pair.Free,
+					File:        fn.File,
+					Line:        assign.Line,
+					Column:      assign.Column,
+				})
+			}
+			if frees[pair.Free] {
+				continue
+			}
+			frees[pair.Free] = true
+			for _, call := range fn.Calls {
+				if call.Name == pair.Free && len(call.Args) > 0 {
+					fn.Deallocations = append(fn.Deallocations, parser.Deallocation{
+						VarName:     strings.TrimPrefix(call.Args[0], "this->"),
+						Deallocator: pair.Free,
+						File:        fn.File,
+						Line:        call.Line,
+					})
+				}
+			}
+		}
+	}
+	return class
+}
+
+// callsFunction reports whether an expression such as (char*)g_malloc(64)
+// calls the named function
+func callsFunction(expr, name string) bool {
+	for i := 0; ; {
+		j := strings.Index(expr[i:], name+"(")
+		if j < 0 {
+			return false
+		}
+		j += i
+		if j == 0 || !isIdentByte(expr[j-1]) {
+			return true
+		}
+		i = j + 1
+	}
+}
+
+// addressOf returns the variable whose address an argument such as
+// (void**)&devBuffer takes, or "" when it takes none
+func addressOf(arg string) string {
+	i := strings.LastIndexByte(arg, '&')
+	if i < 0 {
+		return ""
+	}
+	name := strings.TrimPrefix(strings.TrimSpace(arg[i+1:]), "this->")
+	end := 0
+	for end < len(name) && isIdentByte(name[end]) {
+		end++
+	}
+	return name[:end]
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9'
+}
+
+// ReleaseStatement returns the statement that frees an allocation
+func ReleaseStatement(alloc parser.Allocation, varName string) string {
+	switch {
+	case alloc.Deallocator != "":
+		return alloc.Deallocator + "(" + varName + ")"
+	case alloc.IsArray:
+		return "delete[] " + varName
+	}
+	return "delete " + varName
+}
+
+// allocatorName returns the function or operator that made an allocation
+func allocatorName(alloc parser.Allocation) string {
+	if alloc.Allocator != "" {
+		return alloc.Allocator
+	}
+	return "new"
+}
+
+// ConstructorAllocation returns the raw allocation of a member in the
+// class constructor, including allocations made through custom allocators
+func (a *Analyzer) ConstructorAllocation(class parser.Class, varName string) (parser.Allocation, bool) {
+	class = a.withAllocators(class)
+	if class.Constructor == nil {
+		return parser.Allocation{}, false
+	}
+	for _, alloc := range class.Constructor.Allocations {
+		if alloc.IsRaw() && alloc.VarName == varName {
+			return alloc, true
+		}
+	}
+	return parser.Allocation{}, false
+}
@@ -1,8 +1,13 @@
 package analyzer
 
 import (
-	"fmt"
+	"context"
 	"leakcheck/internal/parser"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 )
 
 // MaxMethodDepth is the maximum depth to follow method calls
@@ -10,7 +15,38 @@ const MaxMethodDepth = 5
 
 // Analyzer detects memory leaks in parsed C++ classes
 type Analyzer struct {
-	classes []parser.Class
+	classes   []parser.Class
+	functions []parser.Function
+	globals   []parser.Global
+	sources   []ClassSource
+	// Summaries describe ownership behaviour of functions that are not
+	// part of the analyzed classes
+	Summaries FunctionSummaries
+	// Allocators are custom allocation functions tracked like new
+	Allocators []AllocatorPair
+	// DisabledRules lists rule IDs whose findings are dropped. Opt-in rules
+	// run only when listed here as false.
+	DisabledRules map[string]bool
+	// SeverityOverrides replaces the default severity of rules by ID
+	SeverityOverrides map[string]string
+	// WholeProgram resolves calls to the methods of other classes and to
+	// the free functions added with AddFunctions, so members released or
+	// adopted by a function elsewhere count as released. It applies to the
+	// classes added with AddClasses.
+	WholeProgram bool
+	// ClassDone, when set, is called after each class is analyzed with the
+	// time its analysis started and the number of findings it produced
+	ClassDone func(class *parser.Class, started time.Time, findings int)
+
+	// ruleTimes accumulates the time spent in each rule when logging at
+	// info level, for --verbose
+	ruleTimes map[string]*ruleTiming
+}
+
+// ruleTiming is the time a rule took over all classes and what it found
+type ruleTiming struct {
+	duration time.Duration
+	findings int
 }
 
 // NewAnalyzer creates a new analyzer
@@ -23,187 +59,401 @@ func (a *Analyzer) AddClasses(classes []parser.Class) {
 	a.classes = append(a.classes, classes...)
 }
 
-// Analyze performs leak detection and returns found issues
-func (a *Analyzer) Analyze() []parser.Leak {
+// AddFunctions adds free functions, for whole-program analysis and the
+// rules that check free functions
+func (a *Analyzer) AddFunctions(functions []parser.Function) {
+	a.functions = append(a.functions, functions...)
+}
+
+// AddGlobals adds file-scope pointers initialized with new, for the rules
+// that check globals
+func (a *Analyzer) AddGlobals(globals []parser.Global) {
+	a.globals = append(a.globals, globals...)
+}
+
+// ClassSource produces classes one at a time, stopping early when yield
+// returns false. It returns an error when producing classes fails.
+type ClassSource func(yield func(parser.Class) bool) error
+
+// AddSource adds classes that are analyzed as the source produces them,
+// after the classes added with AddClasses, and are not retained. Each is
+// analyzed on its own: the Index in its analysis context covers only that
+// class. A source may call AddFunctions and AddGlobals as it runs; free
+// functions and globals are analyzed after every source is done.
+func (a *Analyzer) AddSource(source ClassSource) {
+	a.sources = append(a.sources, source)
+}
+
+// Analyze performs leak detection and returns found issues. When ctx is
+// cancelled it returns the findings of the classes analyzed so far together
+// with the context's error.
+func (a *Analyzer) Analyze(ctx context.Context) ([]parser.Leak, error) {
 	var leaks []parser.Leak
+	err := a.AnalyzeEach(ctx, func(leak parser.Leak) error {
+		leaks = append(leaks, leak)
+		return nil
+	})
+	return leaks, err
+}
 
+// AnalyzeEach analyzes one class at a time and passes each finding to emit
+// as soon as its class is done, so large runs need not buffer every finding.
+// It stops at the first error returned by emit, or when ctx is cancelled.
+func (a *Analyzer) AnalyzeEach(ctx context.Context, emit func(parser.Leak) error) error {
+	if slog.Default().Enabled(ctx, slog.LevelInfo) {
+		a.ruleTimes = make(map[string]*ruleTiming)
+		defer a.logRuleTimes()
+	}
+	sources := make(sourceLines)
+	index := parser.NewIndex(a.classes)
+	var prog *program
+	if a.WholeProgram {
+		prog = a.newProgram()
+	}
+	uses := a.globalUses()
 	for _, class := range a.classes {
-		classLeaks := a.analyzeClass(class)
-		leaks = append(leaks, classLeaks...)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.analyzeClass(class, index, prog, sources, emit); err != nil {
+			return err
+		}
+		uses.addClass(&class)
 	}
 
-	return leaks
+	for _, source := range a.sources {
+		var err error
+		sourceErr := source(func(class parser.Class) bool {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			// Nothing is cached across classes, so memory stays flat
+			index := parser.NewIndex([]parser.Class{class})
+			err = a.analyzeClass(class, index, nil, make(sourceLines), emit)
+			uses.addClass(&class)
+			return err == nil
+		})
+		if err != nil {
+			return err
+		}
+		if sourceErr != nil {
+			return sourceErr
+		}
+	}
+
+	if err := a.analyzeFunctions(sources, emit); err != nil {
+		return err
+	}
+	return a.analyzeGlobals(uses, sources, emit)
 }
 
-func (a *Analyzer) analyzeClass(class parser.Class) []parser.Leak {
-	var leaks []parser.Leak
+// analyzeClass runs the enabled rules over one class and emits its findings.
+// prog is nil unless whole-program analysis is enabled.
+func (a *Analyzer) analyzeClass(class parser.Class, index *parser.Index, prog *program, sources sourceLines, emit func(parser.Leak) error) error {
+	started := time.Now()
+	class = a.withAllocators(class)
+	ctx := a.newAnalysisContext(&class, index)
+	if prog != nil {
+		ctx.program = prog
+		ctx.Handoffs = prog.collectHandoffs(&class, ctx)
+	}
 
-	// Get all pointer members
-	pointerMembers := make(map[string]parser.Member)
-	for _, m := range class.Members {
-		if m.IsPointer {
-			pointerMembers[m.Name] = m
+	var leaks []parser.Leak
+	for _, rule := range checks {
+		if !RuleEnabled(rule.ID(), a.DisabledRules) {
+			continue
 		}
+		start := time.Now()
+		found := withRuleDefaults(rule, rule.Check(&class, ctx))
+		if a.ruleTimes != nil {
+			t := a.ruleTimes[rule.ID()]
+			if t == nil {
+				t = &ruleTiming{}
+				a.ruleTimes[rule.ID()] = t
+			}
+			t.duration += time.Since(start)
+			t.findings += len(found)
+		}
+		slog.Debug("rule checked", "rule", rule.ID(), "class", class.Name, "findings", len(found))
+		leaks = append(leaks, found...)
 	}
 
-	if len(pointerMembers) == 0 {
-		return nil
+	leaks = applyOwnership(leaks, class.Members)
+	leaks = applyEscapes(leaks, ctx)
+	leaks = a.applyRuleSettings(leaks)
+	relateDefinition(leaks, &class)
+	nameClass(leaks, &class)
+	assignFingerprints(leaks, sources)
+	if a.ClassDone != nil {
+		a.ClassDone(&class, started, len(leaks))
+	}
+	for _, leak := range leaks {
+		if err := emit(leak); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// Track allocations in constructor
-	allocatedVars := make(map[string]parser.Allocation)
-	if class.Constructor != nil {
-		for _, alloc := range class.Constructor.Allocations {
-			allocatedVars[alloc.VarName] = alloc
+// analyzeFunctions runs the enabled rules that check free functions over
+// the functions added with AddFunctions and emits their findings
+func (a *Analyzer) analyzeFunctions(sources sourceLines, emit func(parser.Leak) error) error {
+	var leaks []parser.Leak
+	for _, rule := range checks {
+		check, ok := rule.(FunctionRule)
+		if !ok || !RuleEnabled(rule.ID(), a.DisabledRules) {
+			continue
+		}
+		for i := range a.functions {
+			leaks = append(leaks, withRuleDefaults(rule, check.CheckFunction(&a.functions[i]))...)
 		}
 	}
+	leaks = a.applyRuleSettings(leaks)
+	assignFingerprints(leaks, sources)
+	for _, leak := range leaks {
+		if err := emit(leak); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Build method map for quick lookup
-	methodMap := make(map[string]*parser.Function)
-	for i := range class.Methods {
-		methodMap[class.Methods[i].Name] = &class.Methods[i]
+// globalUses collects, class by class, what the global rules need: the
+// static members and static locals allocated with new, and every name
+// deleted. It keeps no classes, so classes from sources are covered too.
+type globalUses struct {
+	globals  []parser.Global
+	released map[string]bool
+	seen     map[string]bool // a static member is reported once
+}
+
+// globalUses returns an empty collection, or nil when no global rule is
+// enabled
+func (a *Analyzer) globalUses() *globalUses {
+	enabled := slices.ContainsFunc(checks, func(rule Rule) bool {
+		_, ok := rule.(GlobalRule)
+		return ok && RuleEnabled(rule.ID(), a.DisabledRules)
+	})
+	if !enabled {
+		return nil
+	}
+	return &globalUses{released: make(map[string]bool), seen: make(map[string]bool)}
+}
+
+func (u *globalUses) addClass(class *parser.Class) {
+	if u == nil {
+		return
+	}
+	for _, fn := range classFunctions(class) {
+		u.add(class, fn)
 	}
+}
 
-	// Track deallocations in destructor using MULTI-LEVEL method tracking
-	deallocatedVars := make(map[string]parser.Deallocation)
-	aliasMap := buildAliasMap(class) // Build pointer alias map
+// add records the statics fn allocates and the names it deletes; class is
+// nil for free functions
+func (u *globalUses) add(class *parser.Class, fn *parser.Function) {
+	for _, dealloc := range fn.Deallocations {
+		u.released[strings.TrimPrefix(dealloc.VarName, "this->")] = true
+	}
+	for _, g := range staticsIn(class, fn) {
+		if key := g.Class + "::" + g.Function + "::" + g.Name; !u.seen[key] {
+			u.seen[key] = true
+			u.globals = append(u.globals, g)
+		}
+	}
+}
 
-	if class.Destructor != nil {
-		// Collect all deallocations recursively (multi-level)
-		collectDeallocations(class.Destructor, methodMap, deallocatedVars, MaxMethodDepth, make(map[string]bool))
+// analyzeGlobals runs the enabled rules that check globals over the
+// globals added with AddGlobals and the statics collected in uses and in
+// the functions added, and emits their findings
+func (a *Analyzer) analyzeGlobals(uses *globalUses, sources sourceLines, emit func(parser.Leak) error) error {
+	if uses == nil {
+		return nil
 	}
+	for i := range a.functions {
+		uses.add(nil, &a.functions[i])
+	}
+	globals := append(slices.Clone(a.globals), uses.globals...)
+	released := uses.released
 
-	// Rule 1: Allocated in constructor but not deleted in destructor
-	for varName, alloc := range allocatedVars {
-		// Check direct delete or delete through alias
-		deleted := isVarDeallocated(varName, deallocatedVars, aliasMap)
+	var leaks []parser.Leak
+	for _, rule := range checks {
+		check, ok := rule.(GlobalRule)
+		if !ok || !RuleEnabled(rule.ID(), a.DisabledRules) {
+			continue
+		}
+		for i := range globals {
+			leaks = append(leaks, withRuleDefaults(rule, check.CheckGlobal(&globals[i], released))...)
+		}
+	}
+	leaks = a.applyRuleSettings(leaks)
+	assignFingerprints(leaks, sources)
+	for _, leak := range leaks {
+		if err := emit(leak); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		if !deleted {
-			deleteOp := "delete"
-			if alloc.IsArray {
-				deleteOp = "delete[]"
+// staticsIn returns the static locals fn initializes with new and the
+// static pointer members of class it allocates; class is nil for free
+// functions
+func staticsIn(class *parser.Class, fn *parser.Function) []parser.Global {
+	var globals []parser.Global
+	for _, alloc := range fn.Allocations {
+		if !alloc.IsRaw() {
+			continue
+		}
+		name := strings.TrimPrefix(alloc.VarName, "this->")
+		g := parser.Global{Name: name, Type: alloc.Type, IsArray: alloc.IsArray, File: fn.File, Line: alloc.Line, Column: alloc.Column}
+		if class != nil {
+			g.Class = class.Name
+			if g.File == "" {
+				g.File = class.File
 			}
-			leaks = append(leaks, parser.Leak{
-				File:           class.File,
-				Line:           alloc.Line,
-				ClassName:      class.Name,
-				VarName:        varName,
-				Reason:         "allocated with 'new' but not deleted in destructor",
-				Severity:       "error",
-				Recommendation: "In destructor ~" + class.Name + "(), add: " + deleteOp + " " + varName + "; // prevents memory leak from line " + fmt.Sprintf("%d", alloc.Line),
+		}
+		switch {
+		case alloc.Static:
+			g.Function = fn.Name
+			g.Returned = slices.ContainsFunc(fn.Returns, func(ret parser.Return) bool {
+				return strings.TrimPrefix(ret.Value, "*") == name
 			})
-		} else {
-			// Check for array mismatch
-			dealloc := findDeallocation(varName, deallocatedVars, aliasMap)
-			if dealloc != nil {
-				if alloc.IsArray && !dealloc.IsArray {
-					leaks = append(leaks, parser.Leak{
-						File:           class.File,
-						Line:           dealloc.Line,
-						ClassName:      class.Name,
-						VarName:        varName,
-						Reason:         "allocated with 'new[]' but deleted with 'delete' instead of 'delete[]'",
-						Severity:       "error",
-						Recommendation: fmt.Sprintf("At line %d, change 'delete %s' to 'delete[] %s'. Using delete on array allocations causes undefined behavior.", dealloc.Line, varName, varName),
-					})
-				} else if !alloc.IsArray && dealloc.IsArray {
-					leaks = append(leaks, parser.Leak{
-						File:           class.File,
-						Line:           dealloc.Line,
-						ClassName:      class.Name,
-						VarName:        varName,
-						Reason:         "allocated with 'new' but deleted with 'delete[]' instead of 'delete'",
-						Severity:       "warning",
-						Recommendation: fmt.Sprintf("At line %d, change 'delete[] %s' to 'delete %s'. Single object allocated with 'new' should use 'delete'.", dealloc.Line, varName, varName),
-					})
-				}
-			}
+		case class != nil && slices.ContainsFunc(class.Members, func(m parser.Member) bool {
+			return m.IsStatic && m.IsPointer && m.Name == name
+		}):
+		default:
+			continue
 		}
+		globals = append(globals, g)
 	}
+	return globals
+}
 
-	// Rule 2: Pointer reassignment without prior delete in methods
-	for _, method := range class.Methods {
-		for _, alloc := range method.Allocations {
-			if _, exists := pointerMembers[alloc.VarName]; exists {
-				// Check if this variable is deallocated before reassignment in the same method
-				hasDeleteBeforeNew := false
-				for _, dealloc := range method.Deallocations {
-					if dealloc.VarName == alloc.VarName && dealloc.Line < alloc.Line {
-						hasDeleteBeforeNew = true
-						break
-					}
-				}
-
-				if !hasDeleteBeforeNew {
-					// Check if there's an existing allocation (reassignment without delete)
-					if _, wasAllocatedInCtor := allocatedVars[alloc.VarName]; wasAllocatedInCtor {
-						leaks = append(leaks, parser.Leak{
-							File:           class.File,
-							Line:           alloc.Line,
-							ClassName:      class.Name,
-							VarName:        alloc.VarName,
-							Reason:         "pointer reassigned with 'new' without deleting previous allocation (in " + method.Name + ")",
-							Severity:       "warning",
-							Recommendation: fmt.Sprintf("Before line %d in %s::%s(), add: delete %s; // Or consider using std::unique_ptr<%s> for automatic memory management", alloc.Line, class.Name, method.Name, alloc.VarName, "T"),
-						})
-					}
-				}
-			}
+// logRuleTimes logs the time spent in each rule that ran, in rule order
+func (a *Analyzer) logRuleTimes() {
+	for _, id := range slices.Sorted(maps.Keys(a.ruleTimes)) {
+		t := a.ruleTimes[id]
+		slog.Info("rule timing", "rule", id, "duration", t.duration, "findings", t.findings)
+	}
+	a.ruleTimes = nil
+}
+
+// applyRuleSettings drops findings of disabled rules and applies severity
+// overrides
+func (a *Analyzer) applyRuleSettings(leaks []parser.Leak) []parser.Leak {
+	result := leaks[:0]
+	for _, leak := range leaks {
+		if !RuleEnabled(leak.RuleID, a.DisabledRules) {
+			slog.Debug("finding dropped, rule disabled", "rule", leak.RuleID, "class", leak.ClassName, "variable", leak.VarName)
+			continue
+		}
+		if severity, ok := a.SeverityOverrides[leak.RuleID]; ok {
+			slog.Debug("severity overridden", "rule", leak.RuleID, "from", leak.Severity, "to", severity)
+			leak.Severity = severity
 		}
+		result = append(result, leak)
 	}
+	return result
+}
 
-	// Rule 3: Pointer aliasing - delete through alias is valid, but warn about potential issues
-	for _, method := range class.Methods {
-		for _, alias := range method.Aliases {
-			if _, isPointerMember := pointerMembers[alias.SourceVar]; isPointerMember {
-				// Check if target is later deleted but source is also deleted (double delete)
-				sourceDeleted := false
-				targetDeleted := false
-				for _, dealloc := range method.Deallocations {
-					if dealloc.VarName == alias.SourceVar {
-						sourceDeleted = true
-					}
-					if dealloc.VarName == alias.TargetVar {
-						targetDeleted = true
-					}
-				}
-				if sourceDeleted && targetDeleted {
-					leaks = append(leaks, parser.Leak{
-						File:           class.File,
-						Line:           alias.Line,
-						ClassName:      class.Name,
-						VarName:        alias.SourceVar,
-						Reason:         "pointer aliased to '" + alias.TargetVar + "' and both are deleted (potential double-free)",
-						Severity:       "error",
-						Recommendation: fmt.Sprintf("Double-free detected: '%s' and '%s' point to same memory. Remove one delete, or set '%s = nullptr;' after first delete to prevent crash.", alias.SourceVar, alias.TargetVar, alias.SourceVar),
-					})
-				}
-			}
+// classFunctions returns every parsed function of the class
+func classFunctions(class *parser.Class) []*parser.Function {
+	var fns []*parser.Function
+	for _, fn := range []*parser.Function{class.Constructor, class.Destructor, class.MoveConstructor} {
+		if fn != nil {
+			fns = append(fns, fn)
 		}
 	}
+	for i := range class.Methods {
+		fns = append(fns, &class.Methods[i])
+	}
+	return fns
+}
 
-	// Rule 4: No destructor but has allocations
-	if class.Destructor == nil {
-		for _, member := range pointerMembers {
-			if _, allocated := allocatedVars[member.Name]; allocated {
-				alloc := allocatedVars[member.Name]
-				deleteOp := "delete"
-				if alloc.IsArray {
-					deleteOp = "delete[]"
-				}
-				leaks = append(leaks, parser.Leak{
-					File:           class.File,
-					Line:           member.Line,
-					ClassName:      class.Name,
-					VarName:        member.Name,
-					Reason:         "pointer member allocated but class has no destructor",
-					Severity:       "error",
-					Recommendation: fmt.Sprintf("Add destructor to class %s: ~%s() { %s %s; %s = nullptr; }", class.Name, class.Name, deleteOp, member.Name, member.Name),
-				})
-			}
+// fileOf returns file, where the parser saw the code a finding points at, or
+// the file of class when that is unknown
+func fileOf(class *parser.Class, file string) string {
+	if file != "" {
+		return file
+	}
+	return class.File
+}
+
+// relateDefinition points findings outside the file of the class body, such
+// as a missing delete in the .cpp of a class declared in a header, at the
+// body
+func relateDefinition(leaks []parser.Leak, class *parser.Class) {
+	if class.DefinitionFile == "" {
+		return
+	}
+	for i := range leaks {
+		defined := slices.ContainsFunc(class.Locations, func(loc parser.SourceLocation) bool {
+			return loc.File == leaks[i].File && loc.Kind == parser.LocationDefinition
+		})
+		if !defined {
+			leaks[i].Related = append(leaks[i].Related, parser.RelatedLocation{
+				File:    class.DefinitionFile,
+				Line:    class.StartLine,
+				Message: "class " + class.SpecializedName() + " defined here",
+			})
 		}
 	}
+}
+
+// nameClass reports the findings of a class under its namespace and, for a
+// template specialization, its arguments, e.g. Cache<std::string>, to tell
+// it from same-named classes and the primary template
+func nameClass(leaks []parser.Leak, class *parser.Class) {
+	for i := range leaks {
+		if leaks[i].ClassName == class.Name {
+			leaks[i].ClassName = class.SpecializedName()
+			leaks[i].Namespace = class.Namespace
+		}
+	}
+}
 
+// applyOwnership drops findings on members annotated leakcheck:non-owning and
+// assigns confidence, raising it for members annotated leakcheck:owns
+func applyOwnership(leaks []parser.Leak, members []parser.Member) []parser.Leak {
+	ownership := make(map[string]parser.Ownership)
+	for _, m := range members {
+		ownership[m.Name] = m.Ownership
+	}
+
+	result := leaks[:0]
+	for _, leak := range leaks {
+		switch ownership[leak.VarName] {
+		case parser.OwnershipNonOwning:
+			slog.Debug("finding dropped, member is non-owning", "rule", leak.RuleID, "class", leak.ClassName, "variable", leak.VarName)
+			continue
+		case parser.OwnershipOwns:
+			leak.Confidence = "high"
+		default:
+			leak.Confidence = "medium"
+		}
+		result = append(result, leak)
+	}
+	return result
+}
+
+// applyEscapes lowers the confidence of "not deleted" findings on members
+// that a method returns to its callers, which may own or delete them, and
+// points at the return. Members annotated leakcheck:owns keep theirs.
+func applyEscapes(leaks []parser.Leak, ctx *AnalysisContext) []parser.Leak {
+	for i, leak := range leaks {
+		e, escapes := ctx.Escapes[leak.VarName]
+		if !escapes || leak.Confidence == "high" || !slices.Contains([]string{RuleMissingDelete, RuleNoDestructor, RuleDefaultedDtor}, leak.RuleID) {
+			continue
+		}
+		slog.Debug("confidence lowered, member is returned by a method", "rule", leak.RuleID, "class", leak.ClassName, "variable", leak.VarName, "method", e.method.Name)
+		leaks[i].Confidence = "low"
+		leaks[i].Related = append(slices.Clone(leak.Related), parser.RelatedLocation{
+			File:    e.method.File,
+			Line:    e.line,
+			Message: "'" + e.method.Name + "' returns '" + leak.VarName + "', so callers may own or delete it",
+		})
+	}
 	return leaks
 }
 
@@ -221,8 +471,11 @@ func collectDeallocations(fn *parser.Function, methodMap map[string]*parser.Func
 	}
 	visited[fn.Name] = true
 
-	// Add direct deallocations
+	// Add direct deallocations, preferring unconditional ones
 	for _, dealloc := range fn.Deallocations {
+		if existing, ok := result[dealloc.VarName]; ok && !existing.Conditional {
+			continue
+		}
 		result[dealloc.VarName] = dealloc
 	}
 
@@ -234,35 +487,132 @@ func collectDeallocations(fn *parser.Function, methodMap map[string]*parser.Func
 	}
 }
 
-// buildAliasMap creates a map of source -> targets for pointer aliases
-func buildAliasMap(class parser.Class) map[string][]string {
-	aliasMap := make(map[string][]string)
+// collectTransfers recursively collects member variables passed to parameters
+// annotated leakcheck:takes-ownership from a function and its called methods
+func collectTransfers(fn *parser.Function, methodMap map[string]*parser.Function,
+	result map[string]bool, depth int, visited map[string]bool) {
 
-	// Collect aliases from all functions
-	collectAliasesFromFunc := func(fn *parser.Function) {
-		if fn == nil {
-			return
+	if depth <= 0 || fn == nil {
+		return
+	}
+
+	// Prevent infinite recursion
+	if visited[fn.Name] {
+		return
+	}
+	visited[fn.Name] = true
+
+	for _, call := range fn.Calls {
+		callee, exists := methodMap[call.Name]
+		if !exists {
+			continue
 		}
-		for _, alias := range fn.Aliases {
-			aliasMap[alias.SourceVar] = append(aliasMap[alias.SourceVar], alias.TargetVar)
-			// Also reverse: if we delete target, it's like deleting source
-			aliasMap[alias.TargetVar] = append(aliasMap[alias.TargetVar], alias.SourceVar)
+		for _, index := range callee.TakesOwnership {
+			if index < len(call.Args) {
+				result[call.Args[index]] = true
+			}
 		}
 	}
 
-	if class.Constructor != nil {
-		collectAliasesFromFunc(class.Constructor)
+	// Recurse into called methods
+	for _, methodName := range fn.MethodCalls {
+		if calledMethod, exists := methodMap[methodName]; exists {
+			collectTransfers(calledMethod, methodMap, result, depth-1, visited)
+		}
+	}
+}
+
+// buildAliasMap maps each pointer to every variable aliasing it, in both
+// directions, following chains such as tmp = data_; other = tmp;
+func buildAliasMap(class parser.Class) map[string][]string {
+	return aliasesIn(classFunctions(&class), class.Members)
+}
+
+// aliasNode is a variable in the alias graph. Locals are scoped to the
+// function declaring them, so two methods using tmp are not linked; members
+// are shared and join the chains of different functions.
+type aliasNode struct {
+	fn   string // empty for members
+	name string
+}
+
+// aliasesIn builds the alias map of a group of functions, linking every
+// variable to the others reachable through its alias chains
+func aliasesIn(fns []*parser.Function, members []parser.Member) map[string][]string {
+	isMember := make(map[string]bool, len(members))
+	for _, m := range members {
+		isMember[m.Name] = true
 	}
-	if class.Destructor != nil {
-		collectAliasesFromFunc(class.Destructor)
+	node := func(fn *parser.Function, name string) aliasNode {
+		if isMember[name] {
+			return aliasNode{name: name}
+		}
+		return aliasNode{fn: fn.Name, name: name}
 	}
-	for i := range class.Methods {
-		collectAliasesFromFunc(&class.Methods[i])
+
+	parent := make(map[aliasNode]aliasNode)
+	var order []aliasNode
+	var find func(n aliasNode) aliasNode
+	find = func(n aliasNode) aliasNode {
+		p, ok := parent[n]
+		if !ok {
+			parent[n] = n
+			order = append(order, n)
+			return n
+		}
+		if p != n {
+			p = find(p)
+			parent[n] = p
+		}
+		return p
+	}
+	for _, fn := range fns {
+		for _, alias := range fn.Aliases {
+			source, target := find(node(fn, alias.SourceVar)), find(node(fn, alias.TargetVar))
+			if source != target {
+				parent[target] = source
+			}
+		}
 	}
 
+	groups := make(map[aliasNode][]string)
+	for _, n := range order {
+		root := find(n)
+		if !slices.Contains(groups[root], n.name) {
+			groups[root] = append(groups[root], n.name)
+		}
+	}
+	aliasMap := make(map[string][]string)
+	for _, n := range order {
+		for _, other := range groups[find(n)] {
+			if other != n.name && !slices.Contains(aliasMap[n.name], other) {
+				aliasMap[n.name] = append(aliasMap[n.name], other)
+			}
+		}
+	}
 	return aliasMap
 }
 
+// reachableFunctions returns fn followed by the class methods it calls,
+// directly or through other methods, up to depth calls deep
+func reachableFunctions(fn *parser.Function, methodMap map[string]*parser.Function, depth int) []*parser.Function {
+	var fns []*parser.Function
+	visited := make(map[string]bool)
+	var visit func(fn *parser.Function, depth int)
+	visit = func(fn *parser.Function, depth int) {
+		if depth <= 0 || fn == nil || visited[fn.Name] {
+			return
+		}
+		visited[fn.Name] = true
+		fns = append(fns, fn)
+		for _, methodName := range fn.MethodCalls {
+			visit(methodMap[methodName], depth-1)
+		}
+	}
+	visit(fn, depth)
+	return fns
+}
+
 // isVarDeallocated checks if a variable is deallocated directly or through an alias
 func isVarDeallocated(varName string, deallocatedVars map[string]parser.Deallocation, aliasMap map[string][]string) bool {
 	// Direct check
@@ -305,5 +655,6 @@ func findDeallocation(varName string, deallocatedVars map[string]parser.Dealloca
 func AnalyzeClasses(classes []parser.Class) []parser.Leak {
 	analyzer := NewAnalyzer()
 	analyzer.AddClasses(classes)
-	return analyzer.Analyze()
+	leaks, _ := analyzer.Analyze(context.Background())
+	return leaks
 }
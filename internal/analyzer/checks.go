@@ -0,0 +1,1201 @@
+package analyzer
+
+import (
+	"fmt"
+	"leakcheck/internal/parser"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// builtinChecks lists the built-in rules in the order they run
+var builtinChecks = []Rule{
+	missingDeleteRule{},
+	arrayMismatchRule{},
+	reassignmentRule{},
+	aliasDoubleFreeRule{},
+	noDestructorRule{},
+	movedFromRule{},
+	ownershipUnclearRule{},
+	discardedNewRule{},
+	danglingPointerRule{},
+	arithmeticDeleteRule{},
+	throwLeakRule{},
+	rawFactoryRule{},
+	unownedDeleteRule{},
+	selfAssignmentRule{},
+	resetLeakRule{},
+	globalLeakRule{},
+	defaultedDestructorRule{},
+	containerLeakRule{},
+}
+
+// missingDeleteRule reports pointers allocated in the constructor that the
+// destructor never releases
+type missingDeleteRule struct{}
+
+func (missingDeleteRule) ID() string { return RuleMissingDelete }
+
+func (missingDeleteRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	// A defaulted destructor releases nothing; defaultedDestructorRule
+	// reports its members
+	if len(ctx.PointerMembers) == 0 || class.Destructor != nil && class.Destructor.Defaulted {
+		return nil
+	}
+
+	var leaks []parser.Leak
+	for varName, alloc := range ctx.ConstructorAllocations {
+		if ctx.Released(varName) {
+			if dealloc := ctx.ConditionalRelease(varName); dealloc != nil {
+				leaks = append(leaks, parser.Leak{
+					RuleID:         RuleMissingDelete,
+					File:           fileOf(class, dealloc.File),
+					Line:           dealloc.Line,
+					Column:         dealloc.Column,
+					ClassName:      class.Name,
+					VarName:        varName,
+					Reason:         "deleted in destructor only when a condition holds, otherwise the allocation from line " + fmt.Sprintf("%d", alloc.Line) + " leaks",
+					Severity:       "warning",
+					Recommendation: fmt.Sprintf("At line %d, delete '%s' unconditionally; deleting a null pointer is a no-op, so a null check is the only guard needed.", dealloc.Line, varName),
+				})
+			}
+			continue
+		}
+		leaks = append(leaks, parser.Leak{
+			RuleID:         RuleMissingDelete,
+			File:           fileOf(class, alloc.File),
+			Line:           alloc.Line,
+			Column:         alloc.Column,
+			ClassName:      class.Name,
+			VarName:        varName,
+			Reason:         "allocated with '" + allocatorName(alloc) + "' but not deleted in destructor",
+			Severity:       "error",
+			Recommendation: "In destructor ~" + class.Name + "(), add: " + ReleaseStatement(alloc, varName) + "; // prevents memory leak from line " + fmt.Sprintf("%d", alloc.Line),
+		})
+	}
+	return leaks
+}
+
+// arrayMismatchRule reports new[] released with delete and new released with
+// delete[]
+type arrayMismatchRule struct{}
+
+func (arrayMismatchRule) ID() string { return RuleArrayMismatch }
+
+func (arrayMismatchRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	if len(ctx.PointerMembers) == 0 {
+		return nil
+	}
+
+	var leaks []parser.Leak
+	for varName, alloc := range ctx.ConstructorAllocations {
+		dealloc := findDeallocation(varName, ctx.Deallocations, ctx.Aliases)
+		if dealloc == nil {
+			continue
+		}
+		if alloc.Deallocator != dealloc.Deallocator {
+			// Memory from strdup or malloc goes back to free, not delete,
+			// and the reverse
+			release := "delete"
+			if dealloc.Deallocator != "" {
+				release = dealloc.Deallocator + "()"
+			} else if dealloc.IsArray {
+				release = "delete[]"
+			}
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleArrayMismatch,
+				File:           fileOf(class, dealloc.File),
+				Line:           dealloc.Line,
+				Column:         dealloc.Column,
+				ClassName:      class.Name,
+				VarName:        varName,
+				Reason:         "allocated with '" + allocatorName(alloc) + "' but released with '" + release + "'",
+				Severity:       "error",
+				Recommendation: fmt.Sprintf("At line %d, release '%s' with: %s;", dealloc.Line, varName, ReleaseStatement(alloc, varName)),
+			})
+		} else if alloc.IsArray && !dealloc.IsArray {
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleArrayMismatch,
+				File:           fileOf(class, dealloc.File),
+				Line:           dealloc.Line,
+				Column:         dealloc.Column,
+				ClassName:      class.Name,
+				VarName:        varName,
+				Reason:         "allocated with 'new[]' but deleted with 'delete' instead of 'delete[]'",
+				Severity:       "error",
+				Recommendation: fmt.Sprintf("At line %d, change 'delete %s' to 'delete[] %s'. Using delete on array allocations causes undefined behavior.", dealloc.Line, varName, varName),
+			})
+		} else if !alloc.IsArray && dealloc.IsArray {
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleArrayMismatch,
+				File:           fileOf(class, dealloc.File),
+				Line:           dealloc.Line,
+				Column:         dealloc.Column,
+				ClassName:      class.Name,
+				VarName:        varName,
+				Reason:         "allocated with 'new' but deleted with 'delete[]' instead of 'delete'",
+				Severity:       "warning",
+				Recommendation: fmt.Sprintf("At line %d, change 'delete[] %s' to 'delete %s'. Single object allocated with 'new' should use 'delete'.", dealloc.Line, varName, varName),
+			})
+		}
+	}
+	return leaks
+}
+
+// reassignmentRule reports pointer members allocated again in a method
+// without deleting the allocation made by the constructor. A copy-and-swap
+// assignment operator builds the new state in its by-value parameter and
+// swaps it in, leaving the old state to the parameter's destructor, so its
+// allocations are not reassignments.
+type reassignmentRule struct{}
+
+func (reassignmentRule) ID() string { return RuleReassignment }
+
+func (reassignmentRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, method := range class.Methods {
+		if method.CopyAndSwap {
+			continue
+		}
+		for _, alloc := range method.Allocations {
+			if !alloc.IsRaw() {
+				continue
+			}
+			if _, exists := ctx.PointerMembers[alloc.VarName]; !exists {
+				continue
+			}
+
+			// Check if this variable is deallocated before reassignment in the same method
+			hasDeleteBeforeNew := false
+			for _, dealloc := range method.Deallocations {
+				if dealloc.VarName == alloc.VarName && dealloc.Line < alloc.Line {
+					hasDeleteBeforeNew = true
+					break
+				}
+			}
+			if hasDeleteBeforeNew {
+				continue
+			}
+
+			// Check if there's an existing allocation (reassignment without delete)
+			if _, wasAllocatedInCtor := ctx.ConstructorAllocations[alloc.VarName]; wasAllocatedInCtor {
+				leaks = append(leaks, parser.Leak{
+					RuleID:         RuleReassignment,
+					File:           fileOf(class, alloc.File),
+					Line:           alloc.Line,
+					Column:         alloc.Column,
+					ClassName:      class.Name,
+					VarName:        alloc.VarName,
+					Reason:         "pointer reassigned with 'new' without deleting previous allocation (in " + method.Name + ")",
+					Severity:       "warning",
+					Recommendation: fmt.Sprintf("Before line %d in %s::%s(), add: delete %s; // Or consider using std::unique_ptr<%s> for automatic memory management", alloc.Line, class.Name, method.Name, alloc.VarName, "T"),
+				})
+			}
+		}
+	}
+	return leaks
+}
+
+// aliasDoubleFreeRule reports a pointer member and an alias of it that are
+// both deleted, within one method or within the destructor and the methods
+// it calls. Aliases are followed through chains such as tmp = data_;
+// other = tmp;, and a member is reported once however many of its aliases
+// are deleted.
+type aliasDoubleFreeRule struct{}
+
+func (aliasDoubleFreeRule) ID() string { return RuleAliasDoubleFree }
+
+func (aliasDoubleFreeRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	reported := make(map[string]map[string]bool) // method -> members reported in it
+	for i := range class.Methods {
+		method := &class.Methods[i]
+		found := checkAliasDoubleFree(class, ctx, []*parser.Function{method}, nil)
+		reported[method.Name] = make(map[string]bool)
+		for _, leak := range found {
+			reported[method.Name][leak.VarName] = true
+		}
+		leaks = append(leaks, found...)
+	}
+
+	if class.Destructor != nil {
+		fns := reachableFunctions(class.Destructor, ctx.Methods, MaxMethodDepth)
+		// Double frees inside one called method are reported above
+		skip := make(map[string]bool)
+		for _, fn := range fns {
+			for member := range reported[fn.Name] {
+				skip[member] = true
+			}
+		}
+		leaks = append(leaks, checkAliasDoubleFree(class, ctx, fns, skip)...)
+		leaks = append(leaks, checkHandoffDoubleFree(class, ctx)...)
+	}
+	return leaks
+}
+
+// checkHandoffDoubleFree reports members the destructor deletes and also
+// hands to a function elsewhere that deletes them, found by whole-program
+// analysis
+func checkHandoffDoubleFree(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, member := range slices.Sorted(maps.Keys(ctx.Handoffs)) {
+		h := ctx.Handoffs[member]
+		if h.release.owned() {
+			continue
+		}
+		dealloc := findDeallocation(member, ctx.Deallocations, ctx.Aliases)
+		if dealloc == nil {
+			continue
+		}
+		leaks = append(leaks, parser.Leak{
+			RuleID:         RuleAliasDoubleFree,
+			File:           fileOf(class, h.file),
+			Line:           h.call.Line,
+			ClassName:      class.Name,
+			VarName:        member,
+			Reason:         "pointer passed to '" + h.call.Name + "', which deletes it, and also deleted by the destructor (potential double-free)",
+			Severity:       "error",
+			Recommendation: fmt.Sprintf("Release '%s' in one place: remove the delete at line %d, or stop passing it to '%s'.", member, dealloc.Line, h.call.Name),
+			Related: []parser.RelatedLocation{
+				h.release.location(h.release.describe(h.release.fn.Name) + " here"),
+				{File: fileOf(class, dealloc.File), Line: dealloc.Line, Message: "'" + dealloc.VarName + "' deleted here"},
+			},
+		})
+	}
+	return leaks
+}
+
+// checkAliasDoubleFree reports the pointer members deleted together with an
+// alias by a group of functions, except the members in skip
+func checkAliasDoubleFree(class *parser.Class, ctx *AnalysisContext, fns []*parser.Function, skip map[string]bool) []parser.Leak {
+	aliases := aliasesIn(fns, class.Members)
+	deleted := make(map[string]bool)
+	for _, fn := range fns {
+		for _, dealloc := range fn.Deallocations {
+			deleted[dealloc.VarName] = true
+		}
+	}
+
+	var leaks []parser.Leak
+	done := make(map[string]bool)
+	for _, fn := range fns {
+		for _, alias := range fn.Aliases {
+			member := alias.SourceVar
+			if _, isPointerMember := ctx.PointerMembers[member]; !isPointerMember || !deleted[member] || skip[member] || done[member] {
+				continue
+			}
+			i := slices.IndexFunc(aliases[member], func(name string) bool { return deleted[name] })
+			if i < 0 {
+				continue
+			}
+			done[member] = true // reported at its first alias
+			deletedAlias := aliases[member][i]
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleAliasDoubleFree,
+				File:           fileOf(class, fn.File),
+				Line:           alias.Line,
+				Column:         alias.Column,
+				ClassName:      class.Name,
+				VarName:        member,
+				Reason:         "pointer aliased to '" + deletedAlias + "' and both are deleted (potential double-free)",
+				Severity:       "error",
+				Recommendation: fmt.Sprintf("Double-free detected: '%s' and '%s' point to same memory. Remove one delete, or set '%s = nullptr;' after first delete to prevent crash.", member, deletedAlias, member),
+			})
+		}
+	}
+	return leaks
+}
+
+// noDestructorRule reports classes that allocate pointer members in the
+// constructor but declare no destructor
+type noDestructorRule struct{}
+
+func (noDestructorRule) ID() string { return RuleNoDestructor }
+
+func (noDestructorRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	if class.Destructor != nil {
+		return nil
+	}
+
+	var leaks []parser.Leak
+	for _, member := range ctx.PointerMembers {
+		alloc, allocated := ctx.ConstructorAllocations[member.Name]
+		if _, sunk := ctx.Sunk[member.Name]; !allocated || sunk {
+			continue
+		}
+		leaks = append(leaks, parser.Leak{
+			RuleID:         RuleNoDestructor,
+			File:           fileOf(class, class.DefinitionFile),
+			Line:           member.Line,
+			Column:         member.Column,
+			ClassName:      class.Name,
+			VarName:        member.Name,
+			Reason:         "pointer member allocated but class has no destructor",
+			Severity:       "error",
+			Recommendation: fmt.Sprintf("Add destructor to class %s: ~%s() { %s; %s = nullptr; }", class.Name, class.Name, ReleaseStatement(alloc, member.Name), member.Name),
+		})
+	}
+	return leaks
+}
+
+// movedFromRule reports move operations that take a pointer member from the
+// source object without resetting it
+type movedFromRule struct{}
+
+func (movedFromRule) ID() string { return RuleMovedFromNotReset }
+
+func (movedFromRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	leaks := checkMovedFrom(class, class.MoveConstructor, ctx.PointerMembers)
+	return append(leaks, checkMovedFrom(class, class.MoveAssignment, ctx.PointerMembers)...)
+}
+
+// checkMovedFrom flags pointer members transferred out of the source object
+// of a move operation (data_ = other.data_) without resetting the source to
+// nullptr, which leaves both objects deleting the same memory
+func checkMovedFrom(class *parser.Class, fn *parser.Function, pointerMembers map[string]parser.Member) []parser.Leak {
+	if fn == nil || fn.MoveSource == "" {
+		return nil
+	}
+
+	var leaks []parser.Leak
+	for _, transfer := range fn.Assignments {
+		var member string
+		for _, sep := range []string{".", "->"} {
+			if name, ok := strings.CutPrefix(transfer.Value, fn.MoveSource+sep); ok {
+				member = name
+			}
+		}
+		if _, isPointer := pointerMembers[member]; !isPointer {
+			continue
+		}
+
+		reset := false
+		for _, assign := range fn.Assignments {
+			if assign.Target != fn.MoveSource+"."+member && assign.Target != fn.MoveSource+"->"+member {
+				continue
+			}
+			if assign.Value == "nullptr" || assign.Value == "NULL" || assign.Value == "0" {
+				reset = true
+				break
+			}
+		}
+		if reset {
+			continue
+		}
+
+		leaks = append(leaks, parser.Leak{
+			RuleID:         RuleMovedFromNotReset,
+			File:           fileOf(class, fn.File),
+			Line:           transfer.Line,
+			Column:         transfer.Column,
+			ClassName:      class.Name,
+			VarName:        member,
+			Reason:         "pointer moved from '" + fn.MoveSource + "' without resetting the source (in " + fn.Name + ", potential double-free)",
+			Severity:       "error",
+			Recommendation: fmt.Sprintf("After line %d, add: %s.%s = nullptr; // or use std::exchange(%s.%s, nullptr)", transfer.Line, fn.MoveSource, member, fn.MoveSource, member),
+		})
+	}
+	return leaks
+}
+
+// ownershipUnclearRule reports allocations handed directly to a call
+// (registerWidget(new Widget)) unless the callee is known to take ownership,
+// either from a leakcheck:takes-ownership annotation or a function summary.
+// Unlike the member rules it applies to every class.
+type ownershipUnclearRule struct{}
+
+func (ownershipUnclearRule) ID() string { return RuleOwnershipUnclear }
+
+func (ownershipUnclearRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, fn := range classFunctions(class) {
+		for _, alloc := range fn.Allocations {
+			if alloc.PassedTo == "" {
+				continue
+			}
+			if ctx.Summaries.TakesOwnership(alloc.PassedTo, alloc.ArgIndex) {
+				continue
+			}
+			// Stored in a member container of pointers; containerLeakRule
+			// checks that the destructor deletes it
+			if slices.ContainsFunc(class.Members, func(m parser.Member) bool { return m.PointerContainer && m.Name == alloc.Container }) {
+				continue
+			}
+			if callee, exists := ctx.Methods[alloc.PassedTo]; exists && slices.Contains(callee.TakesOwnership, alloc.ArgIndex) {
+				continue
+			}
+			var related []parser.RelatedLocation
+			if ctx.program != nil {
+				if ctx.program.callee(alloc.PassedTo, alloc.ArgIndex) != nil {
+					continue
+				}
+				if keeper := ctx.program.keeper(alloc.PassedTo, alloc.ArgIndex); keeper != nil {
+					related = append(related, parser.RelatedLocation{
+						File:    keeper.File,
+						Line:    keeper.StartLine,
+						Message: "'" + keeper.Name + "' neither deletes nor stores its " + paramLabel(keeper, alloc.ArgIndex),
+					})
+				}
+			}
+
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleOwnershipUnclear,
+				File:           fileOf(class, alloc.File),
+				Line:           alloc.Line,
+				Column:         alloc.Column,
+				ClassName:      class.Name,
+				VarName:        "new " + alloc.Type,
+				Reason:         "allocation passed to '" + alloc.PassedTo + "' with unclear ownership (in " + fn.Name + ")",
+				Severity:       "info",
+				Recommendation: fmt.Sprintf("Pass a std::unique_ptr<%s> to make the transfer explicit, or add '%s' to the function summaries if it takes ownership.", alloc.Type, alloc.PassedTo),
+				Related:        related,
+			})
+		}
+	}
+	return leaks
+}
+
+// discardedNewRule reports `new Foo(args);` statements: nothing holds the
+// pointer, so the object can never be deleted
+type discardedNewRule struct{}
+
+func (discardedNewRule) ID() string { return RuleDiscardedNew }
+
+func (discardedNewRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, fn := range classFunctions(class) {
+		for _, alloc := range fn.Allocations {
+			if !alloc.Discarded {
+				continue
+			}
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleDiscardedNew,
+				File:           fileOf(class, alloc.File),
+				Line:           alloc.Line,
+				Column:         alloc.Column,
+				ClassName:      class.Name,
+				VarName:        "new " + alloc.Type,
+				Reason:         "result of 'new " + alloc.Type + "' is discarded (in " + fn.Name + "), the object can never be deleted",
+				Severity:       "error",
+				Recommendation: fmt.Sprintf("At line %d, store the result (e.g. auto obj = std::make_unique<%s>(...);) or create the object on the stack.", alloc.Line, alloc.Type),
+			})
+		}
+	}
+	return leaks
+}
+
+// danglingPointerRule reports members deleted outside the destructor and
+// left pointing at the freed memory, so a later use or delete is undefined
+// behavior. It is opt-in.
+type danglingPointerRule struct{}
+
+func (danglingPointerRule) ID() string { return RuleDanglingPointer }
+
+func (danglingPointerRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, fn := range classFunctions(class) {
+		if fn == class.Destructor {
+			continue
+		}
+		for _, dealloc := range fn.Deallocations {
+			if _, isPointer := ctx.PointerMembers[dealloc.VarName]; !isPointer || reassignedAfter(fn, dealloc) {
+				continue
+			}
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleDanglingPointer,
+				File:           fileOf(class, dealloc.File),
+				Line:           dealloc.Line,
+				Column:         dealloc.Column,
+				ClassName:      class.Name,
+				VarName:        dealloc.VarName,
+				Reason:         "deleted in " + fn.Name + " without being set to nullptr, it points at freed memory until reassigned",
+				Severity:       "info",
+				Recommendation: fmt.Sprintf("After line %d, add: %s = nullptr;", dealloc.Line, dealloc.VarName),
+			})
+		}
+	}
+	return leaks
+}
+
+// reassignedAfter reports whether fn assigns the deleted variable again after
+// the delete, e.g. p = nullptr or p = new T
+func reassignedAfter(fn *parser.Function, dealloc parser.Deallocation) bool {
+	after := func(line, column int) bool {
+		return line > dealloc.Line || line == dealloc.Line && column > dealloc.Column
+	}
+	for _, assign := range fn.Assignments {
+		if assign.Target == dealloc.VarName && after(assign.Line, assign.Column) {
+			return true
+		}
+	}
+	for _, alloc := range fn.Allocations {
+		if alloc.VarName == dealloc.VarName && after(alloc.Line, alloc.Column) {
+			return true
+		}
+	}
+	return false
+}
+
+// arithmeticDeleteRule reports deletes of owning pointers that were moved
+// with pointer arithmetic earlier in the same function: the delete then
+// frees an address new never returned
+type arithmeticDeleteRule struct{}
+
+func (arithmeticDeleteRule) ID() string { return RuleArithmeticDelete }
+
+func (arithmeticDeleteRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, fn := range classFunctions(class) {
+		if len(fn.Arithmetic) == 0 {
+			continue
+		}
+		for _, dealloc := range fn.Deallocations {
+			if _, isPointer := ctx.PointerMembers[dealloc.VarName]; !isPointer && !allocatedIn(fn, dealloc.VarName) {
+				continue
+			}
+			step := movedBefore(fn, dealloc)
+			if step == nil {
+				continue
+			}
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleArithmeticDelete,
+				File:           fileOf(class, dealloc.File),
+				Line:           dealloc.Line,
+				Column:         dealloc.Column,
+				ClassName:      class.Name,
+				VarName:        dealloc.VarName,
+				Reason:         fmt.Sprintf("deleted after pointer arithmetic ('%s' at line %d, in %s), the address freed is not the one allocated", arithmeticText(*step), step.Line, fn.Name),
+				Severity:       "error",
+				Recommendation: fmt.Sprintf("Keep '%s' pointing at the allocation and move a copy instead (e.g. auto* cursor = %s;), or undo the offset before line %d.", dealloc.VarName, dealloc.VarName, dealloc.Line),
+			})
+		}
+	}
+	return leaks
+}
+
+// allocatedIn reports whether fn assigns the result of new to name
+func allocatedIn(fn *parser.Function, name string) bool {
+	return slices.ContainsFunc(fn.Allocations, func(alloc parser.Allocation) bool {
+		return alloc.VarName == name
+	})
+}
+
+// movedBefore returns the last arithmetic on the deleted variable before the
+// delete, unless the variable is assigned a new address in between
+func movedBefore(fn *parser.Function, dealloc parser.Deallocation) *parser.PointerArithmetic {
+	before := func(line, column int) bool {
+		return line < dealloc.Line || line == dealloc.Line && column < dealloc.Column
+	}
+	var step *parser.PointerArithmetic
+	for i, s := range fn.Arithmetic {
+		if s.VarName == dealloc.VarName && before(s.Line, s.Column) {
+			step = &fn.Arithmetic[i]
+		}
+	}
+	if step == nil {
+		return nil
+	}
+	after := func(line, column int) bool {
+		return line > step.Line || line == step.Line && column > step.Column
+	}
+	for _, assign := range fn.Assignments {
+		if assign.Target == dealloc.VarName && after(assign.Line, assign.Column) && before(assign.Line, assign.Column) {
+			return nil
+		}
+	}
+	for _, alloc := range fn.Allocations {
+		if alloc.VarName == dealloc.VarName && after(alloc.Line, alloc.Column) && before(alloc.Line, alloc.Column) {
+			return nil
+		}
+	}
+	return step
+}
+
+// arithmeticText renders pointer arithmetic for messages, e.g. p++ or p += ...
+func arithmeticText(step parser.PointerArithmetic) string {
+	if step.Op == "++" || step.Op == "--" {
+		return step.VarName + step.Op
+	}
+	return step.VarName + " " + step.Op + " ..."
+}
+
+// throwLeakRule reports allocations still owned by a raw pointer when a
+// throw is reached: locals in any method, and members in the constructor,
+// whose destructor does not run when it throws
+type throwLeakRule struct{}
+
+func (throwLeakRule) ID() string { return RuleThrowLeak }
+
+func (throwLeakRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, fn := range classFunctions(class) {
+		if len(fn.Throws) == 0 {
+			continue
+		}
+		for _, alloc := range fn.Allocations {
+			if !alloc.IsRaw() || strings.Contains(alloc.VarName, ".") || strings.Contains(alloc.VarName, "->") {
+				continue
+			}
+			_, isMember := ctx.PointerMembers[alloc.VarName]
+			if isMember && fn != class.Constructor || ctx.SmartMembers[alloc.VarName] {
+				continue
+			}
+			throw := leakingThrow(fn, alloc)
+			if throw == nil {
+				continue
+			}
+			what := "local '" + alloc.VarName + "'"
+			if isMember {
+				what = "member '" + alloc.VarName + "' (the destructor does not run when a constructor throws)"
+			}
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleThrowLeak,
+				File:           fileOf(class, alloc.File),
+				Line:           alloc.Line,
+				Column:         alloc.Column,
+				ClassName:      class.Name,
+				VarName:        alloc.VarName,
+				Reason:         fmt.Sprintf("allocation leaks if the throw at line %d is reached (in %s), still owned by %s", throw.Line, fn.Name, what),
+				Severity:       "warning",
+				Recommendation: fmt.Sprintf("Hold the allocation in a std::unique_ptr<%s> until ownership is transferred, or release it before the throw at line %d.", alloc.Type, throw.Line),
+				Related: []parser.RelatedLocation{
+					{File: fn.File, Line: throw.Line, Message: "throws here while '" + alloc.VarName + "' is still owned"},
+				},
+			})
+		}
+	}
+	return leaks
+}
+
+// leakingThrow returns the first throw after an allocation that leaves it
+// unreleased: not deleted, stored or passed to a call before the throw, and
+// not cleaned up by a catch handler around the throw
+func leakingThrow(fn *parser.Function, alloc parser.Allocation) *parser.Throw {
+	start := position{alloc.Line, alloc.Column}
+	for i, throw := range fn.Throws {
+		end := position{throw.Line, throw.Column}
+		if !start.before(end) || releasedBetween(fn, alloc.VarName, start, end) || caughtAndReleased(fn, alloc.VarName, throw) {
+			continue
+		}
+		return &fn.Throws[i]
+	}
+	return nil
+}
+
+// position is a place in a source file
+type position struct {
+	line, column int
+}
+
+func (p position) before(q position) bool {
+	return p.line < q.line || p.line == q.line && p.column < q.column
+}
+
+// releasedBetween reports whether name is deleted, stored elsewhere or
+// passed to a call between start and end. Calls count as transfers since
+// they are often smart pointer constructors or adopting functions.
+func releasedBetween(fn *parser.Function, name string, start, end position) bool {
+	within := func(p position) bool { return start.before(p) && p.before(end) }
+	for _, dealloc := range fn.Deallocations {
+		if dealloc.VarName == name && within(position{dealloc.Line, dealloc.Column}) {
+			return true
+		}
+	}
+	for _, assign := range fn.Assignments {
+		if assign.Value == name && within(position{assign.Line, assign.Column}) {
+			return true
+		}
+	}
+	for _, call := range fn.Calls {
+		if call.Line >= start.line && call.Line <= end.line && slices.Contains(call.Args, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// caughtAndReleased reports whether a try block around the throw cleans name
+// up: a catch handler deletes it, or the handlers swallow the exception and
+// it is deleted after the try statement
+func caughtAndReleased(fn *parser.Function, name string, throw parser.Throw) bool {
+	deletedWithin := func(from, to int) bool {
+		return slices.ContainsFunc(fn.Deallocations, func(d parser.Deallocation) bool {
+			return d.VarName == name && d.Line >= from && d.Line <= to
+		})
+	}
+	for _, try := range fn.TryBlocks {
+		if throw.Line < try.Line || throw.Line > try.EndLine || len(try.Catches) == 0 {
+			continue
+		}
+		rethrows := false
+		for _, c := range try.Catches {
+			if deletedWithin(c.Line, c.EndLine) {
+				return true
+			}
+			rethrows = rethrows || slices.ContainsFunc(fn.Throws, func(t parser.Throw) bool {
+				return t.Line >= c.Line && t.Line <= c.EndLine
+			})
+		}
+		last := try.Catches[len(try.Catches)-1].EndLine
+		if !rethrows && deletedWithin(last+1, fn.EndLine) {
+			return true
+		}
+	}
+	return false
+}
+
+// rawFactoryRule reports free functions and static methods returning a
+// fresh new allocation through a raw pointer return type. It is opt-in.
+type rawFactoryRule struct{}
+
+func (rawFactoryRule) ID() string { return RuleRawFactory }
+
+func (rawFactoryRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	// static is only written on the declaration, which may be a separate
+	// entry from the out-of-class definition
+	static := make(map[string]bool)
+	for _, method := range class.Methods {
+		static[method.Name] = static[method.Name] || method.IsStatic
+	}
+	var leaks []parser.Leak
+	for i := range class.Methods {
+		if fn := &class.Methods[i]; static[fn.Name] {
+			if leak := rawFactory(fn, fileOf(class, fn.File)); leak != nil {
+				leak.ClassName = class.Name
+				leaks = append(leaks, *leak)
+			}
+		}
+	}
+	return leaks
+}
+
+func (rawFactoryRule) CheckFunction(fn *parser.Function) []parser.Leak {
+	if leak := rawFactory(fn, fn.File); leak != nil {
+		return []parser.Leak{*leak}
+	}
+	return nil
+}
+
+// rawFactory returns the finding for fn when it has a raw pointer return
+// type and returns the result of new, directly or through a local
+func rawFactory(fn *parser.Function, file string) *parser.Leak {
+	if !fn.ReturnsRaw {
+		return nil
+	}
+	if fn.File != "" {
+		file = fn.File
+	}
+	for _, ret := range fn.Returns {
+		value := strings.Trim(ret.Value, "()")
+		var allocType string
+		var isArray bool
+		if rest, ok := strings.CutPrefix(value, "new "); ok {
+			// return new Widget(args); is not recorded as an allocation
+			allocType = strings.TrimSpace(rest[:strings.IndexFunc(rest+"(", func(r rune) bool { return strings.ContainsRune("([{", r) })])
+			isArray = strings.Contains(rest, "[")
+		} else if !slices.Contains(fn.Params, value) {
+			for _, alloc := range fn.Allocations {
+				// A static local is created once and kept, not handed over
+				if alloc.VarName == value && alloc.Allocator == "" && !alloc.Managed && !alloc.Static {
+					allocType, isArray = alloc.Type, alloc.IsArray
+					break
+				}
+			}
+		}
+		if allocType == "" {
+			continue
+		}
+		if isArray {
+			allocType += "[]"
+		}
+		return &parser.Leak{
+			RuleID:         RuleRawFactory,
+			File:           file,
+			Line:           ret.Line,
+			VarName:        fn.Name,
+			Reason:         fmt.Sprintf("%s returns the raw pointer of a new %s, leaving every caller to delete it", fn.Name, strings.TrimSuffix(allocType, "[]")),
+			Recommendation: fmt.Sprintf("Return std::unique_ptr<%s> (e.g. return std::make_unique<%s>(...);) so the caller owns the object explicitly.", allocType, allocType),
+		}
+	}
+	return nil
+}
+
+// unownedDeleteRule reports members the destructor deletes although no
+// function of the class allocates them
+type unownedDeleteRule struct{}
+
+func (unownedDeleteRule) ID() string { return RuleUnownedDelete }
+
+func (unownedDeleteRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for name, member := range ctx.PointerMembers {
+		dealloc, deleted := ctx.Deallocations[name]
+		// Elements of arrays of pointers, buffers[i] = new T, are not
+		// tracked as allocations
+		if !deleted || member.IsArray || member.Ownership == parser.OwnershipOwns {
+			continue
+		}
+		if _, friend := ctx.FriendAllocations[name]; friend {
+			continue
+		}
+		acquired, source := memberSources(class, name)
+		if acquired {
+			continue
+		}
+		leak := parser.Leak{
+			RuleID:         RuleUnownedDelete,
+			File:           fileOf(class, dealloc.File),
+			Line:           dealloc.Line,
+			Column:         dealloc.Column,
+			ClassName:      class.Name,
+			VarName:        name,
+			Reason:         "deleted in the destructor but never allocated by " + class.Name + "; if the memory is owned elsewhere this is a double free",
+			Recommendation: fmt.Sprintf("If %s adopts the pointer it is given, annotate '%s' with // leakcheck:owns or hold it in a std::unique_ptr; otherwise remove the delete at line %d.", class.Name, name, dealloc.Line),
+		}
+		if source != nil {
+			leak.Related = []parser.RelatedLocation{{File: source.fn.File, Line: source.line, Message: "'" + name + "' is set from '" + source.value + "' here"}}
+		}
+		leaks = append(leaks, leak)
+	}
+	slices.SortFunc(leaks, func(a, b parser.Leak) int { return a.Line - b.Line })
+	return leaks
+}
+
+// memberSource is an assignment to a member of a value the class did not
+// allocate
+type memberSource struct {
+	fn    *parser.Function
+	line  int
+	value string
+}
+
+// memberSources reports whether a function of the class acquires the
+// member: allocates it, directly, through a local or in an initializer
+// list, sets it from a call such as a factory, or from a parameter
+// annotated leakcheck:takes-ownership. Otherwise it returns the first
+// assignment of another value, if any. A swap, or a copy-and-swap
+// assignment operator, only exchanges the member with another instance of
+// the class and is not a source.
+func memberSources(class *parser.Class, name string) (acquired bool, source *memberSource) {
+	for _, fn := range classFunctions(class) {
+		if allocatedIn(fn, name) {
+			return true, nil
+		}
+		exchange := fn.Name == "swap" || fn.CopyAndSwap
+		assigned := func(value string, line int) bool {
+			value = strings.TrimPrefix(value, "this->")
+			if strings.Contains(value, "(") || strings.HasPrefix(value, "new ") || allocatedIn(fn, value) {
+				return true
+			}
+			if i := slices.Index(fn.Params, value); i >= 0 && slices.Contains(fn.TakesOwnership, i) {
+				return true
+			}
+			if source == nil && !exchange && value != "nullptr" && value != "NULL" && value != "0" {
+				source = &memberSource{fn: fn, line: line, value: value}
+			}
+			return false
+		}
+		for _, assign := range fn.Assignments {
+			if strings.TrimPrefix(assign.Target, "this->") == name && assigned(assign.Value, assign.Line) {
+				return true, nil
+			}
+		}
+		for _, alias := range fn.Aliases {
+			if alias.TargetVar == name && assigned(alias.SourceVar, alias.Line) {
+				return true, nil
+			}
+		}
+	}
+	return false, source
+}
+
+// selfAssignmentRule reports assignment operators that delete and
+// reallocate a pointer member without guarding against self-assignment
+type selfAssignmentRule struct{}
+
+func (selfAssignmentRule) ID() string { return RuleSelfAssignment }
+
+func (selfAssignmentRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for i := range class.Methods {
+		fn := &class.Methods[i]
+		if fn.Name != "operator=" || fn.SelfCheck > 0 || slices.Contains(fn.MethodCalls, "swap") {
+			continue
+		}
+		for _, dealloc := range fn.Deallocations {
+			name := strings.TrimPrefix(dealloc.VarName, "this->")
+			if _, member := ctx.PointerMembers[name]; !member || !reallocatedAfter(fn, name, dealloc.Line) {
+				continue
+			}
+			other := "other"
+			if len(fn.Params) > 0 && fn.Params[0] != "" {
+				other = fn.Params[0]
+			}
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleSelfAssignment,
+				File:           fileOf(class, dealloc.File),
+				Line:           dealloc.Line,
+				Column:         dealloc.Column,
+				ClassName:      class.Name,
+				VarName:        name,
+				Reason:         "operator= deletes '" + name + "' and reallocates it without checking for self-assignment; assigning an object to itself copies from freed memory",
+				Recommendation: fmt.Sprintf("Start operator= with: if (this == &%s) return *this; or implement it with copy-and-swap.", other),
+			})
+			break
+		}
+	}
+	return leaks
+}
+
+// reallocatedAfter reports whether fn allocates name again at or after line
+func reallocatedAfter(fn *parser.Function, name string, line int) bool {
+	return slices.ContainsFunc(fn.Allocations, func(alloc parser.Allocation) bool {
+		return strings.TrimPrefix(alloc.VarName, "this->") == name && alloc.Line >= line
+	})
+}
+
+// resetPrefixes are the name prefixes of methods that replace the state of
+// an object, matched case-insensitively
+var resetPrefixes = []string{"clear", "reset", "reload", "reinit"}
+
+// resetLeakRule reports reset-style methods that allocate an owning pointer
+// member without deleting its previous value. Members the constructor
+// allocates are left to reassignmentRule.
+type resetLeakRule struct{}
+
+func (resetLeakRule) ID() string { return RuleResetLeak }
+
+func (resetLeakRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for i := range class.Methods {
+		fn := &class.Methods[i]
+		lower := strings.ToLower(fn.Name)
+		if fn.CopyAndSwap || !slices.ContainsFunc(resetPrefixes, func(prefix string) bool { return strings.HasPrefix(lower, prefix) }) {
+			continue
+		}
+		reported := make(map[string]bool)
+		for _, alloc := range fn.Allocations {
+			name := strings.TrimPrefix(alloc.VarName, "this->")
+			member, isMember := ctx.PointerMembers[name]
+			if !alloc.IsRaw() || !isMember || reported[name] {
+				continue
+			}
+			_, inCtor := ctx.ConstructorAllocations[name]
+			if _, sunk := ctx.Sunk[name]; inCtor || sunk {
+				continue
+			}
+			if !ctx.Released(name) && member.Ownership != parser.OwnershipOwns {
+				continue
+			}
+			if releasedBefore(fn, name, alloc.Line, ctx.Methods) {
+				continue
+			}
+			reported[name] = true
+			typ := alloc.Type
+			if typ == "" {
+				typ = "T"
+			}
+			deleteStmt, smart := "delete "+name, "std::unique_ptr<"+typ+">"
+			if alloc.IsArray {
+				deleteStmt, smart = "delete[] "+name, "std::unique_ptr<"+typ+"[]>"
+			}
+			leaks = append(leaks, parser.Leak{
+				RuleID:         RuleResetLeak,
+				File:           fileOf(class, alloc.File),
+				Line:           alloc.Line,
+				Column:         alloc.Column,
+				ClassName:      class.Name,
+				VarName:        name,
+				Reason:         fn.Name + "() allocates '" + name + "' without deleting its previous value; every call leaks the old object",
+				Recommendation: fmt.Sprintf("Before line %d in %s::%s(), add: %s; or hold '%s' in a %s and assign with reset().", alloc.Line, class.Name, fn.Name, deleteStmt, name, smart),
+			})
+		}
+	}
+	return leaks
+}
+
+// releasedBefore reports whether fn deletes name before line, directly or
+// in a method of the class it calls before line
+func releasedBefore(fn *parser.Function, name string, line int, methods map[string]*parser.Function) bool {
+	for _, dealloc := range fn.Deallocations {
+		if strings.TrimPrefix(dealloc.VarName, "this->") == name && dealloc.Line < line {
+			return true
+		}
+	}
+	for _, call := range fn.Calls {
+		method, ok := methods[call.Name]
+		if !ok || call.Line >= line || method == fn {
+			continue
+		}
+		released := make(map[string]parser.Deallocation)
+		collectDeallocations(method, methods, released, MaxMethodDepth, make(map[string]bool))
+		if _, ok := released[name]; ok {
+			return true
+		}
+		if _, ok := released["this->"+name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// globalLeakRule reports file-scope pointers, static members and static
+// locals allocated with new that no function deletes. The memory lives
+// until exit, which is often deliberate: singletons created on first use
+// and never destroyed are reported with low confidence.
+type globalLeakRule struct{}
+
+func (globalLeakRule) ID() string { return RuleGlobalLeak }
+
+// Check does nothing; globals are checked across the whole program by
+// CheckGlobal
+func (globalLeakRule) Check(*parser.Class, *AnalysisContext) []parser.Leak { return nil }
+
+func (globalLeakRule) CheckGlobal(g *parser.Global, released map[string]bool) []parser.Leak {
+	if released[g.Name] {
+		return nil
+	}
+	var what string
+	switch {
+	case g.Function != "" && g.Class != "":
+		what = "static local '" + g.Name + "' of " + g.Class + "::" + g.Function + "()"
+	case g.Function != "":
+		what = "static local '" + g.Name + "' of " + g.Function + "()"
+	case g.Class != "":
+		what = "static member '" + g.Class + "::" + g.Name + "'"
+	default:
+		what = "global '" + g.Name + "'"
+	}
+	typ := g.Type
+	if typ == "" {
+		typ = "T"
+	}
+	smart := "std::unique_ptr<" + typ + ">"
+	if g.IsArray {
+		smart = "std::unique_ptr<" + typ + "[]>"
+	}
+	leak := parser.Leak{
+		RuleID:         RuleGlobalLeak,
+		File:           g.File,
+		Line:           g.Line,
+		Column:         g.Column,
+		ClassName:      g.Class,
+		VarName:        g.Name,
+		Confidence:     "medium",
+		Reason:         what + " is allocated with new and never deleted; the memory is only reclaimed at exit",
+		Recommendation: fmt.Sprintf("Hold '%s' in a %s or as an object rather than a pointer, or delete it during shutdown.", g.Name, smart),
+	}
+	lower := strings.ToLower(g.Name)
+	if g.Returned || (g.Class != "" && g.Type == g.Class) || strings.Contains(lower, "instance") || strings.Contains(lower, "singleton") {
+		leak.Confidence = "low"
+		leak.Reason = what + " looks like an intentional leaky singleton: created once with new and never deleted"
+		leak.Recommendation = "If it is meant to live until exit, nothing needs to change; otherwise return a function-local static object (static " +
+			typ + " instance; return instance;), which is destroyed at exit."
+	}
+	return []parser.Leak{leak}
+}
+
+// defaultedDestructorRule reports classes that allocate pointer members in
+// the constructor but declare the destructor = default
+type defaultedDestructorRule struct{}
+
+func (defaultedDestructorRule) ID() string { return RuleDefaultedDtor }
+
+func (defaultedDestructorRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	dtor := class.Destructor
+	if dtor == nil || !dtor.Defaulted {
+		return nil
+	}
+
+	var leaks []parser.Leak
+	for _, member := range ctx.PointerMembers {
+		alloc, allocated := ctx.ConstructorAllocations[member.Name]
+		if !allocated || ctx.Released(member.Name) {
+			continue
+		}
+		leaks = append(leaks, parser.Leak{
+			RuleID:         RuleDefaultedDtor,
+			File:           fileOf(class, alloc.File),
+			Line:           alloc.Line,
+			Column:         alloc.Column,
+			ClassName:      class.Name,
+			VarName:        member.Name,
+			Reason:         "allocated with '" + allocatorName(alloc) + "' but the destructor is defaulted and never releases it",
+			Recommendation: fmt.Sprintf("Replace '~%s() = default;' with ~%s() { %s; }, or hold '%s' in a smart pointer so the defaulted destructor frees it.", class.Name, class.Name, ReleaseStatement(alloc, member.Name), member.Name),
+			Related: []parser.RelatedLocation{
+				{File: fileOf(class, dtor.File), Line: dtor.StartLine, Message: "destructor defaulted here"},
+			},
+		})
+	}
+	return leaks
+}
+
+// containerLeakRule reports member containers of raw pointers filled with
+// new whose elements the destructor never deletes
+type containerLeakRule struct{}
+
+func (containerLeakRule) ID() string { return RuleContainerLeak }
+
+func (containerLeakRule) Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak {
+	var leaks []parser.Leak
+	for _, member := range class.Members {
+		if !member.PointerContainer || member.IsStatic {
+			continue
+		}
+		alloc := containerAllocation(class, member.Name)
+		if alloc == nil || containerReleased(class, ctx, member.Name) {
+			continue
+		}
+		reason := "filled with '" + allocatorName(*alloc) + "' but the destructor never deletes its elements"
+		if class.Destructor == nil {
+			reason = "filled with '" + allocatorName(*alloc) + "' but the class has no destructor to delete its elements"
+		}
+		leaks = append(leaks, parser.Leak{
+			RuleID:         RuleContainerLeak,
+			File:           fileOf(class, alloc.File),
+			Line:           alloc.Line,
+			Column:         alloc.Column,
+			ClassName:      class.Name,
+			VarName:        member.Name,
+			Reason:         reason,
+			Severity:       "warning",
+			Recommendation: fmt.Sprintf("In destructor ~%s(), delete every element of '%s', or store std::unique_ptr<%s> in it instead of raw pointers.", class.Name, member.Name, alloc.Type),
+			Related: []parser.RelatedLocation{
+				{File: fileOf(class, class.DefinitionFile), Line: member.Line, Message: "container declared here"},
+			},
+		})
+	}
+	return leaks
+}
+
+// containerAllocation returns the first new stored in the container name by
+// a function of the class
+func containerAllocation(class *parser.Class, name string) *parser.Allocation {
+	for _, fn := range classFunctions(class) {
+		for i := range fn.Allocations {
+			if fn.Allocations[i].Container == name {
+				return &fn.Allocations[i]
+			}
+		}
+	}
+	return nil
+}
+
+// containerReleased reports whether the destructor, or a function it calls,
+// deletes the elements of the container name
+func containerReleased(class *parser.Class, ctx *AnalysisContext, name string) bool {
+	if class.Destructor == nil {
+		return false
+	}
+	for _, fn := range reachableFunctions(class.Destructor, ctx.Methods, MaxMethodDepth) {
+		if slices.ContainsFunc(fn.Deallocations, func(d parser.Deallocation) bool { return d.Container == name }) {
+			return true
+		}
+		if slices.ContainsFunc(fn.Calls, func(call parser.Call) bool {
+			return call.Name == "qDeleteAll" && len(call.Args) > 0 && call.Args[0] == name
+		}) {
+			return true
+		}
+	}
+	return false
+}
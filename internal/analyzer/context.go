@@ -0,0 +1,244 @@
+package analyzer
+
+import (
+	"leakcheck/internal/parser"
+	"slices"
+	"strings"
+)
+
+// AnalysisContext holds what the analyzer knows about a class, computed once
+// and shared by every rule that checks it
+type AnalysisContext struct {
+	// Summaries describe ownership behaviour of external functions
+	Summaries FunctionSummaries
+	// Index answers questions about the other analyzed classes, such as
+	// their bases and subclasses
+	Index *parser.Index
+	// PointerMembers are the raw pointer members by name
+	PointerMembers map[string]parser.Member
+	// SmartMembers are the names of smart pointer members
+	SmartMembers map[string]bool
+	// ConstructorAllocations are raw allocations made by the constructor,
+	// by variable; memory handed to a smart pointer is not included
+	ConstructorAllocations map[string]parser.Allocation
+	// Methods are the class methods by name
+	Methods map[string]*parser.Function
+	// Deallocations are the releases reached from the destructor, following
+	// method calls up to MaxMethodDepth
+	Deallocations map[string]parser.Deallocation
+	// Transferred are members the destructor hands to a parameter annotated
+	// leakcheck:takes-ownership
+	Transferred map[string]bool
+	// Sunk are members any function of the class passes to a function
+	// summarized as taking ownership, such as a configured sink, with the
+	// first such call
+	Sunk map[string]parser.Call
+	// Aliases maps each pointer to the variables aliasing it, in both
+	// directions
+	Aliases map[string][]string
+	// Escapes are pointer members returned by a method, such as
+	// Widget* data() { return data_; }, by member name
+	Escapes map[string]escape
+	// Handoffs are members the class hands to a function of another class
+	// or a free function that deletes or adopts them; only set by
+	// whole-program analysis
+	Handoffs map[string]handoff
+	// FriendReleases are pointer members a friend class or function of the
+	// class deletes through an object, as in delete pool.head_, with the
+	// friend function; FriendAllocations those it allocates
+	FriendReleases    map[string]*parser.Function
+	FriendAllocations map[string]*parser.Function
+
+	program *program
+}
+
+// newAnalysisContext collects the facts the rules need about a class
+func (a *Analyzer) newAnalysisContext(class *parser.Class, index *parser.Index) *AnalysisContext {
+	ctx := &AnalysisContext{
+		Summaries:              a.Summaries,
+		Index:                  index,
+		PointerMembers:         make(map[string]parser.Member),
+		SmartMembers:           make(map[string]bool),
+		ConstructorAllocations: make(map[string]parser.Allocation),
+		Methods:                make(map[string]*parser.Function),
+		Deallocations:          make(map[string]parser.Deallocation),
+		Transferred:            make(map[string]bool),
+		Sunk:                   make(map[string]parser.Call),
+		Aliases:                buildAliasMap(*class),
+		Escapes:                make(map[string]escape),
+		FriendReleases:         make(map[string]*parser.Function),
+		FriendAllocations:      make(map[string]*parser.Function),
+	}
+
+	for _, m := range class.Members {
+		if m.IsPointer {
+			ctx.PointerMembers[m.Name] = m
+		}
+		if m.IsSmartPointer {
+			ctx.SmartMembers[m.Name] = true
+		}
+	}
+
+	if class.Constructor != nil {
+		for _, alloc := range class.Constructor.Allocations {
+			if alloc.IsRaw() && !ctx.SmartMembers[alloc.VarName] {
+				ctx.ConstructorAllocations[alloc.VarName] = alloc
+			}
+		}
+	}
+
+	for i := range class.Methods {
+		ctx.Methods[class.Methods[i].Name] = &class.Methods[i]
+	}
+	ctx.collectEscapes(class)
+	ctx.collectSinks(class)
+	ctx.collectFriends(class, a.functions)
+
+	if class.Destructor != nil {
+		collectDeallocations(class.Destructor, ctx.Methods, ctx.Deallocations, MaxMethodDepth, make(map[string]bool))
+		collectTransfers(class.Destructor, ctx.Methods, ctx.Transferred, MaxMethodDepth, make(map[string]bool))
+	}
+	return ctx
+}
+
+// Released reports whether the destructor releases a variable, directly,
+// through an alias or by transferring it to a new owner, or whether the
+// class hands it to an ownership sink or a friend deletes it
+func (ctx *AnalysisContext) Released(varName string) bool {
+	if _, handed := ctx.Handoffs[varName]; handed {
+		return true
+	}
+	if _, sunk := ctx.Sunk[varName]; sunk {
+		return true
+	}
+	if _, friend := ctx.FriendReleases[varName]; friend {
+		return true
+	}
+	return isVarDeallocated(varName, ctx.Deallocations, ctx.Aliases) || ctx.Transferred[varName]
+}
+
+// ConditionalRelease returns the delete by which the destructor releases a
+// variable when that delete depends on a condition other than a null check,
+// or nil when the release is unconditional
+func (ctx *AnalysisContext) ConditionalRelease(varName string) *parser.Deallocation {
+	if _, handed := ctx.Handoffs[varName]; handed || ctx.Transferred[varName] {
+		return nil
+	}
+	if _, sunk := ctx.Sunk[varName]; sunk {
+		return nil
+	}
+	if _, friend := ctx.FriendReleases[varName]; friend {
+		return nil
+	}
+	var conditional *parser.Deallocation
+	for _, name := range append([]string{varName}, ctx.Aliases[varName]...) {
+		dealloc, ok := ctx.Deallocations[name]
+		if !ok {
+			continue
+		}
+		if !dealloc.Conditional {
+			return nil
+		}
+		if conditional == nil {
+			conditional = &dealloc
+		}
+	}
+	return conditional
+}
+
+// collectFriends records the pointer members that the friend classes and
+// friend functions of the class delete or allocate through an object of the
+// class. Friend classes are looked up in the index and friend functions
+// among their methods or the free functions.
+func (ctx *AnalysisContext) collectFriends(class *parser.Class, functions []parser.Function) {
+	for _, friend := range class.Friends {
+		var fns []*parser.Function
+		var own map[string]bool // the friend class's own members
+		if !friend.Function {
+			if other, ok := ctx.Index.Lookup(friend.Name); ok {
+				fns = classFunctions(other)
+				own = make(map[string]bool)
+				for _, m := range other.Members {
+					own[m.Name] = true
+				}
+			}
+		} else {
+			owner, name := "", friend.Name
+			if i := strings.LastIndex(name, "::"); i >= 0 {
+				owner, name = name[:i], name[i+2:]
+			}
+			if other, ok := ctx.Index.Lookup(owner); ok && owner != "" {
+				fns = slices.DeleteFunc(classFunctions(other), func(fn *parser.Function) bool { return fn.Name != name })
+			} else {
+				for i := range functions {
+					if functions[i].Name == name {
+						fns = append(fns, &functions[i])
+					}
+				}
+			}
+		}
+
+		for _, fn := range fns {
+			for _, dealloc := range fn.Deallocations {
+				i := strings.LastIndexAny(dealloc.VarName, ".>")
+				if i < 0 {
+					continue
+				}
+				member := dealloc.VarName[i+1:]
+				if _, ok := ctx.PointerMembers[member]; ok && ctx.FriendReleases[member] == nil {
+					ctx.FriendReleases[member] = fn
+				}
+			}
+			// Allocations record the member without the object
+			for _, alloc := range fn.Allocations {
+				if _, ok := ctx.PointerMembers[alloc.VarName]; ok && alloc.IsRaw() && !own[alloc.VarName] && ctx.FriendAllocations[alloc.VarName] == nil {
+					ctx.FriendAllocations[alloc.VarName] = fn
+				}
+			}
+		}
+	}
+}
+
+// collectSinks records the pointer members the functions of the class pass,
+// directly or through a local alias, to a function that takes ownership
+func (ctx *AnalysisContext) collectSinks(class *parser.Class) {
+	if len(ctx.Summaries) == 0 {
+		return
+	}
+	for _, fn := range classFunctions(class) {
+		aliases := aliasesIn([]*parser.Function{fn}, class.Members)
+		for _, call := range fn.Calls {
+			for j, arg := range call.Args {
+				member := memberNamed(strings.TrimPrefix(arg, "this->"), aliases, ctx.PointerMembers)
+				if _, seen := ctx.Sunk[member]; member == "" || seen || !ctx.Summaries.TakesOwnership(call.Name, j) {
+					continue
+				}
+				ctx.Sunk[member] = call
+			}
+		}
+	}
+}
+
+// escape is a method handing out a pointer member
+type escape struct {
+	method *parser.Function
+	line   int // of the return statement
+}
+
+// collectEscapes records the pointer members each method returns, directly
+// or through a local alias
+func (ctx *AnalysisContext) collectEscapes(class *parser.Class) {
+	for i := range class.Methods {
+		method := &class.Methods[i]
+		if method == class.MoveAssignment || len(method.Returns) == 0 {
+			continue
+		}
+		aliases := aliasesIn([]*parser.Function{method}, class.Members)
+		for _, ret := range method.Returns {
+			member := memberNamed(ret.Value, aliases, ctx.PointerMembers)
+			if _, seen := ctx.Escapes[member]; member != "" && !seen {
+				ctx.Escapes[member] = escape{method: method, line: ret.Line}
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"leakcheck/internal/charset"
+	"leakcheck/internal/parser"
+	"strings"
+)
+
+// Fingerprint identifies a finding independently of its line number, so it
+// can be matched across runs. Findings produced by Analyze carry a
+// content-based fingerprint; for others one is derived from the rule,
+// class and variable alone.
+func Fingerprint(leak parser.Leak) string {
+	if leak.Fingerprint != "" {
+		return leak.Fingerprint
+	}
+	return computeFingerprint(leak, "")
+}
+
+// computeFingerprint hashes the rule, class, variable and normalized source
+// line of a finding. Line numbers and file paths are left out so the
+// fingerprint survives edits elsewhere in the file and file moves.
+func computeFingerprint(leak parser.Leak, context string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		leak.RuleID, leak.ClassName, leak.VarName, context,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// sourceLines caches file contents by path for fingerprinting
+type sourceLines map[string][]string
+
+// assignFingerprints sets a content-based fingerprint on each finding
+func assignFingerprints(leaks []parser.Leak, sources sourceLines) {
+	for i := range leaks {
+		file := leaks[i].File
+		lines, ok := sources[file]
+		if !ok {
+			if content, _, err := charset.ReadFile(file); err == nil {
+				lines = strings.Split(string(content), "\n")
+			}
+			sources[file] = lines
+		}
+
+		context := ""
+		if line := leaks[i].Line; line >= 1 && line <= len(lines) {
+			context = normalizeCode(lines[line-1])
+		}
+		leaks[i].Fingerprint = computeFingerprint(leaks[i], context)
+	}
+}
+
+// normalizeCode strips line comments and collapses whitespace so formatting
+// changes don't alter fingerprints
+func normalizeCode(line string) string {
+	if i := strings.Index(line, "//"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Join(strings.Fields(line), " ")
+}
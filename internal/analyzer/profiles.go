@@ -0,0 +1,51 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Profiles are built-in function summaries for frameworks whose APIs take
+// ownership of objects passed to them
+var Profiles = map[string][]FunctionSummary{
+	// Qt reparents widgets, layouts and items handed to these calls
+	"qt": {
+		{Name: "addWidget", TakesOwnership: true, Params: []int{0}},
+		{Name: "addLayout", TakesOwnership: true, Params: []int{0}},
+		{Name: "addItem", TakesOwnership: true, Params: []int{0}},
+		{Name: "addTab", TakesOwnership: true, Params: []int{0}},
+		{Name: "addDockWidget", TakesOwnership: true, Params: []int{1}},
+		{Name: "setLayout", TakesOwnership: true, Params: []int{0}},
+		{Name: "setCentralWidget", TakesOwnership: true, Params: []int{0}},
+		{Name: "setMenuBar", TakesOwnership: true, Params: []int{0}},
+		{Name: "setStatusBar", TakesOwnership: true, Params: []int{0}},
+		{Name: "setWidget", TakesOwnership: true, Params: []int{0}},
+	},
+	// wxWidgets windows own their sizers, pages and menus
+	"wxwidgets": {
+		{Name: "SetSizer", TakesOwnership: true, Params: []int{0}},
+		{Name: "AddPage", TakesOwnership: true, Params: []int{0}},
+		{Name: "SetMenuBar", TakesOwnership: true, Params: []int{0}},
+		{Name: "Append", TakesOwnership: true},
+	},
+}
+
+// ProfileNames returns the profile names in sorted order
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddProfile registers the summaries of a built-in framework profile
+func (s FunctionSummaries) AddProfile(name string) error {
+	summaries, ok := Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	s.Add(summaries...)
+	return nil
+}
@@ -0,0 +1,245 @@
+package analyzer
+
+import (
+	"leakcheck/internal/parser"
+	"slices"
+	"strconv"
+)
+
+// program is what whole-program analysis knows about every analyzed
+// function, class methods and free functions alike: which parameters they
+// release or adopt. Calls are resolved by name, since receivers carry no
+// type; a call counts only when every function of that name agrees.
+type program struct {
+	functions map[string][]*parser.Function // by unqualified name
+	members   map[*parser.Function][]parser.Member
+	releases  map[paramKey]*paramRelease
+	visiting  map[paramKey]bool
+	frees     map[string]bool // custom deallocators, e.g. g_free
+	summaries FunctionSummaries
+}
+
+// paramKey identifies one parameter of a function
+type paramKey struct {
+	fn    *parser.Function
+	index int
+}
+
+// paramRelease is where a function releases one of its parameters
+type paramRelease struct {
+	fn     *parser.Function
+	line   int
+	member string // member the parameter is stored in, if not deleted
+	// adopted marks a parameter annotated leakcheck:takes-ownership or
+	// passed to a function summarized as taking ownership
+	adopted bool
+}
+
+// owned reports whether the parameter changes owner rather than being
+// deleted
+func (r *paramRelease) owned() bool {
+	return r.member != "" || r.adopted
+}
+
+// newProgram indexes the functions of the analyzed classes and the free
+// functions added with AddFunctions
+func (a *Analyzer) newProgram() *program {
+	prog := &program{
+		functions: make(map[string][]*parser.Function),
+		members:   make(map[*parser.Function][]parser.Member),
+		releases:  make(map[paramKey]*paramRelease),
+		visiting:  make(map[paramKey]bool),
+		frees:     make(map[string]bool),
+		summaries: a.Summaries,
+	}
+	for _, pair := range a.Allocators {
+		prog.frees[pair.Free] = true
+	}
+	for _, pair := range StringAllocators {
+		prog.frees[pair.Free] = true
+	}
+	for _, pairs := range LanguageAllocators {
+		for _, pair := range pairs {
+			prog.frees[pair.Free] = true
+		}
+	}
+	for i := range a.classes {
+		class := &a.classes[i]
+		for _, fn := range classFunctions(class) {
+			prog.functions[fn.Name] = append(prog.functions[fn.Name], fn)
+			prog.members[fn] = class.Members
+		}
+	}
+	for i := range a.functions {
+		fn := &a.functions[i]
+		prog.functions[fn.Name] = append(prog.functions[fn.Name], fn)
+	}
+	return prog
+}
+
+// callee returns how the functions named name treat the argument at index:
+// the release of the first of them when all release or adopt it, or nil
+func (prog *program) callee(name string, index int) *paramRelease {
+	var release *paramRelease
+	for _, fn := range prog.functions[name] {
+		if index >= len(fn.Params) {
+			continue
+		}
+		r := prog.release(fn, index, MaxMethodDepth)
+		if r == nil {
+			return nil
+		}
+		if release == nil {
+			release = r
+		}
+	}
+	return release
+}
+
+// keeper returns the first function named name that takes an argument at
+// index but neither releases nor adopts it, or nil
+func (prog *program) keeper(name string, index int) *parser.Function {
+	for _, fn := range prog.functions[name] {
+		if index < len(fn.Params) && prog.release(fn, index, MaxMethodDepth) == nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+// release reports where fn releases or adopts its parameter at index:
+// deleting it directly or through an alias, storing it in a member, or
+// passing it on to a function that does
+func (prog *program) release(fn *parser.Function, index, depth int) *paramRelease {
+	key := paramKey{fn, index}
+	if r, ok := prog.releases[key]; ok {
+		return r
+	}
+	if depth <= 0 || prog.visiting[key] || index >= len(fn.Params) || fn.Params[index] == "" {
+		return nil
+	}
+	prog.visiting[key] = true
+	defer delete(prog.visiting, key)
+
+	r := prog.findRelease(fn, index, depth)
+	prog.releases[key] = r
+	return r
+}
+
+func (prog *program) findRelease(fn *parser.Function, index, depth int) *paramRelease {
+	param := fn.Params[index]
+	names := append([]string{param}, aliasesIn([]*parser.Function{fn}, prog.members[fn])[param]...)
+
+	if slices.Contains(fn.TakesOwnership, index) {
+		return &paramRelease{fn: fn, line: fn.StartLine, adopted: true}
+	}
+	for _, dealloc := range fn.Deallocations {
+		if slices.Contains(names, dealloc.VarName) {
+			return &paramRelease{fn: fn, line: dealloc.Line}
+		}
+	}
+	for _, assign := range fn.Assignments {
+		if slices.Contains(names, assign.Value) && slices.ContainsFunc(prog.members[fn], func(m parser.Member) bool {
+			return m.Name == assign.Target && m.IsPointer
+		}) {
+			return &paramRelease{fn: fn, line: assign.Line, member: assign.Target}
+		}
+	}
+	for _, call := range fn.Calls {
+		for j, arg := range call.Args {
+			if !slices.Contains(names, arg) {
+				continue
+			}
+			if prog.frees[call.Name] && j == 0 {
+				return &paramRelease{fn: fn, line: call.Line}
+			}
+			if prog.summaries.TakesOwnership(call.Name, j) {
+				return &paramRelease{fn: fn, line: call.Line, adopted: true}
+			}
+			for _, callee := range prog.functions[call.Name] {
+				if r := prog.release(callee, j, depth-1); r != nil {
+					return r
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// handoff is a member handed to a function of another class or a free
+// function that releases or adopts it
+type handoff struct {
+	call    parser.Call
+	file    string // of the function making the call
+	release *paramRelease
+}
+
+// collectHandoffs finds the members the destructor, or the methods it calls,
+// hands to a function that deletes them, and those any function of the
+// class hands to a new owner
+func (prog *program) collectHandoffs(class *parser.Class, ctx *AnalysisContext) map[string]handoff {
+	handoffs := make(map[string]handoff)
+	var fromDestructor []*parser.Function
+	if class.Destructor != nil {
+		fromDestructor = reachableFunctions(class.Destructor, ctx.Methods, MaxMethodDepth)
+	}
+	for _, fn := range classFunctions(class) {
+		aliases := aliasesIn([]*parser.Function{fn}, class.Members)
+		for _, call := range fn.Calls {
+			for j, arg := range call.Args {
+				member := memberNamed(arg, aliases, ctx.PointerMembers)
+				if member == "" {
+					continue
+				}
+				if _, seen := handoffs[member]; seen {
+					continue
+				}
+				release := prog.callee(call.Name, j)
+				if release == nil || !release.owned() && !slices.Contains(fromDestructor, fn) {
+					continue
+				}
+				handoffs[member] = handoff{call: call, file: fn.File, release: release}
+			}
+		}
+	}
+	return handoffs
+}
+
+// memberNamed returns the pointer member a call argument names, directly or
+// through an alias, or ""
+func memberNamed(arg string, aliases map[string][]string, pointerMembers map[string]parser.Member) string {
+	if _, ok := pointerMembers[arg]; ok {
+		return arg
+	}
+	for _, name := range aliases[arg] {
+		if _, ok := pointerMembers[name]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// describe says what a function does with a parameter handed to it, e.g.
+// "'destroy' deletes it" or "'Registry::adopt' stores it in 'items_'"
+func (r *paramRelease) describe(name string) string {
+	switch {
+	case r.member != "":
+		return "'" + name + "' stores it in '" + r.member + "'"
+	case r.adopted:
+		return "'" + name + "' takes ownership of it"
+	}
+	return "'" + name + "' deletes it"
+}
+
+// location returns the related location of a release
+func (r *paramRelease) location(message string) parser.RelatedLocation {
+	return parser.RelatedLocation{File: r.fn.File, Line: r.line, Message: message}
+}
+
+// paramLabel names a parameter for messages, e.g. 'w' or parameter 2
+func paramLabel(fn *parser.Function, index int) string {
+	if index < len(fn.Params) && fn.Params[index] != "" {
+		return "parameter '" + fn.Params[index] + "'"
+	}
+	return "parameter " + strconv.Itoa(index+1)
+}
@@ -0,0 +1,513 @@
+package analyzer
+
+import (
+	"leakcheck/internal/parser"
+)
+
+// Rule IDs reported on each finding
+const (
+	RuleMissingDelete     = "LC001"
+	RuleArrayMismatch     = "LC002"
+	RuleReassignment      = "LC003"
+	RuleAliasDoubleFree   = "LC004"
+	RuleNoDestructor      = "LC005"
+	RuleMovedFromNotReset = "LC006"
+	RuleOwnershipUnclear  = "LC007"
+	RuleDiscardedNew      = "LC008"
+	RuleDanglingPointer   = "LC009"
+	RuleArithmeticDelete  = "LC010"
+	RuleThrowLeak         = "LC011"
+	RuleRawFactory        = "LC012"
+	RuleUnownedDelete     = "LC013"
+	RuleSelfAssignment    = "LC014"
+	RuleResetLeak         = "LC015"
+	RuleGlobalLeak        = "LC016"
+	RuleDefaultedDtor     = "LC017"
+	RuleContainerLeak     = "LC018"
+)
+
+// RuleInfo describes a detection rule
+type RuleInfo struct {
+	ID       string
+	Name     string
+	Severity string // default severity: "error", "warning" or "info"
+	Summary  string
+	// OptIn rules run only when listed in the rules.enable setting, which
+	// adds them to the default rules
+	OptIn bool
+
+	// Documentation shown by `leakcheck explain`
+	Description    string
+	Example        string   // code that triggers the rule
+	Fixed          string   // the same code without the problem
+	FalsePositives []string // known patterns reported wrongly
+}
+
+// Rules lists every built-in rule in ID order
+var Rules = []RuleInfo{
+	{
+		ID:       RuleMissingDelete,
+		Name:     "missing-delete",
+		Severity: "error",
+		Summary:  "Pointer allocated in the constructor is not deleted in the destructor",
+		Description: "A raw pointer member receives memory from new (or a configured allocator) in the constructor, " +
+			"but neither the destructor nor any method it calls, up to five levels deep, releases it. " +
+			"The memory leaks every time an object is destroyed. " +
+			"Members a friend class or friend function deletes through an object, as in delete pool.head_, count as released. " +
+			"A delete the destructor only reaches under a condition other than a null check, such as if (owns_), is reported as a warning.",
+		Example: `class Cache {
+    int* slots;
+public:
+    Cache() { slots = new int[64]; }
+    ~Cache() {}
+};`,
+		Fixed: `class Cache {
+    int* slots;
+public:
+    Cache() { slots = new int[64]; }
+    ~Cache() { delete[] slots; }
+};`,
+		FalsePositives: []string{
+			"The member is released by a function outside the class, such as a pool or a registry.",
+			"Ownership is handed to another object in the destructor through a call the analyzer cannot see.",
+			"A conditional delete guarded by a flag that records whether the object owns the memory.",
+		},
+	},
+	{
+		ID:       RuleArrayMismatch,
+		Name:     "array-mismatch",
+		Severity: "error",
+		Summary:  "new[] released with delete, new released with delete[], or free and delete mixed up",
+		Description: "Memory from new[] must be released with delete[], and memory from new with delete. " +
+			"Mixing the two is undefined behavior: destructors of array elements are skipped and the heap can be corrupted. " +
+			"new[] released with delete is an error; new released with delete[] is reported as a warning. " +
+			"Memory from a C allocator such as strdup or malloc released with delete, or memory from new passed to free, is an error too.",
+		Example: `Buffer() { data = new char[256]; }
+~Buffer() { delete data; }`,
+		Fixed: `Buffer() { data = new char[256]; }
+~Buffer() { delete[] data; }`,
+		FalsePositives: []string{
+			"The pointer is reassigned between allocation and release to memory of the other kind.",
+		},
+	},
+	{
+		ID:       RuleReassignment,
+		Name:     "reassignment-leak",
+		Severity: "warning",
+		Summary:  "Pointer reassigned with new without deleting the previous allocation",
+		Description: "A method assigns the result of new to a pointer member that the constructor already allocated, " +
+			"without deleting the old value first in the same method. The previous object becomes unreachable. " +
+			"Copy-and-swap assignment operators, taking the class by value and calling swap, are not checked.",
+		Example: `void Parser::reset() {
+    buffer = new char[1024];
+}`,
+		Fixed: `void Parser::reset() {
+    delete[] buffer;
+    buffer = new char[1024];
+}`,
+		FalsePositives: []string{
+			"The old value is released by a helper method called before the assignment.",
+			"The old value was handed to another owner earlier in the method.",
+		},
+	},
+	{
+		ID:       RuleAliasDoubleFree,
+		Name:     "alias-double-free",
+		Severity: "error",
+		Summary:  "Pointer and its alias are both deleted",
+		Description: "A pointer member is copied to another variable, directly or through a chain of copies, and both are deleted " +
+			"in the same method or by the destructor and the methods it calls. " +
+			"They point to the same memory, so the second delete is a double free.",
+		Example: `void Owner::shutdown() {
+    Node* tmp = head;
+    delete tmp;
+    delete head;
+}`,
+		Fixed: `void Owner::shutdown() {
+    delete head;
+    head = nullptr;
+}`,
+		FalsePositives: []string{
+			"The alias is reassigned to different memory before it is deleted.",
+			"With --whole-program, calls are matched by function name, so a call may be attributed to another function of the same name that deletes its parameter.",
+		},
+	},
+	{
+		ID:       RuleNoDestructor,
+		Name:     "no-destructor",
+		Severity: "error",
+		Summary:  "Class allocates memory but has no destructor",
+		Description: "The constructor allocates memory for a raw pointer member but the class declares no destructor, " +
+			"so the compiler-generated one never releases it.",
+		Example: `class Image {
+    unsigned char* pixels;
+public:
+    Image() { pixels = new unsigned char[4096]; }
+};`,
+		Fixed: `class Image {
+    std::unique_ptr<unsigned char[]> pixels;
+public:
+    Image() : pixels(new unsigned char[4096]) {}
+};`,
+		FalsePositives: []string{
+			"The destructor is defined in a file that was not scanned.",
+		},
+	},
+	{
+		ID:       RuleMovedFromNotReset,
+		Name:     "moved-from-not-reset",
+		Severity: "error",
+		Summary:  "Move operation transfers a pointer without nulling the source",
+		Description: "A move constructor or move assignment copies a pointer member out of the source object " +
+			"but leaves the source pointing at the same memory. Both destructors then delete it.",
+		Example: `Buffer(Buffer&& other) : data(other.data) {}`,
+		Fixed:   `Buffer(Buffer&& other) : data(std::exchange(other.data, nullptr)) {}`,
+		FalsePositives: []string{
+			"The source is reset by a helper method such as other.release().",
+		},
+	},
+	{
+		ID:       RuleOwnershipUnclear,
+		Name:     "ownership-unclear",
+		Severity: "info",
+		Summary:  "New allocation passed directly to a call whose ownership semantics are unknown",
+		Description: "The result of new is passed straight to a function. If the function does not take ownership, " +
+			"the object leaks. The finding is informational and does not fail the run.",
+		Example: `void Panel::build() {
+    registerWidget(new Widget());
+}`,
+		Fixed: `void Panel::build() {
+    registerWidget(std::make_unique<Widget>());
+}`,
+		FalsePositives: []string{
+			"The callee takes ownership: annotate its parameter with leakcheck:takes-ownership, " +
+				"list it in a summaries file, or enable a framework profile such as qt.",
+		},
+	},
+	{
+		ID:       RuleDiscardedNew,
+		Name:     "discarded-new",
+		Severity: "error",
+		Summary:  "Result of a new expression statement is never stored",
+		Description: "A statement consists only of a new expression. Nothing holds the returned pointer, " +
+			"so the object can never be deleted.",
+		Example: `void App::start() {
+    new Logger("app.log");
+}`,
+		Fixed: `void App::start() {
+    logger = std::make_unique<Logger>("app.log");
+}`,
+		FalsePositives: []string{
+			"The constructor registers the object with a parent that owns it (for example Qt widgets created with a parent).",
+		},
+	},
+	{
+		ID:       RuleDanglingPointer,
+		Name:     "dangling-after-delete",
+		Severity: "info",
+		Summary:  "Member deleted outside the destructor is not reset to nullptr",
+		Description: "A method other than the destructor deletes a pointer member and does not assign it afterwards. " +
+			"The member keeps the address of freed memory, so a later use, or a second delete from the destructor, is undefined behavior. " +
+			"The rule is opt-in: list it in rules.enable to run it. --fix adds the missing null assignment.",
+		Example: `void Parser::reset() {
+    delete buffer_;
+}`,
+		Fixed: `void Parser::reset() {
+    delete buffer_;
+    buffer_ = nullptr;
+}`,
+		FalsePositives: []string{
+			"The object is never used again after the call, e.g. a close() that is always followed by destruction.",
+			"The member is reset by a helper method called after the delete.",
+		},
+		OptIn: true,
+	},
+	{
+		ID:       RuleArithmeticDelete,
+		Name:     "arithmetic-before-delete",
+		Severity: "error",
+		Summary:  "Pointer moved with ++, --, += or -= before it is deleted",
+		Description: "An owning pointer, a pointer member or a local holding the result of new, is moved with pointer arithmetic " +
+			"and later deleted in the same function without being reassigned in between. " +
+			"delete must receive the exact address new returned, so this is undefined behavior and usually corrupts the heap.",
+		Example: `void Reader::consume() {
+    while (*buffer_ != '\0') buffer_++;
+    delete[] buffer_;
+}`,
+		Fixed: `void Reader::consume() {
+    char* cursor = buffer_;
+    while (*cursor != '\0') cursor++;
+    delete[] buffer_;
+}`,
+		FalsePositives: []string{
+			"The offset is undone before the delete, e.g. p++ followed later by p--.",
+			"The arithmetic is in a branch that returns before reaching the delete.",
+		},
+	},
+	{
+		ID:       RuleThrowLeak,
+		Name:     "leak-on-throw",
+		Severity: "warning",
+		Summary:  "Raw allocation still owned when an exception is thrown",
+		Description: "A method stores the result of new in a local raw pointer, or a constructor stores it in a member, " +
+			"and a throw follows before the pointer is deleted, stored elsewhere or passed to a call. " +
+			"The exception skips the cleanup, and a constructor that throws never runs its destructor, so the memory leaks. " +
+			"A catch handler around the throw that deletes the pointer counts as cleanup.",
+		Example: `void Loader::load(const std::string& path) {
+    Image* img = new Image(path);
+    if (!img->valid()) throw LoadError(path);
+    images_.push_back(img);
+}`,
+		Fixed: `void Loader::load(const std::string& path) {
+    auto img = std::make_unique<Image>(path);
+    if (!img->valid()) throw LoadError(path);
+    images_.push_back(img.release());
+}`,
+		FalsePositives: []string{
+			"The throw is in a branch the allocation never reaches, e.g. the other side of an if.",
+			"A function-try-block or a caller cleans up through another pointer to the allocation.",
+		},
+	},
+	{
+		ID:       RuleRawFactory,
+		Name:     "raw-factory",
+		Severity: "warning",
+		Summary:  "Factory function returns a fresh new allocation as a raw pointer",
+		Description: "A free function or static method with a raw pointer return type returns the result of new, directly or through a local. " +
+			"Every caller becomes responsible for a delete the signature does not ask for, which makes factories the main source of caller-side leaks. " +
+			"Returning std::unique_ptr states the transfer of ownership and releases the object automatically. " +
+			"The rule is opt-in: list it in rules.enable to run it.",
+		Example: `Widget* makeWidget(const Config& cfg) {
+    Widget* w = new Widget(cfg);
+    w->init();
+    return w;
+}`,
+		Fixed: `std::unique_ptr<Widget> makeWidget(const Config& cfg) {
+    auto w = std::make_unique<Widget>(cfg);
+    w->init();
+    return w;
+}`,
+		FalsePositives: []string{
+			"The function is part of a C API or plugin interface whose callers release the object with a matching destroy function.",
+			"The returned object registers itself with an owner, such as a Qt parent, before the function returns.",
+		},
+		OptIn: true,
+	},
+	{
+		ID:       RuleUnownedDelete,
+		Name:     "unowned-delete",
+		Severity: "warning",
+		Summary:  "Destructor deletes a member the class never allocates",
+		Description: "The destructor, or a method it calls, deletes a raw pointer member that no function of the class allocates: " +
+			"the member is only ever set from a parameter or another object, or not at all. " +
+			"The memory is likely owned elsewhere, so the delete is a latent double free, or a delete of memory that was never allocated with new. " +
+			"Members set from a call, such as a factory, or from a local holding the result of new count as allocated, " +
+			"as do members a friend class or friend function allocates.",
+		Example: `class View {
+    Model* model_;
+public:
+    explicit View(Model* model) : model_(model) {}
+    ~View() { delete model_; }
+};`,
+		Fixed: `class View {
+    Model* model_; // leakcheck:non-owning
+public:
+    explicit View(Model* model) : model_(model) {}
+    ~View() {}
+};`,
+		FalsePositives: []string{
+			"The class adopts the pointer it is given; annotate the member leakcheck:owns, or the parameter leakcheck:takes-ownership, to say so.",
+			"The member is allocated by a derived class.",
+		},
+	},
+	{
+		ID:       RuleSelfAssignment,
+		Name:     "self-assignment",
+		Severity: "warning",
+		Summary:  "operator= deletes and reallocates a member without a self-assignment guard",
+		Description: "An assignment operator deletes a pointer member and then allocates it again, " +
+			"with no if (this != &other) check and no copy-and-swap. " +
+			"Assigning an object to itself, which happens through aliases such as a[i] = a[j], deletes the memory before copying from it, " +
+			"so the copy reads freed memory.",
+		Example: `Buffer& Buffer::operator=(const Buffer& other) {
+    delete[] data_;
+    data_ = new char[other.size_];
+    std::memcpy(data_, other.data_, other.size_);
+    return *this;
+}`,
+		Fixed: `Buffer& Buffer::operator=(const Buffer& other) {
+    if (this == &other) return *this;
+    delete[] data_;
+    data_ = new char[other.size_];
+    std::memcpy(data_, other.data_, other.size_);
+    return *this;
+}`,
+		FalsePositives: []string{
+			"The guard is in a helper called before the delete, or compares the objects with a custom identity check.",
+		},
+	},
+	{
+		ID:       RuleResetLeak,
+		Name:     "reset-leak",
+		Severity: "warning",
+		Summary:  "clear/reset/reload/reinit method allocates an owning member without deleting its previous value",
+		Description: "A method named like clear, reset, reload or reinit assigns the result of new to a pointer member the destructor releases, " +
+			"without deleting the old value first, in the method or in a helper it calls before. " +
+			"Every call leaks the previous object. LC003 covers members the constructor allocates; this rule covers members set up later, " +
+			"such as by an init or load method.",
+		Example: `void Cache::reload() {
+    table_ = new Table(path_);
+}`,
+		Fixed: `void Cache::reload() {
+    delete table_;
+    table_ = new Table(path_);
+}`,
+		FalsePositives: []string{
+			"The method is only ever called while the member is null, such as once after construction.",
+			"The previous value is handed to another owner before the method runs.",
+		},
+	},
+	{
+		ID:       RuleGlobalLeak,
+		Name:     "global-leak",
+		Severity: "info",
+		Summary:  "File-scope, static member or static local pointer allocated with new is never deleted",
+		Description: "A raw pointer with static storage duration, a file-scope variable, a static data member or a static local, " +
+			"is initialized or lazily assigned with new, and no analyzed function deletes it. The memory is only reclaimed when the process exits, " +
+			"which leak checkers such as Valgrind report and which skips the object's destructor. " +
+			"Pointers that look like intentional leaky singletons, a static local its function returns, a static member holding its own class " +
+			"or a name containing instance or singleton, are reported with low confidence and say so. " +
+			"The rule is opt-in: list it in rules.enable to run it.",
+		Example: `static Config* g_config = new Config("app.ini");`,
+		Fixed:   `static std::unique_ptr<Config> g_config = std::make_unique<Config>("app.ini");`,
+		FalsePositives: []string{
+			"The pointer is deleted through another name, e.g. a cleanup function passed to atexit that deletes a copy of it.",
+			"The singleton is deliberately never destroyed, to avoid destruction order problems at exit.",
+		},
+		OptIn: true,
+	},
+	{
+		ID:       RuleDefaultedDtor,
+		Name:     "defaulted-destructor",
+		Severity: "error",
+		Summary:  "Class allocates memory but its destructor is defaulted",
+		Description: "The constructor allocates memory for a raw pointer member but the destructor is declared = default, " +
+			"in the class or in a source file, so it destroys the pointer without releasing what it points to. " +
+			"LC005 does not apply because a destructor is declared, and LC001 is not reported for the same member.",
+		Example: `class Buffer {
+    char* data_;
+public:
+    Buffer() { data_ = new char[256]; }
+    ~Buffer() = default;
+};`,
+		Fixed: `class Buffer {
+    char* data_;
+public:
+    Buffer() { data_ = new char[256]; }
+    ~Buffer() { delete[] data_; }
+};`,
+		FalsePositives: []string{
+			"The memory is released by another owner that holds a copy of the pointer.",
+		},
+	},
+	{
+		ID:       RuleContainerLeak,
+		Name:     "pointer-container-leak",
+		Severity: "warning",
+		Summary:  "Container of raw pointers is filled with new but its elements are never deleted",
+		Description: "A member container of raw pointers, such as std::vector<Widget*> or std::map<std::string, Widget*>, " +
+			"is filled with new in a function of the class, but neither the destructor nor a function it calls deletes its elements, " +
+			"in a loop over the container, by index, or with qDeleteAll. Destroying the container frees only the pointers.",
+		Example: `class Registry {
+    std::map<std::string, Widget*> widgets_;
+public:
+    void add(const std::string& key) { widgets_[key] = new Widget; }
+    ~Registry() {}
+};`,
+		Fixed: `class Registry {
+    std::map<std::string, std::unique_ptr<Widget>> widgets_;
+public:
+    void add(const std::string& key) { widgets_[key] = std::make_unique<Widget>(); }
+};`,
+		FalsePositives: []string{
+			"The elements are deleted through a helper the destructor calls by a name the parser cannot resolve, such as std::for_each with a lambda.",
+			"The container does not own its elements; annotate the member with leakcheck:non-owning.",
+		},
+	},
+}
+
+// LookupRule returns the rule with the given ID
+func LookupRule(id string) (RuleInfo, bool) {
+	for _, r := range Rules {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return RuleInfo{}, false
+}
+
+// RuleEnabled reports whether a rule runs, given the rule IDs disabled by
+// configuration: rules that are not listed run unless they are opt-in
+func RuleEnabled(id string, disabled map[string]bool) bool {
+	if off, ok := disabled[id]; ok {
+		return !off
+	}
+	info, _ := LookupRule(id)
+	return !info.OptIn
+}
+
+// Rule is a detection rule run against every analyzed class. Findings that
+// leave RuleID or Severity empty get the rule's ID and default severity.
+type Rule interface {
+	ID() string
+	Check(class *parser.Class, ctx *AnalysisContext) []parser.Leak
+}
+
+// FunctionRule is implemented by rules that also check free functions,
+// which belong to no class. Their findings leave ClassName empty.
+type FunctionRule interface {
+	CheckFunction(fn *parser.Function) []parser.Leak
+}
+
+// GlobalRule is implemented by rules that check raw pointers with static
+// storage duration: file-scope variables, static members and static
+// locals. released holds the names any analyzed function deletes.
+type GlobalRule interface {
+	CheckGlobal(g *parser.Global, released map[string]bool) []parser.Leak
+}
+
+// checks are the rules run by Analyze: the built-in ones followed by any
+// added with Register
+var checks = builtinChecks
+
+// Register adds a project-specific rule, described by info, to every
+// analyzer. It is meant to be called from an init function and panics if
+// the rule's ID is empty or already taken.
+func Register(rule Rule, info RuleInfo) {
+	id := rule.ID()
+	if id == "" {
+		panic("analyzer: Register called with an empty rule ID")
+	}
+	if _, dup := LookupRule(id); dup {
+		panic("analyzer: Register called twice for rule " + id)
+	}
+	info.ID = id
+	Rules = append(Rules, info)
+	checks = append(checks, rule)
+}
+
+// withRuleDefaults fills in the rule ID and default severity on findings
+// that don't set them
+func withRuleDefaults(rule Rule, leaks []parser.Leak) []parser.Leak {
+	for i := range leaks {
+		if leaks[i].RuleID == "" {
+			leaks[i].RuleID = rule.ID()
+		}
+		if leaks[i].Severity == "" {
+			if info, ok := LookupRule(leaks[i].RuleID); ok {
+				leaks[i].Severity = info.Severity
+			}
+		}
+	}
+	return leaks
+}
@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FunctionSummary describes how a function outside the analyzed classes
+// treats the pointers passed to it
+type FunctionSummary struct {
+	Name           string `json:"name" yaml:"name"` // plain or qualified, e.g. registerWidget or Registry::add
+	TakesOwnership bool   `json:"takes_ownership" yaml:"takes_ownership"`
+	Params         []int  `json:"params,omitempty" yaml:"params"` // adopted parameter indices; empty means all
+}
+
+// FunctionSummaries indexes summaries by unqualified function name
+type FunctionSummaries map[string][]FunctionSummary
+
+// LoadFunctionSummaries reads a JSON summaries file:
+//
+//	{"functions": [{"name": "registerWidget", "takes_ownership": true}]}
+func LoadFunctionSummaries(path string) (FunctionSummaries, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Functions []FunctionSummary `json:"functions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	summaries := make(FunctionSummaries)
+	summaries.Add(file.Functions...)
+	return summaries, nil
+}
+
+// Add registers summaries
+func (s FunctionSummaries) Add(summaries ...FunctionSummary) {
+	for _, summary := range summaries {
+		name := summary.Name
+		if i := strings.LastIndex(name, "::"); i >= 0 {
+			name = name[i+2:]
+		}
+		s[name] = append(s[name], summary)
+	}
+}
+
+// TakesOwnership reports whether the named function adopts the argument at index
+func (s FunctionSummaries) TakesOwnership(name string, index int) bool {
+	for _, summary := range s[name] {
+		if !summary.TakesOwnership {
+			continue
+		}
+		if len(summary.Params) == 0 {
+			return true
+		}
+		for _, p := range summary.Params {
+			if p == index {
+				return true
+			}
+		}
+	}
+	return false
+}
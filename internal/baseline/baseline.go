@@ -0,0 +1,114 @@
+package baseline
+
+import (
+	"encoding/json"
+	"fmt"
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version is the baseline file format version
+const Version = 1
+
+// Entry records one known finding
+type Entry struct {
+	Fingerprint string `json:"fingerprint"`
+	RuleID      string `json:"rule"`
+	File        string `json:"file"`
+	ClassName   string `json:"class"`
+	VarName     string `json:"variable"`
+}
+
+// Baseline is a snapshot of accepted findings
+type Baseline struct {
+	Version  int     `json:"version"`
+	Findings []Entry `json:"findings"`
+}
+
+// New creates a baseline from the given findings, recording file paths
+// relative to baseDir when possible
+func New(leaks []parser.Leak, baseDir string) *Baseline {
+	b := &Baseline{Version: Version, Findings: []Entry{}}
+	for _, leak := range leaks {
+		file := leak.File
+		if rel, err := filepath.Rel(baseDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = filepath.ToSlash(rel)
+		}
+		b.Findings = append(b.Findings, Entry{
+			Fingerprint: analyzer.Fingerprint(leak),
+			RuleID:      leak.RuleID,
+			File:        file,
+			ClassName:   leak.ClassName,
+			VarName:     leak.VarName,
+		})
+	}
+	return b
+}
+
+// Load reads a baseline file
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if b.Version != Version {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d", path, b.Version)
+	}
+	return &b, nil
+}
+
+// Write saves the baseline as JSON
+func (b *Baseline) Write(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Filter removes findings recorded in the baseline and returns the new ones
+// along with the number suppressed. A fingerprint recorded n times
+// suppresses at most n findings, so additional copies still count as new.
+func (b *Baseline) Filter(leaks []parser.Leak) ([]parser.Leak, int) {
+	known := b.Matcher()
+	var fresh []parser.Leak
+	suppressed := 0
+	for _, leak := range leaks {
+		if known(leak) {
+			suppressed++
+			continue
+		}
+		fresh = append(fresh, leak)
+	}
+	return fresh, suppressed
+}
+
+// Matcher returns a function reporting whether a finding is recorded in the
+// baseline, for filtering findings one at a time. Each recorded entry
+// matches at most once.
+func (b *Baseline) Matcher() func(parser.Leak) bool {
+	known := make(map[string]int)
+	for _, e := range b.Findings {
+		known[e.Fingerprint]++
+	}
+	return func(leak parser.Leak) bool {
+		fp := analyzer.Fingerprint(leak)
+		if known[fp] > 0 {
+			known[fp]--
+			return true
+		}
+		return false
+	}
+}
@@ -0,0 +1,194 @@
+package charset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding names a detected source encoding
+type Encoding string
+
+const (
+	UTF8    Encoding = "UTF-8"
+	UTF8BOM Encoding = "UTF-8 with BOM"
+	UTF16LE Encoding = "UTF-16LE"
+	UTF16BE Encoding = "UTF-16BE"
+	Latin1  Encoding = "ISO-8859-1"
+)
+
+// SniffSize is how much of the start of a file Detect looks at
+const SniffSize = 8 << 10
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// Detect guesses the encoding of a file from its first bytes: a byte order
+// mark, UTF-16 without one (ASCII text with every other byte zero), or
+// Latin-1 when the bytes are not valid UTF-8
+func Detect(head []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		return UTF8BOM
+	case bytes.HasPrefix(head, bomUTF16LE):
+		return UTF16LE
+	case bytes.HasPrefix(head, bomUTF16BE):
+		return UTF16BE
+	}
+	if enc, ok := detectUTF16(head); ok {
+		return enc
+	}
+	if !validUTF8(head) {
+		return Latin1
+	}
+	return UTF8
+}
+
+// IsUTF16 reports whether enc is one of the UTF-16 encodings, whose files
+// contain zero bytes without being binary
+func IsUTF16(enc Encoding) bool {
+	return enc == UTF16LE || enc == UTF16BE
+}
+
+// Transcoded reports whether text in enc is rewritten when decoded, so
+// offsets into the decoded text do not match the file's bytes
+func Transcoded(enc Encoding) bool {
+	return enc != UTF8 && enc != UTF8BOM
+}
+
+// detectUTF16 recognizes UTF-16 without a byte order mark by the zero
+// high bytes of ASCII characters
+func detectUTF16(head []byte) (Encoding, bool) {
+	pairs := min(len(head), 1024) / 2
+	if pairs < 2 {
+		return "", false
+	}
+	evenZeros, oddZeros := 0, 0
+	for i := range pairs {
+		if head[2*i] == 0 {
+			evenZeros++
+		}
+		if head[2*i+1] == 0 {
+			oddZeros++
+		}
+	}
+	switch {
+	case oddZeros > pairs*3/4 && evenZeros == 0:
+		return UTF16LE, true
+	case evenZeros > pairs*3/4 && oddZeros == 0:
+		return UTF16BE, true
+	}
+	return "", false
+}
+
+// validUTF8 is utf8.Valid allowing head to end in the middle of a rune
+func validUTF8(head []byte) bool {
+	for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				head = head[:i]
+			}
+			break
+		}
+	}
+	return utf8.Valid(head)
+}
+
+// NewReader returns a buffered reader producing the UTF-8 text of r,
+// without a byte order mark, and the encoding detected
+func NewReader(r io.Reader) (*bufio.Reader, Encoding) {
+	br := bufio.NewReaderSize(r, SniffSize)
+	head, _ := br.Peek(SniffSize) // shorter at the end of input
+	enc := Detect(head)
+	switch enc {
+	case UTF8BOM:
+		br.Discard(len(bomUTF8))
+	case UTF16LE, UTF16BE:
+		if bytes.HasPrefix(head, bomUTF16LE) || bytes.HasPrefix(head, bomUTF16BE) {
+			br.Discard(2)
+		}
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == UTF16BE {
+			order = binary.BigEndian
+		}
+		return bufio.NewReader(&decoder{next: utf16Rune(br, order)}), enc
+	case Latin1:
+		return bufio.NewReader(&decoder{next: func() (rune, error) {
+			b, err := br.ReadByte()
+			return rune(b), err
+		}}), enc
+	}
+	return br, enc
+}
+
+// ReadFile reads a file as UTF-8 text, transcoding it when necessary
+func ReadFile(path string) ([]byte, Encoding, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	r, enc := NewReader(f)
+	data, err := io.ReadAll(r)
+	return data, enc, err
+}
+
+// utf16Rune returns a function reading the runes of UTF-16 text. A
+// trailing odd byte is dropped and unpaired surrogates decode to U+FFFD.
+func utf16Rune(r *bufio.Reader, order binary.ByteOrder) func() (rune, error) {
+	var unit [2]byte
+	read := func() (rune, error) {
+		if _, err := io.ReadFull(r, unit[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		return rune(order.Uint16(unit[:])), nil
+	}
+	return func() (rune, error) {
+		r1, err := read()
+		if err != nil || !utf16.IsSurrogate(r1) {
+			return r1, err
+		}
+		r2, err := read()
+		if err != nil {
+			return utf8.RuneError, nil
+		}
+		return utf16.DecodeRune(r1, r2), nil
+	}
+}
+
+// decoder encodes the runes produced by next as UTF-8
+type decoder struct {
+	next    func() (rune, error)
+	buf     [utf8.UTFMax]byte
+	pending []byte
+}
+
+func (d *decoder) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(d.pending) == 0 {
+			r, err := d.next()
+			if err != nil {
+				if n > 0 {
+					return n, nil
+				}
+				return 0, err
+			}
+			d.pending = utf8.AppendRune(d.buf[:0], r)
+		}
+		copied := copy(p[n:], d.pending)
+		d.pending = d.pending[copied:]
+		n += copied
+	}
+	return n, nil
+}
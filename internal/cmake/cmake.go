@@ -0,0 +1,240 @@
+package cmake
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"leakcheck/internal/parser"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Project is what a configured CMake build directory says about the
+// sources it compiles
+type Project struct {
+	// SourceDir is the top-level source directory (CMAKE_HOME_DIRECTORY),
+	// or "" when the build directory has no CMakeCache.txt
+	SourceDir string
+	// Files are the compiled sources followed by the headers they include
+	// from the source directory, as absolute paths
+	Files []string
+	// Flags are the include directories and definitions of each file; a
+	// header gets those of the first source including it
+	Flags map[string]*parser.BuildFlags
+}
+
+// compileCommand is an entry of compile_commands.json
+type compileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Command   string   `json:"command"`
+	Arguments []string `json:"arguments"`
+}
+
+// includePattern matches #include lines, capturing the delimiter and name
+var includePattern = regexp.MustCompile(`^\s*#\s*include\s*([<"])([^>"]+)[>"]`)
+
+// Load reads compile_commands.json from a build directory configured with
+// CMAKE_EXPORT_COMPILE_COMMANDS=ON, and CMakeCache.txt for the source
+// directory. Headers are found by following the #include lines of the
+// sources through their include directories; those outside the source
+// directory, such as system and fetched dependency headers, are left out.
+func Load(buildDir string) (*Project, error) {
+	buildDir, err := filepath.Abs(buildDir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(buildDir, "compile_commands.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s has no compile_commands.json; configure it with -DCMAKE_EXPORT_COMPILE_COMMANDS=ON", buildDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var commands []compileCommand
+	if err := json.Unmarshal(data, &commands); err != nil {
+		return nil, fmt.Errorf("compile_commands.json: %w", err)
+	}
+
+	project := &Project{
+		SourceDir: sourceDir(buildDir),
+		Flags:     make(map[string]*parser.BuildFlags),
+	}
+	var headers []string
+	for _, cmd := range commands {
+		file := absolute(cmd.Directory, cmd.File)
+		if _, seen := project.Flags[file]; seen || !project.inSource(file) {
+			continue
+		}
+		args := cmd.Arguments
+		if len(args) == 0 {
+			args = splitCommand(cmd.Command)
+		}
+		flags := buildFlags(cmd.Directory, args)
+		project.Files = append(project.Files, file)
+		project.Flags[file] = flags
+		headers = project.followIncludes(file, flags, headers)
+	}
+	project.Files = append(project.Files, headers...)
+	return project, nil
+}
+
+// followIncludes appends the headers file includes, directly or through
+// other headers, that are not known yet
+func (p *Project) followIncludes(file string, flags *parser.BuildFlags, headers []string) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return headers
+	}
+	var found []string
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		m := includePattern.FindStringSubmatch(lines.Text())
+		if m == nil {
+			continue
+		}
+		dirs := flags.IncludeDirs
+		if m[1] == `"` {
+			dirs = append([]string{filepath.Dir(file)}, dirs...)
+		}
+		for _, dir := range dirs {
+			header := filepath.Join(dir, m[2])
+			if info, err := os.Stat(header); err == nil && !info.IsDir() {
+				if _, seen := p.Flags[header]; !seen && p.inSource(header) {
+					p.Flags[header] = flags
+					found = append(found, header)
+				}
+				break
+			}
+		}
+	}
+	f.Close()
+
+	headers = append(headers, found...)
+	for _, header := range found {
+		headers = p.followIncludes(header, flags, headers)
+	}
+	return headers
+}
+
+// inSource reports whether file lies in the source directory, when known
+func (p *Project) inSource(file string) bool {
+	if p.SourceDir == "" {
+		return true
+	}
+	rel, err := filepath.Rel(p.SourceDir, file)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// sourceDir reads CMAKE_HOME_DIRECTORY from the build directory's cache
+func sourceDir(buildDir string) string {
+	f, err := os.Open(filepath.Join(buildDir, "CMakeCache.txt"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		if value, ok := strings.CutPrefix(lines.Text(), "CMAKE_HOME_DIRECTORY:INTERNAL="); ok {
+			return filepath.Clean(value)
+		}
+	}
+	return ""
+}
+
+// buildFlags extracts include directories and macro definitions from a
+// compiler command line, in GCC/Clang or MSVC syntax. System include
+// directories are left out: their headers are not worth reading.
+func buildFlags(dir string, args []string) *parser.BuildFlags {
+	flags := &parser.BuildFlags{Defines: make(map[string]string)}
+	msvc := false
+	if len(args) > 0 {
+		compiler := strings.ToLower(strings.TrimSuffix(filepath.Base(filepath.FromSlash(args[0])), ".exe"))
+		msvc = compiler == "cl" || compiler == "clang-cl"
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if msvc && strings.HasPrefix(arg, "/") && len(arg) >= 2 {
+			arg = "-" + arg[1:] // /I, /D and /U work like their dash forms
+		}
+		// value returns the option's argument, attached or the next one
+		value := func(prefix string) string {
+			if v := arg[len(prefix):]; v != "" {
+				return v
+			}
+			if i+1 < len(args) {
+				i++
+				return args[i]
+			}
+			return ""
+		}
+		switch {
+		case strings.HasPrefix(arg, "-I"):
+			flags.IncludeDirs = append(flags.IncludeDirs, absolute(dir, value("-I")))
+		case strings.HasPrefix(arg, "-iquote"):
+			flags.IncludeDirs = append(flags.IncludeDirs, absolute(dir, value("-iquote")))
+		case strings.HasPrefix(arg, "-isystem"):
+			value("-isystem")
+		case strings.HasPrefix(arg, "-D"):
+			name, def, _ := strings.Cut(value("-D"), "=")
+			flags.Defines[name] = def
+		case strings.HasPrefix(arg, "-U"):
+			name := value("-U")
+			delete(flags.Defines, name)
+			flags.Undefines = append(flags.Undefines, name)
+		}
+	}
+	return flags
+}
+
+// absolute resolves path against dir
+func absolute(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
+
+// splitCommand splits a compile command the way a POSIX shell would,
+// honoring quotes and backslash escapes
+func splitCommand(command string) []string {
+	var args []string
+	var sb strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range command {
+		switch {
+		case escaped:
+			sb.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return args
+}
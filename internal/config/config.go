@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the config file names discovered from the scan root
+var FileNames = []string{".leakcheck.yml", ".leakcheck.yaml"}
+
+// Config is the project configuration read from .leakcheck.yml. Paths are
+// resolved relative to the directory containing the file.
+type Config struct {
+	Exclude          []string                   `yaml:"exclude"`
+	Include          []string                   `yaml:"include"`
+	Extensions       []string                   `yaml:"extensions"`
+	RespectGitignore bool                       `yaml:"respect_gitignore"`
+	Rules            RuleSelection              `yaml:"rules"`
+	Severity         map[string]string          `yaml:"severity"`   // rule ID -> error, warning or info
+	Profiles         []string                   `yaml:"profiles"`   // framework ownership profiles, e.g. qt
+	Allocators       []analyzer.AllocatorPair   `yaml:"allocators"` // custom allocation/release function pairs
+	Sinks            []analyzer.FunctionSummary `yaml:"sinks"`      // functions taking ownership of pointer arguments, e.g. registerAndOwn
+	Summaries        string                     `yaml:"summaries"`
+	Suppressions     string                     `yaml:"suppressions"`
+	Baseline         string                     `yaml:"baseline"`
+	Format           []string                   `yaml:"format"`         // same values as --format, e.g. sarif:build/leaks.sarif
+	MergeStrategy    parser.MergeStrategy       `yaml:"merge_strategy"` // prefer-header (default), prefer-impl or union
+	Notify           Notify                     `yaml:"notify"`
+
+	// Path is the file the configuration was loaded from
+	Path string `yaml:"-"`
+}
+
+// RuleSelection enables or disables rules by ID
+type RuleSelection struct {
+	Enable  []string `yaml:"enable"` // opt-in rules to add, or, when a default rule is listed, the only rules to run
+	Disable []string `yaml:"disable"`
+	Scripts string   `yaml:"scripts"` // directory of Starlark rule scripts
+}
+
+// Notify configures where results are sent when a run completes. Chat
+// messages go out only when the new findings reach the threshold set by On
+// and MinNew. URLs may reference environment variables as ${NAME}.
+type Notify struct {
+	Webhook   Webhook `yaml:"webhook"`
+	Slack     string  `yaml:"slack"`      // Slack incoming webhook URL
+	Teams     string  `yaml:"teams"`      // Microsoft Teams workflow webhook URL
+	ReportURL string  `yaml:"report_url"` // linked from chat messages, e.g. the CI artifact
+	On        string  `yaml:"on"`         // lowest severity of new findings that counts: error or warning (default)
+	MinNew    int     `yaml:"min_new"`    // new findings needed for a chat message (default 1)
+	Email     Email   `yaml:"email"`
+}
+
+// Email mails a summary with the HTML report attached after every run
+type Email struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"` // default 587; 465 for implicit TLS
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"` // e.g. ${SMTP_PASSWORD}
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Subject  string   `yaml:"subject"`
+}
+
+// Webhook receives the JSON report with a POST request
+type Webhook struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"` // values may reference environment variables as ${NAME}
+}
+
+// Load reads and validates a configuration file, apart from rule IDs which
+// are checked by ValidateRules
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.Path = path
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	cfg.resolvePaths()
+	return &cfg, nil
+}
+
+// Find looks for a config file in dir and its parents, stopping at the
+// repository root (a directory containing .git). It returns "" when there
+// is none.
+func Find(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range FileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ValidateRules checks that rule IDs referenced by the configuration exist.
+// It is separate from Load because scripted rules are only known once the
+// scripts directory has been loaded.
+func (c *Config) ValidateRules() error {
+	for _, id := range append(append([]string{}, c.Rules.Enable...), c.Rules.Disable...) {
+		if _, ok := analyzer.LookupRule(id); !ok {
+			return fmt.Errorf("%s: unknown rule %q", c.Path, id)
+		}
+	}
+	for id := range c.Severity {
+		if _, ok := analyzer.LookupRule(id); !ok {
+			return fmt.Errorf("%s: unknown rule %q in severity", c.Path, id)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	for id, severity := range c.Severity {
+		switch severity {
+		case "error", "warning", "info":
+		default:
+			return fmt.Errorf("invalid severity %q for %s (use error, warning or info)", severity, id)
+		}
+	}
+	for _, name := range c.Profiles {
+		if _, ok := analyzer.Profiles[name]; !ok {
+			return fmt.Errorf("unknown profile %q (supported: %s)", name, strings.Join(analyzer.ProfileNames(), ", "))
+		}
+	}
+	for _, pair := range c.Allocators {
+		if pair.Alloc == "" || pair.Free == "" {
+			return fmt.Errorf("allocator pairs need both alloc and free")
+		}
+	}
+	for _, sink := range c.Sinks {
+		if sink.Name == "" {
+			return fmt.Errorf("sinks need a name")
+		}
+		if slices.ContainsFunc(sink.Params, func(i int) bool { return i < 0 }) {
+			return fmt.Errorf("sink %s: params are argument positions from 0", sink.Name)
+		}
+	}
+	if c.MergeStrategy != "" && !slices.Contains(parser.MergeStrategies, c.MergeStrategy) {
+		return fmt.Errorf("invalid merge_strategy %q (use prefer-header, prefer-impl or union)", c.MergeStrategy)
+	}
+	switch c.Notify.On {
+	case "", "error", "warning":
+	default:
+		return fmt.Errorf("invalid notify.on %q (use error or warning)", c.Notify.On)
+	}
+	if c.Notify.MinNew < 0 {
+		return fmt.Errorf("notify.min_new must not be negative")
+	}
+	if email := c.Notify.Email; email.Host != "" && (email.From == "" || len(email.To) == 0) {
+		return fmt.Errorf("notify.email needs from and to")
+	}
+	for i, ext := range c.Extensions {
+		if !strings.HasPrefix(ext, ".") {
+			c.Extensions[i] = "." + ext
+		}
+	}
+	return nil
+}
+
+// DisabledRules returns the IDs of rules turned off by the rules section.
+// An enable list naming only opt-in rules turns them on next to the
+// default rules; one naming any other rule runs only the listed rules.
+func (c *Config) DisabledRules() map[string]bool {
+	disabled := make(map[string]bool)
+	exclusive := slices.ContainsFunc(c.Rules.Enable, func(id string) bool {
+		info, _ := analyzer.LookupRule(id)
+		return !info.OptIn
+	})
+	for _, r := range analyzer.Rules {
+		if slices.Contains(c.Rules.Enable, r.ID) {
+			disabled[r.ID] = false
+		} else if exclusive {
+			disabled[r.ID] = true
+		}
+	}
+	for _, id := range c.Rules.Disable {
+		disabled[id] = true
+	}
+	return disabled
+}
+
+// resolvePaths makes file references relative to the config file
+func (c *Config) resolvePaths() {
+	dir := filepath.Dir(c.Path)
+	for _, p := range []*string{&c.Summaries, &c.Suppressions, &c.Baseline, &c.Rules.Scripts} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	for i, format := range c.Format {
+		if name, path, ok := strings.Cut(format, ":"); ok && path != "" && !filepath.IsAbs(path) {
+			c.Format[i] = name + ":" + filepath.Join(dir, path)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package fix
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines around each hunk
+const contextLines = 3
+
+// diffLine is one line of a unified diff body
+type diffLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// WriteDiff writes the patch as a unified diff, labelling the file with name
+func (p *Patch) WriteDiff(w io.Writer, name string) error {
+	lines := p.diffLines()
+
+	var changed []int
+	for i, l := range lines {
+		if l.kind != ' ' {
+			changed = append(changed, i)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if _, err := fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", name, name); err != nil {
+		return err
+	}
+	for start := 0; start < len(changed); {
+		// Extend the hunk while the next change is within the shared context
+		end := start
+		for end+1 < len(changed) && changed[end+1]-changed[end] <= 2*contextLines {
+			end++
+		}
+		from := max(changed[start]-contextLines, 0)
+		to := min(changed[end]+contextLines+1, len(lines))
+		if err := writeHunk(w, lines, from, to); err != nil {
+			return err
+		}
+		start = end + 1
+	}
+	return nil
+}
+
+// diffLines lines up the original and patched content
+func (p *Patch) diffLines() []diffLine {
+	orig := p.orig
+	if len(orig) > 0 && orig[len(orig)-1] == "" {
+		orig = orig[:len(orig)-1] // final newline
+	}
+
+	var lines []diffLine
+	for n := 1; n <= len(orig); n++ {
+		for _, text := range p.before[n] {
+			lines = append(lines, diffLine{'+', text})
+		}
+		if replaced, ok := p.lines[n]; ok && replaced != orig[n-1] {
+			lines = append(lines, diffLine{'-', orig[n-1]}, diffLine{'+', replaced})
+		} else {
+			lines = append(lines, diffLine{' ', orig[n-1]})
+		}
+	}
+	return lines
+}
+
+func writeHunk(w io.Writer, lines []diffLine, from, to int) error {
+	// Line numbers of the hunk start in the old and new file
+	oldStart, newStart := 1, 1
+	for _, l := range lines[:from] {
+		if l.kind != '+' {
+			oldStart++
+		}
+		if l.kind != '-' {
+			newStart++
+		}
+	}
+	oldCount, newCount := 0, 0
+	var body strings.Builder
+	for _, l := range lines[from:to] {
+		if l.kind != '+' {
+			oldCount++
+		}
+		if l.kind != '-' {
+			newCount++
+		}
+		body.WriteByte(l.kind)
+		body.WriteString(l.text)
+		body.WriteByte('\n')
+	}
+	_, err := fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n%s", oldStart, oldCount, newStart, newCount, body.String())
+	return err
+}
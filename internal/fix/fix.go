@@ -0,0 +1,385 @@
+package fix
+
+import (
+	"fmt"
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/charset"
+	"leakcheck/internal/parser"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Patch holds the pending edits to one source file. Edits refer to
+// original line numbers, so several fixes can target the same file.
+type Patch struct {
+	File   string
+	orig   []string
+	before map[int][]string // lines inserted before original line n
+	lines  map[int]string   // replacement content of original line n
+	fixed  int              // number of findings addressed
+}
+
+// Plan derives patches for the fixable findings: missing deletes in an
+// existing destructor (LC001), missing destructors (LC005),
+// delete/delete[] mismatches (LC002) and members left dangling after a
+// delete (LC009). Other findings are left alone.
+func Plan(a *analyzer.Analyzer, classes []parser.Class, leaks []parser.Leak) ([]*Patch, error) {
+	// Keyed by qualified name, so audio::Buffer and video::Buffer, or Cache
+	// and Cache<int>, are fixed apart
+	byName := make(map[string]*parser.Class)
+	for i := range classes {
+		byName[classes[i].QualifiedName()] = &classes[i]
+	}
+
+	// Process findings in source order so inserted statements are stable
+	leaks = slices.Clone(leaks)
+	sort.SliceStable(leaks, func(i, j int) bool {
+		if leaks[i].File != leaks[j].File {
+			return leaks[i].File < leaks[j].File
+		}
+		return leaks[i].Line < leaks[j].Line
+	})
+
+	p := &planner{analyzer: a, patches: make(map[string]*Patch)}
+	missingDtor := make(map[string][]string) // class -> members needing release
+	for _, leak := range leaks {
+		class, ok := byName[leak.QualifiedClass()]
+		if !ok {
+			continue
+		}
+		var err error
+		switch leak.RuleID {
+		case analyzer.RuleMissingDelete:
+			if class.Destructor != nil && isPointerMember(class, leak.VarName) {
+				err = p.addRelease(class, leak.VarName)
+			}
+		case analyzer.RuleNoDestructor:
+			if !isPointerMember(class, leak.VarName) {
+				continue
+			}
+			missingDtor[class.QualifiedName()] = append(missingDtor[class.QualifiedName()], leak.VarName)
+		case analyzer.RuleArrayMismatch:
+			err = p.fixMismatch(class, leak)
+		case analyzer.RuleDanglingPointer:
+			err = p.resetAfterDelete(class, leak)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(missingDtor))
+	for name := range missingDtor {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := p.addDestructor(byName[name], missingDtor[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	var result []*Patch
+	for _, patch := range p.patches {
+		if patch.fixed > 0 {
+			result = append(result, patch)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].File < result[j].File })
+	return result, nil
+}
+
+type planner struct {
+	analyzer *analyzer.Analyzer
+	patches  map[string]*Patch
+}
+
+func (p *planner) patch(file string) (*Patch, error) {
+	if patch, ok := p.patches[file]; ok {
+		return patch, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if encoding := charset.Detect(data); charset.Transcoded(encoding) {
+		return nil, fmt.Errorf("%s: cannot edit %s source", file, encoding)
+	}
+	patch := &Patch{
+		File:   file,
+		orig:   strings.Split(string(data), "\n"),
+		before: make(map[int][]string),
+		lines:  make(map[int]string),
+	}
+	p.patches[file] = patch
+	return patch, nil
+}
+
+// release returns the statement freeing a member
+func (p *planner) release(class *parser.Class, member string) string {
+	alloc, _ := p.analyzer.ConstructorAllocation(*class, member)
+	return analyzer.ReleaseStatement(alloc, member) + ";"
+}
+
+// addRelease inserts a release statement before the destructor's closing brace
+func (p *planner) addRelease(class *parser.Class, member string) error {
+	dtor := class.Destructor
+	if dtor.File == "" || dtor.EndLine == 0 {
+		return nil // declared but never defined
+	}
+	patch, err := p.patch(dtor.File)
+	if err != nil {
+		return err
+	}
+	stmt := p.release(class, member)
+
+	closing := patch.line(dtor.EndLine)
+	brace := strings.LastIndex(closing, "}")
+	if brace < 0 {
+		return nil
+	}
+	if strings.TrimSpace(closing[:brace]) != "" {
+		// Single-line destructor: ~Foo() { delete a; }
+		patch.lines[dtor.EndLine] = strings.TrimRight(closing[:brace], " \t") + " " + stmt + " " + closing[brace:]
+	} else {
+		indent := leadingSpace(closing)
+		patch.before[dtor.EndLine] = append(patch.before[dtor.EndLine], indent+patch.indentUnit(dtor.StartLine, dtor.EndLine, indent)+stmt)
+	}
+	patch.fixed++
+	return nil
+}
+
+// addDestructor inserts a public destructor releasing members before the
+// closing brace of the class body
+func (p *planner) addDestructor(class *parser.Class, members []string) error {
+	if class.DefinitionFile == "" || class.EndLine == 0 {
+		return nil
+	}
+	patch, err := p.patch(class.DefinitionFile)
+	if err != nil {
+		return err
+	}
+
+	closing := patch.line(class.EndLine)
+	if strings.TrimSpace(closing) != "};" && !strings.HasPrefix(strings.TrimSpace(closing), "}") {
+		return nil // class body closes on a line with other code
+	}
+	outer := leadingSpace(closing)
+	unit := patch.indentUnit(class.StartLine, class.EndLine, outer)
+
+	var lines []string
+	if !patch.publicAtEnd(class.StartLine, class.EndLine) {
+		lines = append(lines, outer+"public:")
+	}
+	lines = append(lines, outer+unit+"~"+class.Name+"() {")
+	for _, member := range members {
+		lines = append(lines, outer+unit+unit+p.release(class, member))
+	}
+	lines = append(lines, outer+unit+"}")
+	patch.before[class.EndLine] = append(patch.before[class.EndLine], lines...)
+	patch.fixed += len(members)
+	return nil
+}
+
+var (
+	scalarDelete = regexp.MustCompile(`\bdelete\s+`)
+	arrayDelete  = regexp.MustCompile(`\bdelete\s*\[\s*\]\s*`)
+)
+
+// fixMismatch replaces the release on the deallocation line with the one
+// the allocation needs: delete and delete[] are swapped, memory from a C
+// allocator such as strdup goes to its free function, and memory from new
+// handed to free is deleted instead
+func (p *planner) fixMismatch(class *parser.Class, leak parser.Leak) error {
+	file := deallocationFile(class, leak)
+	if file == "" {
+		return nil
+	}
+	alloc, ok := p.analyzer.ConstructorAllocation(*class, leak.VarName)
+	if !ok {
+		return nil
+	}
+	patch, err := p.patch(file)
+	if err != nil {
+		return err
+	}
+
+	line := patch.line(leak.Line)
+	target := `(this->)?` + regexp.QuoteMeta(leak.VarName) + `\b`
+	releases := []*regexp.Regexp{
+		regexp.MustCompile(arrayDelete.String() + target),
+		regexp.MustCompile(scalarDelete.String() + target),
+	}
+	for _, free := range p.releaseFunctions() {
+		releases = append(releases, regexp.MustCompile(`\b`+regexp.QuoteMeta(free)+`\s*\(\s*`+target+`\s*\)`))
+	}
+	fixed := line
+	for _, release := range releases {
+		if release.MatchString(line) {
+			fixed = release.ReplaceAllLiteralString(line, analyzer.ReleaseStatement(alloc, leak.VarName))
+			break
+		}
+	}
+	if fixed != line {
+		patch.lines[leak.Line] = fixed
+		patch.fixed++
+	}
+	return nil
+}
+
+// releaseFunctions returns the release functions of the tracked allocators
+func (p *planner) releaseFunctions() []string {
+	var frees []string
+	pairs := slices.Concat(p.analyzer.Allocators, analyzer.StringAllocators)
+	for _, language := range analyzer.LanguageAllocators {
+		pairs = append(pairs, language...)
+	}
+	for _, pair := range pairs {
+		if !slices.Contains(frees, pair.Free) {
+			frees = append(frees, pair.Free)
+		}
+	}
+	return frees
+}
+
+// resetAfterDelete inserts member = nullptr; after a delete statement that
+// stands on its own line. A delete sharing its line with a condition is
+// left alone, since resetting the member unconditionally would lose it.
+func (p *planner) resetAfterDelete(class *parser.Class, leak parser.Leak) error {
+	file := deallocationFile(class, leak)
+	if file == "" {
+		return nil
+	}
+	patch, err := p.patch(file)
+	if err != nil {
+		return err
+	}
+
+	line := patch.line(leak.Line)
+	statement := strings.TrimSpace(line)
+	if !strings.HasPrefix(statement, "delete") || !strings.HasSuffix(statement, ";") || strings.Count(statement, ";") > 1 {
+		return nil
+	}
+	patch.before[leak.Line+1] = append(patch.before[leak.Line+1], leadingSpace(line)+leak.VarName+" = nullptr;")
+	patch.fixed++
+	return nil
+}
+
+// deallocationFile returns the file of the function deleting the finding's
+// variable at its line, or ""
+func deallocationFile(class *parser.Class, leak parser.Leak) string {
+	for _, fn := range functions(class) {
+		for _, d := range fn.Deallocations {
+			if d.Line == leak.Line && d.VarName == leak.VarName {
+				return fn.File
+			}
+		}
+		// Releases through free functions are only deallocations once the
+		// analyzer has resolved the allocators
+		for _, call := range fn.Calls {
+			if call.Line == leak.Line && len(call.Args) > 0 && strings.TrimPrefix(call.Args[0], "this->") == leak.VarName {
+				return fn.File
+			}
+		}
+	}
+	return ""
+}
+
+// isPointerMember reports whether name is a declared raw pointer member;
+// only those are safe to release from the destructor
+func isPointerMember(class *parser.Class, name string) bool {
+	for _, m := range class.Members {
+		if m.Name == name && m.IsPointer {
+			return true
+		}
+	}
+	return false
+}
+
+func functions(class *parser.Class) []*parser.Function {
+	var fns []*parser.Function
+	for _, fn := range []*parser.Function{class.Constructor, class.Destructor, class.MoveConstructor, class.MoveAssignment} {
+		if fn != nil {
+			fns = append(fns, fn)
+		}
+	}
+	for i := range class.Methods {
+		fns = append(fns, &class.Methods[i])
+	}
+	return fns
+}
+
+// line returns the current content of original line n
+func (p *Patch) line(n int) string {
+	if s, ok := p.lines[n]; ok {
+		return s
+	}
+	if n < 1 || n > len(p.orig) {
+		return ""
+	}
+	return p.orig[n-1]
+}
+
+// indentUnit guesses one indentation level from the lines between start
+// and end that are indented deeper than outer
+func (p *Patch) indentUnit(start, end int, outer string) string {
+	for n := start + 1; n < end; n++ {
+		indent := leadingSpace(p.line(n))
+		if strings.TrimSpace(p.line(n)) != "" && len(indent) > len(outer) && strings.HasPrefix(indent, outer) {
+			return indent[len(outer):]
+		}
+	}
+	if strings.Contains(outer, "\t") {
+		return "\t"
+	}
+	return "    "
+}
+
+var accessSpecifier = regexp.MustCompile(`^\s*(public|private|protected)\s*:`)
+
+// publicAtEnd reports whether declarations added at the end of the class
+// body are public
+func (p *Patch) publicAtEnd(start, end int) bool {
+	public := strings.Contains(p.line(start), "struct")
+	for n := start; n < end; n++ {
+		if m := accessSpecifier.FindStringSubmatch(p.line(n)); m != nil {
+			public = m[1] == "public"
+		}
+	}
+	return public
+}
+
+// Fixed returns the number of findings the patch addresses
+func (p *Patch) Fixed() int {
+	return p.fixed
+}
+
+// Content returns the patched file content
+func (p *Patch) Content() string {
+	return strings.Join(p.result(), "\n")
+}
+
+// Apply writes the patched content back to the file
+func (p *Patch) Apply() error {
+	info, err := os.Stat(p.File)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p.File, []byte(p.Content()), info.Mode().Perm())
+}
+
+func (p *Patch) result() []string {
+	var out []string
+	for n := 1; n <= len(p.orig); n++ {
+		out = append(out, p.before[n]...)
+		out = append(out, p.line(n))
+	}
+	return out
+}
+
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
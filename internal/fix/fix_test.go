@@ -0,0 +1,135 @@
+package fix
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+)
+
+// plan parses src as one file, analyzes it and returns the fixed content
+// with the number of findings fixed
+func plan(t *testing.T, src string) (string, int) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "fix.cpp")
+	if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	classes, err := parser.ParseFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := parser.NewClassRegistry()
+	registry.AddClasses(classes)
+	merged := registry.MergeClasses()
+
+	a := analyzer.NewAnalyzer()
+	a.AddClasses(merged)
+	leaks, err := a.Analyze(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	patches, err := Plan(a, merged, leaks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 {
+		t.Fatalf("got %d patches for %d findings, want 1", len(patches), len(leaks))
+	}
+	return patches[0].Content(), patches[0].Fixed()
+}
+
+func TestPlanSameNameInNamespaces(t *testing.T) {
+	got, fixed := plan(t, `namespace audio {
+class Buffer {
+public:
+    Buffer() { data = new char[4]; }
+    ~Buffer() {}
+private:
+    char* data;
+};
+}
+namespace video {
+class Buffer {
+public:
+    Buffer() { data = new char[4]; }
+    ~Buffer() { delete[] data; }
+private:
+    char* data;
+};
+}
+`)
+	lines := strings.Split(got, "\n")
+	if fixed != 1 {
+		t.Errorf("fixed %d findings, want 1", fixed)
+	}
+	if want := "    ~Buffer() { delete[] data; }"; lines[4] != want {
+		t.Errorf("audio::Buffer destructor = %q, want %q", lines[4], want)
+	}
+	if strings.Count(got, "delete[] data;") != 2 {
+		t.Errorf("want one delete[] in each destructor, got:\n%s", got)
+	}
+}
+
+func TestPlanSpecialization(t *testing.T) {
+	got, fixed := plan(t, `template <typename T>
+class Cache {
+public:
+    Cache() { data = new T; }
+    ~Cache() {}
+private:
+    T* data;
+};
+template <>
+class Cache<int> {
+public:
+    Cache() { data = new int; }
+    ~Cache() {
+        delete data;
+    }
+private:
+    int* data;
+};
+`)
+	lines := strings.Split(got, "\n")
+	if fixed != 1 {
+		t.Errorf("fixed %d findings, want 1", fixed)
+	}
+	if want := "    ~Cache() { delete data; }"; lines[4] != want {
+		t.Errorf("primary destructor = %q, want %q", lines[4], want)
+	}
+	if strings.Count(got, "delete data;") != 2 {
+		t.Errorf("want one delete in each destructor, got:\n%s", got)
+	}
+}
+
+func TestPlanSpecializationLeak(t *testing.T) {
+	got, fixed := plan(t, `template <typename T>
+class Cache {
+public:
+    Cache() { data = new T; }
+    ~Cache() { delete data; }
+private:
+    T* data;
+};
+template <>
+class Cache<int> {
+public:
+    Cache() { data = new int; }
+    ~Cache() {}
+private:
+    int* data;
+};
+`)
+	lines := strings.Split(got, "\n")
+	if fixed != 1 {
+		t.Errorf("fixed %d findings, want 1", fixed)
+	}
+	if want := "    ~Cache() { delete data; }"; lines[12] != want {
+		t.Errorf("Cache<int> destructor = %q, want %q", lines[12], want)
+	}
+}
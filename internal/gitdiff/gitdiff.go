@@ -0,0 +1,184 @@
+package gitdiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"leakcheck/internal/parser"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Changes records the files and lines that differ from a git ref, keyed by
+// absolute path
+type Changes map[string]*FileChanges
+
+// FileChanges lists the changed lines of one file in its current version
+type FileChanges struct {
+	All   bool // new untracked file: every line counts as changed
+	Lines map[int]bool
+}
+
+// Load compares the working tree of the repository containing dir with ref,
+// including untracked files that are not ignored
+func Load(dir, ref string) (Changes, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+
+	diff, err := git(root, "diff", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	changes, err := parseDiff(root, diff)
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := git(root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(strings.TrimSpace(untracked), "\n") {
+		if name != "" {
+			changes[filepath.Join(root, filepath.FromSlash(name))] = &FileChanges{All: true}
+		}
+	}
+	return changes, nil
+}
+
+// parseDiff reads the new-side line ranges from a zero-context unified diff
+func parseDiff(root, diff string) (Changes, error) {
+	changes := make(Changes)
+	var current *FileChanges
+
+	lines := bufio.NewScanner(strings.NewReader(diff))
+	lines.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lines.Scan() {
+		line := lines.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if name == "/dev/null" {
+				current = nil // deleted file
+				continue
+			}
+			name = strings.TrimPrefix(name, "b/")
+			current = &FileChanges{Lines: make(map[int]bool)}
+			changes[filepath.Join(root, filepath.FromSlash(name))] = current
+		case strings.HasPrefix(line, "@@ ") && current != nil:
+			start, count, err := hunkRange(line)
+			if err != nil {
+				return nil, err
+			}
+			for i := start; i < start+count; i++ {
+				current.Lines[i] = true
+			}
+		}
+	}
+	return changes, lines.Err()
+}
+
+// hunkRange parses the new-file range of a hunk header: @@ -a,b +c,d @@
+func hunkRange(header string) (start, count int, err error) {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	startText, countText, hasCount := strings.Cut(fields[2][1:], ",")
+	if start, err = strconv.Atoi(startText); err != nil {
+		return 0, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	count = 1
+	if hasCount {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return 0, 0, fmt.Errorf("malformed hunk header %q", header)
+		}
+	}
+	return start, count, nil
+}
+
+// Filter keeps findings in changed files, or on changed lines when
+// linesOnly is set. Findings on classes split across files are kept when
+// any of their files changed.
+func (c Changes) Filter(leaks []parser.Leak, linesOnly bool) []parser.Leak {
+	var kept []parser.Leak
+	for _, leak := range leaks {
+		if c.Contains(leak, linesOnly) {
+			kept = append(kept, leak)
+		}
+	}
+	return kept
+}
+
+// Contains reports whether a finding lies in a changed file, or on a
+// changed line when linesOnly is set
+func (c Changes) Contains(leak parser.Leak, linesOnly bool) bool {
+	fc, changed := c[leak.File]
+	return changed && (!linesOnly || fc.All || fc.Lines[leak.Line])
+}
+
+// Staged returns the absolute paths of the files added, copied, modified or
+// renamed in the index of the repository containing dir
+func Staged(dir string) ([]string, error) {
+	root, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+
+	out, err := git(root, "diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range strings.Split(out, "\x00") {
+		if name != "" {
+			files = append(files, filepath.Join(root, filepath.FromSlash(name)))
+		}
+	}
+	return files, nil
+}
+
+// HooksDir returns the directory git runs hooks from for the repository
+// containing dir, honoring core.hooksPath and worktrees
+func HooksDir(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--path-format=absolute", "--git-path", "hooks")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Head returns the commit checked out in the repository containing dir and
+// its branch, which is "" on a detached HEAD
+func Head(dir string) (commit, branch string, err error) {
+	out, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+	commit = strings.TrimSpace(out)
+	if out, err := git(dir, "symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+		branch = strings.TrimSpace(out)
+	}
+	return commit, branch, nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,99 @@
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether the slash-separated name matches pattern with
+// doublestar semantics: "*", "?" and "[...]" match within one path
+// component, and a "**" component matches zero or more components.
+func Match(pattern, name string) bool {
+	return matchParts(split(pattern), split(name))
+}
+
+// Valid reports whether pattern is well formed as MatchPath reads it
+func Valid(pattern string) bool {
+	for _, part := range split(normalize(pattern)) {
+		if part == "**" {
+			continue
+		}
+		if _, err := path.Match(part, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchPath matches a path against a pattern the way --include and
+// --exclude do: a pattern without a slash matches the final component, or
+// any component when anyComponent is set; other patterns match the whole
+// path. Backslashes in the pattern are separators, as written on Windows,
+// and leading ./ and trailing separators are ignored, so vendor\, ./vendor
+// and vendor/ all mean vendor. A backslash never escapes: src\*.gen is
+// src/*.gen, and *, ? and [ cannot be matched literally.
+func MatchPath(pattern, name string, anyComponent bool) bool {
+	pattern = normalize(pattern)
+	if strings.Contains(pattern, "/") {
+		return Match(strings.TrimPrefix(pattern, "/"), name)
+	}
+	parts := split(name)
+	if !anyComponent && len(parts) > 0 {
+		parts = parts[len(parts)-1:]
+	}
+	for _, part := range parts {
+		if ok, _ := path.Match(pattern, part); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize rewrites a pattern as written on the command line or in a
+// config file into a slash-separated pattern
+func normalize(pattern string) string {
+	pattern = strings.ReplaceAll(pattern, "\\", "/")
+	for strings.HasPrefix(pattern, "./") {
+		pattern = strings.TrimLeft(pattern[2:], "/")
+	}
+	if trimmed := strings.TrimRight(pattern, "/"); trimmed != "" {
+		pattern = trimmed
+	}
+	return pattern
+}
+
+func split(s string) []string {
+	s = strings.Trim(s, "/")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "/")
+}
+
+func matchParts(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse repeated ** and try every split point
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchParts(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,80 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"src/*.cpp", "src/main.cpp", true},
+		{"src/*.cpp", "src/net/socket.cpp", false},
+		{"src/**/*.cpp", "src/main.cpp", true},
+		{"src/**/*.cpp", "src/net/tcp/socket.cpp", true},
+		{"**/gen/*.h", "gen/api.h", true},
+		{"**/gen/*.h", "lib/core/gen/api.h", true},
+		{"**/gen/*.h", "lib/core/gen/sub/api.h", false},
+		{"third_party/**", "third_party/zlib/inflate.c", true},
+		{"third_party/**", "src/third_party.cpp", false},
+		{"**/**/test_?.cpp", "a/b/test_1.cpp", true},
+		{"[a-c]*/x.h", "beta/x.h", true},
+		{"[a-c]*/x.h", "delta/x.h", false},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		anyComponent  bool
+		want          bool
+	}{
+		// Windows separators
+		{`vendor\`, "vendor/zlib/inflate.c", true, true},
+		{`third_party\zlib`, "third_party/zlib", false, true},
+		{`src\**\*.cpp`, "src/net/socket.cpp", false, true},
+		{`src\gen\*.h`, "src/other/api.h", false, false},
+		{`src\*.gen`, "src/api.gen", false, true},
+		{`src\*.gen`, "src*.gen", false, false},
+		{`src\[ab].h`, "src/a.h", false, true},
+
+		// Trailing and leading separators
+		{"vendor/", "vendor", false, true},
+		{"vendor/", "lib/vendor/x.cpp", true, true},
+		{"vendor//", "lib/vendor/x.cpp", true, true},
+		{"./vendor", "lib/vendor/x.cpp", true, true},
+		{".//vendor/", "vendor", false, true},
+		{"build/out/", "build/out", false, true},
+		{"/build", "build", false, true},
+
+		// Nested matches
+		{"vendor", "lib/vendor/zlib/inflate.c", true, true},
+		{"vendor", "lib/vendor/zlib/inflate.c", false, false},
+		{"*.pb.h", "proto/gen/api.pb.h", false, true},
+		{"gen", "src/generated/api.h", true, false},
+		{"src/**/gen", "src/a/b/gen", false, true},
+		{"src/**/gen", "lib/a/gen", false, false},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.name, tt.anyComponent); got != tt.want {
+			t.Errorf("MatchPath(%q, %q, %v) = %v, want %v", tt.pattern, tt.name, tt.anyComponent, got, tt.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for _, pattern := range []string{"src/**/*.cpp", "**", "[ab]*.h", `src\*.gen`, `src\[ab].h`} {
+		if !Valid(pattern) {
+			t.Errorf("Valid(%q) = false, want true", pattern)
+		}
+	}
+	for _, pattern := range []string{"src/[a-", "[]", `src\[a-`, `[\]`} {
+		if Valid(pattern) {
+			t.Errorf("Valid(%q) = true, want false", pattern)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"leakcheck/internal/parser"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxOffenders is how many files a chat message lists
+const maxOffenders = 5
+
+// Digest summarizes a run for chat notifications
+type Digest struct {
+	NewErrors   int
+	NewWarnings int
+	NewInfo     int
+	// Fixed counts baseline findings that are gone; -1 when the run had
+	// no baseline to compare with
+	Fixed     int
+	Known     int        // baseline findings still present
+	Offenders []Offender // files with the most new findings, worst first
+	ReportURL string     // link to the full report, if any
+}
+
+// Offender is a file and its new findings
+type Offender struct {
+	File     string
+	Errors   int
+	Warnings int
+}
+
+// NewDigest counts the new findings of a run and ranks their files, shown
+// relative to baseDir when beneath it
+func NewDigest(leaks []parser.Leak, fixed, known int, baseDir, reportURL string) Digest {
+	d := Digest{Fixed: fixed, Known: known, ReportURL: reportURL}
+	files := make(map[string]*Offender)
+	for _, leak := range leaks {
+		file := leak.File
+		if rel, err := filepath.Rel(baseDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			file = filepath.ToSlash(rel)
+		}
+		o := files[file]
+		if o == nil {
+			o = &Offender{File: file}
+			files[file] = o
+		}
+		switch leak.Severity {
+		case "error":
+			d.NewErrors++
+			o.Errors++
+		case "warning":
+			d.NewWarnings++
+			o.Warnings++
+		default:
+			d.NewInfo++
+		}
+	}
+	for _, o := range files {
+		if o.Errors+o.Warnings > 0 {
+			d.Offenders = append(d.Offenders, *o)
+		}
+	}
+	slices.SortFunc(d.Offenders, func(a, b Offender) int {
+		return cmp.Or(b.Errors-a.Errors, b.Warnings-a.Warnings, strings.Compare(a.File, b.File))
+	})
+	if len(d.Offenders) > maxOffenders {
+		d.Offenders = d.Offenders[:maxOffenders]
+	}
+	return d
+}
+
+// Exceeds reports whether at least minNew new findings are of severity on
+// ("error" or "warning") or worse
+func (d Digest) Exceeds(on string, minNew int) bool {
+	n := d.NewErrors
+	if on != "error" {
+		n += d.NewWarnings
+	}
+	return n > 0 && n >= minNew
+}
+
+// title is the first line of a chat message
+func (d Digest) title() string {
+	title := fmt.Sprintf("leakcheck: %d new error(s), %d new warning(s)", d.NewErrors, d.NewWarnings)
+	if d.Fixed >= 0 {
+		title += fmt.Sprintf(", %d fixed", d.Fixed)
+	}
+	return title
+}
+
+// offenderLines lists the worst files, one per line
+func (d Digest) offenderLines() []string {
+	var lines []string
+	for _, o := range d.Offenders {
+		lines = append(lines, fmt.Sprintf("%s: %d error(s), %d warning(s)", o.File, o.Errors, o.Warnings))
+	}
+	return lines
+}
+
+// PostSlack sends the digest to a Slack incoming webhook
+func PostSlack(ctx context.Context, url string, d Digest) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%s*", d.title())
+	if lines := d.offenderLines(); len(lines) > 0 {
+		sb.WriteString("\nWorst offenders:")
+		for _, line := range lines {
+			sb.WriteString("\n• " + slackEscape(line))
+		}
+	}
+	if d.ReportURL != "" {
+		fmt.Fprintf(&sb, "\n<%s|Full report>", d.ReportURL)
+	}
+	body, err := json.Marshal(map[string]string{"text": sb.String()})
+	if err != nil {
+		return err
+	}
+	return Webhook{URL: url}.Post(ctx, body)
+}
+
+// slackEscape escapes the characters Slack treats as markup
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// PostTeams sends the digest as an Adaptive Card to a Microsoft Teams
+// webhook (a Workflows "post to a channel when a webhook request is
+// received" URL)
+func PostTeams(ctx context.Context, url string, d Digest) error {
+	body := []any{
+		map[string]any{"type": "TextBlock", "text": d.title(), "weight": "Bolder", "wrap": true},
+	}
+	if lines := d.offenderLines(); len(lines) > 0 {
+		body = append(body, map[string]any{"type": "TextBlock", "text": "Worst offenders:", "wrap": true})
+		for _, line := range lines {
+			body = append(body, map[string]any{"type": "TextBlock", "text": "- " + line, "wrap": true, "spacing": "None"})
+		}
+	}
+	card := map[string]any{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+	}
+	if d.ReportURL != "" {
+		card["actions"] = []any{map[string]any{"type": "Action.OpenUrl", "title": "Full report", "url": d.ReportURL}}
+	}
+	message := map[string]any{
+		"type": "message",
+		"attachments": []any{map[string]any{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return Webhook{URL: url}.Post(ctx, data)
+}
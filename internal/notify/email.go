@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Email delivers a report by SMTP. Port 465 uses implicit TLS; other ports
+// upgrade with STARTTLS when the server offers it.
+type Email struct {
+	Host     string
+	Port     int // default 587
+	Username string
+	Password string
+	From     string
+	To       []string
+	Subject  string // default: the digest's title
+}
+
+// Send mails the digest as text with the HTML report attached
+func (e Email) Send(d Digest, html []byte) error {
+	msg, err := e.message(d, html)
+	if err != nil {
+		return err
+	}
+	client, err := e.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.From); err != nil {
+		return err
+	}
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dial connects to the server, with TLS when available
+func (e Email) dial() (*smtp.Client, error) {
+	port := e.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(port))
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	tlsConfig := &tls.Config{ServerName: e.Host}
+	if port == 465 {
+		conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return smtp.NewClient(conn, e.Host)
+	}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// message builds a multipart/mixed mail with a text summary and the HTML
+// report as an attachment
+func (e Email) message(d Digest, html []byte) ([]byte, error) {
+	subject := e.Subject
+	if subject == "" {
+		subject = d.title()
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", e.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprint(text, strings.ReplaceAll(d.text(), "\n", "\r\n"))
+
+	attachment, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {`attachment; filename="leakcheck-report.html"`},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(html)
+	for len(encoded) > 76 {
+		fmt.Fprintf(attachment, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(attachment, "%s\r\n", encoded)
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// text is the plain-text summary of a mail
+func (d Digest) text() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "New findings: %d error(s), %d warning(s), %d info\n", d.NewErrors, d.NewWarnings, d.NewInfo)
+	if d.Fixed >= 0 {
+		fmt.Fprintf(&sb, "Fixed since the baseline: %d\n", d.Fixed)
+		fmt.Fprintf(&sb, "Still present from the baseline: %d\n", d.Known)
+	}
+	if lines := d.offenderLines(); len(lines) > 0 {
+		sb.WriteString("\nFiles with the most new findings:\n")
+		for _, line := range lines {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	if d.ReportURL != "" {
+		fmt.Fprintf(&sb, "\nFull report: %s\n", d.ReportURL)
+	}
+	sb.WriteString("\nThe complete report is attached as leakcheck-report.html.\n")
+	return sb.String()
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// requestTimeout bounds each notification request
+const requestTimeout = 30 * time.Second
+
+// Webhook posts a JSON document to a URL
+type Webhook struct {
+	URL string
+	// Headers are sent with the request, e.g. Authorization. Values may
+	// reference environment variables as $NAME or ${NAME}, so tokens stay
+	// out of the configuration file.
+	Headers map[string]string
+}
+
+// Post sends body with POST, failing on non-2xx responses
+func (w Webhook) Post(ctx context.Context, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "leakcheck")
+	for name, value := range w.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", w.URL, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package parser
+
+import "strings"
+
+// Annotation names recognized in // leakcheck:<name> comments
+const (
+	annotationOwns           = "owns"
+	annotationNonOwning      = "non-owning"
+	annotationTakesOwnership = "takes-ownership"
+)
+
+// commentAnnotations extracts leakcheck:<name> annotations from a comment
+func commentAnnotations(text string) []string {
+	if !strings.Contains(text, "leakcheck:") {
+		return nil
+	}
+	var result []string
+	for _, field := range strings.Fields(text) {
+		field = strings.TrimRight(field, "*/,;.")
+		if name, ok := strings.CutPrefix(field, "leakcheck:"); ok && name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// hasAnnotation reports whether the comment carries the given annotation
+func hasAnnotation(c Comment, name string) bool {
+	for _, a := range commentAnnotations(c.Text) {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// memberOwnership looks for an ownership annotation attached to a declaration
+// spanning startLine..endLine: either a trailing comment on those lines or a
+// comment on its own line directly above the declaration.
+func (p *Parser) memberOwnership(startLine, endLine int) Ownership {
+	for _, c := range p.comments {
+		attached := (!c.OwnLine && c.Line >= startLine && c.Line <= endLine) ||
+			(c.OwnLine && c.EndLine == startLine-1)
+		if !attached {
+			continue
+		}
+		if hasAnnotation(c, annotationNonOwning) {
+			return OwnershipNonOwning
+		}
+		if hasAnnotation(c, annotationOwns) {
+			return OwnershipOwns
+		}
+	}
+	return OwnershipUnknown
+}
+
+// ownershipParams returns the indices of parameters annotated with
+// leakcheck:takes-ownership inside the parameter list tokens[open:close],
+// where open and close are the positions of the parentheses.
+func (p *Parser) ownershipParams(open, close int) []int {
+	var result []int
+	for _, c := range p.comments {
+		if !hasAnnotation(c, annotationTakesOwnership) {
+			continue
+		}
+		if !tokenBefore(p.tokens[open], c) || tokenBefore(p.tokens[close], c) {
+			continue
+		}
+
+		// The comment annotates the parameter it follows, including when it
+		// trails the separating comma (Widget* w, // leakcheck:...)
+		index := 0
+		depth := 0
+		trailingComma := false
+		for i := open + 1; i < close && tokenBefore(p.tokens[i], c); i++ {
+			trailingComma = false
+			switch p.tokens[i].Value {
+			case "(", "<", "[":
+				depth++
+			case ")", ">", "]":
+				depth--
+			case ",":
+				if depth == 0 {
+					index++
+					trailingComma = true
+				}
+			}
+		}
+		if trailingComma {
+			index--
+		}
+		result = append(result, index)
+	}
+	return result
+}
+
+// tokenBefore reports whether tok starts before comment c
+func tokenBefore(tok Token, c Comment) bool {
+	return tok.Line < c.Line || (tok.Line == c.Line && tok.Column < c.Column)
+}
@@ -0,0 +1,269 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// evaluate computes the value of an #if or #elif condition. defined(X) and
+// __has_include("file") are resolved first and macros expanded; names left
+// over, including unknown function-like checks such as
+// __has_cpp_attribute(x), count as 0. It reports false when the condition
+// is malformed.
+func (pp *Preprocessor) evaluate(tokens []Token, file string) (int64, bool) {
+	var resolved []Token
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.Value {
+		case "defined":
+			name, next := conditionOperand(tokens, i+1)
+			_, ok := pp.macros[name]
+			resolved = append(resolved, boolToken(ok))
+			i = next - 1
+			continue
+		case "__has_include":
+			operand, next := conditionOperand(tokens, i+1)
+			ok := pp.resolveInclude([]Token{{Type: TokenString, Value: operand}}, file) != ""
+			resolved = append(resolved, boolToken(ok))
+			i = next - 1
+			continue
+		}
+		resolved = append(resolved, tok)
+	}
+
+	var expanded []Token
+	for i := 0; i < len(resolved); {
+		if pp.expandable(resolved[i]) {
+			expansion, next := pp.expandAt(resolved, i, nil, 0)
+			expanded = append(expanded, expansion...)
+			i = next
+			continue
+		}
+		expanded = append(expanded, resolved[i])
+		i++
+	}
+
+	e := &evaluator{tokens: expanded, ok: true}
+	value := e.or()
+	return value, e.ok && e.pos == len(e.tokens)
+}
+
+// conditionOperand reads the operand of defined or __has_include, with or
+// without parentheses, returning it and the index after it
+func conditionOperand(tokens []Token, i int) (string, int) {
+	if i < len(tokens) && tokens[i].Value == "(" {
+		end := i + 1
+		for end < len(tokens) && tokens[end].Value != ")" {
+			end++
+		}
+		return renderTokens(tokens[i+1 : end]), min(end+1, len(tokens))
+	}
+	if i < len(tokens) {
+		return tokens[i].Value, i + 1
+	}
+	return "", i
+}
+
+func boolToken(b bool) Token {
+	if b {
+		return Token{Type: TokenNumber, Value: "1"}
+	}
+	return Token{Type: TokenNumber, Value: "0"}
+}
+
+// evaluator is a recursive-descent evaluator for preprocessor conditions
+type evaluator struct {
+	tokens []Token
+	pos    int
+	ok     bool
+}
+
+func (e *evaluator) peek() string {
+	if e.pos < len(e.tokens) {
+		return e.tokens[e.pos].Value
+	}
+	return ""
+}
+
+// binary evaluates a left-associative level of binary operators
+func (e *evaluator) binary(operand func() int64, apply func(op string, a, b int64) (int64, bool)) int64 {
+	value := operand()
+	for e.pos < len(e.tokens) {
+		op := e.peek()
+		if _, ok := apply(op, 0, 1); !ok {
+			return value
+		}
+		e.pos++
+		value, _ = apply(op, value, operand())
+	}
+	return value
+}
+
+func (e *evaluator) or() int64 {
+	return e.binary(e.and, func(op string, a, b int64) (int64, bool) {
+		return toInt(a != 0 || b != 0), op == "||"
+	})
+}
+
+func (e *evaluator) and() int64 {
+	return e.binary(e.bitOr, func(op string, a, b int64) (int64, bool) {
+		return toInt(a != 0 && b != 0), op == "&&"
+	})
+}
+
+func (e *evaluator) bitOr() int64 {
+	return e.binary(e.bitXor, func(op string, a, b int64) (int64, bool) {
+		return a | b, op == "|"
+	})
+}
+
+func (e *evaluator) bitXor() int64 {
+	return e.binary(e.bitAnd, func(op string, a, b int64) (int64, bool) {
+		return a ^ b, op == "^"
+	})
+}
+
+func (e *evaluator) bitAnd() int64 {
+	return e.binary(e.equality, func(op string, a, b int64) (int64, bool) {
+		return a & b, op == "&"
+	})
+}
+
+func (e *evaluator) equality() int64 {
+	return e.binary(e.relational, func(op string, a, b int64) (int64, bool) {
+		switch op {
+		case "==":
+			return toInt(a == b), true
+		case "!=":
+			return toInt(a != b), true
+		}
+		return 0, false
+	})
+}
+
+func (e *evaluator) relational() int64 {
+	return e.binary(e.additive, func(op string, a, b int64) (int64, bool) {
+		switch op {
+		case "<":
+			return toInt(a < b), true
+		case ">":
+			return toInt(a > b), true
+		case "<=":
+			return toInt(a <= b), true
+		case ">=":
+			return toInt(a >= b), true
+		}
+		return 0, false
+	})
+}
+
+func (e *evaluator) additive() int64 {
+	return e.binary(e.multiplicative, func(op string, a, b int64) (int64, bool) {
+		switch op {
+		case "+":
+			return a + b, true
+		case "-":
+			return a - b, true
+		}
+		return 0, false
+	})
+}
+
+func (e *evaluator) multiplicative() int64 {
+	return e.binary(e.unary, func(op string, a, b int64) (int64, bool) {
+		switch op {
+		case "*":
+			return a * b, true
+		case "/", "%":
+			if b == 0 {
+				e.ok = false
+				return 0, true
+			}
+			if op == "/" {
+				return a / b, true
+			}
+			return a % b, true
+		}
+		return 0, false
+	})
+}
+
+func (e *evaluator) unary() int64 {
+	switch e.peek() {
+	case "!":
+		e.pos++
+		return toInt(e.unary() == 0)
+	case "-":
+		e.pos++
+		return -e.unary()
+	case "+":
+		e.pos++
+		return e.unary()
+	case "~":
+		e.pos++
+		return ^e.unary()
+	}
+	return e.primary()
+}
+
+func (e *evaluator) primary() int64 {
+	if e.pos >= len(e.tokens) {
+		e.ok = false
+		return 0
+	}
+	tok := e.tokens[e.pos]
+	e.pos++
+	switch {
+	case tok.Value == "(":
+		value := e.or()
+		if e.peek() != ")" {
+			e.ok = false
+			return 0
+		}
+		e.pos++
+		return value
+	case tok.Type == TokenNumber:
+		// Suffixes such as the L of 201703L are read as a separate name
+		if e.pos < len(e.tokens) && e.tokens[e.pos].Type == TokenIdent && strings.Trim(e.tokens[e.pos].Value, "uUlL") == "" {
+			e.pos++
+		}
+		value, err := strconv.ParseInt(strings.TrimRight(tok.Value, "uUlL"), 0, 64)
+		if err != nil {
+			e.ok = false
+		}
+		return value
+	case tok.Type == TokenString && strings.HasPrefix(tok.Value, "'"):
+		if text, err := strconv.Unquote(tok.Value); err == nil && len(text) > 0 {
+			return int64(text[0])
+		}
+		e.ok = false
+		return 0
+	case tok.Value == "true":
+		return 1
+	case tok.Type == TokenIdent || tok.Type == TokenKeyword:
+		// An unknown check such as __has_feature(x) is 0 as a whole
+		if e.peek() == "(" {
+			depth := 0
+			for ; e.pos < len(e.tokens); e.pos++ {
+				if e.tokens[e.pos].Value == "(" {
+					depth++
+				} else if e.tokens[e.pos].Value == ")" {
+					if depth--; depth == 0 {
+						e.pos++
+						break
+					}
+				}
+			}
+		}
+		return 0
+	}
+	e.ok = false
+	return 0
+}
+
+func toInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package parser
+
+import "slices"
+
+// loopBinding is a variable a for loop binds to the elements of a
+// container, as w in for (auto* w : widgets_) or it in
+// for (auto it = widgets_.begin(); ...)
+type loopBinding struct {
+	names     []string
+	container string
+	end       int // token index past the loop body
+}
+
+// forBinding reads the for statement at pos, returning what it binds when
+// it walks a container held in a plain variable
+func (p *Parser) forBinding(pos int) (loopBinding, bool) {
+	open := pos + 1
+	if open >= len(p.tokens) || p.tokens[open].Value != "(" {
+		return loopBinding{}, false
+	}
+	close := p.matchingParen(open)
+	var binding loopBinding
+	colon := -1
+	for i, depth := open+1, 0; i < close; i++ {
+		switch p.tokens[i].Value {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+		case ":":
+			if depth == 0 && colon < 0 {
+				colon = i
+			}
+		}
+	}
+	if colon >= 0 {
+		// for (auto* w : widgets_) or for (auto& [key, w] : widgets_)
+		decl := p.tokens[open+1 : colon]
+		if bracket := slices.IndexFunc(decl, func(t Token) bool { return t.Value == "[" }); bracket >= 0 {
+			for _, tok := range decl[bracket:] {
+				if tok.Type == TokenIdent {
+					binding.names = append(binding.names, tok.Value)
+				}
+			}
+		} else if n := len(decl); n > 0 && decl[n-1].Type == TokenIdent {
+			binding.names = []string{decl[n-1].Value}
+		}
+		binding.container = plainVariable(p.tokens[colon+1 : close])
+	} else {
+		// for (auto it = widgets_.begin(); ...)
+		for i := open + 1; i+4 < close && p.tokens[i].Value != ";"; i++ {
+			t := p.tokens[i:]
+			if t[0].Type == TokenIdent && t[1].Value == "=" && t[2].Type == TokenIdent && t[3].Value == "." && (t[4].Value == "begin" || t[4].Value == "cbegin" || t[4].Value == "rbegin") {
+				binding.names = []string{t[0].Value}
+				binding.container = t[2].Value
+				break
+			}
+		}
+	}
+	if len(binding.names) == 0 || binding.container == "" {
+		return loopBinding{}, false
+	}
+
+	// The body is a block or a single statement
+	binding.end = close + 1
+	for depth := 0; binding.end < len(p.tokens) && p.tokens[binding.end].Type != TokenEOF; binding.end++ {
+		switch p.tokens[binding.end].Value {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+		if depth == 0 && (p.tokens[binding.end].Value == "}" || p.tokens[binding.end].Value == ";") {
+			break
+		}
+	}
+	return binding, true
+}
+
+// deletedElement returns the container whose element the delete at pos
+// releases, and the variable deleted: one bound by an enclosing loop over
+// the container, as in delete w, delete *it or delete it->second, or the
+// container itself for an indexed element, delete items_[i]
+func (p *Parser) deletedElement(pos int, loops []loopBinding) (string, string) {
+	i := pos + 1
+	if i+1 < len(p.tokens) && p.tokens[i].Value == "[" && p.tokens[i+1].Value == "]" {
+		i += 2
+	}
+	if i < len(p.tokens) && p.tokens[i].Value == "*" {
+		i++
+	}
+	if i+1 < len(p.tokens) && p.tokens[i].Value == "this" && p.tokens[i+1].Value == "->" {
+		i += 2
+	}
+	if i+1 >= len(p.tokens) || p.tokens[i].Type != TokenIdent {
+		return "", ""
+	}
+	name := p.tokens[i].Value
+	for j := len(loops) - 1; j >= 0; j-- {
+		for _, bound := range loops[j].names {
+			if bound == name {
+				return loops[j].container, name
+			}
+		}
+	}
+	next := p.tokens[i+1].Value
+	if next == "[" || next == "." && i+2 < len(p.tokens) && (p.tokens[i+2].Value == "at" || p.tokens[i+2].Value == "back" || p.tokens[i+2].Value == "front") {
+		return name, name
+	}
+	return "", ""
+}
+
+// storedContainer returns the container the new at pos is stored in, as in
+// items_.push_back(new Item) or widgets_[key] = new Widget
+func (p *Parser) storedContainer(pos int) string {
+	if pos >= 2 && p.tokens[pos-1].Value == "=" && p.tokens[pos-2].Value == "]" {
+		depth := 0
+		for i := pos - 2; i > 0; i-- {
+			switch p.tokens[i].Value {
+			case "]":
+				depth++
+			case "[":
+				if depth--; depth == 0 {
+					if p.tokens[i-1].Type == TokenIdent {
+						return p.tokens[i-1].Value
+					}
+					return ""
+				}
+			}
+		}
+		return ""
+	}
+	if pos < 1 || p.tokens[pos-1].Value != "(" && p.tokens[pos-1].Value != "," {
+		return ""
+	}
+	depth := 0
+	for i := pos - 1; i > 2; i-- {
+		switch p.tokens[i].Value {
+		case ")", "]":
+			depth++
+		case "[":
+			depth--
+		case ";", "{", "}":
+			return ""
+		case "(":
+			if depth > 0 {
+				depth--
+				continue
+			}
+			// receiver.method(
+			if (p.tokens[i-2].Value == "." || p.tokens[i-2].Value == "->") && p.tokens[i-3].Type == TokenIdent {
+				return p.tokens[i-3].Value
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// plainVariable returns the variable tokens name, as in widgets_ or
+// this->widgets_, or "" for any other expression
+func plainVariable(tokens []Token) string {
+	if len(tokens) == 3 && tokens[0].Value == "this" && tokens[1].Value == "->" {
+		tokens = tokens[2:]
+	}
+	if len(tokens) == 1 && tokens[0].Type == TokenIdent {
+		return tokens[0].Value
+	}
+	return ""
+}
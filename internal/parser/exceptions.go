@@ -0,0 +1,35 @@
+package parser
+
+// tryAt reads the try block at the current position and its catch handlers
+// without moving the parser, or reports false when there is none
+func (p *Parser) tryAt() (TryBlock, bool) {
+	if !p.checkValue("try") || p.pos+1 >= len(p.tokens) || p.tokens[p.pos+1].Value != "{" {
+		return TryBlock{}, false
+	}
+	end := p.branchEnd(p.pos + 1)
+	block := TryBlock{Line: p.current().Line, EndLine: p.tokens[end].Line}
+	for i := end + 1; i+1 < len(p.tokens) && p.tokens[i].Value == "catch" && p.tokens[i+1].Value == "("; {
+		body := p.matchingParen(i+1) + 1
+		if body >= len(p.tokens) || p.tokens[body].Value != "{" {
+			break
+		}
+		close := p.branchEnd(body)
+		block.Catches = append(block.Catches, Catch{Line: p.tokens[i].Line, EndLine: p.tokens[close].Line})
+		i = close + 1
+	}
+	return block, true
+}
+
+// throwAt reads the throw expression at the current position, or reports
+// false for a dynamic exception specification such as throw()
+func (p *Parser) throwAt() (Throw, bool) {
+	next := ""
+	if p.pos+1 < len(p.tokens) {
+		next = p.tokens[p.pos+1].Value
+	}
+	if next == "(" && p.pos+2 < len(p.tokens) && p.tokens[p.pos+2].Value == ")" {
+		return Throw{}, false
+	}
+	tok := p.current()
+	return Throw{Line: tok.Line, Column: tok.Column, Rethrow: next == ";"}, true
+}
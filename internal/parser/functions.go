@@ -0,0 +1,143 @@
+package parser
+
+// paramNames returns the names of the parameters in tokens[open:close], the
+// parentheses of a parameter list, with "" for unnamed parameters
+func (p *Parser) paramNames(open, close int) []string {
+	var names []string
+	var param []Token
+	flush := func() {
+		// The name is the last identifier before any default argument,
+		// unless the parameter is nothing but a type (Foo*, int)
+		if i := indexValue(param, "="); i >= 0 {
+			param = param[:i]
+		}
+		name := ""
+		if n := len(param); n >= 2 && param[n-1].Type == TokenIdent && param[n-2].Value != "::" {
+			name = param[n-1].Value
+		}
+		names = append(names, name)
+		param = nil
+	}
+
+	depth := 0
+	for i := open + 1; i < close; i++ {
+		tok := p.tokens[i]
+		switch tok.Value {
+		case "(", "<", "[":
+			depth++
+		case ")", ">", "]":
+			depth--
+		case ",":
+			if depth == 0 {
+				flush()
+				continue
+			}
+		}
+		param = append(param, tok)
+	}
+	if len(param) > 0 && !(len(param) == 1 && param[0].Value == "void") {
+		flush()
+	}
+	return names
+}
+
+func indexValue(tokens []Token, value string) int {
+	for i, tok := range tokens {
+		if tok.Value == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// isFreeFunction checks for the definition of a function outside any class:
+// a return type, a name, parameters and a body, e.g. void destroy(Foo* p) {
+func (p *Parser) isFreeFunction() bool {
+	if !p.check(TokenIdent) || p.pos == 0 || p.pos+1 >= len(p.tokens) || p.tokens[p.pos+1].Value != "(" {
+		return false
+	}
+	switch prev := p.tokens[p.pos-1]; {
+	case prev.Type == TokenIdent, prev.Value == "*", prev.Value == "&", prev.Value == "&&", prev.Value == ">":
+	case prev.Type == TokenKeyword && !statementKeywords[prev.Value]:
+	default:
+		return false
+	}
+
+	close := p.matchingParen(p.pos + 1)
+	for i := close + 1; i < len(p.tokens) && i <= close+16; i++ {
+		switch p.tokens[i].Value {
+		case "{":
+			return true
+		case "(":
+			// noexcept(expr), throw(), __attribute__((cold))
+			if prev := p.tokens[i-1]; prev.Type != TokenIdent && prev.Value != "noexcept" && prev.Value != "throw" {
+				return false
+			}
+			i = p.matchingParen(i)
+		case ";", "=", "}", ",", ")", ":":
+			return false
+		}
+	}
+	return false
+}
+
+// statementKeywords start or continue statements rather than declare a
+// return type
+var statementKeywords = map[string]bool{
+	"return": true, "else": true, "new": true, "delete": true, "case": true,
+	"throw": true, "co_return": true, "co_await": true, "sizeof": true, "goto": true,
+}
+
+// matchingParen returns the index of the ) closing the ( at open, or the
+// last token index when it is unbalanced
+func (p *Parser) matchingParen(open int) int {
+	depth := 0
+	for i := open; i < len(p.tokens); i++ {
+		switch p.tokens[i].Value {
+		case "(":
+			depth++
+		case ")":
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+		if p.tokens[i].Type == TokenEOF {
+			return i
+		}
+	}
+	return len(p.tokens) - 1
+}
+
+// parseFreeFunction records the free function whose name is at the current
+// position. The parser stays at the name: classes declared in the body are
+// still found by the caller, as they were before free functions were
+// recorded.
+func (p *Parser) parseFreeFunction() {
+	start := p.pos
+	defer func() { p.pos = start }()
+
+	fn := Function{
+		File:       p.file,
+		Name:       p.current().Value,
+		StartLine:  p.current().Line,
+		ReturnsRaw: p.tokens[p.pos-1].Value == "*",
+	}
+	open := p.pos + 1
+	close := p.matchingParen(open)
+	fn.Params = p.paramNames(open, close)
+	fn.TakesOwnership = p.ownershipParams(open, close)
+
+	p.pos = close + 1
+	if trailing, raw := p.skipSpecifiers(); trailing {
+		fn.ReturnsRaw = raw
+	}
+	for !p.isAtEnd() && !p.checkValue("{") {
+		p.advance()
+	}
+	if p.isAtEnd() {
+		return
+	}
+	p.parseFunctionBody(&fn)
+	p.functions = append(p.functions, fn)
+	p.bodyEnd = p.pos
+}
@@ -0,0 +1,93 @@
+package parser
+
+// guard is an if or else branch enclosing code in a function body
+type guard struct {
+	cond []Token // condition of an if; nil for an else branch
+	end  int     // index of the last token of the branch
+}
+
+// guardAt reads the if or else at the current position, or reports false
+// when there is none
+func (p *Parser) guardAt() (guard, bool) {
+	i := p.pos + 1
+	var cond []Token
+	if p.checkKeyword("if") {
+		if i < len(p.tokens) && p.tokens[i].Value == "constexpr" {
+			i++
+		}
+		if i >= len(p.tokens) || p.tokens[i].Value != "(" {
+			return guard{}, false
+		}
+		close := p.matchingParen(i)
+		cond = p.tokens[i+1 : close]
+		i = close + 1
+	} else if !p.checkKeyword("else") {
+		return guard{}, false
+	}
+	return guard{cond: cond, end: p.branchEnd(i)}, true
+}
+
+// branchEnd returns the index of the last token of the statement starting
+// at i: its closing } for a block, otherwise its ;
+func (p *Parser) branchEnd(i int) int {
+	depth := 0
+	for ; i < len(p.tokens); i++ {
+		switch tok := p.tokens[i]; {
+		case tok.Type == TokenEOF:
+			return i
+		case tok.Value == "{" || tok.Value == "(":
+			depth++
+		case tok.Value == "}" || tok.Value == ")":
+			if depth--; depth <= 0 && tok.Value == "}" {
+				return i
+			}
+		case tok.Value == ";" && depth == 0:
+			return i
+		}
+	}
+	return len(p.tokens) - 1
+}
+
+// nullChecks reports whether cond only tests that name is not null: name,
+// this->name, name != nullptr, NULL != name and the like
+func nullChecks(cond []Token, name string) bool {
+	if len(cond) > 2 && cond[0].Value == "this" && cond[1].Value == "->" {
+		cond = cond[2:]
+	}
+	switch len(cond) {
+	case 1:
+		return cond[0].Value == name
+	case 3:
+		if cond[1].Value != "!=" {
+			return false
+		}
+		left, right := cond[0].Value, cond[2].Value
+		return left == name && isNull(right) || isNull(left) && right == name
+	case 5:
+		// this->name != nullptr with the this-> on the right side
+		return cond[1].Value == "!=" && isNull(cond[0].Value) &&
+			cond[2].Value == "this" && cond[3].Value == "->" && cond[4].Value == name
+	}
+	return false
+}
+
+// selfComparison reports whether cond compares this with the address of
+// an object: this != &other, &other == this, this == std::addressof(other)
+func selfComparison(cond []Token) bool {
+	this, compares, address := false, false, false
+	for i, tok := range cond {
+		switch tok.Value {
+		case "this":
+			this = this || i+1 == len(cond) || cond[i+1].Value != "->"
+		case "==", "!=":
+			compares = true
+		case "&", "addressof":
+			address = true
+		}
+	}
+	return this && compares && address
+}
+
+func isNull(value string) bool {
+	return value == "nullptr" || value == "NULL" || value == "0"
+}
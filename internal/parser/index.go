@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Index answers questions about a set of merged classes: lookup by name,
+// classes by file, unowned raw pointer members and inheritance
+type Index struct {
+	classes []*Class
+	byName  map[string]*Class // by qualified name
+	byShort map[string]*Class // by unqualified name, the first class of each
+	derived map[string][]string
+}
+
+// NewIndex indexes classes, typically the result of MergeClasses
+func NewIndex(classes []Class) *Index {
+	ptrs := make([]*Class, len(classes))
+	for i := range classes {
+		ptrs[i] = &classes[i]
+	}
+	return newIndex(ptrs)
+}
+
+// newIndex indexes classes in place
+func newIndex(classes []*Class) *Index {
+	idx := &Index{
+		classes: slices.Clone(classes),
+		byName:  make(map[string]*Class),
+		byShort: make(map[string]*Class),
+		derived: make(map[string][]string),
+	}
+	for _, class := range classes {
+		idx.byName[class.QualifiedName()] = class
+		if _, ok := idx.byShort[class.Name]; !ok {
+			idx.byShort[class.Name] = class
+		}
+	}
+	sort.Slice(idx.classes, func(i, j int) bool {
+		return idx.classes[i].QualifiedName() < idx.classes[j].QualifiedName()
+	})
+	for _, class := range idx.classes {
+		for _, base := range class.Bases {
+			name := baseName(base.Name)
+			idx.derived[name] = append(idx.derived[name], class.Name)
+		}
+	}
+	return idx
+}
+
+// Classes returns every indexed class, ordered by qualified name
+func (idx *Index) Classes() []*Class {
+	return idx.classes
+}
+
+// Lookup finds a class by qualified name, such as ui::Widget. A name that
+// matches no namespace-qualified class falls back to a class of the same
+// unqualified name, as namespaces written at use sites are often partial.
+func (idx *Index) Lookup(name string) (*Class, bool) {
+	name = strings.TrimPrefix(name, "::")
+	if class, ok := idx.byName[name]; ok {
+		return class, true
+	}
+	class, ok := idx.byShort[unqualified(name)]
+	return class, ok
+}
+
+// InFile returns the classes defined or implemented in file
+func (idx *Index) InFile(file string) []*Class {
+	file = cleanPath(file)
+	var result []*Class
+	for _, class := range idx.classes {
+		if slices.Contains(classFiles(class), file) {
+			result = append(result, class)
+		}
+	}
+	return result
+}
+
+// WithUnownedMembers returns the classes that have raw pointer members
+// without a leakcheck ownership annotation
+func (idx *Index) WithUnownedMembers() []*Class {
+	var result []*Class
+	for _, class := range idx.classes {
+		if len(UnownedMembers(class)) > 0 {
+			result = append(result, class)
+		}
+	}
+	return result
+}
+
+// UnownedMembers returns the raw pointer members of a class whose ownership
+// is not declared by an annotation
+func UnownedMembers(class *Class) []Member {
+	var result []Member
+	for _, m := range class.Members {
+		if m.IsPointer && !m.IsSmartPointer && m.Ownership == OwnershipUnknown {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// Bases returns the direct base classes of the named class in declaration
+// order; bases outside the index are included
+func (idx *Index) Bases(name string) []Base {
+	if class, ok := idx.Lookup(name); ok {
+		return class.Bases
+	}
+	return nil
+}
+
+// Derived returns the names of classes that directly inherit from name
+func (idx *Index) Derived(name string) []string {
+	return idx.derived[unqualified(strings.TrimPrefix(name, "::"))]
+}
+
+// Ancestors returns every indexed or external base of the named class,
+// nearest first. A base reached along several paths, such as a virtual
+// base in a diamond, is returned once.
+func (idx *Index) Ancestors(name string) []Base {
+	var result []Base
+	seen := map[string]bool{unqualified(name): true}
+	queue := idx.Bases(name)
+	for len(queue) > 0 {
+		base := queue[0]
+		queue = queue[1:]
+		key := baseName(base.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, base)
+		queue = append(queue, idx.Bases(key)...)
+	}
+	return result
+}
+
+// baseName strips template arguments and namespace qualifiers from a base
+// class as written, e.g. ns::Base<int> becomes Base
+func baseName(base string) string {
+	if i := strings.IndexByte(base, '<'); i >= 0 {
+		base = base[:i]
+	}
+	return unqualified(base)
+}
+
+func unqualified(name string) string {
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		return name[i+2:]
+	}
+	return name
+}
+
+// classFiles returns the files holding the class body or any of its
+// functions
+func classFiles(class *Class) []string {
+	var files []string
+	add := func(file string) {
+		if file != "" {
+			files = append(files, cleanPath(file))
+		}
+	}
+	add(class.DefinitionFile)
+	for _, fn := range []*Function{class.Constructor, class.Destructor, class.MoveConstructor, class.MoveAssignment} {
+		if fn != nil {
+			add(fn.File)
+		}
+	}
+	for i := range class.Methods {
+		add(class.Methods[i].File)
+	}
+	return files
+}
+
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
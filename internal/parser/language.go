@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Language is the source language of a file, guessed from its extension
+type Language string
+
+const (
+	LanguageC    Language = "c"
+	LanguageCPP  Language = "c++"
+	LanguageCUDA Language = "cuda"
+)
+
+// LanguageOf returns the language of a file: C for .c (but not .C, which is
+// C++), CUDA for .cu and .cuh, and C++ for everything else, including
+// headers, which do not say which language includes them
+func LanguageOf(filename string) Language {
+	ext := filepath.Ext(filename)
+	switch {
+	case ext == ".c":
+		return LanguageC
+	case strings.EqualFold(ext, ".cu") || strings.EqualFold(ext, ".cuh"):
+		return LanguageCUDA
+	}
+	return LanguageCPP
+}
@@ -1,7 +1,12 @@
 package parser
 
 import (
+	"bufio"
+	"errors"
+	"io"
+	"leakcheck/internal/charset"
 	"strings"
+	"sync"
 	"unicode"
 )
 
@@ -15,34 +20,103 @@ var keywords = map[string]bool{
 	"template": true, "typename": true, "namespace": true, "using": true,
 }
 
-// Lexer tokenizes C++ source code
+// twoCharOperators are the operators read as a single token
+var twoCharOperators = map[string]string{}
+
+// byteStrings holds the one-byte string for every byte value, so single
+// character tokens share storage
+var byteStrings [256]string
+
+func init() {
+	for _, op := range []string{"::", "->", "==", "!=", "<=", ">=", "&&", "||", "++", "--", "+=", "-=", "*=", "/="} {
+		twoCharOperators[op] = op
+	}
+	for i := range byteStrings {
+		byteStrings[i] = string([]byte{byte(i)})
+	}
+}
+
+// tokenSlices recycles token slices between files; see releaseTokens
+var tokenSlices = sync.Pool{
+	New: func() any { return new([]Token) },
+}
+
+// releaseTokens returns a token slice to the pool once nothing refers to it
+func releaseTokens(tokens []Token) {
+	clear(tokens)
+	tokens = tokens[:0]
+	tokenSlices.Put(&tokens)
+}
+
+// Lexer tokenizes C++ source code. It reads its input through a buffered
+// reader with a two-byte lookahead, so the source never has to be held in
+// memory as a whole.
 type Lexer struct {
-	input  string
-	pos    int
-	line   int
-	column int
-	tokens []Token
+	r         *bufio.Reader
+	encoding  charset.Encoding
+	line      int
+	column    int
+	lineBlank bool // only whitespace seen so far on the current line
+	directive bool // inside a preprocessor directive, ended by a NEWLINE token
+	err       error
+	tokens    []Token
+	comments  []Comment
+	// names interns identifier and number text, which repeats heavily
+	// within a file; scratch accumulates the bytes of the current one
+	names   map[string]string
+	scratch []byte
 }
 
 // NewLexer creates a new lexer for the given input
 func NewLexer(input string) *Lexer {
+	return NewReaderLexer(strings.NewReader(input))
+}
+
+// NewReaderLexer creates a lexer reading source from r, transcoding it to
+// UTF-8 when it is in another encoding
+func NewReaderLexer(r io.Reader) *Lexer {
+	text, encoding := charset.NewReader(r)
 	return &Lexer{
-		input:  input,
-		pos:    0,
-		line:   1,
-		column: 1,
+		r:         text,
+		encoding:  encoding,
+		line:      1,
+		column:    1,
+		lineBlank: true,
+		tokens:    (*tokenSlices.Get().(*[]Token))[:0],
+		names:     make(map[string]string),
 	}
 }
 
+// intern returns the string for b, allocating only the first time a value
+// is seen by this lexer
+func (l *Lexer) intern(b []byte) string {
+	if s, ok := l.names[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	l.names[s] = s
+	return s
+}
+
+// Encoding returns the encoding detected for the input
+func (l *Lexer) Encoding() charset.Encoding {
+	return l.encoding
+}
+
+// Err returns the first error reading the input, other than io.EOF
+func (l *Lexer) Err() error {
+	return l.err
+}
+
 // Tokenize processes the entire input and returns all tokens
 func (l *Lexer) Tokenize() []Token {
-	for l.pos < len(l.input) {
+	for !l.atEnd() {
 		l.skipWhitespaceAndComments()
-		if l.pos >= len(l.input) {
+		if l.atEnd() {
 			break
 		}
 
-		ch := l.input[l.pos]
+		ch := l.current()
 
 		// Check for :: scope operator before treating : as punctuation
 		if ch == ':' && l.peek() == ':' {
@@ -51,12 +125,20 @@ func (l *Lexer) Tokenize() []Token {
 			l.advance()
 			continue
 		}
+		// Parameter packs and fold expressions: Args&&... args, (ts + ...)
+		if ch == '.' && l.peek() == '.' && l.lookahead(2) == '.' {
+			l.addToken(TokenOperator, "...")
+			l.advance()
+			l.advance()
+			l.advance()
+			continue
+		}
 
 		switch {
 		case ch == '"' || ch == '\'':
 			l.readString(ch)
 		case ch == '#':
-			l.skipPreprocessor()
+			l.readHash()
 		case unicode.IsLetter(rune(ch)) || ch == '_':
 			l.readIdentifier()
 		case unicode.IsDigit(rune(ch)):
@@ -64,76 +146,151 @@ func (l *Lexer) Tokenize() []Token {
 		case l.isOperator(ch):
 			l.readOperator()
 		case l.isPunctuation(ch):
-			l.addToken(TokenPunctuation, string(ch))
+			l.addToken(TokenPunctuation, byteStrings[ch])
 			l.advance()
 		default:
 			l.advance()
 		}
 	}
 
+	if l.directive {
+		l.addToken(TokenNewline, "")
+		l.directive = false
+	}
 	l.tokens = append(l.tokens, Token{Type: TokenEOF, Line: l.line, Column: l.column})
 	return l.tokens
 }
 
-func (l *Lexer) advance() {
-	if l.pos < len(l.input) {
-		if l.input[l.pos] == '\n' {
-			l.line++
-			l.column = 1
-		} else {
-			l.column++
-		}
-		l.pos++
+// lookahead returns the byte n positions ahead of the current one, or 0 at
+// the end of the input
+func (l *Lexer) lookahead(n int) byte {
+	buf, err := l.r.Peek(n + 1)
+	if len(buf) > n {
+		return buf[n]
+	}
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) && l.err == nil {
+		l.err = err
+	}
+	return 0
+}
+
+// atEnd reports whether the input is exhausted
+func (l *Lexer) atEnd() bool {
+	_, err := l.r.Peek(1)
+	if err != nil && !errors.Is(err, io.EOF) && l.err == nil {
+		l.err = err
 	}
+	return err != nil
+}
+
+func (l *Lexer) current() byte {
+	return l.lookahead(0)
 }
 
 func (l *Lexer) peek() byte {
-	if l.pos+1 < len(l.input) {
-		return l.input[l.pos+1]
+	return l.lookahead(1)
+}
+
+// advance consumes the current byte and returns it
+func (l *Lexer) advance() byte {
+	ch, err := l.r.ReadByte()
+	if err != nil {
+		return 0
 	}
-	return 0
+	switch ch {
+	case '\n':
+		l.line++
+		l.column = 1
+		l.lineBlank = true
+	case ' ', '\t', '\r':
+		l.column++
+	default:
+		l.column++
+		l.lineBlank = false
+	}
+	return ch
 }
 
 func (l *Lexer) skipWhitespaceAndComments() {
-	for l.pos < len(l.input) {
-		ch := l.input[l.pos]
+	for !l.atEnd() {
+		ch := l.current()
 
-		if ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' {
+		if l.directive && ch == '\\' && (l.peek() == '\n' || l.peek() == '\r') {
+			// Line continuation inside a directive
+			l.advance()
+			if l.current() == '\r' {
+				l.advance()
+			}
+			l.advance()
+		} else if l.directive && ch == '\n' {
+			l.addToken(TokenNewline, "")
+			l.directive = false
+			l.advance()
+		} else if ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' {
 			l.advance()
 		} else if ch == '/' && l.peek() == '/' {
 			// Single-line comment
-			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
-				l.advance()
+			line, col, ownLine := l.line, l.column, l.lineBlank
+			var sb strings.Builder
+			for !l.atEnd() && l.current() != '\n' {
+				sb.WriteByte(l.advance())
 			}
+			l.addComment(sb.String(), line, col, ownLine)
 		} else if ch == '/' && l.peek() == '*' {
 			// Multi-line comment
-			l.advance() // skip /
-			l.advance() // skip *
-			for l.pos < len(l.input)-1 {
-				if l.input[l.pos] == '*' && l.peek() == '/' {
-					l.advance() // skip *
-					l.advance() // skip /
+			line, col, ownLine := l.line, l.column, l.lineBlank
+			var sb strings.Builder
+			sb.WriteByte(l.advance()) // skip /
+			sb.WriteByte(l.advance()) // skip *
+			for !l.atEnd() && l.peek() != 0 {
+				if l.current() == '*' && l.peek() == '/' {
+					sb.WriteByte(l.advance()) // skip *
+					sb.WriteByte(l.advance()) // skip /
 					break
 				}
-				l.advance()
+				sb.WriteByte(l.advance())
 			}
+			l.addComment(sb.String(), line, col, ownLine)
 		} else {
 			break
 		}
 	}
 }
 
-func (l *Lexer) skipPreprocessor() {
-	// Skip preprocessor directives (lines starting with #)
-	for l.pos < len(l.input) && l.input[l.pos] != '\n' {
-		// Handle line continuation
-		if l.input[l.pos] == '\\' && l.peek() == '\n' {
-			l.advance()
-			l.advance()
-			continue
-		}
+// addComment records a comment that started at line:col and ended at the
+// current position; ownLine is true when only whitespace preceded it
+func (l *Lexer) addComment(text string, line, col int, ownLine bool) {
+	l.comments = append(l.comments, Comment{
+		Text:    text,
+		Line:    line,
+		Column:  col,
+		EndLine: l.line,
+		OwnLine: ownLine,
+	})
+}
+
+// Comments returns the comments seen during tokenization
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
+// readHash starts a preprocessor directive, whose tokens run up to a
+// NEWLINE token for the Preprocessor, or inside one reads the # and ##
+// operators of macro bodies
+func (l *Lexer) readHash() {
+	if !l.directive {
+		l.addToken(TokenPunctuation, "#")
+		l.advance()
+		l.directive = true
+		return
+	}
+	if l.peek() == '#' {
+		l.addToken(TokenOperator, "##")
 		l.advance()
+	} else {
+		l.addToken(TokenOperator, "#")
 	}
+	l.advance()
 }
 
 func (l *Lexer) readString(quote byte) {
@@ -143,24 +300,20 @@ func (l *Lexer) readString(quote byte) {
 	sb.WriteByte(quote)
 	l.advance() // skip opening quote
 
-	for l.pos < len(l.input) {
-		ch := l.input[l.pos]
-		if ch == '\\' && l.pos+1 < len(l.input) {
-			sb.WriteByte(ch)
-			l.advance()
-			if l.pos < len(l.input) {
-				sb.WriteByte(l.input[l.pos])
-				l.advance()
+	for !l.atEnd() {
+		ch := l.current()
+		if ch == '\\' && l.peek() != 0 {
+			sb.WriteByte(l.advance())
+			if !l.atEnd() {
+				sb.WriteByte(l.advance())
 			}
 		} else if ch == quote {
-			sb.WriteByte(ch)
-			l.advance()
+			sb.WriteByte(l.advance())
 			break
 		} else if ch == '\n' {
 			break // Unterminated string
 		} else {
-			sb.WriteByte(ch)
-			l.advance()
+			sb.WriteByte(l.advance())
 		}
 	}
 
@@ -175,18 +328,18 @@ func (l *Lexer) readString(quote byte) {
 func (l *Lexer) readIdentifier() {
 	startLine := l.line
 	startCol := l.column
-	start := l.pos
+	l.scratch = l.scratch[:0]
 
-	for l.pos < len(l.input) {
-		ch := l.input[l.pos]
+	for !l.atEnd() {
+		ch := l.current()
 		if unicode.IsLetter(rune(ch)) || unicode.IsDigit(rune(ch)) || ch == '_' {
-			l.advance()
+			l.scratch = append(l.scratch, l.advance())
 		} else {
 			break
 		}
 	}
 
-	value := l.input[start:l.pos]
+	value := l.intern(l.scratch)
 	tokenType := TokenIdent
 	if keywords[value] {
 		tokenType = TokenKeyword
@@ -203,13 +356,13 @@ func (l *Lexer) readIdentifier() {
 func (l *Lexer) readNumber() {
 	startLine := l.line
 	startCol := l.column
-	start := l.pos
+	l.scratch = l.scratch[:0]
 
-	for l.pos < len(l.input) {
-		ch := l.input[l.pos]
+	for !l.atEnd() {
+		ch := l.current()
 		if unicode.IsDigit(rune(ch)) || ch == '.' || ch == 'x' || ch == 'X' ||
 			(ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F') {
-			l.advance()
+			l.scratch = append(l.scratch, l.advance())
 		} else {
 			break
 		}
@@ -217,7 +370,7 @@ func (l *Lexer) readNumber() {
 
 	l.tokens = append(l.tokens, Token{
 		Type:   TokenNumber,
-		Value:  l.input[start:l.pos],
+		Value:  l.intern(l.scratch),
 		Line:   startLine,
 		Column: startCol,
 	})
@@ -232,15 +385,11 @@ func (l *Lexer) isOperator(ch byte) bool {
 func (l *Lexer) readOperator() {
 	startLine := l.line
 	startCol := l.column
-	start := l.pos
 
 	// Handle multi-character operators
-	if l.pos+1 < len(l.input) {
-		two := l.input[l.pos : l.pos+2]
-		if two == "::" || two == "->" || two == "==" || two == "!=" ||
-			two == "<=" || two == ">=" || two == "&&" || two == "||" ||
-			two == "++" || two == "--" || two == "+=" || two == "-=" ||
-			two == "*=" || two == "/=" {
+	if next := l.peek(); next != 0 {
+		pair := [2]byte{l.current(), next}
+		if two, ok := twoCharOperators[string(pair[:])]; ok {
 			l.advance()
 			l.advance()
 			l.tokens = append(l.tokens, Token{
@@ -253,10 +402,9 @@ func (l *Lexer) readOperator() {
 		}
 	}
 
-	l.advance()
 	l.tokens = append(l.tokens, Token{
 		Type:   TokenOperator,
-		Value:  l.input[start:l.pos],
+		Value:  byteStrings[l.advance()],
 		Line:   startLine,
 		Column: startCol,
 	})
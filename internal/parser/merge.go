@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"cmp"
+	"slices"
+)
+
+// MergeStrategy decides whose definition a merged class keeps when its
+// header and implementation files disagree
+type MergeStrategy string
+
+const (
+	// MergePreferHeader keeps the header's members and combines the
+	// allocations and deallocations of differing bodies; the default
+	MergePreferHeader MergeStrategy = "prefer-header"
+	// MergePreferImpl keeps the members and function bodies of the
+	// implementation file over the header's
+	MergePreferImpl MergeStrategy = "prefer-impl"
+	// MergeUnion keeps the members of every file, matched by name, and
+	// picks functions as MergePreferHeader does
+	MergeUnion MergeStrategy = "union"
+)
+
+// MergeStrategies lists the supported merge strategies
+var MergeStrategies = []MergeStrategy{MergePreferHeader, MergePreferImpl, MergeUnion}
+
+// MergeConflict is a part of a class that two files define differently, so
+// merging them had to pick one or combine both
+type MergeConflict struct {
+	Class string   `json:"class"`
+	Kind  string   `json:"kind"` // members, constructor or destructor
+	Files []string `json:"files"`
+	// Kept is the file whose definition the merged class kept, or empty
+	// when both were combined
+	Kept string `json:"kept,omitempty"`
+}
+
+// Conflicts returns the merge conflicts of every class merged so far,
+// ordered by class
+func (r *ClassRegistry) Conflicts() []MergeConflict {
+	var conflicts []MergeConflict
+	for _, found := range r.conflicts {
+		conflicts = append(conflicts, found...)
+	}
+	slices.SortStableFunc(conflicts, func(a, b MergeConflict) int {
+		return cmp.Compare(a.Class, b.Class)
+	})
+	return conflicts
+}
+
+// conflictsOf lists what target and source define differently, before
+// source is merged into target
+func conflictsOf(key string, target, source *Class) []MergeConflict {
+	var conflicts []MergeConflict
+	if len(target.Members) > 0 && len(source.Members) > 0 && !sameMemberNames(target.Members, source.Members) {
+		conflicts = append(conflicts, MergeConflict{Class: key, Kind: "members", Files: []string{target.File, source.File}})
+	}
+	// Constructors taking different parameters are overloads, not a conflict
+	if differentBodies(target.Constructor, source.Constructor) && len(target.Constructor.Params) == len(source.Constructor.Params) {
+		conflicts = append(conflicts, MergeConflict{Class: key, Kind: "constructor", Files: []string{target.Constructor.File, source.Constructor.File}})
+	}
+	if differentBodies(target.Destructor, source.Destructor) {
+		conflicts = append(conflicts, MergeConflict{Class: key, Kind: "destructor", Files: []string{target.Destructor.File, source.Destructor.File}})
+	}
+	return conflicts
+}
+
+// resolveConflicts records which file's definition merged kept, given the
+// class before source was merged in
+func resolveConflicts(conflicts []MergeConflict, merged, before, source *Class) {
+	for i := range conflicts {
+		c := &conflicts[i]
+		switch c.Kind {
+		case "members":
+			if sameSlice(merged.Members, source.Members) {
+				c.Kept = c.Files[1]
+			} else if sameSlice(merged.Members, before.Members) {
+				c.Kept = c.Files[0]
+			}
+		case "constructor":
+			c.Kept = keptFile(c.Files, merged.Constructor, before.Constructor, source.Constructor)
+		case "destructor":
+			c.Kept = keptFile(c.Files, merged.Destructor, before.Destructor, source.Destructor)
+		}
+	}
+}
+
+// keptFile returns the file of whichever of target and source merged is,
+// or "" when merged combines both
+func keptFile(files []string, merged, target, source *Function) string {
+	switch merged {
+	case target:
+		return files[0]
+	case source:
+		return files[1]
+	}
+	return ""
+}
+
+// sameSlice reports whether a and b are the same non-empty slice
+func sameSlice(a, b []Member) bool {
+	return len(a) > 0 && len(a) == len(b) && &a[0] == &b[0]
+}
+
+// sameMemberNames reports whether a and b declare the same members
+func sameMemberNames(a, b []Member) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	names := make(map[string]bool, len(a))
+	for _, m := range a {
+		names[m.Name] = true
+	}
+	for _, m := range b {
+		if !names[m.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// differentBodies reports whether a and b are both defined with a body and
+// the bodies differ. Copies of one file found elsewhere are not a conflict.
+func differentBodies(a, b *Function) bool {
+	if a == nil || b == nil || a.EndLine == 0 || b.EndLine == 0 {
+		return false
+	}
+	return a.StartLine != b.StartLine || a.EndLine != b.EndLine || !slices.Equal(a.Params, b.Params) ||
+		!slices.EqualFunc(a.Allocations, b.Allocations, func(x, y Allocation) bool { return x.VarName == y.VarName }) ||
+		!slices.EqualFunc(a.Deallocations, b.Deallocations, func(x, y Deallocation) bool { return x.VarName == y.VarName })
+}
+
+// unionMembers returns the members of a followed by those of b a does not
+// declare
+func unionMembers(a, b []Member) []Member {
+	if len(a) == 0 {
+		return b
+	}
+	union := slices.Clone(a)
+	for _, m := range b {
+		if !slices.ContainsFunc(union, func(u Member) bool { return u.Name == m.Name }) {
+			union = append(union, m)
+		}
+	}
+	if len(union) == len(a) {
+		return a
+	}
+	return union
+}
+
+// accumulate returns chosen with the allocations and deallocations of
+// other, a different body of the same special member, such as a
+// constructor overload defined in another file, of members chosen does not
+// already handle
+func accumulate(chosen, other *Function) *Function {
+	combined := *chosen
+	combined.Allocations = slices.Clone(chosen.Allocations)
+	for _, alloc := range other.Allocations {
+		if !slices.ContainsFunc(combined.Allocations, func(a Allocation) bool { return a.VarName == alloc.VarName }) {
+			combined.Allocations = append(combined.Allocations, alloc)
+		}
+	}
+	combined.Deallocations = slices.Clone(chosen.Deallocations)
+	for _, dealloc := range other.Deallocations {
+		if !slices.ContainsFunc(combined.Deallocations, func(d Deallocation) bool { return d.VarName == dealloc.VarName }) {
+			combined.Deallocations = append(combined.Deallocations, dealloc)
+		}
+	}
+	return &combined
+}
+
+// combineBodies returns chosen, picked from target and source, combined
+// with the other one when both are bodies that differ
+func combineBodies(chosen, target, source *Function) *Function {
+	if !differentBodies(target, source) {
+		return chosen
+	}
+	if chosen == target {
+		return accumulate(chosen, source)
+	}
+	return accumulate(chosen, target)
+}
+
+// matchingMethod returns the index of the method in methods that fn
+// declares or defines again, preferring one with as many parameters, or
+// -1 when fn is new. Two bodies match only when one copies the other, so
+// overloads defined in different files are all kept.
+func matchingMethod(methods []Function, fn *Function) int {
+	match := -1
+	for i := range methods {
+		m := &methods[i]
+		switch {
+		case m.Name != fn.Name:
+		case m.EndLine > 0 && fn.EndLine > 0:
+			if !differentBodies(m, fn) {
+				return i
+			}
+		case len(m.Params) == len(fn.Params):
+			return i
+		case match < 0:
+			match = i
+		}
+	}
+	return match
+}
+
+// compareParts orders the parts of a class so the merge does not depend on
+// the order files were parsed in: the class body first, headers before
+// implementation files, then by file and line
+func compareParts(a, b *Class) int {
+	rank := func(c *Class) int {
+		r := 0
+		if c.DefinitionFile == "" {
+			r += 2
+		}
+		if !isHeaderFile(c.File) {
+			r++
+		}
+		return r
+	}
+	return cmp.Or(
+		cmp.Compare(rank(a), rank(b)),
+		cmp.Compare(a.File, b.File),
+		cmp.Compare(a.StartLine, b.StartLine),
+	)
+}
+
+// preferImplementation picks the implementation file's definition with a
+// body over the header's, when one of each is given
+func preferImplementation(target, source *Function) (*Function, bool) {
+	if target == nil || source == nil || isHeaderFile(target.File) == isHeaderFile(source.File) {
+		return target, false
+	}
+	if isHeaderFile(target.File) && source.EndLine > 0 {
+		return source, true
+	}
+	if isHeaderFile(source.File) && target.EndLine > 0 {
+		return target, true
+	}
+	return target, false
+}
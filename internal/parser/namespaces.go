@@ -0,0 +1,106 @@
+package parser
+
+import "strings"
+
+// namespaceScope is a namespace body the parser is in
+type namespaceScope struct {
+	name      string // as written, e.g. audio::detail; inline parts are left out
+	anonymous bool
+	depth     int // file-scope brace depth of its {
+}
+
+// anonymousNamespace is the qualifier standing for the anonymous namespace
+// of file, which is distinct in every translation unit
+func anonymousNamespace(file string) string {
+	return "(anonymous " + file + ")"
+}
+
+// isAnonymous reports whether namespace is or is nested in an anonymous
+// namespace
+func isAnonymous(namespace string) bool {
+	return strings.Contains(namespace, "(anonymous ")
+}
+
+// openNamespace reads namespace audio {, namespace audio::detail {,
+// inline namespace v1 { or namespace { at the current position and enters
+// it. A namespace alias or using-directive is left at its ;.
+func (p *Parser) openNamespace() {
+	inline := p.checkValue("inline")
+	if inline {
+		p.advance()
+	}
+	p.advance() // skip 'namespace'
+	var parts []string
+	named := false
+	for !p.isAtEnd() && !p.checkValue("{") && !p.checkValue(";") && !p.checkValue("=") {
+		if end := p.attributeEnd(p.pos); end > p.pos {
+			p.pos = end
+			continue
+		}
+		switch {
+		case p.checkValue("inline"):
+			// namespace audio::inline v1 {
+			inline = true
+		case p.check(TokenIdent):
+			named = true
+			if !inline {
+				parts = append(parts, p.current().Value)
+			}
+			inline = false
+		}
+		p.advance()
+	}
+	if !p.matchValue("{") {
+		return
+	}
+	p.depth++
+	p.namespaces = append(p.namespaces, namespaceScope{
+		name:      strings.Join(parts, "::"),
+		anonymous: !named,
+		depth:     p.depth,
+	})
+}
+
+// closeBrace leaves the namespace a } at file scope closes, if any
+func (p *Parser) closeBrace() {
+	if n := len(p.namespaces); n > 0 && p.namespaces[n-1].depth == p.depth {
+		p.namespaces = p.namespaces[:n-1]
+	}
+	p.depth = max(p.depth-1, 0)
+}
+
+// namespace returns the qualified namespace the parser is in, with inline
+// namespaces left out, as they are transparent to their enclosing one, and
+// anonymous ones made unique to the file
+func (p *Parser) namespace() string {
+	var parts []string
+	for _, ns := range p.namespaces {
+		switch {
+		case ns.anonymous:
+			parts = append(parts, anonymousNamespace(p.file))
+		case ns.name != "":
+			parts = append(parts, ns.name)
+		}
+	}
+	return strings.Join(parts, "::")
+}
+
+// typeScope returns the scope aliases are looked up from in class, which
+// anonymous namespaces do not qualify
+func (p *Parser) typeScope(class string) string {
+	var parts []string
+	for _, ns := range p.namespaces {
+		if ns.name != "" {
+			parts = append(parts, ns.name)
+		}
+	}
+	return strings.Join(append(parts, class), "::")
+}
+
+// qualify prefixes name with namespace, when there is one
+func qualify(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "::" + name
+}
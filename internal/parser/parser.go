@@ -1,54 +1,197 @@
 package parser
 
 import (
+	"context"
+	"leakcheck/internal/charset"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
 // Parser parses C++ source files and extracts class information
 type Parser struct {
-	tokens  []Token
-	pos     int
-	file    string
-	classes []Class
+	tokens     []Token
+	comments   []Comment
+	pos        int
+	file       string
+	classes    []Class
+	functions  []Function // free functions
+	globals    []Global   // file-scope pointers initialized with new
+	bodyEnd    int        // token index past the last free function body, which parse walks again
+	ctx        context.Context
+	types      *TypeTable
+	scope      string // class whose body is being parsed, for resolving nested types
+	namespaces []namespaceScope
+	template   templateHead // the last template head read at file scope
+	depth      int          // brace depth at file scope, for closing namespaces
+	steps      int          // tokens advanced over, for periodic cancellation checks
+	err        error        // set when ctx is cancelled mid-parse
 }
 
-// ParseFile parses a single C++ file
-func ParseFile(filename string) ([]Class, error) {
-	content, err := os.ReadFile(filename)
+// cancelCheckInterval is how many token advances run between checks for
+// cancellation; a power of two
+const cancelCheckInterval = 1024
+
+// maxDeclarationTokens bounds how far a member declaration is looked for
+const maxDeclarationTokens = 64
+
+// ParseFile parses a single C++ file. It stops early with the context's
+// error when ctx is cancelled.
+func ParseFile(ctx context.Context, filename string) ([]Class, error) {
+	classes, _, err := ParseFileLines(ctx, filename)
+	return classes, err
+}
+
+// ParseFileLines is like ParseFile and also reports the number of source
+// lines tokenized
+func ParseFileLines(ctx context.Context, filename string) ([]Class, int, error) {
+	unit, err := ParseUnit(ctx, filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	return unit.Classes, unit.Lines, nil
+}
+
+// ParseUnit is like ParseFileLines and also returns the free functions the
+// file defines, for whole-program analysis
+func ParseUnit(ctx context.Context, filename string) (*Unit, error) {
+	return ParseUnitFlags(ctx, filename, nil)
+}
+
+// ParseUnitFlags is like ParseUnit for a file compiled with flags, which
+// may be nil
+func ParseUnitFlags(ctx context.Context, filename string, flags *BuildFlags) (*Unit, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
 	absPath, _ := filepath.Abs(filename)
-	lexer := NewLexer(string(content))
-	tokens := lexer.Tokenize()
+	lexer := NewReaderLexer(f)
+	raw := lexer.Tokenize()
+	defer releaseTokens(raw)
+	if err := lexer.Err(); err != nil {
+		return nil, err
+	}
+
+	if encoding := lexer.Encoding(); encoding != charset.UTF8 {
+		slog.Info("transcoded source to UTF-8", "file", absPath, "encoding", encoding)
+	}
 
+	types := NewTypeTable()
+	pp := NewPreprocessor(absPath)
+	pp.Configure(flags)
+	pp.OnInclude = types.Collect
+	tokens := pp.Process(raw)
+	types.Collect(tokens)
 	parser := &Parser{
-		tokens: tokens,
-		pos:    0,
-		file:   absPath,
+		tokens:   tokens,
+		types:    types,
+		comments: lexer.Comments(),
+		pos:      0,
+		file:     absPath,
+		ctx:      ctx,
 	}
 
-	return parser.parse(), nil
+	eof := raw[len(raw)-1]
+	lines := eof.Line
+	if eof.Column == 1 && lines > 1 {
+		lines-- // the EOF token sits on the empty line after the final newline
+	}
+	classes, err := parser.parse()
+	releaseTokens(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return &Unit{Classes: classes, Functions: parser.functions, Globals: parser.globals, Lines: lines}, nil
 }
 
-func (p *Parser) parse() []Class {
+func (p *Parser) parse() ([]Class, error) {
 	// First pass: parse inline class definitions
 	for !p.isAtEnd() {
 		if p.matchKeyword("class") || p.matchKeyword("struct") {
 			if class := p.parseClass(); class != nil {
 				p.classes = append(p.classes, *class)
 			}
+		} else if p.checkValue("namespace") || p.checkValue("inline") && p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Value == "namespace" {
+			p.openNamespace()
+		} else if p.checkValue("{") {
+			p.depth++
+			p.advance()
+		} else if p.checkValue("}") {
+			p.closeBrace()
+			p.advance()
+		} else if p.checkKeyword("template") {
+			p.readTemplateHead()
+		} else if p.checkValue("requires") {
+			// template <typename T> requires Allocatable<T> class Pool
+			p.pos = p.requiresClauseEnd(p.pos)
+		} else if p.checkValue("concept") {
+			// concept Allocatable = requires (T t) { ... };
+			for depth := 0; !p.isAtEnd() && !(depth == 0 && p.checkValue(";")); p.advance() {
+				if p.checkValue("{") {
+					depth++
+				} else if p.checkValue("}") {
+					depth--
+				}
+			}
 		} else if p.isOutOfClassMethod() {
 			// Parse out-of-class method definitions (ClassName::MethodName)
 			p.parseOutOfClassMethod()
+		} else if p.isFreeFunction() {
+			p.parseFreeFunction()
+			p.advance()
+		} else if p.checkKeyword("new") {
+			if g := p.globalAt(); g != nil {
+				p.globals = append(p.globals, *g)
+			}
+			p.advance()
 		} else {
 			p.advance()
 		}
 	}
-	return p.classes
+	if p.err != nil {
+		return nil, p.err
+	}
+	for i := range p.classes {
+		p.classes[i].Locations = []SourceLocation{p.location(&p.classes[i])}
+		p.classes[i].Language = LanguageOf(p.file)
+	}
+	return p.classes, nil
+}
+
+// location returns the span of the parsed file covered by class
+func (p *Parser) location(class *Class) SourceLocation {
+	loc := SourceLocation{File: p.file, StartLine: class.StartLine, EndLine: class.EndLine, Kind: LocationDefinition}
+	if class.DefinitionFile != "" {
+		return loc
+	}
+	loc.Kind = LocationImplementation
+	fns := []*Function{class.Constructor, class.Destructor, class.MoveConstructor}
+	for i := range class.Methods {
+		fns = append(fns, &class.Methods[i])
+	}
+	for _, fn := range fns {
+		if fn == nil {
+			continue
+		}
+		if loc.StartLine == 0 || fn.StartLine < loc.StartLine {
+			loc.StartLine = fn.StartLine
+		}
+		loc.EndLine = max(loc.EndLine, fn.EndLine)
+	}
+	return loc
+}
+
+// skipped logs a construct the parser recognizes but does not model
+func (p *Parser) skipped(construct string, line int) {
+	slog.Info("skipped construct", "construct", construct, "file", p.file, "line", line)
 }
 
 // isOutOfClassMethod checks for pattern: Type ClassName::MethodName(
@@ -87,10 +230,19 @@ func (p *Parser) parseOutOfClassMethod() {
 	startLine := p.current().Line
 
 	// Collect tokens until we find ::
-	var className string
+	var className, args string
+	returnsRaw := false
 	for !p.isAtEnd() && !p.checkValue("::") {
+		if end := p.angleEnd(p.pos); p.checkValue("<") && end < len(p.tokens) && p.tokens[end].Value == "::" {
+			// Template arguments, as in Pool<T>::shrink
+			args = p.templateArgs(p.pos, end)
+			p.pos = end
+			continue
+		}
 		if p.check(TokenIdent) {
 			className = p.current().Value // Last ident before :: is class name
+			args = ""
+			returnsRaw = p.pos > startPos && p.tokens[p.pos-1].Value == "*"
 		}
 		p.advance()
 	}
@@ -99,6 +251,28 @@ func (p *Parser) parseOutOfClassMethod() {
 		return
 	}
 
+	// Namespace qualifiers, as in audio::Buffer::fill
+	var qualifiers []string
+	for p.check(TokenIdent) {
+		next, nextArgs := p.pos+1, ""
+		if next < len(p.tokens) && p.tokens[next].Value == "<" {
+			end := p.angleEnd(next)
+			nextArgs = p.templateArgs(next, end)
+			next = end
+		}
+		if next >= len(p.tokens) || p.tokens[next].Value != "::" {
+			break
+		}
+		qualifiers = append(qualifiers, className)
+		className, args = p.current().Value, nextArgs
+		p.pos = next + 1
+	}
+	namespace := p.namespace()
+	if len(qualifiers) > 0 {
+		namespace = qualify(namespace, strings.Join(qualifiers, "::"))
+	}
+	specialization := p.specialization(args, startPos)
+
 	// Check for destructor (~)
 	isDestructor := p.checkValue("~")
 	if isDestructor {
@@ -112,56 +286,82 @@ func (p *Parser) parseOutOfClassMethod() {
 	methodName := p.current().Value
 	p.advance()
 
+	// operator=, operator[] etc.
+	if methodName == "operator" {
+		for !p.isAtEnd() && !p.checkValue("(") && !p.checkValue(";") {
+			methodName += p.current().Value
+			p.advance()
+		}
+	}
+
 	// Skip parameters
+	paramsOpen := p.pos
 	if !p.matchValue("(") {
 		return
 	}
-	parenCount := 1
-	for !p.isAtEnd() && parenCount > 0 {
-		if p.checkValue("(") {
-			parenCount++
-		} else if p.checkValue(")") {
-			parenCount--
-		}
-		p.advance()
+	p.skipParams()
+
+	fn := &Function{
+		File:           p.file,
+		Name:           methodName,
+		IsDestructor:   isDestructor,
+		ReturnsRaw:     returnsRaw,
+		StartLine:      startLine,
+		Params:         p.paramNames(paramsOpen, p.pos-1),
+		TakesOwnership: p.ownershipParams(paramsOpen, p.pos-1),
+	}
+	if methodName == className || methodName == "operator=" {
+		fn.MoveSource = p.moveParam(paramsOpen, p.pos-1, className)
+	}
+	if trailing, raw := p.skipSpecifiers(); trailing {
+		fn.ReturnsRaw = raw
 	}
 
-	// Skip initializer list for constructors
+	// Parse initializer list for constructors
 	if p.checkValue(":") && !isDestructor {
-		p.advance()
-		for !p.isAtEnd() && !p.checkValue("{") && !p.checkValue(";") {
-			p.advance()
-		}
+		p.parseInitializerList(fn)
 	}
 
-	// Parse body
-	if !p.checkValue("{") {
+	// Parse body; Foo::~Foo() = default; has none but still defines the
+	// destructor
+	fn.Defaulted = isDestructor && p.defaulted()
+	if !p.checkValue("{") && !fn.Defaulted {
 		return
 	}
 
-	fn := &Function{
-		Name:         methodName,
-		IsDestructor: isDestructor,
-		StartLine:    startLine,
+	if !fn.Defaulted {
+		p.parseFunctionBody(fn)
+	}
+	if methodName == "operator=" {
+		fn.CopyAndSwap = p.copyAndSwap(fn, paramsOpen, className)
 	}
 
-	p.parseFunctionBody(fn)
-
-	// Find or create class to attach this method to
+	// Find or create class to attach this method to; an unqualified
+	// Buffer::fill, as after using namespace audio, takes any Buffer
 	var targetClass *Class
 	for i := range p.classes {
-		if p.classes[i].Name == className {
+		if p.classes[i].Name == className && p.classes[i].Namespace == namespace && p.classes[i].Specialization == specialization {
 			targetClass = &p.classes[i]
 			break
 		}
 	}
+	for i := range p.classes {
+		if targetClass != nil || namespace != "" {
+			break
+		}
+		if p.classes[i].Name == className && p.classes[i].Specialization == specialization {
+			targetClass = &p.classes[i]
+		}
+	}
 
 	if targetClass == nil {
 		// Create a placeholder class for this method
 		newClass := Class{
-			Name:    className,
-			File:    p.file,
-			Methods: []Function{},
+			Name:           className,
+			Namespace:      namespace,
+			Specialization: specialization,
+			File:           p.file,
+			Methods:        []Function{},
 		}
 		p.classes = append(p.classes, newClass)
 		targetClass = &p.classes[len(p.classes)-1]
@@ -170,27 +370,74 @@ func (p *Parser) parseOutOfClassMethod() {
 	// Attach method to class
 	if isDestructor {
 		targetClass.Destructor = fn
+	} else if methodName == className && fn.MoveSource != "" {
+		targetClass.MoveConstructor = fn
 	} else if methodName == className {
 		targetClass.Constructor = fn
 	} else {
 		targetClass.Methods = append(targetClass.Methods, *fn)
+		if fn.MoveSource != "" {
+			targetClass.MoveAssignment = fn
+		}
 	}
 }
 
 func (p *Parser) parseClass() *Class {
 	// Get class name
 	if !p.check(TokenIdent) {
+		p.skipped("anonymous class or struct", p.current().Line)
 		return nil
 	}
 
 	className := p.current().Value
 	startLine := p.current().Line
+	// Bases of a struct are public unless stated otherwise
+	defaultAccess := "private"
+	if p.tokens[p.pos-1].Value == "struct" {
+		defaultAccess = "public"
+	}
+	start := p.pos - 1
 	p.advance()
 
-	// Skip inheritance declaration
+	// template <> class Cache<std::string> and partial specializations
+	var specialization string
+	if p.checkValue("<") {
+		end := p.angleEnd(p.pos)
+		specialization = p.specialization(p.templateArgs(p.pos, end), start)
+		p.pos = end
+	}
+
+	// Collect the base classes from the inheritance declaration
+	var bases []Base
+	var base []Token
+	next := Base{Access: defaultAccess}
+	inBaseList := false
+	angleDepth := 0
 	for !p.isAtEnd() && !p.checkValue("{") && !p.checkValue(";") {
+		tok := p.current()
+		switch {
+		case tok.Value == ":" && !inBaseList:
+			inBaseList = true
+		case !inBaseList:
+		case tok.Value == "<":
+			angleDepth++
+			base = append(base, tok)
+		case tok.Value == ">":
+			angleDepth--
+			base = append(base, tok)
+		case tok.Value == "," && angleDepth == 0:
+			bases = appendBase(bases, next, base)
+			base, next = nil, Base{Access: defaultAccess}
+		case angleDepth == 0 && (tok.Value == "public" || tok.Value == "protected" || tok.Value == "private"):
+			next.Access = tok.Value
+		case angleDepth == 0 && tok.Value == "virtual":
+			next.Virtual = true
+		default:
+			base = append(base, tok)
+		}
 		p.advance()
 	}
+	bases = appendBase(bases, next, base)
 
 	// Forward declaration (ends with ;)
 	if p.checkValue(";") {
@@ -202,14 +449,21 @@ func (p *Parser) parseClass() *Class {
 	}
 
 	class := &Class{
-		Name:      className,
-		File:      p.file,
-		StartLine: startLine,
-		Members:   []Member{},
-		Methods:   []Function{},
-	}
-
-	// Parse class body
+		Name:           className,
+		Namespace:      p.namespace(),
+		Specialization: specialization,
+		File:           p.file,
+		DefinitionFile: p.file,
+		StartLine:      startLine,
+		Bases:          bases,
+		Members:        []Member{},
+		Methods:        []Function{},
+	}
+
+	// Parse class body, resolving nested type names from within the class
+	outer := p.scope
+	p.scope = p.typeScope(className)
+	defer func() { p.scope = outer }()
 	braceCount := 1
 	for !p.isAtEnd() && braceCount > 0 {
 		if p.checkValue("{") {
@@ -224,21 +478,58 @@ func (p *Parser) parseClass() *Class {
 		} else if p.checkKeyword("public") || p.checkKeyword("private") || p.checkKeyword("protected") {
 			p.advance()
 			p.matchValue(":") // skip the colon
+		} else if p.checkValue("typedef") || p.checkValue("using") {
+			// Nested type aliases are resolved through p.types
+			for depth := 0; !p.isAtEnd() && !(depth == 0 && (p.checkValue(";") || p.checkValue("}"))); p.advance() {
+				if p.checkValue("{") {
+					depth++
+				} else if p.checkValue("}") {
+					depth--
+				}
+			}
+			p.matchValue(";")
+		} else if p.checkKeyword("template") {
+			// template <typename... Ts> before a member, and its
+			// requires-clause; the member follows
+			p.advance()
+			if p.checkValue("<") {
+				p.pos = p.angleEnd(p.pos)
+			}
+			if p.checkValue("requires") {
+				p.pos = p.requiresClauseEnd(p.pos)
+			}
+		} else if end := p.bitfieldEnd(); end >= 0 {
+			// unsigned flags : 3; holds no pointer
+			p.pos = end + 1
+		} else if p.checkValue("friend") {
+			if friend := p.parseFriend(); friend != nil {
+				class.Friends = append(class.Friends, *friend)
+			}
 		} else if p.isDestructorStart(className) {
 			if fn := p.parseDestructor(className); fn != nil {
 				class.Destructor = fn
 			}
 		} else if p.isConstructorStart(className) {
 			if fn := p.parseConstructor(className); fn != nil {
-				class.Constructor = fn
+				if fn.MoveSource != "" {
+					class.MoveConstructor = fn
+				} else {
+					class.Constructor = fn
+				}
 			}
 		} else if p.isMemberDeclaration() {
 			if member := p.parseMember(); member != nil {
 				class.Members = append(class.Members, *member)
 			}
+		} else if decl, ok := p.declarationAt(p.pos); ok && len(decl) > 1 {
+			// A member holding no pointer, such as std::function<void(Foo*)>
+			p.pos += len(decl) + 1
 		} else if p.isFunctionStart() {
-			if fn := p.parseMethod(); fn != nil {
+			if fn := p.parseMethod(className); fn != nil {
 				class.Methods = append(class.Methods, *fn)
+				if fn.MoveSource != "" {
+					class.MoveAssignment = fn
+				}
 			}
 		} else {
 			p.advance()
@@ -248,59 +539,108 @@ func (p *Parser) parseClass() *Class {
 	return class
 }
 
-func (p *Parser) isDestructorStart(className string) bool {
-	if p.checkValue("~") {
-		// Look ahead for class name
-		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Value == className {
-			return true
+// parseFriend parses a friend declaration at 'friend', through its ; or the
+// body of an inline friend function, which is recorded as a free function
+func (p *Parser) parseFriend() *Friend {
+	friend := &Friend{Line: p.current().Line}
+	p.advance() // skip 'friend'
+	namePos, depth := -1, 0
+	for !p.isAtEnd() && !p.checkValue(";") && !p.checkValue("{") && !p.checkValue("}") {
+		tok := p.current()
+		switch {
+		case tok.Value == "<":
+			depth++
+		case tok.Value == ">":
+			depth--
+		case depth > 0 || friend.Function:
+		case tok.Value == "operator":
+			// operator<<, operator() etc.: the name runs to the parameter list
+			if p.tokens[p.pos-1].Value != "::" {
+				friend.Name = ""
+			} else if friend.Name != "" {
+				friend.Name += "::"
+			}
+			friend.Name += tok.Value
+			namePos = p.pos
+			for p.advance(); !p.isAtEnd() && (!p.checkValue("(") || friend.Name == "operator"); p.advance() {
+				friend.Name += p.current().Value
+			}
+			continue
+		case tok.Value == "(" && namePos >= 0:
+			friend.Function = true
+			p.pos = p.matchingParen(p.pos)
+		case tok.Type == TokenIdent:
+			if p.tokens[p.pos-1].Value != "::" {
+				friend.Name = ""
+			} else if friend.Name != "" {
+				friend.Name += "::"
+			}
+			friend.Name += tok.Value
+			namePos = p.pos
 		}
+		p.advance()
 	}
-	// virtual ~ClassName
-	if p.checkKeyword("virtual") {
-		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Value == "~" {
-			return true
+	if friend.Name == "" {
+		p.matchValue(";")
+		return nil
+	}
+
+	if p.checkValue("{") && friend.Function {
+		// friend void destroy(Pool& p) { ... } defines a free function
+		if p.tokens[namePos+1].Value == "(" {
+			p.pos = namePos
+			p.parseFreeFunction()
+			p.pos = p.bodyEnd
+		} else {
+			for depth := 0; !p.isAtEnd(); p.advance() {
+				if p.checkValue("{") {
+					depth++
+				} else if p.checkValue("}") {
+					if depth--; depth == 0 {
+						p.advance()
+						break
+					}
+				}
+			}
 		}
 	}
-	return false
+	p.matchValue(";")
+	return friend
+}
+
+func (p *Parser) isDestructorStart(className string) bool {
+	// ~ClassName, virtual ~ClassName, [[nodiscard]] constexpr ~ClassName
+	i := p.declSpecifierEnd(p.pos)
+	return i+1 < len(p.tokens) && p.tokens[i].Value == "~" && p.tokens[i+1].Value == className
 }
 
 func (p *Parser) isConstructorStart(className string) bool {
-	if p.check(TokenIdent) && p.current().Value == className {
-		// Look ahead for (
-		if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Value == "(" {
-			return true
-		}
-	}
-	return false
+	// ClassName(, explicit ClassName(, constexpr ClassName(
+	i := p.declSpecifierEnd(p.pos)
+	return i+1 < len(p.tokens) && p.tokens[i].Type == TokenIdent && p.tokens[i].Value == className && p.tokens[i+1].Value == "("
 }
 
 func (p *Parser) parseDestructor(className string) *Function {
 	startLine := p.current().Line
 
-	// Skip virtual if present
-	if p.checkKeyword("virtual") {
-		p.advance()
-	}
-
-	// Skip ~
+	// Skip virtual, constexpr etc., ~ and the class name
+	p.pos = p.declSpecifierEnd(p.pos)
 	p.matchValue("~")
-
-	// Skip class name
 	p.advance()
 
 	// Skip parameters ()
 	if !p.matchValue("(") {
 		return nil
 	}
-	for !p.isAtEnd() && !p.checkValue(")") {
-		p.advance()
-	}
-	p.matchValue(")")
+	p.skipParams()
+	p.skipSpecifiers()
 
 	fn := &Function{
+		File:         p.file,
 		Name:         "~" + className,
 		IsDestructor: true,
 		StartLine:    startLine,
+		Defaulted:    p.defaulted(),
 	}
 
 	// Parse body or skip declaration
@@ -319,29 +659,30 @@ func (p *Parser) parseDestructor(className string) *Function {
 func (p *Parser) parseConstructor(className string) *Function {
 	startLine := p.current().Line
 
-	// Skip class name
+	// Skip explicit, constexpr etc. and the class name
+	p.pos = p.declSpecifierEnd(p.pos)
 	p.advance()
 
 	// Parse parameters
+	paramsOpen := p.pos
 	if !p.matchValue("(") {
 		return nil
 	}
-	for !p.isAtEnd() && !p.checkValue(")") {
-		p.advance()
-	}
-	p.matchValue(")")
+	p.skipParams()
 
 	fn := &Function{
-		Name:      className,
-		StartLine: startLine,
+		File:           p.file,
+		Name:           className,
+		StartLine:      startLine,
+		Params:         p.paramNames(paramsOpen, p.pos-1),
+		TakesOwnership: p.ownershipParams(paramsOpen, p.pos-1),
+		MoveSource:     p.moveParam(paramsOpen, p.pos-1, className),
 	}
+	p.skipSpecifiers()
 
-	// Skip initializer list
+	// Parse initializer list
 	if p.checkValue(":") {
-		p.advance()
-		for !p.isAtEnd() && !p.checkValue("{") && !p.checkValue(";") {
-			p.advance()
-		}
+		p.parseInitializerList(fn)
 	}
 
 	// Parse body or skip declaration
@@ -357,11 +698,21 @@ func (p *Parser) parseConstructor(className string) *Function {
 	return fn
 }
 
-func (p *Parser) parseMethod() *Function {
+func (p *Parser) parseMethod(className string) *Function {
 	startLine := p.current().Line
 
 	// Skip return type and modifiers
+	isStatic := false
 	for !p.isAtEnd() && !p.checkValue("(") && !p.checkValue(";") && !p.checkValue("{") {
+		if end := p.attributeEnd(p.pos); end > p.pos {
+			p.pos = end // [[deprecated("...")]] has parentheses of its own
+			continue
+		}
+		if p.checkValue("requires") {
+			p.pos = p.requiresClauseEnd(p.pos)
+			continue
+		}
+		isStatic = isStatic || p.checkKeyword("static")
 		p.advance()
 	}
 
@@ -375,33 +726,35 @@ func (p *Parser) parseMethod() *Function {
 	if p.pos > 0 {
 		funcName = p.tokens[p.pos-1].Value
 	}
+	if p.pos > 1 && p.tokens[p.pos-2].Value == "operator" {
+		funcName = "operator" + funcName
+	}
 
+	paramsOpen := p.pos
 	if !p.matchValue("(") {
 		return nil
 	}
 
 	// Skip parameters
-	parenCount := 1
-	for !p.isAtEnd() && parenCount > 0 {
-		if p.checkValue("(") {
-			parenCount++
-		} else if p.checkValue(")") {
-			parenCount--
-		}
-		p.advance()
-	}
+	p.skipParams()
 
 	fn := &Function{
-		Name:      funcName,
-		StartLine: startLine,
+		File:           p.file,
+		Name:           funcName,
+		IsStatic:       isStatic,
+		ReturnsRaw:     paramsOpen > 1 && p.tokens[paramsOpen-2].Value == "*",
+		StartLine:      startLine,
+		Params:         p.paramNames(paramsOpen, p.pos-1),
+		TakesOwnership: p.ownershipParams(paramsOpen, p.pos-1),
+	}
+	if funcName == "operator=" {
+		fn.MoveSource = p.moveParam(paramsOpen, p.pos-1, className)
 	}
 
-	// Skip const, noexcept, etc.
-	for p.checkKeyword("const") || p.check(TokenIdent) {
-		if p.checkValue("{") || p.checkValue(";") {
-			break
-		}
-		p.advance()
+	// Skip const, noexcept, override etc.; with auto getBuffer() -> char*
+	// the name is still the token before (
+	if trailing, raw := p.skipSpecifiers(); trailing {
+		fn.ReturnsRaw = raw
 	}
 
 	if p.checkValue(";") {
@@ -411,18 +764,209 @@ func (p *Parser) parseMethod() *Function {
 
 	if p.checkValue("{") {
 		p.parseFunctionBody(fn)
+		if funcName == "operator=" {
+			fn.CopyAndSwap = p.copyAndSwap(fn, paramsOpen, className)
+		}
 	}
 
 	return fn
 }
 
+// moveParam returns the parameter name when the parameter list
+// tokens[open:close] is a single rvalue reference to the class (Foo&& other)
+func (p *Parser) moveParam(open, close int, className string) string {
+	params := p.tokens[open+1 : close]
+	if len(params) < 3 {
+		return ""
+	}
+	last := params[len(params)-1]
+	if last.Type != TokenIdent || params[len(params)-2].Value != "&&" ||
+		params[len(params)-3].Value != className {
+		return ""
+	}
+	for _, tok := range params {
+		if tok.Value == "," {
+			return ""
+		}
+	}
+	return last.Value
+}
+
+// copyAndSwap reports whether the assignment operator fn, whose parameter
+// list opens at tokens[open], takes the class by value (Foo other or
+// const Foo other) and calls swap, leaving the old state to the destructor
+// of the parameter
+func (p *Parser) copyAndSwap(fn *Function, open int, className string) bool {
+	if len(fn.Params) != 1 || !slices.Contains(fn.MethodCalls, "swap") {
+		return false
+	}
+	i := open + 1
+	if p.tokens[i].Value == "const" {
+		i++
+	}
+	if p.tokens[i].Value != className {
+		return false
+	}
+	i++
+	if p.tokens[i].Value == "<" {
+		for depth := 0; i < len(p.tokens); i++ {
+			if p.tokens[i].Value == "<" {
+				depth++
+			} else if p.tokens[i].Value == ">" {
+				if depth--; depth == 0 {
+					i++
+					break
+				}
+			}
+		}
+	}
+	return i+1 < len(p.tokens) && p.tokens[i].Value == fn.Params[0] && p.tokens[i+1].Value == ")"
+}
+
+// defaulted reports whether the tokens after a parameter list declare the
+// function = default, allowing specifiers such as noexcept or override first
+func (p *Parser) defaulted() bool {
+	for i := p.pos; i+1 < len(p.tokens); i++ {
+		switch p.tokens[i].Value {
+		case ";", "{", "}":
+			return false
+		case "=":
+			return p.tokens[i+1].Value == "default"
+		}
+	}
+	return false
+}
+
+// parseInitializerList parses a constructor member initializer list starting
+// at ':', recording each member(value) or member{value} entry as an assignment
+func (p *Parser) parseInitializerList(fn *Function) {
+	p.matchValue(":")
+	for !p.isAtEnd() && !p.checkValue(";") {
+		if !p.check(TokenIdent) {
+			p.advance()
+			continue
+		}
+
+		target := p.current()
+		p.advance()
+		// Skip qualified or templated base names: Base<T>::Base(...)
+		for p.checkValue("::") || p.checkValue("<") {
+			if p.checkValue("<") {
+				p.skipAngleBrackets()
+			} else {
+				p.advance()
+			}
+			if p.check(TokenIdent) {
+				target = p.current()
+				p.advance()
+			}
+		}
+
+		var closer string
+		switch {
+		case p.checkValue("("):
+			closer = ")"
+		case p.checkValue("{"):
+			closer = "}"
+		default:
+			continue
+		}
+		opener := p.current().Value
+		p.advance()
+
+		var value []Token
+		depth := 1
+		for !p.isAtEnd() {
+			if p.checkValue(opener) {
+				depth++
+			} else if p.checkValue(closer) {
+				depth--
+				if depth == 0 {
+					p.advance()
+					break
+				}
+			}
+			value = append(value, p.current())
+			p.advance()
+		}
+
+		fn.Assignments = append(fn.Assignments, Assignment{
+			Target: target.Value,
+			Value:  renderTokens(value),
+			Line:   target.Line,
+			Column: target.Column,
+		})
+
+		// Entries are separated by commas; the body follows the last one
+		if !p.matchValue(",") {
+			return
+		}
+	}
+}
+
+// skipAngleBrackets skips a balanced <...> template argument list
+func (p *Parser) skipAngleBrackets() {
+	depth := 0
+	for !p.isAtEnd() {
+		if p.checkValue("<") {
+			depth++
+		} else if p.checkValue(">") {
+			depth--
+			if depth == 0 {
+				p.advance()
+				return
+			}
+		} else if p.checkValue(";") || p.checkValue("{") {
+			return
+		}
+		p.advance()
+	}
+}
+
+// skipParams skips a parameter list whose opening parenthesis has already
+// been consumed, leaving the position just past the matching ')'
+func (p *Parser) skipParams() {
+	parenCount := 1
+	for !p.isAtEnd() && parenCount > 0 {
+		if p.checkValue("(") {
+			parenCount++
+		} else if p.checkValue(")") {
+			parenCount--
+		}
+		p.advance()
+	}
+}
+
 func (p *Parser) parseFunctionBody(fn *Function) {
 	if !p.matchValue("{") {
 		return
 	}
 
 	braceCount := 1
+	var guards []guard      // if and else branches around the current position
+	var loops []loopBinding // loops over containers around the current position
 	for !p.isAtEnd() && braceCount > 0 {
+		for len(guards) > 0 && guards[len(guards)-1].end < p.pos {
+			guards = guards[:len(guards)-1]
+		}
+		for len(loops) > 0 && loops[len(loops)-1].end < p.pos {
+			loops = loops[:len(loops)-1]
+		}
+		if p.checkKeyword("for") {
+			if loop, ok := p.forBinding(p.pos); ok {
+				loops = append(loops, loop)
+			}
+		}
+		if g, ok := p.guardAt(); ok {
+			guards = append(guards, g)
+			if fn.SelfCheck == 0 && selfComparison(g.cond) {
+				fn.SelfCheck = p.current().Line
+			}
+		}
+		if try, ok := p.tryAt(); ok {
+			fn.TryBlocks = append(fn.TryBlocks, try)
+		}
+
 		if p.checkValue("{") {
 			braceCount++
 			p.advance()
@@ -438,10 +982,30 @@ func (p *Parser) parseFunctionBody(fn *Function) {
 				fn.Allocations = append(fn.Allocations, *alloc)
 			}
 		} else if p.checkKeyword("delete") {
+			tok := p.current()
+			container, element := p.deletedElement(p.pos, loops)
 			dealloc := p.parseDeallocation()
+			if dealloc == nil && container != "" {
+				// delete *it, where it walks the container
+				dealloc = &Deallocation{VarName: element, File: p.file, Line: tok.Line, Column: tok.Column}
+			}
 			if dealloc != nil {
+				dealloc.Container = container
+				for _, g := range guards {
+					dealloc.Conditional = dealloc.Conditional || !nullChecks(g.cond, dealloc.VarName)
+				}
 				fn.Deallocations = append(fn.Deallocations, *dealloc)
 			}
+		} else if p.checkKeyword("return") {
+			if ret := p.returnValue(); ret != nil {
+				fn.Returns = append(fn.Returns, *ret)
+			}
+			p.advance()
+		} else if p.checkValue("throw") {
+			if throw, ok := p.throwAt(); ok {
+				fn.Throws = append(fn.Throws, throw)
+			}
+			p.advance()
 		} else if p.check(TokenIdent) {
 			identName := p.current().Value
 			identLine := p.current().Line
@@ -449,6 +1013,11 @@ func (p *Parser) parseFunctionBody(fn *Function) {
 			// Check for method calls
 			if p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Value == "(" {
 				fn.MethodCalls = append(fn.MethodCalls, identName)
+				fn.Calls = append(fn.Calls, Call{
+					Name: identName,
+					Args: p.callArgs(p.pos + 1),
+					Line: identLine,
+				})
 			}
 
 			// Check for pointer aliasing: ptr2 = ptr1 (where both are identifiers, no 'new')
@@ -457,6 +1026,14 @@ func (p *Parser) parseFunctionBody(fn *Function) {
 				fn.Aliases = append(fn.Aliases, *alias)
 			}
 
+			if assign := p.checkAssignment(); assign != nil {
+				fn.Assignments = append(fn.Assignments, *assign)
+			}
+
+			if step := p.checkArithmetic(); step != nil {
+				fn.Arithmetic = append(fn.Arithmetic, *step)
+			}
+
 			p.advance()
 		} else {
 			p.advance()
@@ -464,6 +1041,173 @@ func (p *Parser) parseFunctionBody(fn *Function) {
 	}
 }
 
+// returnValue reads the expression of the return statement at the current
+// position without moving the parser, or nil for a bare return
+func (p *Parser) returnValue() *Return {
+	var value []Token
+	depth := 0
+	for i := p.pos + 1; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.Type == TokenEOF || depth == 0 && (tok.Value == ";" || tok.Value == "}") {
+			break
+		}
+		if tok.Value == "(" || tok.Value == "[" || tok.Value == "{" {
+			depth++
+		} else if tok.Value == ")" || tok.Value == "]" || tok.Value == "}" {
+			depth--
+		}
+		value = append(value, tok)
+	}
+	if len(value) == 0 {
+		return nil
+	}
+	return &Return{Value: joinArg(value), Line: p.current().Line}
+}
+
+// checkArithmetic checks if the identifier at the current position is moved
+// by an offset: p++, ++p, p--, --p, p += n, p -= n, p = p + n or p = p - n
+func (p *Parser) checkArithmetic() *PointerArithmetic {
+	tok := p.current()
+	name := tok.Value
+	prefix := p.pos
+	if p.pos >= 2 && p.tokens[p.pos-1].Value == "->" && p.tokens[p.pos-2].Value == "this" {
+		prefix = p.pos - 2
+	}
+
+	op := ""
+	next := func(i int) string {
+		if p.pos+i < len(p.tokens) {
+			return p.tokens[p.pos+i].Value
+		}
+		return ""
+	}
+	switch {
+	case next(1) == "++" || next(1) == "--" || next(1) == "+=" || next(1) == "-=":
+		op = next(1)
+	case prefix > 0 && (p.tokens[prefix-1].Value == "++" || p.tokens[prefix-1].Value == "--"):
+		op = p.tokens[prefix-1].Value
+	case next(1) == "=" && next(2) == name && (next(3) == "+" || next(3) == "-"):
+		op = next(3) + "="
+	default:
+		return nil
+	}
+	if p.pos >= 1 && (p.tokens[p.pos-1].Value == "." || p.tokens[p.pos-1].Value == "->") && prefix == p.pos {
+		return nil // a member of another object
+	}
+	return &PointerArithmetic{VarName: name, Op: op, Line: tok.Line, Column: tok.Column}
+}
+
+// checkAssignment checks if the identifier at the current position is the
+// target of a simple assignment: [this->|obj.|obj->]ident = value;
+func (p *Parser) checkAssignment() *Assignment {
+	if p.pos+1 >= len(p.tokens) || p.tokens[p.pos+1].Value != "=" {
+		return nil
+	}
+
+	target := p.current().Value
+	if p.pos >= 2 && (p.tokens[p.pos-1].Value == "." || p.tokens[p.pos-1].Value == "->") {
+		object := p.tokens[p.pos-2]
+		if object.Value != "this" {
+			target = object.Value + p.tokens[p.pos-1].Value + target
+		}
+	}
+
+	var value []Token
+	depth := 0
+	for i := p.pos + 2; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.Type == TokenEOF || tok.Value == "{" || tok.Value == "}" {
+			break
+		}
+		if tok.Value == "(" || tok.Value == "[" {
+			depth++
+		} else if tok.Value == ")" || tok.Value == "]" {
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if depth == 0 && (tok.Value == ";" || tok.Value == ",") {
+			break
+		}
+		value = append(value, tok)
+	}
+	if len(value) == 0 {
+		return nil
+	}
+
+	return &Assignment{
+		Target: target,
+		Value:  renderTokens(value),
+		Line:   p.current().Line,
+		Column: p.current().Column,
+	}
+}
+
+// renderTokens renders tokens as compact source text, separating only
+// adjacent words: other.data_, new int[10], std::exchange(other.p,nullptr)
+// appendBase adds a base class name rendered from its tokens, if any
+func appendBase(bases []Base, base Base, tokens []Token) []Base {
+	if len(tokens) == 0 {
+		return bases
+	}
+	base.Name = renderTokens(tokens)
+	return append(bases, base)
+}
+
+func renderTokens(tokens []Token) string {
+	var sb strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && isWordToken(tokens[i-1]) && isWordToken(tok) {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(tok.Value)
+	}
+	return sb.String()
+}
+
+func isWordToken(tok Token) bool {
+	return tok.Type == TokenIdent || tok.Type == TokenKeyword || tok.Type == TokenNumber
+}
+
+// callArgs returns the top-level arguments of the call whose '(' is at
+// position open, without moving the parser
+func (p *Parser) callArgs(open int) []string {
+	var args []string
+	var current []Token
+	depth := 0
+	for i := open + 1; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.Type == TokenEOF || tok.Value == ";" || tok.Value == "{" {
+			break
+		}
+		if tok.Value == "(" || tok.Value == "[" {
+			depth++
+		} else if tok.Value == ")" || tok.Value == "]" {
+			if depth == 0 {
+				break
+			}
+			depth--
+		} else if tok.Value == "," && depth == 0 {
+			args = append(args, joinArg(current))
+			current = nil
+			continue
+		}
+		current = append(current, tok)
+	}
+	if len(current) > 0 {
+		args = append(args, joinArg(current))
+	}
+	return args
+}
+
+// joinArg renders argument tokens as text, stripping a leading this->
+func joinArg(tokens []Token) string {
+	if len(tokens) > 2 && tokens[0].Value == "this" && tokens[1].Value == "->" {
+		tokens = tokens[2:]
+	}
+	return renderTokens(tokens)
+}
+
 // checkPointerAlias checks if current position is a pointer alias assignment
 // Pattern: target = source; (where source is an identifier, not 'new')
 func (p *Parser) checkPointerAlias(targetName string, line int) *PointerAlias {
@@ -495,6 +1239,7 @@ func (p *Parser) checkPointerAlias(targetName string, line int) *PointerAlias {
 				TargetVar: targetName,
 				SourceVar: nextTok.Value,
 				Line:      line,
+				Column:    p.current().Column,
 			}
 		}
 	}
@@ -504,19 +1249,32 @@ func (p *Parser) checkPointerAlias(targetName string, line int) *PointerAlias {
 
 func (p *Parser) parseAllocation() *Allocation {
 	line := p.current().Line
+	column := p.current().Column
+	varName, managed := p.managedAllocationTarget(p.pos)
+	passedTo, argIndex := "", 0
+	if !managed {
+		passedTo, argIndex = p.enclosingCall(p.pos)
+	}
+	container := p.storedContainer(p.pos)
+	discarded := p.startsStatement(p.pos)
+	static := p.staticDeclaration(p.pos)
 	p.advance() // skip 'new'
 
 	isArray := false
 	if p.checkValue("[") {
 		isArray = true
 	}
+	allocType := p.allocatedType()
 
 	// Look for variable being assigned
 	// Pattern: varName = new Type or this->varName = new Type
 	// We need to look backwards for the variable name
-	varName := p.findAssignmentTarget()
+	if !managed && passedTo == "" && !discarded && container == "" {
+		varName = p.findAssignmentTarget()
+	}
 
-	if varName == "" {
+	if varName == "" && passedTo == "" && !discarded && container == "" {
+		p.skipped("new expression with untracked result", line)
 		return nil
 	}
 
@@ -529,12 +1287,133 @@ func (p *Parser) parseAllocation() *Allocation {
 	}
 
 	return &Allocation{
-		VarName: varName,
-		IsArray: isArray,
-		Line:    line,
+		VarName:   varName,
+		Type:      allocType,
+		IsArray:   isArray,
+		Managed:   managed,
+		PassedTo:  passedTo,
+		ArgIndex:  argIndex,
+		Discarded: discarded,
+		Static:    static,
+		Container: container,
+		File:      p.file,
+		Line:      line,
+		Column:    column,
 	}
 }
 
+// staticDeclaration reports whether the statement containing the token at
+// pos declares a static variable
+func (p *Parser) staticDeclaration(pos int) bool {
+	for i := pos - 1; i >= 0; i-- {
+		switch p.tokens[i].Value {
+		case ";", "{", "}":
+			return false
+		case "static":
+			return p.tokens[i].Type == TokenKeyword
+		}
+	}
+	return false
+}
+
+// globalAt returns the file-scope pointer initialized by the new at the
+// current position, as in Widget* g_widget = new Widget; or
+// Registry* Registry::instance_ = new Registry;, or nil when the new
+// initializes something else
+func (p *Parser) globalAt() *Global {
+	at := p.current()
+	i := p.pos - 1
+	if p.pos < p.bodyEnd || i < 2 || p.tokens[i].Value != "=" || p.tokens[i-1].Type != TokenIdent {
+		return nil
+	}
+	g := &Global{Name: p.tokens[i-1].Value, File: p.file, Line: at.Line, Column: at.Column}
+	i -= 2
+	if i >= 1 && p.tokens[i].Value == "::" && p.tokens[i-1].Type == TokenIdent {
+		g.Class = p.tokens[i-1].Value
+		i -= 2
+	}
+	if i < 0 || p.tokens[i].Value != "*" {
+		return nil
+	}
+	g.Type = p.allocatedTypeAt(p.pos + 1)
+	for j := p.pos + 1; j < len(p.tokens) && p.tokens[j].Value != ";"; j++ {
+		if p.tokens[j].Value == "[" {
+			g.IsArray = true
+			break
+		}
+	}
+	return g
+}
+
+// startsStatement reports whether the token at pos begins an expression
+// statement, i.e. follows the end of a previous statement or block
+func (p *Parser) startsStatement(pos int) bool {
+	if pos == 0 {
+		return true
+	}
+	switch p.tokens[pos-1].Value {
+	case ";", "{", "}", "else":
+		return true
+	}
+	return false
+}
+
+// allocatedType returns the type named after 'new', e.g. Widget or ns::Widget
+func (p *Parser) allocatedType() string {
+	return p.allocatedTypeAt(p.pos)
+}
+
+// allocatedTypeAt returns the type named from tokens[pos]
+func (p *Parser) allocatedTypeAt(pos int) string {
+	var parts []string
+	for i := pos; i < len(p.tokens); i++ {
+		tok := p.tokens[i]
+		if tok.Type == TokenIdent || tok.Type == TokenKeyword || tok.Value == "::" {
+			parts = append(parts, tok.Value)
+			continue
+		}
+		break
+	}
+	return strings.Join(parts, "")
+}
+
+// enclosingCall checks whether the 'new' at position pos is a direct argument
+// of a call, e.g. registerWidget(new Widget) or add(id, new Widget), and
+// returns the called function and the argument index
+func (p *Parser) enclosingCall(pos int) (string, int) {
+	if pos < 2 || (p.tokens[pos-1].Value != "(" && p.tokens[pos-1].Value != ",") {
+		return "", 0
+	}
+
+	index := 0
+	depth := 0
+	for i := pos - 1; i > 0; i-- {
+		switch p.tokens[i].Value {
+		case ")", "]":
+			depth++
+		case "[":
+			depth--
+		case ",":
+			if depth == 0 {
+				index++
+			}
+		case ";", "{", "}":
+			return "", 0
+		case "(":
+			if depth > 0 {
+				depth--
+				continue
+			}
+			callee := p.tokens[i-1]
+			if callee.Type != TokenIdent {
+				return "", 0
+			}
+			return callee.Value, index
+		}
+	}
+	return "", 0
+}
+
 func (p *Parser) findAssignmentTarget() string {
 	// Look backwards for pattern: varName = or this->varName =
 	for i := p.pos - 1; i >= 0 && i > p.pos-10; i-- {
@@ -552,6 +1431,7 @@ func (p *Parser) findAssignmentTarget() string {
 
 func (p *Parser) parseDeallocation() *Deallocation {
 	line := p.current().Line
+	column := p.current().Column
 	p.advance() // skip 'delete'
 
 	isArray := false
@@ -575,91 +1455,181 @@ func (p *Parser) parseDeallocation() *Deallocation {
 		}
 	} else if p.check(TokenIdent) {
 		varName = p.current().Value
+		// A static member deleted by its qualified name, Registry::instance_
+		for p.pos+2 < len(p.tokens) && p.tokens[p.pos+1].Value == "::" && p.tokens[p.pos+2].Type == TokenIdent {
+			p.advance()
+			p.advance()
+			varName = p.current().Value
+		}
+		// A member of another object, pool.head_ or p->tail_
+		for p.pos+2 < len(p.tokens) && (p.tokens[p.pos+1].Value == "." || p.tokens[p.pos+1].Value == "->") && p.tokens[p.pos+2].Type == TokenIdent {
+			varName += p.tokens[p.pos+1].Value + p.tokens[p.pos+2].Value
+			p.advance()
+			p.advance()
+		}
 	}
 
 	if varName == "" {
+		p.skipped("delete of a complex expression", line)
 		return nil
 	}
 
 	return &Deallocation{
 		VarName: varName,
 		IsArray: isArray,
+		File:    p.file,
 		Line:    line,
+		Column:  column,
 	}
 }
 
+// isMemberDeclaration reports whether a data member holding a pointer, a
+// smart pointer or a container of pointers is declared at the current
+// position, looking through typedefs and using aliases
 func (p *Parser) isMemberDeclaration() bool {
-	// Look for pattern: Type* varName; or Type *varName;
-	// Must contain a pointer indicator
-	savedPos := p.pos
-	hasPointer := false
-	hasIdent := false
+	decl, ok := p.declarationAt(p.pos)
+	if !ok {
+		return false
+	}
+	typ, name := splitDeclarator(decl)
+	return name >= 0 && classifyType(p.types.Resolve(typ, p.scope)) != plainType
+}
 
-	for i := 0; i < 10 && savedPos+i < len(p.tokens); i++ {
-		tok := p.tokens[savedPos+i]
-		if tok.Value == ";" {
-			break
-		}
-		if tok.Value == "(" || tok.Value == "{" {
-			return false // It's a function
+// bitfieldEnd returns the index of the ; ending a bitfield declaration at
+// the current position, such as unsigned flags : 3; or unsigned : 0;, or -1.
+// The width may be any expression, and a default member initializer may
+// follow it.
+func (p *Parser) bitfieldEnd() int {
+	colon := false
+	depth := 0
+	for i := p.pos; i < len(p.tokens) && i < p.pos+maxDeclarationTokens; i++ {
+		tok := p.tokens[i]
+		switch {
+		case tok.Type == TokenEOF:
+			return -1
+		case tok.Value == "(" || tok.Value == "[" || tok.Value == "{":
+			if !colon {
+				return -1 // a function, array or brace-initialized member
+			}
+			depth++
+		case tok.Value == ")" || tok.Value == "]" || tok.Value == "}":
+			if depth--; depth < 0 {
+				return -1
+			}
+		case tok.Value == ";" && depth == 0:
+			if colon {
+				return i
+			}
+			return -1
+		case tok.Value == "=" && !colon:
+			return -1 // an initializer, whose ?: is no bitfield
+		case tok.Value == ":" && depth == 0:
+			if i == p.pos || i == p.pos+1 && p.tokens[p.pos].Type != TokenKeyword {
+				return -1 // a label such as Qt's signals:
+			}
+			colon = true
 		}
-		if tok.Value == "*" {
-			hasPointer = true
+	}
+	return -1
+}
+
+// declarationAt returns the tokens of the declaration at pos up to its ;,
+// reporting false for functions, brace-initialized and alias declarations
+func (p *Parser) declarationAt(pos int) ([]Token, bool) {
+	switch p.tokens[pos].Value {
+	case "typedef", "using", "friend", "static_assert":
+		return nil, false // aliases are resolved through p.types
+	}
+	angles, initialized := 0, false
+	for i := pos; i < len(p.tokens) && i < pos+maxDeclarationTokens; i++ {
+		switch p.tokens[i].Value {
+		case ";":
+			return p.tokens[pos:i], true
+		case "<":
+			angles++
+		case ">":
+			angles--
+		case "=":
+			initialized = true
+		case "(":
+			if angles > 0 && !initialized {
+				// a signature in a template argument, std::function<void(int)>
+				i = p.matchingParen(i)
+				continue
+			}
+			return nil, false
+		case "{", "}":
+			return nil, false
 		}
-		if tok.Type == TokenIdent {
-			hasIdent = true
+		if p.tokens[i].Type == TokenEOF {
+			break
 		}
 	}
+	return nil, false
+}
 
-	return hasPointer && hasIdent
+// splitDeclarator splits a member declaration into its type and the index
+// of the member name, the last identifier followed by [, = or the end; the
+// index is -1 when there is no name
+func splitDeclarator(decl []Token) ([]Token, int) {
+	name := -1
+	for i, tok := range decl {
+		if tok.Type == TokenIdent && (i == len(decl)-1 || decl[i+1].Value == "[" || decl[i+1].Value == "=") {
+			name = i
+		}
+	}
+	if name < 0 {
+		return decl, -1
+	}
+	return decl[:name], name
 }
 
 func (p *Parser) parseMember() *Member {
 	startLine := p.current().Line
-	var tokens []Token
+	startColumn := p.current().Column
+	decl, _ := p.declarationAt(p.pos)
 
-	// Collect tokens until semicolon
+	// Skip to the end of the declaration
 	for !p.isAtEnd() && !p.checkValue(";") {
-		tokens = append(tokens, p.current())
 		p.advance()
 	}
+	endLine := p.current().Line
 	p.matchValue(";")
 
-	if len(tokens) < 2 {
+	typ, name := splitDeclarator(decl)
+	if len(decl) < 2 || name < 0 {
+		return nil
+	}
+	resolved := p.types.Resolve(typ, p.scope)
+	kind := classifyType(resolved)
+	if kind == plainType {
 		return nil
 	}
 
-	// Find pointer and variable name
-	isPointer := false
 	isArray := false
-	varName := ""
-	var typeTokens []string
-
-	for i, tok := range tokens {
-		if tok.Value == "*" {
-			isPointer = true
-		} else if tok.Value == "[" {
+	for _, tok := range decl[name:] {
+		if tok.Value == "[" {
 			isArray = true
-		} else if tok.Type == TokenIdent {
-			// Last identifier before ; is the variable name
-			if i == len(tokens)-1 || tokens[i+1].Value == "[" || tokens[i+1].Value == "=" {
-				varName = tok.Value
-			} else {
-				typeTokens = append(typeTokens, tok.Value)
-			}
 		}
 	}
-
-	if !isPointer || varName == "" {
-		return nil
+	var typeNames []string
+	for _, tok := range resolved {
+		if tok.Type == TokenIdent {
+			typeNames = append(typeNames, tok.Value)
+		}
 	}
 
 	return &Member{
-		Name:      varName,
-		Type:      strings.Join(typeTokens, " "),
-		IsPointer: isPointer,
-		IsArray:   isArray,
-		Line:      startLine,
+		Name:             decl[name].Value,
+		Type:             strings.Join(typeNames, " "),
+		IsPointer:        kind == pointerType,
+		IsSmartPointer:   kind == smartPointerType,
+		PointerContainer: kind == pointerContainerType,
+		IsArray:          isArray,
+		IsStatic:         slices.ContainsFunc(decl, func(tok Token) bool { return tok.Type == TokenKeyword && tok.Value == "static" }),
+		Line:             startLine,
+		Column:           startColumn,
+		Ownership:        p.memberOwnership(startLine, endLine),
 	}
 }
 
@@ -672,11 +1642,15 @@ func (p *Parser) isFunctionStart() bool {
 			return false
 		}
 		if tok.Value == "(" {
-			return true
+			return i > 0 && p.tokens[savedPos+i-1].Value != "..." // not sizeof...(Args)
 		}
 		if tok.Value == "{" || tok.Value == "}" {
 			return false
 		}
+		// A default member initializer, count = sizeof...(Args);
+		if tok.Value == "=" && (i == 0 || p.tokens[savedPos+i-1].Value != "operator") {
+			return false
+		}
 	}
 	return false
 }
@@ -689,10 +1663,19 @@ func (p *Parser) current() Token {
 	return Token{Type: TokenEOF}
 }
 
+// advance moves to the next token. Every cancelCheckInterval advances it
+// checks ctx and, once cancelled, jumps to the end so that every parsing
+// loop stops.
 func (p *Parser) advance() {
 	if p.pos < len(p.tokens) {
 		p.pos++
 	}
+	p.steps++
+	if p.steps&(cancelCheckInterval-1) == 0 && p.err == nil {
+		if p.err = p.ctx.Err(); p.err != nil {
+			p.pos = len(p.tokens)
+		}
+	}
 }
 
 func (p *Parser) isAtEnd() bool {
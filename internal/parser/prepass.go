@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// ClassNames returns the names of the classes a file may define or
+// implement methods of. It only tokenizes the file, and errs on the side of
+// listing too many: every name after class or struct, including forward
+// declarations, and every identifier directly before ::.
+func ClassNames(ctx context.Context, filename string) ([]string, error) {
+	declared, qualified, err := ClassReferences(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	names := append(declared, qualified...)
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// ClassReferences splits the names ClassNames returns into those declared
+// after class or struct and those seen directly before ::, which include
+// namespaces such as std, each sorted without duplicates
+func ClassReferences(ctx context.Context, filename string) (declared, qualified []string, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	lexer := NewReaderLexer(f)
+	raw := lexer.Tokenize()
+	defer releaseTokens(raw)
+	if err := lexer.Err(); err != nil {
+		return nil, nil, err
+	}
+	abs, _ := filepath.Abs(filename)
+	tokens := NewPreprocessor(abs).Process(raw)
+	defer releaseTokens(tokens)
+
+	lastIdent := ""
+	for i, tok := range tokens {
+		switch {
+		case tok.Type == TokenKeyword && (tok.Value == "class" || tok.Value == "struct"):
+			if next := tokens[i+1]; next.Type == TokenIdent {
+				declared = append(declared, next.Value)
+			}
+		case tok.Value == "::" && lastIdent != "":
+			qualified = append(qualified, lastIdent)
+		}
+		if tok.Type == TokenIdent {
+			lastIdent = tok.Value
+		}
+	}
+	slices.Sort(declared)
+	slices.Sort(qualified)
+	return slices.Compact(declared), slices.Compact(qualified), nil
+}
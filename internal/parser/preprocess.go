@@ -0,0 +1,526 @@
+package parser
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxIncludeDepth bounds nested #include chains, e.g. headers without
+// include guards that include each other
+const maxIncludeDepth = 32
+
+// maxExpansionDepth bounds nested macro expansion
+const maxExpansionDepth = 64
+
+// Preprocessor runs between the Lexer and the Parser. It drops directives
+// and the lines of inactive #if/#ifdef branches and expands object-like and
+// function-like macros. Expanded tokens take the position of the macro
+// name they replace, so findings in macro bodies point at the line using
+// the macro.
+//
+// #include "file" is followed, relative to the including file, for its
+// macro definitions only: the code of a header is parsed when the header
+// itself is scanned. Headers marked #pragma once are read once per file.
+// Macros named after keywords, such as MFC's #define new DEBUG_NEW, are
+// not expanded, so the allocations they wrap stay visible.
+type Preprocessor struct {
+	// OnInclude, when set, receives the preprocessed tokens of each
+	// included header, e.g. to collect its type aliases
+	OnInclude func(tokens []Token)
+
+	file        string
+	macros      map[string]*macro
+	once        map[string]bool // headers marked #pragma once
+	depth       int             // current #include depth
+	includeDirs []string
+}
+
+// BuildFlags are the compiler options of a file that matter to the
+// preprocessor, e.g. from a compilation database
+type BuildFlags struct {
+	// IncludeDirs are searched for #include "..." after the directory of
+	// the including file, and for #include <...>
+	IncludeDirs []string
+	Defines     map[string]string // -D; "" defines the macro as 1
+	Undefines   []string          // -U
+}
+
+// macro is a #define
+type macro struct {
+	function bool     // takes arguments, e.g. SAFE_DELETE(p)
+	params   []string // parameter names of a function-like macro
+	variadic bool     // the last parameter is ... (__VA_ARGS__)
+	body     []Token
+}
+
+// conditional is one #if ... #endif block being preprocessed
+type conditional struct {
+	outerActive bool // the enclosing code is active
+	taken       bool // an earlier branch was active
+	active      bool
+}
+
+// NewPreprocessor creates a preprocessor for the named file, with the
+// macros a compiler predefines for its language
+func NewPreprocessor(file string) *Preprocessor {
+	pp := &Preprocessor{
+		file:   file,
+		macros: make(map[string]*macro),
+		once:   make(map[string]bool),
+	}
+	switch LanguageOf(file) {
+	case LanguageCUDA:
+		pp.macros["__CUDACC__"] = &macro{body: []Token{{Type: TokenNumber, Value: "1"}}}
+		fallthrough
+	case LanguageCPP:
+		pp.macros["__cplusplus"] = &macro{body: []Token{{Type: TokenNumber, Value: "201703"}}}
+	}
+	return pp
+}
+
+// Configure applies the include directories and macro definitions a file
+// is compiled with
+func (pp *Preprocessor) Configure(flags *BuildFlags) {
+	if flags == nil {
+		return
+	}
+	pp.includeDirs = flags.IncludeDirs
+	for name, value := range flags.Defines {
+		if value == "" {
+			value = "1"
+		}
+		lexer := NewLexer(value)
+		tokens := lexer.Tokenize()
+		m := &macro{body: slices.Clone(tokens[:len(tokens)-1])} // without EOF
+		releaseTokens(tokens)
+		pp.macros[name] = m
+	}
+	for _, name := range flags.Undefines {
+		delete(pp.macros, name)
+	}
+}
+
+// Process returns the tokens the parser should see, ending in the EOF
+// token of the input
+func (pp *Preprocessor) Process(tokens []Token) []Token {
+	out := (*tokenSlices.Get().(*[]Token))[:0]
+	return pp.process(tokens, pp.file, out)
+}
+
+// process preprocesses the tokens of file, appending the active code to out
+func (pp *Preprocessor) process(tokens []Token, file string, out []Token) []Token {
+	var stack []conditional
+	active := func() bool {
+		return len(stack) == 0 || stack[len(stack)-1].active
+	}
+
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+		if tok.Type == TokenEOF {
+			return append(out, tok)
+		}
+		if tok.Type == TokenPunctuation && tok.Value == "#" {
+			end := i + 1
+			for end < len(tokens) && tokens[end].Type != TokenNewline && tokens[end].Type != TokenEOF {
+				end++
+			}
+			stack = pp.directive(tokens[i+1:end], file, stack, active())
+			if end < len(tokens) && tokens[end].Type == TokenNewline {
+				end++
+			}
+			i = end
+			continue
+		}
+		if !active() {
+			i++
+			continue
+		}
+		if pp.expandable(tok) {
+			expansion, next := pp.expandAt(tokens, i, nil, 0)
+			out = append(out, expansion...)
+			i = next
+			continue
+		}
+		out = append(out, tok)
+		i++
+	}
+	return out
+}
+
+// directive handles the tokens of one directive after the #, returning the
+// updated conditional stack
+func (pp *Preprocessor) directive(line []Token, file string, stack []conditional, active bool) []conditional {
+	if len(line) == 0 {
+		return stack
+	}
+	name, args := line[0].Value, line[1:]
+	switch name {
+	case "if", "ifdef", "ifndef":
+		c := conditional{outerActive: active}
+		if active {
+			c.active = pp.condition(name, args, file)
+			c.taken = c.active
+		}
+		return append(stack, c)
+	case "elif", "elifdef", "elifndef", "else":
+		if len(stack) == 0 {
+			return stack
+		}
+		c := &stack[len(stack)-1]
+		c.active = false
+		if c.outerActive && !c.taken {
+			c.active = name == "else" || pp.condition(strings.TrimPrefix(name, "el"), args, file)
+			c.taken = c.active
+		}
+		return stack
+	case "endif":
+		if len(stack) > 0 {
+			stack = stack[:len(stack)-1]
+		}
+		return stack
+	}
+	if !active {
+		return stack
+	}
+
+	switch name {
+	case "define":
+		pp.defineMacro(args)
+	case "undef":
+		if len(args) > 0 {
+			delete(pp.macros, args[0].Value)
+		}
+	case "include":
+		if header := pp.resolveInclude(args, file); header != "" {
+			pp.include(header)
+		}
+	case "pragma":
+		if len(args) > 0 && args[0].Value == "once" {
+			pp.once[file] = true
+		}
+	}
+	return stack
+}
+
+// condition evaluates the condition of an #if, #ifdef or #ifndef
+func (pp *Preprocessor) condition(kind string, args []Token, file string) bool {
+	switch kind {
+	case "ifdef", "ifndef":
+		if len(args) == 0 {
+			return false
+		}
+		_, defined := pp.macros[args[0].Value]
+		return defined == (kind == "ifdef")
+	}
+	value, ok := pp.evaluate(args, file)
+	if !ok {
+		slog.Debug("cannot evaluate #if condition, treating it as false", "file", file, "line", args[0].Line, "condition", renderTokens(args))
+	}
+	return value != 0
+}
+
+// defineMacro records a #define from the tokens after define
+func (pp *Preprocessor) defineMacro(args []Token) {
+	if len(args) == 0 || args[0].Type != TokenIdent && args[0].Type != TokenKeyword {
+		return
+	}
+	name := args[0]
+	m := &macro{}
+	rest := args[1:]
+	// A function-like macro has its ( directly after the name
+	if len(rest) > 0 && rest[0].Value == "(" && rest[0].Line == name.Line && rest[0].Column == name.Column+len(name.Value) {
+		m.function = true
+		i := 1
+		for ; i < len(rest) && rest[i].Value != ")"; i++ {
+			switch {
+			case rest[i].Value == ".":
+				m.variadic = true
+			case rest[i].Value != ",":
+				m.params = append(m.params, rest[i].Value)
+			}
+		}
+		if m.variadic {
+			m.params = append(m.params, "__VA_ARGS__")
+		}
+		rest = rest[min(i+1, len(rest)):]
+	}
+	m.body = slices.Clone(rest) // the lexer's tokens are recycled
+	pp.macros[name.Value] = m
+}
+
+// resolveInclude returns the header an #include names: a quoted name
+// relative to the including file or in the include directories, a <...>
+// name in the include directories only. It returns "" for missing headers.
+func (pp *Preprocessor) resolveInclude(args []Token, file string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	var name string
+	var dirs []string
+	switch {
+	case args[0].Type == TokenString && strings.HasPrefix(args[0].Value, `"`):
+		name = strings.Trim(args[0].Value, `"`)
+		dirs = append([]string{filepath.Dir(file)}, pp.includeDirs...)
+	case args[0].Value == "<" && len(pp.includeDirs) > 0:
+		var sb strings.Builder
+		for _, tok := range args[1:] {
+			if tok.Value == ">" {
+				break
+			}
+			sb.WriteString(tok.Value)
+		}
+		name, dirs = sb.String(), pp.includeDirs
+	}
+	if name == "" {
+		return ""
+	}
+	for _, dir := range dirs {
+		header := filepath.Join(dir, name)
+		if info, err := os.Stat(header); err == nil && !info.IsDir() {
+			return header
+		}
+	}
+	return ""
+}
+
+// include reads the macro definitions of a header
+func (pp *Preprocessor) include(header string) {
+	if pp.once[header] || pp.depth >= maxIncludeDepth {
+		return
+	}
+	f, err := os.Open(header)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	lexer := NewReaderLexer(f)
+	tokens := lexer.Tokenize()
+	defer releaseTokens(tokens)
+	if lexer.Err() != nil {
+		return
+	}
+
+	pp.depth++
+	code := pp.process(tokens, header, (*tokenSlices.Get().(*[]Token))[:0])
+	if pp.OnInclude != nil {
+		pp.OnInclude(code)
+	}
+	releaseTokens(code)
+	pp.depth--
+}
+
+// expandable reports whether tok names a macro to expand
+func (pp *Preprocessor) expandable(tok Token) bool {
+	if tok.Type != TokenIdent {
+		return false
+	}
+	_, ok := pp.macros[tok.Value]
+	return ok
+}
+
+// expandAt expands the macro named at tokens[i], returning the expansion
+// and the index of the first token after the invocation. Macros in hidden
+// are being expanded already and are left alone.
+func (pp *Preprocessor) expandAt(tokens []Token, i int, hidden map[string]bool, depth int) ([]Token, int) {
+	tok := tokens[i]
+	m := pp.macros[tok.Value]
+	if m == nil || hidden[tok.Value] || depth >= maxExpansionDepth {
+		return tokens[i : i+1], i + 1
+	}
+
+	next := i + 1
+	var args [][]Token
+	if m.function {
+		if next >= len(tokens) || tokens[next].Value != "(" {
+			return tokens[i : i+1], i + 1 // the name alone is no invocation
+		}
+		args, next = macroArgs(tokens, next)
+	}
+
+	body := pp.substitute(m, args, hidden, depth)
+	inner := make(map[string]bool, len(hidden)+1)
+	for name := range hidden {
+		inner[name] = true
+	}
+	inner[tok.Value] = true
+
+	var result []Token
+	for j := 0; j < len(body); {
+		if pp.expandable(body[j]) {
+			expansion, after := pp.expandAt(body, j, inner, depth+1)
+			result = append(result, expansion...)
+			j = after
+			continue
+		}
+		result = append(result, body[j])
+		j++
+	}
+	for j := range result {
+		result[j].Line, result[j].Column = tok.Line, tok.Column
+	}
+	return result, next
+}
+
+// macroArgs splits the arguments of the invocation whose ( is at
+// tokens[open], returning them and the index after the closing )
+func macroArgs(tokens []Token, open int) ([][]Token, int) {
+	var args [][]Token
+	var current []Token
+	depth := 0
+	for i := open + 1; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.Type == TokenEOF:
+			return append(args, current), i
+		case tok.Value == "(":
+			depth++
+		case tok.Value == ")" && depth == 0:
+			if len(current) > 0 || len(args) > 0 {
+				args = append(args, current)
+			}
+			return args, i + 1
+		case tok.Value == ")":
+			depth--
+		case tok.Value == "," && depth == 0:
+			args = append(args, current)
+			current = nil
+			continue
+		}
+		current = append(current, tok)
+	}
+	return append(args, current), len(tokens)
+}
+
+// substitute replaces the parameters in a macro body with the arguments,
+// applying the # and ## operators
+func (pp *Preprocessor) substitute(m *macro, args [][]Token, hidden map[string]bool, depth int) []Token {
+	if !m.function {
+		return m.body
+	}
+	arg := func(name string) ([]Token, bool) {
+		for i, param := range m.params {
+			if param != name {
+				continue
+			}
+			if m.variadic && i == len(m.params)-1 && i < len(args) {
+				// __VA_ARGS__ takes the remaining arguments, commas included
+				var rest []Token
+				for j, a := range args[i:] {
+					if j > 0 {
+						rest = append(rest, Token{Type: TokenPunctuation, Value: ","})
+					}
+					rest = append(rest, a...)
+				}
+				return rest, true
+			}
+			if i < len(args) {
+				return args[i], true
+			}
+			return nil, true
+		}
+		return nil, false
+	}
+
+	var out []Token
+	body := m.body
+	for i := 0; i < len(body); i++ {
+		tok := body[i]
+		switch {
+		case tok.Value == "#" && tok.Type == TokenOperator && i+1 < len(body):
+			if a, ok := arg(body[i+1].Value); ok {
+				out = append(out, Token{Type: TokenString, Value: strconv.Quote(renderTokens(a))})
+				i++
+				continue
+			}
+		case tok.Value == "##" && len(out) > 0 && i+1 < len(body):
+			right := []Token{body[i+1]}
+			if a, ok := arg(body[i+1].Value); ok {
+				right = a
+			}
+			i++
+			if len(right) == 0 {
+				continue
+			}
+			pasted := NewLexer(out[len(out)-1].Value + right[0].Value).Tokenize()
+			out = append(out[:len(out)-1], pasted[:len(pasted)-1]...)
+			out = append(out, right[1:]...)
+			continue
+		}
+		a, ok := arg(tok.Value)
+		if !ok {
+			out = append(out, tok)
+			continue
+		}
+		if i+1 < len(body) && body[i+1].Value == "##" {
+			out = append(out, a...) // pasted operands are not expanded
+			continue
+		}
+		for j := 0; j < len(a); {
+			if pp.expandable(a[j]) {
+				expansion, next := pp.expandAt(a, j, hidden, depth+1)
+				out = append(out, expansion...)
+				j = next
+				continue
+			}
+			out = append(out, a[j])
+			j++
+		}
+	}
+	return out
+}
+
+// IncludedHeaders returns the headers the #include lines of file, with
+// content src, resolve to under flags, and those of the headers they
+// include in turn, whether or not the lines sit in active #if branches.
+// Copies of a file with the same content, flags and included headers
+// preprocess alike.
+func IncludedHeaders(file string, src []byte, flags *BuildFlags) []string {
+	pp := NewPreprocessor(file)
+	pp.Configure(flags)
+	var headers []string
+	seen := make(map[string]bool)
+	var walk func(file string, src []byte, depth int)
+	walk = func(file string, src []byte, depth int) {
+		for line := range strings.Lines(string(src)) {
+			rest, ok := strings.CutPrefix(strings.TrimSpace(line), "#")
+			if !ok {
+				continue
+			}
+			rest, ok = strings.CutPrefix(strings.TrimSpace(rest), "include")
+			if !ok {
+				continue
+			}
+			header := pp.resolveInclude(includeArgs(strings.TrimSpace(rest)), file)
+			if header == "" || seen[header] {
+				continue
+			}
+			seen[header] = true
+			headers = append(headers, header)
+			if data, err := os.ReadFile(header); err == nil && depth < maxIncludeDepth {
+				walk(header, data, depth+1)
+			}
+		}
+	}
+	walk(file, src, 0)
+	return headers
+}
+
+// includeArgs returns the tokens resolveInclude expects for the text after
+// #include, e.g. "defs.h" or <vector>
+func includeArgs(name string) []Token {
+	switch {
+	case strings.HasPrefix(name, `"`):
+		if end := strings.IndexByte(name[1:], '"'); end >= 0 {
+			return []Token{{Type: TokenString, Value: name[:end+2]}}
+		}
+	case strings.HasPrefix(name, "<"):
+		if end := strings.IndexByte(name, '>'); end >= 0 {
+			return []Token{{Value: "<"}, {Type: TokenIdent, Value: name[1:end]}, {Value: ">"}}
+		}
+	}
+	return nil
+}
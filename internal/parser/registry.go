@@ -1,84 +1,262 @@
 package parser
 
 import (
+	"log/slog"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
 // ClassRegistry holds all parsed classes for cross-file analysis
 type ClassRegistry struct {
-	// Classes by name (for matching header declarations with cpp implementations)
+	// Classes by qualified name (for matching header declarations with cpp implementations)
 	classesByName map[string][]*Class
-	// All classes
-	allClasses []Class
+	// Qualified names registered under each unqualified class name
+	keys map[string][]string
+	// Qualified names in order of first appearance
+	names []string
+	// Result of the last merge, reset when classes are added
+	merged []*Class
+	// Conflicts found merging each qualified name
+	conflicts map[string][]MergeConflict
+
+	// Strategy resolves parts that header and implementation define
+	// differently; empty means MergePreferHeader
+	Strategy MergeStrategy
 }
 
 // NewClassRegistry creates a new registry
 func NewClassRegistry() *ClassRegistry {
 	return &ClassRegistry{
 		classesByName: make(map[string][]*Class),
+		keys:          make(map[string][]string),
+		conflicts:     make(map[string][]MergeConflict),
 	}
 }
 
-// AddClasses adds parsed classes to the registry
+// AddClasses adds parsed classes to the registry. The registry refers to
+// the classes in place, so callers must not modify them afterwards.
 func (r *ClassRegistry) AddClasses(classes []Class) {
 	for i := range classes {
 		class := &classes[i]
-		r.allClasses = append(r.allClasses, *class)
-		r.classesByName[class.Name] = append(r.classesByName[class.Name], class)
+		key := class.QualifiedName()
+		if _, seen := r.classesByName[key]; !seen {
+			r.names = append(r.names, key)
+			r.keys[class.Name] = append(r.keys[class.Name], key)
+		}
+		r.classesByName[key] = append(r.classesByName[key], class)
 	}
+	r.merged = nil
 }
 
-// MergeClasses merges class definitions split across header and implementation files
-// Returns a list of fully merged classes
-func (r *ClassRegistry) MergeClasses() []Class {
-	merged := make(map[string]*Class)
-
-	for _, class := range r.allClasses {
-		existing, exists := merged[class.Name]
-		if !exists {
-			// First occurrence of this class
-			classCopy := class
-			merged[class.Name] = &classCopy
+// owner returns the qualified name the classes registered under key merge
+// into. Classes in different namespaces stay apart, but one seen without
+// namespace, as in a header parsed standalone or a file that relies on a
+// using-directive, belongs to the only named-namespace class of its name.
+func (r *ClassRegistry) owner(key string) string {
+	parts := r.classesByName[key]
+	if len(parts) == 0 || parts[0].Namespace != "" {
+		return key
+	}
+	owner := key
+	for _, other := range r.keys[parts[0].Name] {
+		class := r.classesByName[other][0]
+		if class.Namespace == "" || isAnonymous(class.Namespace) || class.Specialization != parts[0].Specialization {
 			continue
 		}
+		if owner != key {
+			return key // ambiguous, e.g. audio::Buffer and video::Buffer
+		}
+		owner = other
+	}
+	return owner
+}
+
+// parts returns the classes merged into the qualified name key
+func (r *ClassRegistry) parts(key string) []*Class {
+	parts := r.classesByName[key]
+	if name := parts[0].SpecializedName(); name != key && r.owner(name) == key {
+		parts = append(slices.Clone(parts), r.classesByName[name]...)
+	}
+	return parts
+}
+
+// Merged merges class definitions split across header and implementation
+// files and returns one class per qualified name, in order of first
+// appearance. The parsed classes are left untouched; the merged classes are
+// shared by later calls until more classes are added.
+func (r *ClassRegistry) Merged() []*Class {
+	if r.merged != nil {
+		return r.merged
+	}
+	r.merged = make([]*Class, 0, len(r.names))
+	for _, key := range r.names {
+		if r.owner(key) == key {
+			r.merged = append(r.merged, r.merge(key))
+		}
+	}
+	return r.merged
+}
+
+// Release merges the classes named name, in every namespace, and removes
+// them from the registry, so a caller that knows no further file defines
+// the class can analyze them and let the memory go. It returns nothing when
+// the registry holds no class of that name.
+func (r *ClassRegistry) Release(name string) []*Class {
+	keys := r.keys[name]
+	var classes []*Class
+	for _, key := range keys {
+		if r.owner(key) == key {
+			classes = append(classes, r.merge(key))
+		}
+	}
+	for _, key := range keys {
+		delete(r.classesByName, key)
+	}
+	delete(r.keys, name)
+	r.names = slices.DeleteFunc(r.names, func(n string) bool { return slices.Contains(keys, n) })
+	r.merged = nil
+	return classes
+}
 
-		// Merge: combine information from multiple files
-		r.mergeClassInto(existing, &class)
+// merge combines every class registered under the qualified name key
+func (r *ClassRegistry) merge(key string) *Class {
+	parts := slices.Clone(r.parts(key))
+	slices.SortStableFunc(parts, compareParts)
+	target := cloneClass(parts[0])
+	var conflicts []MergeConflict
+	for _, source := range parts[1:] {
+		found := conflictsOf(key, target, source)
+		before := *target
+		r.mergeClassInto(target, source)
+		resolveConflicts(found, target, &before, source)
+		conflicts = append(conflicts, found...)
+	}
+	for _, c := range conflicts {
+		slog.Info("merge conflict", "class", c.Class, "kind", c.Kind, "files", c.Files, "kept", c.Kept, "strategy", r.strategy())
 	}
+	r.conflicts[key] = conflicts
+	if len(parts) > 1 {
+		slog.Info("merged class definitions", "class", key, "parts", len(parts), "files", target.Files(), "members_from", memberSource(parts, target))
+	}
+	return target
+}
 
-	// Convert map to slice
-	result := make([]Class, 0, len(merged))
-	for _, class := range merged {
-		result = append(result, *class)
+// strategy returns the merge strategy in effect
+func (r *ClassRegistry) strategy() MergeStrategy {
+	if r.Strategy == "" {
+		return MergePreferHeader
+	}
+	return r.Strategy
+}
+
+// memberSource returns the file of the part whose members the merged class
+// kept, or "" when none has members
+func memberSource(parts []*Class, merged *Class) string {
+	for _, part := range parts {
+		if len(part.Members) > 0 && len(part.Members) == len(merged.Members) && &part.Members[0] == &merged.Members[0] {
+			return part.File
+		}
+	}
+	return ""
+}
+
+// MergeClasses is like Merged but returns copies of the merged classes
+func (r *ClassRegistry) MergeClasses() []Class {
+	merged := r.Merged()
+	result := make([]Class, len(merged))
+	for i, class := range merged {
+		result[i] = *class
 	}
 	return result
 }
 
+// Index returns a query index over the merged classes
+func (r *ClassRegistry) Index() *Index {
+	return newIndex(r.Merged())
+}
+
+// cloneClass copies a class along with the slices a merge appends to
+func cloneClass(class *Class) *Class {
+	clone := *class
+	clone.Methods = slices.Clone(class.Methods)
+	clone.Locations = slices.Clone(class.Locations)
+	return &clone
+}
+
 // mergeClassInto merges source class info into target
 func (r *ClassRegistry) mergeClassInto(target, source *Class) {
 	// Track which file is header vs implementation
 	targetIsHeader := isHeaderFile(target.File)
 	sourceIsHeader := isHeaderFile(source.File)
 
-	// Merge members - always prefer header over implementation
-	// Headers have the member declarations, cpp files typically don't repeat them
-	if sourceIsHeader && !targetIsHeader {
+	// Keep the location of the class body, which findings without a more
+	// precise location are reported at
+	if target.DefinitionFile == "" && source.DefinitionFile != "" {
+		target.File = source.File
+		target.DefinitionFile = source.DefinitionFile
+		target.StartLine = source.StartLine
+		target.EndLine = source.EndLine
+	}
+
+	if target.Namespace == "" {
+		target.Namespace = source.Namespace
+	}
+
+	// Headers are shared between languages; the implementation decides
+	if targetIsHeader && !sourceIsHeader {
+		target.Language = source.Language
+	}
+
+	// Base classes and friends are only listed with the class body
+	if len(target.Bases) == 0 {
+		target.Bases = source.Bases
+	}
+	if len(target.Friends) == 0 {
+		target.Friends = source.Friends
+	}
+
+	// Merge members - prefer header over implementation unless the strategy
+	// says otherwise. Headers have the member declarations, cpp files
+	// typically don't repeat them
+	switch {
+	case r.strategy() == MergeUnion:
+		target.Members = unionMembers(target.Members, source.Members)
+	case r.strategy() == MergePreferImpl && targetIsHeader && !sourceIsHeader:
+		if len(source.Members) > 0 {
+			target.Members = source.Members
+		}
+	case r.strategy() == MergePreferImpl && !targetIsHeader && sourceIsHeader:
+		if len(target.Members) == 0 {
+			target.Members = source.Members
+		}
+	case sourceIsHeader && !targetIsHeader:
 		// Source is header, target is cpp - use source's members
 		if len(source.Members) > 0 {
 			target.Members = source.Members
 		}
-	} else if !sourceIsHeader && targetIsHeader {
+	case !sourceIsHeader && targetIsHeader:
 		// Target is header, source is cpp - keep target's members (already in place)
-	} else if len(target.Members) == 0 && len(source.Members) > 0 {
+	case len(target.Members) == 0 && len(source.Members) > 0:
 		// Both same type, take whichever has members
 		target.Members = source.Members
 	}
 
+	// Under prefer-impl the implementation's constructor and destructor
+	// bodies win outright
+	var ctorDecided, dtorDecided bool
+	ctor, dtor := target.Constructor, target.Destructor
+	if r.strategy() == MergePreferImpl {
+		target.Constructor, ctorDecided = preferImplementation(target.Constructor, source.Constructor)
+		target.Destructor, dtorDecided = preferImplementation(target.Destructor, source.Destructor)
+	}
+
 	// Merge constructor - prefer the one with actual function body (has allocations)
-	if target.Constructor == nil && source.Constructor != nil {
+	switch {
+	case ctorDecided:
+	case target.Constructor == nil && source.Constructor != nil:
 		target.Constructor = source.Constructor
-	} else if source.Constructor != nil && target.Constructor != nil {
+	case source.Constructor != nil && target.Constructor != nil:
 		// Both have constructors - prefer the one with allocations (the implementation)
 		if len(source.Constructor.Allocations) > 0 && len(target.Constructor.Allocations) == 0 {
 			target.Constructor = source.Constructor
@@ -86,37 +264,73 @@ func (r *ClassRegistry) mergeClassInto(target, source *Class) {
 	}
 
 	// Merge destructor - prefer the one with actual function body
-	if target.Destructor == nil && source.Destructor != nil {
+	switch {
+	case dtorDecided:
+	case target.Destructor == nil && source.Destructor != nil:
 		target.Destructor = source.Destructor
-	} else if source.Destructor != nil && target.Destructor != nil {
-		// Both have destructors - prefer the one with deallocations (the implementation)
+	case source.Destructor != nil && target.Destructor != nil:
+		// Both have destructors - prefer the one with deallocations (the implementation),
+		// otherwise the one with a body
 		if len(source.Destructor.Deallocations) > 0 && len(target.Destructor.Deallocations) == 0 {
 			target.Destructor = source.Destructor
+		} else if len(target.Destructor.Deallocations) == 0 {
+			target.Destructor = preferDefinition(target.Destructor, source.Destructor)
 		}
 	}
 
-	// Merge methods
-	methodMap := make(map[string]*Function)
-	for i := range target.Methods {
-		methodMap[target.Methods[i].Name] = &target.Methods[i]
+	// Constructor overloads, and destructor bodies, defined in different
+	// files all count
+	if !ctorDecided {
+		target.Constructor = combineBodies(target.Constructor, ctor, source.Constructor)
+	}
+	if !dtorDecided {
+		target.Destructor = combineBodies(target.Destructor, dtor, source.Destructor)
 	}
+
+	// Merge move operations - prefer the definition with a body
+	target.MoveConstructor = preferDefinition(target.MoveConstructor, source.MoveConstructor)
+	target.MoveAssignment = preferDefinition(target.MoveAssignment, source.MoveAssignment)
+
+	// Merge methods - a definition replaces its declaration, keeping
+	// ownership annotations and static, which only appear on the header
+	// declaration, and the bodies of every file are kept
 	for _, method := range source.Methods {
-		existing, exists := methodMap[method.Name]
-		if !exists {
+		i := matchingMethod(target.Methods, &method)
+		if i < 0 {
 			target.Methods = append(target.Methods, method)
-		} else if len(method.Allocations) > 0 || len(method.Deallocations) > 0 {
-			// Source has more info, update
+			continue
+		}
+		existing := &target.Methods[i]
+		if method.EndLine > 0 && existing.EndLine == 0 {
+			if len(method.TakesOwnership) == 0 {
+				method.TakesOwnership = existing.TakesOwnership
+			}
+			method.IsStatic = method.IsStatic || existing.IsStatic
 			*existing = method
+		} else {
+			if len(existing.TakesOwnership) == 0 {
+				existing.TakesOwnership = method.TakesOwnership
+			}
+			existing.IsStatic = existing.IsStatic || method.IsStatic
 		}
 	}
 
-	// Update file reference to include both
-	if !strings.Contains(target.File, source.File) && target.File != source.File {
-		target.File = target.File + ", " + filepath.Base(source.File)
+	target.Locations = append(target.Locations, source.Locations...)
+}
+
+// preferDefinition picks the function that has a body, or is defaulted, over
+// a bare declaration
+func preferDefinition(target, source *Function) *Function {
+	if target == nil {
+		return source
+	}
+	if source != nil && (source.EndLine > 0 || source.Defaulted) && target.EndLine == 0 && !target.Defaulted {
+		return source
 	}
+	return target
 }
 
 func isHeaderFile(filename string) bool {
 	ext := strings.ToLower(filepath.Ext(filename))
-	return ext == ".h" || ext == ".hpp" || ext == ".hxx"
+	return ext == ".h" || ext == ".hpp" || ext == ".hxx" || ext == ".cuh"
 }
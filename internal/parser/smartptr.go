@@ -0,0 +1,186 @@
+package parser
+
+// smartPointerTypes lists owning smart pointer templates, keyed by the
+// unqualified name with the namespaces they are recognized in
+var smartPointerTypes = map[string][]string{
+	"unique_ptr":    {"std"},
+	"shared_ptr":    {"std", "boost"},
+	"weak_ptr":      {"std", "boost"},
+	"auto_ptr":      {"std"},
+	"scoped_ptr":    {"boost"},
+	"scoped_array":  {"boost"},
+	"shared_array":  {"boost"},
+	"intrusive_ptr": {"boost"},
+}
+
+// containerTypes lists standard containers, and the pair, tuple and
+// optional holders, which own the raw pointers they hold only by convention
+var containerTypes = map[string][]string{
+	"vector":             {"std"},
+	"list":               {"std"},
+	"deque":              {"std"},
+	"forward_list":       {"std"},
+	"array":              {"std"},
+	"set":                {"std"},
+	"multiset":           {"std"},
+	"unordered_set":      {"std"},
+	"unordered_multiset": {"std"},
+	"map":                {"std"},
+	"multimap":           {"std"},
+	"unordered_map":      {"std"},
+	"unordered_multimap": {"std"},
+	"stack":              {"std"},
+	"queue":              {"std"},
+	"priority_queue":     {"std"},
+	"pair":               {"std"},
+	"tuple":              {"std"},
+	"optional":           {"std"},
+}
+
+// typeKind classifies a member type
+type typeKind int
+
+const (
+	plainType            typeKind = iota
+	pointerType                   // a raw pointer, e.g. Widget* or const char*
+	smartPointerType              // e.g. std::unique_ptr<Widget>
+	pointerContainerType          // a container of raw pointers, e.g. std::vector<Widget*>
+)
+
+// classifyType classifies a resolved member type: a * outside template
+// arguments makes a raw pointer, one inside the arguments of a standard
+// container a container of pointers. A * in a parameter list, as in
+// std::function<void(Foo*)>, holds nothing, and a stray > means the tokens
+// are the tail of a template type rather than a type.
+func classifyType(typ []Token) typeKind {
+	start := 0
+	for start < len(typ) && typ[start].Type == TokenKeyword && typeQualifiers[typ[start].Value] {
+		start++
+	}
+	if templateAt(typ, start, smartPointerTypes) {
+		return smartPointerType
+	}
+	depth, parens := 0, 0
+	nestedPointer := false
+	for _, tok := range typ {
+		switch tok.Value {
+		case "<":
+			depth++
+		case ">":
+			if depth--; depth < 0 {
+				return plainType
+			}
+		case "(":
+			parens++
+		case ")":
+			parens--
+		case "*":
+			if parens > 0 {
+				continue
+			}
+			if depth == 0 {
+				return pointerType
+			}
+			nestedPointer = true
+		}
+	}
+	if nestedPointer && templateAt(typ, start, containerTypes) {
+		return pointerContainerType
+	}
+	return plainType
+}
+
+// typeQualifiers are keywords that may precede the type of a member
+var typeQualifiers = map[string]bool{
+	"const": true, "static": true, "mutable": true, "volatile": true,
+	"inline": true, "constexpr": true,
+}
+
+// isSmartPointerAt reports whether the tokens at pos start a smart pointer
+// type such as std::unique_ptr< or boost::scoped_ptr<
+func (p *Parser) isSmartPointerAt(pos int) bool {
+	return templateAt(p.tokens, pos, smartPointerTypes)
+}
+
+// templateAt reports whether the tokens at pos start an instantiation of
+// one of the templates, keyed by unqualified name with the namespaces they
+// are recognized in, such as std::unique_ptr<
+func templateAt(tokens []Token, pos int, templates map[string][]string) bool {
+	if pos+1 >= len(tokens) {
+		return false
+	}
+
+	namespace := ""
+	if pos+2 < len(tokens) && tokens[pos+1].Value == "::" {
+		namespace = tokens[pos].Value
+		pos += 2
+	}
+
+	namespaces, known := templates[tokens[pos].Value]
+	if !known || pos+1 >= len(tokens) || tokens[pos+1].Value != "<" {
+		return false
+	}
+	if namespace == "" {
+		return true // unqualified, e.g. after using namespace std
+	}
+	for _, ns := range namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// managedAllocationTarget checks whether the 'new' at position pos hands its
+// result straight to a smart pointer, either via ptr.reset(new T) or via a
+// smart pointer constructor such as boost::shared_ptr<T>(new T). It returns
+// the smart pointer variable when it can be determined.
+func (p *Parser) managedAllocationTarget(pos int) (string, bool) {
+	if pos < 2 || p.tokens[pos-1].Value != "(" {
+		return "", false
+	}
+
+	// ptr.reset(new T) or ptr->reset(new T)
+	if pos >= 4 && p.tokens[pos-2].Value == "reset" &&
+		(p.tokens[pos-3].Value == "." || p.tokens[pos-3].Value == "->") &&
+		p.tokens[pos-4].Type == TokenIdent {
+		return p.tokens[pos-4].Value, true
+	}
+
+	// smart_ptr<T>(new T)
+	if p.isSmartPointerEndingAt(pos - 2) {
+		return p.findAssignmentTarget(), true
+	}
+
+	// smart_ptr<T> name(new T)
+	if pos >= 3 && p.tokens[pos-2].Type == TokenIdent && p.isSmartPointerEndingAt(pos-3) {
+		return p.tokens[pos-2].Value, true
+	}
+	return "", false
+}
+
+// isSmartPointerEndingAt reports whether the '>' at position end closes the
+// template argument list of a smart pointer type
+func (p *Parser) isSmartPointerEndingAt(end int) bool {
+	if end < 0 || p.tokens[end].Value != ">" {
+		return false
+	}
+	depth := 0
+	for i := end; i >= 0 && i > end-20; i-- {
+		switch p.tokens[i].Value {
+		case ">":
+			depth++
+		case "<":
+			depth--
+		}
+		if depth > 0 {
+			continue
+		}
+		start := i - 1
+		if start >= 2 && p.tokens[start-1].Value == "::" {
+			start -= 2
+		}
+		return start >= 0 && p.isSmartPointerAt(start)
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package parser
+
+// leadingSpecifiers may precede the name of a constructor or destructor
+var leadingSpecifiers = map[string]bool{
+	"explicit": true, "constexpr": true, "consteval": true, "inline": true, "virtual": true,
+}
+
+// attributeEnd returns the index after an attribute at i, such as
+// [[nodiscard]], __attribute__((cold)), __declspec(dllexport) or
+// alignas(16), or i when there is none
+func (p *Parser) attributeEnd(i int) int {
+	if i+1 >= len(p.tokens) {
+		return i
+	}
+	switch p.tokens[i].Value {
+	case "[":
+		if p.tokens[i+1].Value != "[" {
+			return i
+		}
+		for depth := 0; i < len(p.tokens); i++ {
+			switch p.tokens[i].Value {
+			case "[":
+				depth++
+			case "]":
+				if depth--; depth == 0 {
+					return i + 1
+				}
+			}
+		}
+		return i
+	case "__attribute__", "__declspec", "alignas":
+		if p.tokens[i+1].Value == "(" {
+			return p.matchingParen(i+1) + 1
+		}
+	}
+	return i
+}
+
+// declSpecifierEnd returns the index after the attributes and specifiers
+// such as explicit or constexpr that start a declaration at i
+func (p *Parser) declSpecifierEnd(i int) int {
+	for i < len(p.tokens) {
+		if end := p.attributeEnd(i); end > i {
+			i = end
+		} else if leadingSpecifiers[p.tokens[i].Value] {
+			i++
+			// explicit(bool)
+			if i < len(p.tokens) && p.tokens[i-1].Value == "explicit" && p.tokens[i].Value == "(" {
+				i = p.matchingParen(i) + 1
+			}
+		} else {
+			break
+		}
+	}
+	return i
+}
+
+// skipSpecifiers skips what may follow a parameter list before the body,
+// an initializer list, = 0 or the ;: const, volatile and ref qualifiers,
+// noexcept and throw with their arguments, override, final, attributes,
+// macros, a requires-clause and a trailing return type. It reports whether there is a
+// trailing return type and whether that is a raw pointer.
+func (p *Parser) skipSpecifiers() (trailing, raw bool) {
+	for !p.isAtEnd() {
+		switch tok := p.current(); {
+		case p.attributeEnd(p.pos) > p.pos:
+			p.pos = p.attributeEnd(p.pos)
+		case tok.Value == "->":
+			return p.trailingReturn()
+		case tok.Value == "requires":
+			p.pos = p.requiresClauseEnd(p.pos)
+		case tok.Value == "noexcept" || tok.Value == "throw" || tok.Type == TokenIdent:
+			// override, final and macros such as Q_DECL_NOEXCEPT_EXPR(x)
+			p.advance()
+			if p.checkValue("(") {
+				p.pos = p.matchingParen(p.pos) + 1
+			}
+		case tok.Value == "const" || tok.Value == "volatile" || tok.Value == "&" || tok.Value == "&&":
+			p.advance()
+		default:
+			return false, false
+		}
+	}
+	return false, false
+}
+
+// trailingReturn skips a trailing return type such as -> char* at the
+// current position, reporting whether there is one and whether it is a raw
+// pointer
+func (p *Parser) trailingReturn() (trailing, raw bool) {
+	if !p.matchValue("->") {
+		return false, false
+	}
+	depth := 0
+	for ; !p.isAtEnd(); p.advance() {
+		switch v := p.current().Value; {
+		case v == "(" || v == "<" || v == "[":
+			depth++
+		case v == ")" || v == ">" || v == "]":
+			depth--
+		case depth == 0 && (v == "{" || v == ";" || v == "="):
+			return true, raw
+		case depth == 0 && v != "const" && v != "override" && v != "final":
+			raw = v == "*"
+		}
+	}
+	return true, raw
+}
+
+// requiresClauseEnd returns the index after a requires-clause at i, such
+// as requires Allocatable<T> && (sizeof(T) > 4), in a template head or
+// after a parameter list
+func (p *Parser) requiresClauseEnd(i int) int {
+	for i++; i < len(p.tokens); i++ {
+		switch p.tokens[i].Value {
+		case "!":
+			continue
+		case "(":
+			i = p.matchingParen(i) + 1
+		case "requires":
+			// A requires-expression, requires (T t) { t.size(); }
+			if i+1 < len(p.tokens) && p.tokens[i+1].Value == "(" {
+				i = p.matchingParen(i + 1)
+			}
+			i++
+			for depth := 0; i < len(p.tokens); i++ {
+				if p.tokens[i].Value == "{" {
+					depth++
+				} else if p.tokens[i].Value == "}" {
+					if depth--; depth <= 0 {
+						i++
+						break
+					}
+				} else if depth == 0 {
+					break
+				}
+			}
+		default:
+			// A concept or constant, possibly qualified: std::integral<U>, true
+			for i < len(p.tokens) && (p.tokens[i].Type == TokenIdent || p.tokens[i].Value == "::" || p.tokens[i].Value == "true" || p.tokens[i].Value == "false") {
+				i++
+			}
+			if i < len(p.tokens) && p.tokens[i].Value == "<" {
+				i = p.angleEnd(i)
+			}
+		}
+		if i >= len(p.tokens) || p.tokens[i].Value != "&&" && p.tokens[i].Value != "||" {
+			return i
+		}
+	}
+	return i
+}
+
+// angleEnd returns the index after the > closing the < at open, stopping
+// at a ; or { when the brackets do not balance
+func (p *Parser) angleEnd(open int) int {
+	depth := 0
+	for i := open; i < len(p.tokens); i++ {
+		switch p.tokens[i].Value {
+		case "<":
+			depth++
+		case ">":
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		case "(":
+			i = p.matchingParen(i)
+		case ";", "{":
+			return i
+		}
+	}
+	return len(p.tokens) - 1
+}
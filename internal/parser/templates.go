@@ -0,0 +1,100 @@
+package parser
+
+import "strings"
+
+// templateHead is the template <...> the declaration at end is under
+type templateHead struct {
+	params []string // parameter names, variadic ones with ..., e.g. Ts...
+	end    int      // token index of the declaration following the head
+}
+
+// readTemplateHead reads template <typename T, class U> and its
+// requires-clause at the current position. A head right after another, as
+// on a member template defined out of its class template, keeps the outer
+// head's parameters, which are the class's.
+func (p *Parser) readTemplateHead() {
+	nested := p.pos > 0 && p.template.end == p.pos
+	p.advance() // skip 'template'
+	var params []string
+	if p.checkValue("<") {
+		end := p.angleEnd(p.pos)
+		params = p.templateParams(p.pos, end)
+		p.pos = end
+	}
+	if p.checkValue("requires") {
+		p.pos = p.requiresClauseEnd(p.pos)
+	}
+	if nested {
+		params = p.template.params
+	}
+	p.template = templateHead{params: params, end: p.pos}
+}
+
+// templateParams returns the names of the parameters of the template head
+// from open, its <, to end, past its >
+func (p *Parser) templateParams(open, end int) []string {
+	var params []string
+	name, variadic, defaulted := "", false, false
+	flush := func() {
+		if variadic {
+			name += "..."
+		}
+		params = append(params, name)
+		name, variadic, defaulted = "", false, false
+	}
+	depth := 0
+	for i := open + 1; i < end-1; i++ {
+		tok := p.tokens[i]
+		switch {
+		case tok.Value == "<" || tok.Value == "(":
+			depth++
+		case tok.Value == ">" || tok.Value == ")":
+			depth--
+		case depth > 0 || defaulted:
+		case tok.Value == ",":
+			flush()
+		case tok.Value == "=":
+			defaulted = true
+		case tok.Value == "...":
+			variadic = true
+		case tok.Type == TokenIdent:
+			name = tok.Value
+		}
+	}
+	if end-1 > open+1 {
+		flush()
+	}
+	return params
+}
+
+// templateArgs returns the template arguments from open, their <, to end,
+// past their >, as written but with spacing normalized, e.g. std::string, T*
+func (p *Parser) templateArgs(open, end int) string {
+	var b strings.Builder
+	for i := open + 1; i < end-1; i++ {
+		tok := p.tokens[i]
+		if i > open+1 && isWord(tok) && isWord(p.tokens[i-1]) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(tok.Value)
+		if tok.Value == "," {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// specialization returns the specialization named by the template
+// arguments args of a class name starting the declaration at start: none
+// when they are the parameters of the template head over it, as in
+// template <typename T> void Cache<T>::get(), and args otherwise
+func (p *Parser) specialization(args string, start int) string {
+	if p.template.end == start && args == strings.Join(p.template.params, ", ") {
+		return ""
+	}
+	return args
+}
+
+func isWord(tok Token) bool {
+	return tok.Type == TokenIdent || tok.Type == TokenKeyword || tok.Type == TokenNumber
+}
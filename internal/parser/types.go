@@ -1,5 +1,10 @@
 package parser
 
+import (
+	"fmt"
+	"slices"
+)
+
 // Token represents a lexical token from C++ source
 type TokenType int
 
@@ -14,74 +19,385 @@ const (
 	TokenNewline
 )
 
+var tokenTypeNames = [...]string{
+	TokenEOF:         "EOF",
+	TokenIdent:       "IDENT",
+	TokenNumber:      "NUMBER",
+	TokenString:      "STRING",
+	TokenKeyword:     "KEYWORD",
+	TokenOperator:    "OPERATOR",
+	TokenPunctuation: "PUNCT",
+	TokenNewline:     "NEWLINE",
+}
+
+// String returns the token type's name, e.g. IDENT
+func (t TokenType) String() string {
+	if t >= 0 && int(t) < len(tokenTypeNames) {
+		return tokenTypeNames[t]
+	}
+	return fmt.Sprintf("TokenType(%d)", int(t))
+}
+
+// MarshalText encodes the token type as its name
+func (t TokenType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
 type Token struct {
-	Type   TokenType
-	Value  string
-	Line   int
-	Column int
+	Type   TokenType `json:"type"`
+	Value  string    `json:"value"`
+	Line   int       `json:"line"`
+	Column int       `json:"column"`
+}
+
+// Comment represents a source comment retained by the lexer
+type Comment struct {
+	Text    string `json:"text"` // comment text including the // or /* */ markers
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	EndLine int    `json:"end_line"`
+	OwnLine bool   `json:"own_line"` // true when no code precedes the comment on its line
+}
+
+// Ownership describes an ownership annotation attached to a member
+type Ownership int
+
+const (
+	OwnershipUnknown   Ownership = iota
+	OwnershipOwns                // annotated with // leakcheck:owns
+	OwnershipNonOwning           // annotated with // leakcheck:non-owning
+)
+
+// String returns the annotation name, or "" for OwnershipUnknown
+func (o Ownership) String() string {
+	switch o {
+	case OwnershipOwns:
+		return "owns"
+	case OwnershipNonOwning:
+		return "non-owning"
+	}
+	return ""
+}
+
+// MarshalText encodes the ownership as its annotation name
+func (o Ownership) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// Unit is what one parsed file defines
+type Unit struct {
+	Classes []Class
+	// Functions are the functions defined outside any class
+	Functions []Function
+	// Globals are the file-scope raw pointers initialized with new
+	Globals []Global
+	Lines   int // source lines tokenized
+}
+
+// Global is a raw pointer with static storage duration initialized with
+// new: a file-scope variable, a static data member or a static local
+type Global struct {
+	Name     string `json:"name"`
+	Class    string `json:"class,omitempty"`    // class of a static member, or of the function of a static local
+	Function string `json:"function,omitempty"` // function declaring a static local
+	Type     string `json:"type,omitempty"`     // allocated type, e.g. Widget
+	IsArray  bool   `json:"is_array,omitempty"`
+	// Returned is set for a static local that its function returns, the
+	// shape of a lazily created singleton
+	Returned bool   `json:"returned,omitempty"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
 }
 
 // Class represents a C++ class or struct
 type Class struct {
-	Name        string
-	File        string
-	StartLine   int
-	EndLine     int
-	Members     []Member
-	Constructor *Function
-	Destructor  *Function
-	Methods     []Function
+	Name string `json:"name"`
+	// Namespace qualifies Name, e.g. audio::detail; inline namespaces are
+	// left out and anonymous ones are unique to their file
+	Namespace string `json:"namespace,omitempty"`
+	// Specialization holds the template arguments of an explicit or
+	// partial specialization, e.g. std::string for Cache<std::string>,
+	// which is a class apart from its primary template
+	Specialization string `json:"specialization,omitempty"`
+	File           string `json:"file,omitempty"`
+	StartLine      int    `json:"start_line"`
+	EndLine        int    `json:"end_line"`
+	// DefinitionFile is the file containing the class body; empty for
+	// classes only seen through out-of-class method definitions
+	DefinitionFile string `json:"definition_file,omitempty"`
+	// Bases are the direct base classes in declaration order
+	Bases []Base `json:"bases,omitempty"`
+	// Friends are the friend classes and functions the class declares
+	Friends     []Friend   `json:"friends,omitempty"`
+	Members     []Member   `json:"members"`
+	Constructor *Function  `json:"constructor,omitempty"`
+	Destructor  *Function  `json:"destructor,omitempty"`
+	Methods     []Function `json:"methods,omitempty"`
+	// Move operations, parsed separately from the primary constructor
+	MoveConstructor *Function `json:"move_constructor,omitempty"`
+	MoveAssignment  *Function `json:"move_assignment,omitempty"`
+	// Locations are where the class was seen, one per parsed file
+	Locations []SourceLocation `json:"locations,omitempty"`
+	// Language is the language of the file implementing the class
+	Language Language `json:"language,omitempty"`
+}
+
+// Files returns the files the class was seen in, the file of its body
+// first
+func (c *Class) Files() []string {
+	files := []string{c.File}
+	for _, loc := range c.Locations {
+		if !slices.Contains(files, loc.File) {
+			files = append(files, loc.File)
+		}
+	}
+	return files
+}
+
+// QualifiedName returns the name of the class with its namespace and
+// specialization, e.g. audio::Buffer or audio::Cache<std::string>
+func (c *Class) QualifiedName() string {
+	return qualify(c.Namespace, c.SpecializedName())
+}
+
+// SpecializedName returns the name of the class with its specialization
+// arguments, e.g. Cache<std::string>
+func (c *Class) SpecializedName() string {
+	if c.Specialization == "" {
+		return c.Name
+	}
+	return c.Name + "<" + c.Specialization + ">"
+}
+
+// Base is a base class of a class, as in : public virtual ns::Base<int>
+type Base struct {
+	Name    string `json:"name"`   // as written, e.g. ns::Base<int>
+	Access  string `json:"access"` // public, protected or private
+	Virtual bool   `json:"virtual,omitempty"`
+}
+
+// Friend is a friend declaration in a class body: friend class Cleaner; or
+// friend void destroy(Pool*);
+type Friend struct {
+	Name     string `json:"name"` // as written without template arguments, e.g. ns::Cleaner
+	Function bool   `json:"function,omitempty"`
+	Line     int    `json:"line"`
+}
+
+// SourceLocation is the span of a file covered by a class: its body, or
+// the out-of-class definitions of its methods
+type SourceLocation struct {
+	File      string       `json:"file"`
+	StartLine int          `json:"start_line"`
+	EndLine   int          `json:"end_line"`
+	Kind      LocationKind `json:"kind"`
 }
 
+// LocationKind tells the class body from out-of-class method definitions
+type LocationKind string
+
+const (
+	LocationDefinition     LocationKind = "definition"     // the class body, typically in a header
+	LocationImplementation LocationKind = "implementation" // method definitions outside the body
+)
+
 // Member represents a class member variable
 type Member struct {
-	Name      string
-	Type      string
-	IsPointer bool
-	IsArray   bool
-	Line      int
+	Name           string `json:"name"`
+	Type           string `json:"type,omitempty"`
+	IsPointer      bool   `json:"is_pointer"`
+	IsSmartPointer bool   `json:"is_smart_pointer,omitempty"` // std/boost smart pointer, never a raw owner
+	// PointerContainer marks a standard container of raw pointers, e.g.
+	// std::vector<Widget*>; IsPointer is false for it
+	PointerContainer bool      `json:"pointer_container,omitempty"`
+	IsArray          bool      `json:"is_array,omitempty"`
+	IsStatic         bool      `json:"is_static,omitempty"`
+	Line             int       `json:"line"`
+	Column           int       `json:"column,omitempty"`
+	Ownership        Ownership `json:"ownership,omitempty"`
 }
 
 // Function represents a class method (constructor, destructor, or regular method)
 type Function struct {
-	Name          string
-	IsDestructor  bool
-	StartLine     int
-	EndLine       int
-	Allocations   []Allocation
-	Deallocations []Deallocation
-	MethodCalls   []string       // Methods called within this function
-	Aliases       []PointerAlias // Pointer aliasing within this function
+	Name          string         `json:"name"`
+	File          string         `json:"file,omitempty"` // file containing the definition or declaration
+	IsDestructor  bool           `json:"is_destructor,omitempty"`
+	IsStatic      bool           `json:"is_static,omitempty"`   // static member function, as declared in the class
+	ReturnsRaw    bool           `json:"returns_raw,omitempty"` // the return type is a raw pointer, e.g. Widget*
+	StartLine     int            `json:"start_line"`
+	EndLine       int            `json:"end_line"`
+	Allocations   []Allocation   `json:"allocations,omitempty"`
+	Deallocations []Deallocation `json:"deallocations,omitempty"`
+	Params        []string       `json:"params,omitempty"`       // Parameter names, "" when unnamed
+	MethodCalls   []string       `json:"method_calls,omitempty"` // Methods called within this function
+	Calls         []Call         `json:"calls,omitempty"`        // Calls with their arguments, in source order
+	Aliases       []PointerAlias `json:"aliases,omitempty"`      // Pointer aliasing within this function
+	Assignments   []Assignment   `json:"assignments,omitempty"`  // Simple assignments and member initializers
+	Returns       []Return       `json:"returns,omitempty"`      // Values of return statements
+	// Arithmetic that moves a variable, e.g. p++ or p += n
+	Arithmetic []PointerArithmetic `json:"arithmetic,omitempty"`
+	Throws     []Throw             `json:"throws,omitempty"`
+	TryBlocks  []TryBlock          `json:"try_blocks,omitempty"`
+	// Parameter indices annotated with // leakcheck:takes-ownership
+	TakesOwnership []int `json:"takes_ownership,omitempty"`
+	// Name of the rvalue-reference parameter of a move constructor or
+	// move assignment operator, e.g. "other"
+	MoveSource string `json:"move_source,omitempty"`
+	// SelfCheck is the line of an if comparing this with the address of
+	// another object, as in if (this != &other); 0 when there is none
+	SelfCheck int `json:"self_check,omitempty"`
+	// CopyAndSwap is set on an assignment operator that takes the class by
+	// value and swaps with it, as in operator=(Foo other) { swap(*this, other); }
+	CopyAndSwap bool `json:"copy_and_swap,omitempty"`
+	// Defaulted is set on a destructor declared = default, which releases
+	// no raw pointer members
+	Defaulted bool `json:"defaulted,omitempty"`
+}
+
+// Assignment represents an assignment statement or member initializer
+type Assignment struct {
+	Target string `json:"target"` // assigned variable, e.g. data_ or other.data_
+	Value  string `json:"value"`  // right-hand side source text, e.g. other.data_
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+}
+
+// PointerArithmetic represents a statement moving a variable by an offset:
+// p++, ++p, p--, p += n, p -= n or p = p + n
+type PointerArithmetic struct {
+	VarName string `json:"variable"`
+	Op      string `json:"op"` // ++, --, += or -=; p = p + n is recorded as +=
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// Throw represents a throw expression
+type Throw struct {
+	Line    int  `json:"line"`
+	Column  int  `json:"column,omitempty"`
+	Rethrow bool `json:"rethrow,omitempty"` // a bare throw, rethrowing the caught exception
+}
+
+// TryBlock represents a try block with its catch handlers
+type TryBlock struct {
+	Line    int     `json:"line"`     // line of the try keyword
+	EndLine int     `json:"end_line"` // line of the closing brace of the try block
+	Catches []Catch `json:"catches,omitempty"`
+}
+
+// Catch represents one catch handler of a try block
+type Catch struct {
+	Line    int `json:"line"`
+	EndLine int `json:"end_line"`
+}
+
+// Return represents a return statement with a value
+type Return struct {
+	Value string `json:"value"` // returned expression, with any this-> prefix stripped
+	Line  int    `json:"line"`
+}
+
+// Call represents a function or method call inside a function body
+type Call struct {
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"` // argument source text, with any this-> prefix stripped
+	Line int      `json:"line"`
 }
 
 // Allocation represents a dynamic memory allocation
 type Allocation struct {
-	VarName string
-	IsArray bool // true for new[], false for new
-	Line    int
+	VarName  string `json:"variable"`
+	Type     string `json:"type,omitempty"`      // allocated type, e.g. Widget
+	IsArray  bool   `json:"is_array,omitempty"`  // true for new[], false for new
+	Managed  bool   `json:"managed,omitempty"`   // result handed straight to a smart pointer, e.g. ptr.reset(new T)
+	PassedTo string `json:"passed_to,omitempty"` // function receiving the allocation as an argument, e.g. registerWidget(new Widget)
+	ArgIndex int    `json:"arg_index,omitempty"` // argument position when PassedTo is set
+	// Allocator and Deallocator name a custom allocation function pair
+	// (g_malloc/g_free); both are empty for new
+	Allocator   string `json:"allocator,omitempty"`
+	Deallocator string `json:"deallocator,omitempty"`
+	// Discarded marks an expression statement such as `new Foo(args);`
+	// whose result is never stored
+	Discarded bool `json:"discarded,omitempty"`
+	// Static marks a static local, e.g. static Foo* instance = new Foo;
+	Static bool `json:"static,omitempty"`
+	// Container is the container the allocation is stored in, as in
+	// items_.push_back(new Item) or widgets_[key] = new Widget
+	Container string `json:"container,omitempty"`
+	File      string `json:"file,omitempty"` // file of the function it is in
+	Line      int    `json:"line"`
+	Column    int    `json:"column,omitempty"`
+}
+
+// IsRaw reports whether the allocation is stored in a raw pointer variable,
+// as opposed to a smart pointer, a call argument or nowhere at all
+func (a Allocation) IsRaw() bool {
+	return a.VarName != "" && !a.Managed
 }
 
 // Deallocation represents a dynamic memory deallocation
 type Deallocation struct {
-	VarName string
-	IsArray bool // true for delete[], false for delete
-	Line    int
+	// VarName is the deleted variable, or the member access such as
+	// pool.head_ or p->tail_ when a member of another object is deleted
+	VarName string `json:"variable"`
+	IsArray bool   `json:"is_array,omitempty"` // true for delete[], false for delete
+	// Conditional marks a delete inside an if or else branch, unless every
+	// enclosing condition only checks the pointer for null
+	Conditional bool `json:"conditional,omitempty"`
+	// Deallocator names the release function of a custom allocator, e.g.
+	// free; empty for delete
+	Deallocator string `json:"deallocator,omitempty"`
+	// Container is the container whose element is deleted, as in
+	// for (auto* w : widgets_) delete w; or delete items_[i]
+	Container string `json:"container,omitempty"`
+	File      string `json:"file,omitempty"` // file of the function it is in
+	Line      int    `json:"line"`
+	Column    int    `json:"column,omitempty"`
 }
 
 // PointerAlias represents when one pointer is assigned to another
 type PointerAlias struct {
-	SourceVar string // original pointer (e.g., ptr1)
-	TargetVar string // alias pointer (e.g., ptr2 = ptr1)
-	Line      int
+	SourceVar string `json:"source"` // original pointer (e.g., ptr1)
+	TargetVar string `json:"target"` // alias pointer (e.g., ptr2 = ptr1)
+	Line      int    `json:"line"`
+	Column    int    `json:"column,omitempty"`
 }
 
 // Leak represents a detected memory leak
 type Leak struct {
-	File           string `json:"file"`
-	Line           int    `json:"line"`
-	ClassName      string `json:"class"`
+	RuleID    string `json:"rule"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column,omitempty"`
+	ClassName string `json:"class"`
+	// Namespace qualifies ClassName, telling audio::Buffer from
+	// video::Buffer
+	Namespace      string `json:"namespace,omitempty"`
 	VarName        string `json:"variable"`
 	Reason         string `json:"reason"`
 	Severity       string `json:"severity"`       // "error", "warning"
+	Confidence     string `json:"confidence"`     // "high", "medium", "low"
 	Recommendation string `json:"recommendation"` // How to fix
+	Fingerprint    string `json:"fingerprint,omitempty"`
+	// Related are other places the finding involves, e.g. the delete in
+	// another file that makes a double free
+	Related []RelatedLocation `json:"related,omitempty"`
+}
+
+// QualifiedClass returns the class of the finding with its namespace and
+// specialization arguments, e.g. audio::Cache<int>, or "" for findings
+// outside any class
+func (l *Leak) QualifiedClass() string {
+	if l.ClassName == "" {
+		return ""
+	}
+	return qualify(l.Namespace, l.ClassName)
+}
+
+// RelatedLocation is a secondary location of a finding
+type RelatedLocation struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
 }
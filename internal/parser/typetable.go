@@ -0,0 +1,301 @@
+package parser
+
+import "strings"
+
+// maxAliasDepth bounds alias chains such as typedef A B; typedef B C;
+const maxAliasDepth = 16
+
+// TypeTable resolves typedefs, using aliases and alias templates to the
+// types they name, so WidgetPtr in typedef Widget* WidgetPtr reads as
+// Widget*. Aliases declared in a class or namespace are registered under
+// their qualified name, e.g. Pool::Handle, and found unqualified from
+// within that scope.
+type TypeTable struct {
+	aliases map[string]*typeAlias
+	short   map[string]string // unqualified name -> first qualified name
+}
+
+// typeAlias is one typedef or using declaration
+type typeAlias struct {
+	params []string // parameters of an alias template, e.g. T in template <class T> using Ptr = T*
+	target []Token
+}
+
+// NewTypeTable creates an empty type table
+func NewTypeTable() *TypeTable {
+	return &TypeTable{aliases: make(map[string]*typeAlias), short: make(map[string]string)}
+}
+
+// Collect registers the aliases declared in a token stream, tracking the
+// classes and namespaces they are nested in
+func (t *TypeTable) Collect(tokens []Token) {
+	type scope struct {
+		name  string
+		depth int
+	}
+	var scopes []scope
+	depth := 0
+	pending := "" // class or namespace whose { comes next
+	var params []string
+
+	qualified := func(name string) string {
+		if len(scopes) == 0 {
+			return name
+		}
+		return scopes[len(scopes)-1].name + "::" + name
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.Value == "{":
+			depth++
+			if pending != "" {
+				scopes = append(scopes, scope{name: qualified(pending), depth: depth})
+				pending = ""
+			}
+		case tok.Value == "}":
+			if len(scopes) > 0 && scopes[len(scopes)-1].depth == depth {
+				scopes = scopes[:len(scopes)-1]
+			}
+			depth--
+		case tok.Value == ";":
+			pending = ""
+			params = nil
+		case tok.Value == "namespace" && i > 0 && tokens[i-1].Value == "inline":
+			// inline namespace v1 is transparent, and so is an anonymous one
+		case tok.Value == "namespace" && i+1 < len(tokens) && tokens[i+1].Type == TokenIdent:
+			// namespace audio::detail
+			pending = tokens[i+1].Value
+			for i+3 < len(tokens) && tokens[i+2].Value == "::" && tokens[i+3].Type == TokenIdent {
+				pending += "::" + tokens[i+3].Value
+				i += 2
+			}
+		case (tok.Value == "class" || tok.Value == "struct") && i+1 < len(tokens) && tokens[i+1].Type == TokenIdent:
+			pending = tokens[i+1].Value
+		case tok.Value == "template" && i+1 < len(tokens) && tokens[i+1].Value == "<":
+			params, i = templateParams(tokens, i+1)
+		case tok.Value == "typedef":
+			end := statementEnd(tokens, i)
+			if name, target, ok := typedefParts(tokens[i+1 : end]); ok {
+				t.add(qualified(name), &typeAlias{target: target})
+			}
+			i = end
+			params = nil
+		case tok.Value == "using" && i+2 < len(tokens) && tokens[i+1].Type == TokenIdent && tokens[i+2].Value == "=":
+			end := statementEnd(tokens, i)
+			t.add(qualified(tokens[i+1].Value), &typeAlias{params: params, target: tokens[i+3 : end]})
+			i = end
+			params = nil
+		}
+	}
+}
+
+// add registers an alias, copying its tokens, which the lexer recycles
+func (t *TypeTable) add(name string, alias *typeAlias) {
+	if len(alias.target) == 0 {
+		return
+	}
+	alias.target = append([]Token(nil), alias.target...)
+	t.aliases[name] = alias
+	short := name[strings.LastIndex(name, ":")+1:]
+	if _, ok := t.short[short]; !ok {
+		t.short[short] = name
+	}
+}
+
+// statementEnd returns the index of the ; ending the statement at i, or the
+// end of the tokens
+func statementEnd(tokens []Token, i int) int {
+	for ; i < len(tokens); i++ {
+		if tokens[i].Value == ";" || tokens[i].Type == TokenEOF {
+			return i
+		}
+	}
+	return len(tokens)
+}
+
+// templateParams reads the parameter names of template <...> whose < is at
+// open, returning them and the index of the closing >
+func templateParams(tokens []Token, open int) ([]string, int) {
+	var params []string
+	depth := 0
+	expectName := false
+	for i := open; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.Value {
+		case "<":
+			depth++
+		case ">":
+			if depth--; depth == 0 {
+				return params, i
+			}
+		case "typename", "class":
+			expectName = depth == 1
+			continue
+		case ",", "=":
+			expectName = false
+		}
+		if expectName && tok.Type == TokenIdent {
+			params = append(params, tok.Value)
+			expectName = false
+		}
+	}
+	return params, len(tokens) - 1
+}
+
+// typedefParts splits the tokens of typedef <type> <name> into the name and
+// the type. Function pointer typedefs, several declarators and typedefs of
+// a class body are skipped.
+func typedefParts(decl []Token) (string, []Token, bool) {
+	if len(decl) < 2 {
+		return "", nil, false
+	}
+	depth := 0
+	for _, tok := range decl {
+		switch tok.Value {
+		case "(", "{":
+			return "", nil, false
+		case "<":
+			depth++
+		case ">":
+			depth--
+		case ",":
+			if depth == 0 {
+				return "", nil, false
+			}
+		}
+	}
+	last := len(decl) - 1
+	for last > 0 && decl[last].Value == "]" {
+		// typedef char Buffer[64]: an array, not a pointer
+		for last > 0 && decl[last].Value != "[" {
+			last--
+		}
+		last--
+	}
+	if decl[last].Type != TokenIdent {
+		return "", nil, false
+	}
+	return decl[last].Value, decl[:last], true
+}
+
+// lookup finds the alias a possibly qualified name refers to from within
+// scope, searching the enclosing scopes outwards
+func (t *TypeTable) lookup(name, scope string) *typeAlias {
+	for s := scope; s != ""; {
+		if alias, ok := t.aliases[s+"::"+name]; ok {
+			return alias
+		}
+		i := strings.LastIndex(s, "::")
+		if i < 0 {
+			break
+		}
+		s = s[:i]
+	}
+	if alias, ok := t.aliases[name]; ok {
+		return alias
+	}
+	if !strings.Contains(name, "::") {
+		// e.g. after using namespace app
+		if full, ok := t.short[name]; ok {
+			return t.aliases[full]
+		}
+	}
+	return nil
+}
+
+// Resolve returns a type with every alias replaced by the type it names,
+// looked up from within scope (a class name such as Outer::Inner, or "")
+func (t *TypeTable) Resolve(tokens []Token, scope string) []Token {
+	if t == nil || len(t.aliases) == 0 {
+		return tokens
+	}
+	return t.resolve(tokens, scope, 0)
+}
+
+func (t *TypeTable) resolve(tokens []Token, scope string, depth int) []Token {
+	if depth >= maxAliasDepth {
+		return tokens
+	}
+	var out []Token
+	for i := 0; i < len(tokens); {
+		if tokens[i].Type != TokenIdent {
+			out = append(out, tokens[i])
+			i++
+			continue
+		}
+		// Read a qualified name such as Pool::Handle
+		end := i + 1
+		for end+1 < len(tokens) && tokens[end].Value == "::" && tokens[end+1].Type == TokenIdent {
+			end += 2
+		}
+		var name strings.Builder
+		for _, tok := range tokens[i:end] {
+			name.WriteString(tok.Value)
+		}
+		alias := t.lookup(name.String(), scope)
+		if alias == nil {
+			out = append(out, tokens[i:end]...)
+			i = end
+			continue
+		}
+
+		target := alias.target
+		if len(alias.params) > 0 && end < len(tokens) && tokens[end].Value == "<" {
+			args, next := templateArgs(tokens, end)
+			target = substituteParams(target, alias.params, args)
+			end = next
+		}
+		out = append(out, t.resolve(target, scope, depth+1)...)
+		i = end
+	}
+	return out
+}
+
+// templateArgs splits the template arguments whose < is at open, returning
+// them and the index after the closing >
+func templateArgs(tokens []Token, open int) ([][]Token, int) {
+	var args [][]Token
+	var current []Token
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.Value == "<":
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case tok.Value == ">":
+			if depth--; depth == 0 {
+				return append(args, current), i + 1
+			}
+		case tok.Value == "," && depth == 1:
+			args = append(args, current)
+			current = nil
+			continue
+		}
+		current = append(current, tok)
+	}
+	return append(args, current), len(tokens)
+}
+
+// substituteParams replaces alias template parameters with arguments
+func substituteParams(target []Token, params []string, args [][]Token) []Token {
+	var out []Token
+	for _, tok := range target {
+		replaced := false
+		for i, param := range params {
+			if tok.Value == param && i < len(args) {
+				out = append(out, args[i]...)
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			out = append(out, tok)
+		}
+	}
+	return out
+}
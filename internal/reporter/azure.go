@@ -0,0 +1,63 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"leakcheck/internal/parser"
+	"strings"
+)
+
+// AzureFormatter writes Azure Pipelines logging commands: one
+// task.logissue per error or warning, which annotates the pipeline run and
+// pull request, followed by a task.complete setting the task result.
+// Azure has no informational issue type, so info findings only count
+// towards the final message.
+type AzureFormatter struct {
+	BaseDir string // files under BaseDir are written as relative paths
+}
+
+// Format implements Formatter
+func (f *AzureFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	sortByLocation(leaks)
+
+	for _, leak := range leaks {
+		if leak.Severity != "error" && leak.Severity != "warning" {
+			continue
+		}
+		properties := []string{
+			"type=" + leak.Severity,
+			"sourcepath=" + azureProperty(displayPath(f.BaseDir, primaryFile(leak.File))),
+			fmt.Sprintf("linenumber=%d", leak.Line),
+		}
+		if leak.Column > 0 {
+			properties = append(properties, fmt.Sprintf("columnnumber=%d", leak.Column))
+		}
+		properties = append(properties, "code="+azureProperty(leak.RuleID))
+		message := subject(leak) + ": " + leak.Reason
+		if _, err := fmt.Fprintf(w, "##vso[task.logissue %s;]%s\n", strings.Join(properties, ";"), azureMessage(message)); err != nil {
+			return err
+		}
+	}
+
+	summary := Summarize(leaks)
+	result := "Succeeded"
+	switch {
+	case summary.Errors > 0:
+		result = "Failed"
+	case summary.Warnings > 0:
+		result = "SucceededWithIssues"
+	}
+	_, err := fmt.Fprintf(w, "##vso[task.complete result=%s;]leakcheck found %d error(s), %d warning(s), %d info\n",
+		result, summary.Errors, summary.Warnings, summary.Info)
+	return err
+}
+
+// azureMessage escapes the characters that end or corrupt a logging command
+func azureMessage(s string) string {
+	return strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// azureProperty additionally escapes the property separators
+func azureProperty(s string) string {
+	return strings.NewReplacer("%", "%AZP25", "\r", "%0D", "\n", "%0A", ";", "%3B", "]", "%5D").Replace(s)
+}
@@ -0,0 +1,54 @@
+package reporter
+
+import (
+	"fmt"
+	"os"
+)
+
+// ANSI escape sequences used by the console formatter
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+	ansiCyan   = "\033[36m"
+	ansiDim    = "\033[2m"
+)
+
+// ColorModes lists the accepted --color values
+var ColorModes = []string{"auto", "always", "never"}
+
+// UseColor resolves a --color mode for output written to out. In auto mode
+// color is used when out is a terminal and NO_COLOR is not set.
+func UseColor(mode string, out *os.File) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "", "auto":
+		if os.Getenv("NO_COLOR") != "" {
+			return false, nil
+		}
+		return isTerminal(out), nil
+	}
+	return false, fmt.Errorf("invalid color mode %q (use auto, always or never)", mode)
+}
+
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps s in the given ANSI code when enabled
+func paint(enabled bool, code, s string) string {
+	if !enabled {
+		return s
+	}
+	return code + s + ansiReset
+}
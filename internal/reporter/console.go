@@ -0,0 +1,81 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"leakcheck/internal/parser"
+	"path/filepath"
+)
+
+// ConsoleFormatter prints human-readable findings grouped by file, or by
+// class, rule or severity
+type ConsoleFormatter struct {
+	Color   bool   // emit ANSI colors
+	Context int    // source lines shown around each finding; negative disables snippets
+	GroupBy string // see GroupModes; defaults to file
+	SortBy  string // see SortModes; defaults to line
+	Stats   *Stats // printed after the summary when set
+}
+
+// Format implements Formatter
+func (f *ConsoleFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	if len(leaks) == 0 {
+		fmt.Fprintln(w, "[OK] No potential memory leaks detected.")
+		if f.Stats != nil {
+			writeStats(w, f.Stats)
+		}
+		return nil
+	}
+
+	sources := make(sourceCache)
+	byFile := f.GroupBy == "" || f.GroupBy == "file"
+
+	for _, group := range groupFindings(leaks, f.GroupBy, f.SortBy) {
+		fmt.Fprintf(w, "\n%s:\n", paint(f.Color, ansiDim, groupTitle(group.Key, f.GroupBy, "")))
+		for _, leak := range group.Leaks {
+			f.writeFinding(w, leak, byFile, sources)
+		}
+	}
+
+	// Summary
+	summary := Summarize(leaks)
+	if summary.Info > 0 {
+		fmt.Fprintf(w, "\nSummary: %d error(s), %d warning(s), %d info\n", summary.Errors, summary.Warnings, summary.Info)
+	} else {
+		fmt.Fprintf(w, "\nSummary: %d error(s), %d warning(s)\n", summary.Errors, summary.Warnings)
+	}
+	if f.Stats != nil {
+		writeStats(w, f.Stats)
+	}
+	return nil
+}
+
+// writeFinding prints one finding; the file name is included unless the
+// findings are already grouped by file
+func (f *ConsoleFormatter) writeFinding(w io.Writer, leak parser.Leak, byFile bool, sources sourceCache) {
+	icon := paint(f.Color, ansiRed, "[ERROR]")
+	if leak.Severity == "warning" {
+		icon = paint(f.Color, ansiYellow, "[WARN]") + " "
+	} else if leak.Severity == "info" {
+		icon = paint(f.Color, ansiCyan, "[INFO]") + " "
+	}
+
+	location := fmt.Sprintf("Line %d", leak.Line)
+	if !byFile {
+		location = fmt.Sprintf("%s:%d", filepath.Base(primaryFile(leak.File)), leak.Line)
+	}
+	fmt.Fprintf(w, "  %s %s [%s]: %s\n",
+		icon, location, subject(leak), leak.Reason)
+
+	if f.Context >= 0 {
+		writeSnippet(w, sources.lines(primaryFile(leak.File)), leak.Line, leak.Column, f.Context, f.Color)
+	}
+
+	for _, related := range leak.Related {
+		fmt.Fprintf(w, "         %s %s:%d: %s\n", paint(f.Color, ansiDim, "-> See:"), filepath.Base(related.File), related.Line, related.Message)
+	}
+
+	if leak.Recommendation != "" {
+		fmt.Fprintf(w, "         %s %s\n", paint(f.Color, ansiDim, "-> Fix:"), leak.Recommendation)
+	}
+}
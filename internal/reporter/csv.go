@@ -0,0 +1,47 @@
+package reporter
+
+import (
+	"encoding/csv"
+	"io"
+	"leakcheck/internal/parser"
+	"strconv"
+)
+
+// CSVFormatter writes one row per finding for spreadsheets and BI tools
+type CSVFormatter struct {
+	BaseDir string // files under BaseDir are written as relative paths
+}
+
+var csvHeader = []string{"file", "line", "column", "rule", "class", "variable", "severity", "reason", "recommendation"}
+
+// Format implements Formatter
+func (f *CSVFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	sortByLocation(leaks)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, leak := range leaks {
+		column := ""
+		if leak.Column > 0 {
+			column = strconv.Itoa(leak.Column)
+		}
+		record := []string{
+			displayPath(f.BaseDir, primaryFile(leak.File)),
+			strconv.Itoa(leak.Line),
+			column,
+			leak.RuleID,
+			leak.QualifiedClass(),
+			leak.VarName,
+			leak.Severity,
+			leak.Reason,
+			leak.Recommendation,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
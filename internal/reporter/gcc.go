@@ -0,0 +1,53 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"leakcheck/internal/parser"
+)
+
+// GCCFormatter writes compiler-style "file:line:col: error: message [LC001]"
+// lines, which Vim and Emacs quickfix, IDE external tools and generic
+// problem matchers understand without configuration. Related locations
+// follow their finding as note lines.
+type GCCFormatter struct {
+	BaseDir string // files under BaseDir are written as relative paths
+}
+
+// Format implements Formatter
+func (f *GCCFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	sortByLocation(leaks)
+
+	for _, leak := range leaks {
+		message := fmt.Sprintf("%s: %s [%s]", subject(leak), leak.Reason, leak.RuleID)
+		if err := f.writeLine(w, primaryFile(leak.File), leak.Line, leak.Column, gccSeverity(leak.Severity), message); err != nil {
+			return err
+		}
+		for _, related := range leak.Related {
+			if err := f.writeLine(w, related.File, related.Line, 0, "note", related.Message); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *GCCFormatter) writeLine(w io.Writer, file string, line, column int, severity, message string) error {
+	location := fmt.Sprintf("%s:%d", displayPath(f.BaseDir, file), line)
+	if column > 0 {
+		location = fmt.Sprintf("%s:%d", location, column)
+	}
+	_, err := fmt.Fprintf(w, "%s: %s: %s\n", location, severity, message)
+	return err
+}
+
+// gccSeverity maps finding severities to the diagnostic kinds GCC prints
+func gccSeverity(severity string) string {
+	if severity == "info" {
+		return "note"
+	}
+	if severity == "" {
+		return "warning"
+	}
+	return severity
+}
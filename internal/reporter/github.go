@@ -0,0 +1,55 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"leakcheck/internal/parser"
+	"strings"
+)
+
+// GitHubFormatter writes GitHub Actions workflow commands, which show each
+// finding as an annotation on the run and in the pull request diff
+type GitHubFormatter struct {
+	BaseDir string // files under BaseDir are written as relative paths
+}
+
+// Format implements Formatter
+func (f *GitHubFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	sortByLocation(leaks)
+
+	for _, leak := range leaks {
+		command := "warning"
+		switch leak.Severity {
+		case "error":
+			command = "error"
+		case "info":
+			command = "notice"
+		}
+		properties := []string{
+			"file=" + githubProperty(displayPath(f.BaseDir, primaryFile(leak.File))),
+			fmt.Sprintf("line=%d", leak.Line),
+		}
+		if leak.Column > 0 {
+			properties = append(properties, fmt.Sprintf("col=%d", leak.Column))
+		}
+		properties = append(properties, "title="+githubProperty(leak.RuleID+" "+subject(leak)))
+		message := leak.Reason
+		if leak.Recommendation != "" {
+			message += "\n" + leak.Recommendation
+		}
+		if _, err := fmt.Fprintf(w, "::%s %s::%s\n", command, strings.Join(properties, ","), githubMessage(message)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// githubMessage escapes the characters that end or corrupt a workflow command
+func githubMessage(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty additionally escapes the property separators
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
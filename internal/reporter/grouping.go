@@ -0,0 +1,126 @@
+package reporter
+
+import (
+	"fmt"
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GroupModes lists the supported --group-by values
+var GroupModes = []string{"file", "class", "rule", "severity"}
+
+// SortModes lists the supported --sort values
+var SortModes = []string{"line", "severity", "rule"}
+
+// ValidateLayout checks group-by and sort mode names
+func ValidateLayout(groupBy, sortBy string) error {
+	if groupBy != "" && !contains(GroupModes, groupBy) {
+		return fmt.Errorf("unknown group-by mode %q (supported: %s)", groupBy, strings.Join(GroupModes, ", "))
+	}
+	if sortBy != "" && !contains(SortModes, sortBy) {
+		return fmt.Errorf("unknown sort mode %q (supported: %s)", sortBy, strings.Join(SortModes, ", "))
+	}
+	return nil
+}
+
+// findingGroup is a run of findings sharing the same group key
+type findingGroup struct {
+	Key   string
+	Leaks []parser.Leak
+}
+
+// groupFindings orders leaks by sortBy and splits them into groups by
+// groupBy. Groups are ordered by key, except severity groups which run from
+// most to least severe.
+func groupFindings(leaks []parser.Leak, groupBy, sortBy string) []findingGroup {
+	sorted := make([]parser.Leak, len(leaks))
+	copy(sorted, leaks)
+	sortFindings(sorted, sortBy)
+
+	index := make(map[string]int)
+	var groups []findingGroup
+	for _, leak := range sorted {
+		key := groupKey(leak, groupBy)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, findingGroup{Key: key})
+		}
+		groups[i].Leaks = append(groups[i].Leaks, leak)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groupBy == "severity" {
+			return severityRank(groups[i].Key) < severityRank(groups[j].Key)
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+func groupKey(leak parser.Leak, groupBy string) string {
+	switch groupBy {
+	case "class":
+		return leak.QualifiedClass()
+	case "rule":
+		return leak.RuleID
+	case "severity":
+		return leak.Severity
+	}
+	return leak.File
+}
+
+// sortFindings orders leaks by sortBy, falling back to file and line
+func sortFindings(leaks []parser.Leak, sortBy string) {
+	sortByLocation(leaks)
+	switch sortBy {
+	case "severity":
+		sort.SliceStable(leaks, func(i, j int) bool {
+			return severityRank(leaks[i].Severity) < severityRank(leaks[j].Severity)
+		})
+	case "rule":
+		sort.SliceStable(leaks, func(i, j int) bool {
+			return leaks[i].RuleID < leaks[j].RuleID
+		})
+	}
+}
+
+// severityRank orders severities from most to least severe
+func severityRank(severity string) int {
+	switch severity {
+	case "error":
+		return 0
+	case "warning":
+		return 1
+	}
+	return 2
+}
+
+// groupTitle returns the heading shown for a group
+func groupTitle(key, groupBy, baseDir string) string {
+	switch groupBy {
+	case "rule":
+		if rule, ok := analyzer.LookupRule(key); ok {
+			return key + " " + rule.Name
+		}
+	case "file", "":
+		if baseDir == "" {
+			return filepath.Base(key)
+		}
+		return displayPath(baseDir, primaryFile(key))
+	}
+	return key
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
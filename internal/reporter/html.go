@@ -0,0 +1,89 @@
+package reporter
+
+import (
+	"html/template"
+	"io"
+	"leakcheck/internal/parser"
+	"strconv"
+)
+
+// HTMLFormatter writes a self-contained HTML page with one table per file,
+// or per class, rule or severity, for sharing by mail or as a CI artifact
+type HTMLFormatter struct {
+	BaseDir string // files under BaseDir are shown as relative paths
+	GroupBy string // see GroupModes; defaults to file
+	SortBy  string // see SortModes; defaults to line
+}
+
+type htmlGroup struct {
+	Title string
+	Rows  []htmlRow
+}
+
+type htmlRow struct {
+	Severity, Location, Rule, Member, Reason, Recommendation string
+	Related                                                  []string
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>LeakCheck report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; vertical-align: top; font-size: 14px; }
+th { background: #f4f4f4; }
+code { font-size: 13px; }
+.error { color: #c62828; font-weight: bold; }
+.warning { color: #b26a00; font-weight: bold; }
+.info { color: #1565c0; }
+.related { color: #666; font-size: 12px; }
+</style>
+</head>
+<body>
+<h1>LeakCheck: {{.Summary.Errors}} error(s), {{.Summary.Warnings}} warning(s){{if .Summary.Info}}, {{.Summary.Info}} info{{end}}</h1>
+{{if not .Groups}}<p>No potential memory leaks detected.</p>{{end}}
+{{range .Groups}}<h2><code>{{.Title}}</code></h2>
+<table>
+<tr><th>Severity</th><th>Location</th><th>Rule</th><th>Member</th><th>Issue</th><th>Fix</th></tr>
+{{range .Rows}}<tr><td class="{{.Severity}}">{{.Severity}}</td><td>{{.Location}}</td><td>{{.Rule}}</td><td><code>{{.Member}}</code></td><td>{{.Reason}}{{range .Related}}<div class="related">{{.}}</div>{{end}}</td><td>{{.Recommendation}}</td></tr>
+{{end}}</table>
+{{end}}</body>
+</html>
+`))
+
+// Format implements Formatter
+func (f *HTMLFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	byFile := f.GroupBy == "" || f.GroupBy == "file"
+	var groups []htmlGroup
+	for _, group := range groupFindings(leaks, f.GroupBy, f.SortBy) {
+		g := htmlGroup{Title: groupTitle(group.Key, f.GroupBy, f.BaseDir)}
+		if byFile {
+			g.Title = displayPath(f.BaseDir, primaryFile(group.Key))
+		}
+		for _, leak := range group.Leaks {
+			row := htmlRow{
+				Severity:       leak.Severity,
+				Location:       displayPath(f.BaseDir, primaryFile(leak.File)) + ":" + strconv.Itoa(leak.Line),
+				Rule:           leak.RuleID,
+				Member:         subject(leak),
+				Reason:         leak.Reason,
+				Recommendation: leak.Recommendation,
+			}
+			if byFile {
+				row.Location = strconv.Itoa(leak.Line)
+			}
+			for _, related := range leak.Related {
+				row.Related = append(row.Related, "see "+displayPath(f.BaseDir, related.File)+":"+strconv.Itoa(related.Line)+": "+related.Message)
+			}
+			g.Rows = append(g.Rows, row)
+		}
+		groups = append(groups, g)
+	}
+	return htmlReport.Execute(w, struct {
+		Summary Summary
+		Groups  []htmlGroup
+	}{Summarize(leaks), groups})
+}
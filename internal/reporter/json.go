@@ -0,0 +1,56 @@
+package reporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"leakcheck/internal/parser"
+	"os"
+)
+
+// JSONFormatter writes findings and a summary as a JSON document
+type JSONFormatter struct {
+	Stats     *Stats                 // included under "stats" when set
+	Conflicts []parser.MergeConflict // included under "merge_conflicts" when any
+}
+
+// Format implements Formatter
+func (f *JSONFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	output := struct {
+		Leaks     []parser.Leak          `json:"leaks"`
+		Summary   Summary                `json:"summary"`
+		Stats     *Stats                 `json:"stats,omitempty"`
+		Conflicts []parser.MergeConflict `json:"merge_conflicts,omitempty"`
+	}{
+		Leaks:     leaks,
+		Summary:   Summarize(leaks),
+		Stats:     f.Stats,
+		Conflicts: f.Conflicts,
+	}
+
+	if output.Leaks == nil {
+		output.Leaks = []parser.Leak{}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+// ReadJSONReport reads the findings of a report written by JSONFormatter
+func ReadJSONReport(path string) ([]parser.Leak, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report struct {
+		Leaks *[]parser.Leak `json:"leaks"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if report.Leaks == nil {
+		return nil, fmt.Errorf("%s: not a leakcheck JSON report (missing \"leaks\")", path)
+	}
+	return *report.Leaks, nil
+}
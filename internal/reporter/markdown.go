@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"leakcheck/internal/parser"
+	"strings"
+)
+
+// MarkdownFormatter writes a compact report suitable for a single pull
+// request comment: one table per file, or per class, rule or severity
+type MarkdownFormatter struct {
+	BaseDir string // files under BaseDir are shown as relative paths
+	GroupBy string // see GroupModes; defaults to file
+	SortBy  string // see SortModes; defaults to line
+}
+
+// Format implements Formatter
+func (f *MarkdownFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	if len(leaks) == 0 {
+		_, err := fmt.Fprint(w, "## LeakCheck\n\n✅ No potential memory leaks detected.\n")
+		return err
+	}
+
+	summary := Summarize(leaks)
+	fmt.Fprintf(w, "## LeakCheck: %d error(s), %d warning(s)", summary.Errors, summary.Warnings)
+	if summary.Info > 0 {
+		fmt.Fprintf(w, ", %d info", summary.Info)
+	}
+	fmt.Fprintln(w)
+
+	byFile := f.GroupBy == "" || f.GroupBy == "file"
+	for _, group := range groupFindings(leaks, f.GroupBy, f.SortBy) {
+		if byFile {
+			fmt.Fprintf(w, "\n### `%s`\n\n", displayPath(f.BaseDir, primaryFile(group.Key)))
+			fmt.Fprintln(w, "| | Line | Rule | Member | Issue | Fix |")
+		} else {
+			fmt.Fprintf(w, "\n### %s\n\n", markdownCell(groupTitle(group.Key, f.GroupBy, f.BaseDir)))
+			fmt.Fprintln(w, "| | Location | Rule | Member | Issue | Fix |")
+		}
+		fmt.Fprintln(w, "|---|---:|---|---|---|---|")
+
+		for _, leak := range group.Leaks {
+			location := fmt.Sprint(leak.Line)
+			if !byFile {
+				location = fmt.Sprintf("`%s:%d`", displayPath(f.BaseDir, primaryFile(leak.File)), leak.Line)
+			}
+			issue := markdownCell(leak.Reason)
+			for _, related := range leak.Related {
+				issue += fmt.Sprintf("<br>see `%s:%d`: %s", displayPath(f.BaseDir, related.File), related.Line, markdownCell(related.Message))
+			}
+			fmt.Fprintf(w, "| %s | %s | %s | `%s` | %s | %s |\n",
+				severityEmoji(leak.Severity), location, leak.RuleID,
+				subject(leak),
+				issue, markdownCell(leak.Recommendation))
+		}
+	}
+	return nil
+}
+
+func severityEmoji(severity string) string {
+	switch severity {
+	case "error":
+		return "❌"
+	case "warning":
+		return "⚠️"
+	}
+	return "ℹ️"
+}
+
+// markdownCell escapes text for use inside a table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -1,122 +1,149 @@
 package reporter
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"leakcheck/internal/parser"
 	"path/filepath"
 	"sort"
+	"strings"
 )
 
+// Formatter renders leak findings in one output format
+type Formatter interface {
+	Format(w io.Writer, leaks []parser.Leak) error
+}
+
+// Options configures formatters
+type Options struct {
+	ToolVersion string // reported by machine-readable formats
+	BaseDir     string // paths are reported relative to this directory when possible
+	Color       bool   // use ANSI colors in console output
+	Context     int    // source context lines in console output; negative disables snippets
+	GroupBy     string // console and markdown grouping: file (default), class, rule or severity
+	SortBy      string // order within a group: line (default), severity or rule
+	Stats       *Stats // run statistics for console and JSON output; nil omits them
+	// Summary replaces the console findings with a table per file or
+	// class, one of SummaryModes; Classes supplies its class counts
+	Summary string
+	Classes []parser.Class
+	// Conflicts are the class merge conflicts, listed by JSON output
+	Conflicts []parser.MergeConflict
+}
+
+// Formats lists the supported output format names
+var Formats = []string{"console", "json", "sarif", "csv", "markdown", "gcc", "azure", "github", "html"}
+
+// NewFormatter returns the formatter registered under name
+func NewFormatter(name string, opts Options) (Formatter, error) {
+	switch strings.ToLower(name) {
+	case "", "console", "text":
+		if opts.Summary != "" {
+			return &SummaryFormatter{Mode: opts.Summary, Classes: opts.Classes, BaseDir: opts.BaseDir}, nil
+		}
+		return &ConsoleFormatter{Color: opts.Color, Context: opts.Context, GroupBy: opts.GroupBy, SortBy: opts.SortBy, Stats: opts.Stats}, nil
+	case "json":
+		return &JSONFormatter{Stats: opts.Stats, Conflicts: opts.Conflicts}, nil
+	case "sarif":
+		return &SARIFFormatter{ToolVersion: opts.ToolVersion, BaseDir: opts.BaseDir}, nil
+	case "csv":
+		return &CSVFormatter{BaseDir: opts.BaseDir}, nil
+	case "markdown", "md":
+		return &MarkdownFormatter{BaseDir: opts.BaseDir, GroupBy: opts.GroupBy, SortBy: opts.SortBy}, nil
+	case "gcc", "quickfix":
+		return &GCCFormatter{BaseDir: opts.BaseDir}, nil
+	case "azure":
+		return &AzureFormatter{BaseDir: opts.BaseDir}, nil
+	case "github":
+		return &GitHubFormatter{BaseDir: opts.BaseDir}, nil
+	case "html":
+		return &HTMLFormatter{BaseDir: opts.BaseDir, GroupBy: opts.GroupBy, SortBy: opts.SortBy}, nil
+	}
+	return nil, fmt.Errorf("unknown format %q (supported: %s)", name, strings.Join(Formats, ", "))
+}
+
 // Reporter formats and outputs leak detection results
 type Reporter struct {
-	output io.Writer
-	json   bool
+	output    io.Writer
+	formatter Formatter
 }
 
-// NewReporter creates a new reporter
-func NewReporter(output io.Writer, jsonOutput bool) *Reporter {
+// NewReporter creates a new reporter writing with the given formatter
+func NewReporter(output io.Writer, formatter Formatter) *Reporter {
 	return &Reporter{
-		output: output,
-		json:   jsonOutput,
+		output:    output,
+		formatter: formatter,
 	}
 }
 
 // Report outputs the leak findings
 func (r *Reporter) Report(leaks []parser.Leak) error {
-	if r.json {
-		return r.reportJSON(leaks)
-	}
-	return r.reportConsole(leaks)
+	return r.formatter.Format(r.output, leaks)
 }
 
-func (r *Reporter) reportConsole(leaks []parser.Leak) error {
-	if len(leaks) == 0 {
-		fmt.Fprintln(r.output, "[OK] No potential memory leaks detected.")
-		return nil
-	}
-
-	// Sort by file, then line
-	sort.Slice(leaks, func(i, j int) bool {
-		if leaks[i].File != leaks[j].File {
-			return leaks[i].File < leaks[j].File
-		}
-		return leaks[i].Line < leaks[j].Line
-	})
+// Summary holds aggregate information about the analysis
+type Summary struct {
+	TotalIssues int `json:"total_issues"`
+	Errors      int `json:"errors"`
+	Warnings    int `json:"warnings"`
+	Info        int `json:"info"`
+}
 
-	// Group by file
-	currentFile := ""
+// Summarize counts findings by severity
+func Summarize(leaks []parser.Leak) Summary {
+	var s Summary
 	for _, leak := range leaks {
-		if leak.File != currentFile {
-			currentFile = leak.File
-			relPath := filepath.Base(currentFile)
-			fmt.Fprintf(r.output, "\n%s:\n", relPath)
-		}
-
-		icon := "[ERROR]"
-		if leak.Severity == "warning" {
-			icon = "[WARN] "
-		}
-
-		fmt.Fprintf(r.output, "  %s Line %d [%s::%s]: %s\n",
-			icon, leak.Line, leak.ClassName, leak.VarName, leak.Reason)
-
-		if leak.Recommendation != "" {
-			fmt.Fprintf(r.output, "         -> Fix: %s\n", leak.Recommendation)
-		}
+		s.Add(leak)
 	}
+	return s
+}
 
-	// Summary
-	errors := 0
-	warnings := 0
-	for _, leak := range leaks {
-		if leak.Severity == "error" {
-			errors++
-		} else {
-			warnings++
-		}
+// Add counts one finding
+func (s *Summary) Add(leak parser.Leak) {
+	s.TotalIssues++
+	switch leak.Severity {
+	case "error":
+		s.Errors++
+	case "warning":
+		s.Warnings++
+	case "info":
+		s.Info++
 	}
-
-	fmt.Fprintf(r.output, "\nSummary: %d error(s), %d warning(s)\n", errors, warnings)
-	return nil
 }
 
-func (r *Reporter) reportJSON(leaks []parser.Leak) error {
-	output := struct {
-		Leaks   []parser.Leak `json:"leaks"`
-		Summary Summary       `json:"summary"`
-	}{
-		Leaks: leaks,
-		Summary: Summary{
-			TotalIssues: len(leaks),
-			Errors:      countBySeverity(leaks, "error"),
-			Warnings:    countBySeverity(leaks, "warning"),
-		},
-	}
+// primaryFile returns the first file of a finding loaded from a report of an
+// earlier version, which listed every file of a class split across header
+// and implementation as "a.cpp, b.h"
+func primaryFile(file string) string {
+	first, _, _ := strings.Cut(file, ", ")
+	return first
+}
 
-	if output.Leaks == nil {
-		output.Leaks = []parser.Leak{}
+// subject names what a finding is about, Class::member, or just the
+// function or variable when it belongs to no class, as for LC012 and LC016
+func subject(leak parser.Leak) string {
+	if leak.ClassName == "" {
+		return leak.VarName
 	}
-
-	encoder := json.NewEncoder(r.output)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(output)
+	return leak.QualifiedClass() + "::" + leak.VarName
 }
 
-// Summary holds aggregate information about the analysis
-type Summary struct {
-	TotalIssues int `json:"total_issues"`
-	Errors      int `json:"errors"`
-	Warnings    int `json:"warnings"`
+// displayPath returns file relative to baseDir when it lies beneath it
+func displayPath(baseDir, file string) string {
+	if baseDir != "" {
+		if rel, err := filepath.Rel(baseDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return file
 }
 
-func countBySeverity(leaks []parser.Leak, severity string) int {
-	count := 0
-	for _, leak := range leaks {
-		if leak.Severity == severity {
-			count++
+// sortByLocation orders findings by file, then line
+func sortByLocation(leaks []parser.Leak) {
+	sort.SliceStable(leaks, func(i, j int) bool {
+		if leaks[i].File != leaks[j].File {
+			return leaks[i].File < leaks[j].File
 		}
-	}
-	return count
+		return leaks[i].Line < leaks[j].Line
+	})
 }
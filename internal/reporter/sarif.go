@@ -0,0 +1,197 @@
+package reporter
+
+import (
+	"encoding/json"
+	"io"
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolURI      = "https://github.com/omcal/leakdetector"
+	srcRootID    = "%SRCROOT%"
+)
+
+// SARIFFormatter writes a SARIF 2.1.0 log for code scanning services
+type SARIFFormatter struct {
+	ToolVersion string
+	BaseDir     string // files under BaseDir are reported relative to %SRCROOT%
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLoc `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	RelatedLocations    []sarifLocation   `json:"relatedLocations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	ID               int                   `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLoc `json:"artifactLocation"`
+	Region           sarifRegion      `json:"region"`
+}
+
+type sarifArtifactLoc struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Format implements Formatter
+func (f *SARIFFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	ruleIndex := make(map[string]int)
+	rules := make([]sarifRule, 0, len(analyzer.Rules))
+	for i, r := range analyzer.Rules {
+		ruleIndex[r.ID] = i
+		rules = append(rules, sarifRule{
+			ID:                   r.ID,
+			Name:                 r.Name,
+			ShortDescription:     sarifMessage{Text: r.Summary},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(r.Severity)},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(leaks))
+	for _, leak := range leaks {
+		message := subject(leak) + ": " + leak.Reason
+		if leak.Recommendation != "" {
+			message += ". Fix: " + leak.Recommendation
+		}
+		var related []sarifLocation
+		for i, r := range leak.Related {
+			related = append(related, sarifLocation{
+				ID: i + 1,
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: f.artifactLocation(r.File),
+					Region:           sarifRegion{StartLine: r.Line},
+				},
+				Message: &sarifMessage{Text: r.Message},
+			})
+		}
+		results = append(results, sarifResult{
+			RuleID:    leak.RuleID,
+			RuleIndex: ruleIndex[leak.RuleID],
+			Level:     sarifLevel(leak.Severity),
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: f.artifactLocation(primaryFile(leak.File)),
+					Region: sarifRegion{
+						StartLine:   leak.Line,
+						StartColumn: leak.Column,
+					},
+				},
+			}},
+			RelatedLocations: related,
+			PartialFingerprints: map[string]string{
+				"leakcheck/v1": analyzer.Fingerprint(leak),
+			},
+		})
+	}
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "leakcheck",
+			Version:        f.ToolVersion,
+			InformationURI: toolURI,
+			Rules:          rules,
+		}},
+		Results: results,
+	}
+	if f.BaseDir != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLoc{
+			srcRootID: {URI: fileURI(f.BaseDir) + "/"},
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	})
+}
+
+// artifactLocation reports files under BaseDir relative to %SRCROOT%, which
+// is what code scanning services expect, and anything else as a file URI
+func (f *SARIFFormatter) artifactLocation(file string) sarifArtifactLoc {
+	if rel := displayPath(f.BaseDir, file); rel != file {
+		return sarifArtifactLoc{URI: rel, URIBaseID: srcRootID}
+	}
+	return sarifArtifactLoc{URI: fileURI(file)}
+}
+
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // Windows drive paths
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// sarifLevel maps leakcheck severities to SARIF result levels
+func sarifLevel(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "warning":
+		return "warning"
+	}
+	return "note"
+}
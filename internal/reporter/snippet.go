@@ -0,0 +1,65 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"leakcheck/internal/charset"
+	"strings"
+)
+
+// sourceCache holds file contents read back for snippets
+type sourceCache map[string][]string
+
+// lines returns the lines of file, or nil when it cannot be read
+func (c sourceCache) lines(file string) []string {
+	if lines, ok := c[file]; ok {
+		return lines
+	}
+	var lines []string
+	if content, _, err := charset.ReadFile(file); err == nil {
+		lines = strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	}
+	c[file] = lines
+	return lines
+}
+
+// writeSnippet prints the source line at line (1-based) with a caret under
+// column, surrounded by context lines on each side
+func writeSnippet(w io.Writer, lines []string, line, column, context int, color bool) {
+	if line < 1 || line > len(lines) {
+		return
+	}
+	first := max(line-context, 1)
+	last := min(line+context, len(lines))
+	width := len(fmt.Sprint(last))
+
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == line {
+			marker = ">"
+		}
+		gutter := fmt.Sprintf("       %s %*d |", marker, width, n)
+		if n != line {
+			gutter = paint(color, ansiDim, gutter)
+		}
+		fmt.Fprintf(w, "%s %s\n", gutter, lines[n-1])
+
+		if n == line && column > 0 {
+			fmt.Fprintf(w, "         %*s | %s%s\n", width, "", caretIndent(lines[n-1], column), paint(color, ansiRed, "^"))
+		}
+	}
+}
+
+// caretIndent returns whitespace reaching column, keeping tabs so the caret
+// lines up with the source as the terminal renders it
+func caretIndent(line string, column int) string {
+	var sb strings.Builder
+	for i := 0; i < column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			sb.WriteByte('\t')
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
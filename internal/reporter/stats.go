@@ -0,0 +1,58 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Stats describes the work done by an analysis run
+type Stats struct {
+	FilesScanned        int           `json:"files_scanned"`
+	FilesDeduplicated   int           `json:"files_deduplicated,omitempty"` // identical to a file already parsed
+	FilesTimedOut       int           `json:"files_timed_out,omitempty"`    // given up on after --timeout-per-file
+	LinesTokenized      int           `json:"lines_tokenized"`
+	ClassesFound        int           `json:"classes_found"`
+	ClassesWithPointers int           `json:"classes_with_pointers"`
+	RulesExecuted       int           `json:"rules_executed"`
+	Phases              []PhaseTiming `json:"phases"`
+}
+
+// PhaseTiming is the wall time spent in one phase of the run
+type PhaseTiming struct {
+	Name       string  `json:"name"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// AddPhase records the wall time spent in a phase
+func (s *Stats) AddPhase(name string, d time.Duration) {
+	s.Phases = append(s.Phases, PhaseTiming{
+		Name:       name,
+		DurationMS: float64(d.Microseconds()) / 1000,
+	})
+}
+
+// writeStats prints the stats block at the end of console output
+func writeStats(w io.Writer, s *Stats) {
+	fmt.Fprintln(w, "\nStats:")
+	fmt.Fprintf(w, "  %-24s %d\n", "Files scanned:", s.FilesScanned)
+	if s.FilesDeduplicated > 0 {
+		fmt.Fprintf(w, "  %-24s %d\n", "Duplicate files skipped:", s.FilesDeduplicated)
+	}
+	if s.FilesTimedOut > 0 {
+		fmt.Fprintf(w, "  %-24s %d\n", "Files timed out:", s.FilesTimedOut)
+	}
+	fmt.Fprintf(w, "  %-24s %d\n", "Lines tokenized:", s.LinesTokenized)
+	fmt.Fprintf(w, "  %-24s %d\n", "Classes found:", s.ClassesFound)
+	fmt.Fprintf(w, "  %-24s %d\n", "Classes with pointers:", s.ClassesWithPointers)
+	fmt.Fprintf(w, "  %-24s %d\n", "Rules executed:", s.RulesExecuted)
+
+	var phases []string
+	for _, p := range s.Phases {
+		phases = append(phases, fmt.Sprintf("%s %.1fms", p.Name, p.DurationMS))
+	}
+	if len(phases) > 0 {
+		fmt.Fprintf(w, "  %-24s %s\n", "Time:", strings.Join(phases, ", "))
+	}
+}
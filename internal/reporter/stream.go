@@ -0,0 +1,42 @@
+package reporter
+
+import (
+	"encoding/json"
+	"io"
+	"leakcheck/internal/parser"
+)
+
+// StreamWriter writes findings as JSON lines while analysis runs, one
+// {"type":"finding",...} object per finding followed by a final
+// {"type":"summary",...} object
+type StreamWriter struct {
+	encoder *json.Encoder
+	summary Summary
+}
+
+type streamRecord struct {
+	Type    string       `json:"type"`
+	Finding *parser.Leak `json:"finding,omitempty"`
+	Summary *Summary     `json:"summary,omitempty"`
+}
+
+// NewStreamWriter creates a JSON lines writer
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{encoder: json.NewEncoder(w)}
+}
+
+// Write emits one finding
+func (s *StreamWriter) Write(leak parser.Leak) error {
+	s.summary.Add(leak)
+	return s.encoder.Encode(streamRecord{Type: "finding", Finding: &leak})
+}
+
+// Close emits the summary of the findings written so far
+func (s *StreamWriter) Close() error {
+	return s.encoder.Encode(streamRecord{Type: "summary", Summary: &s.summary})
+}
+
+// Summary returns the counts of the findings written so far
+func (s *StreamWriter) Summary() Summary {
+	return s.summary
+}
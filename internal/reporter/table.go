@@ -0,0 +1,114 @@
+package reporter
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"leakcheck/internal/parser"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// SummaryModes lists the supported --summary values
+var SummaryModes = []string{"files", "classes"}
+
+// ValidateSummary checks a --summary mode name; "" means no summary
+func ValidateSummary(mode string) error {
+	if mode != "" && !contains(SummaryModes, mode) {
+		return fmt.Errorf("unknown summary mode %q (supported: %s)", mode, strings.Join(SummaryModes, ", "))
+	}
+	return nil
+}
+
+// SummaryFormatter prints one row per file or class with its number of
+// classes, owning members, errors and warnings instead of the findings
+// themselves. Rows without owning members or findings are left out.
+type SummaryFormatter struct {
+	Mode    string         // files or classes
+	Classes []parser.Class // the analyzed classes; may be empty, e.g. with --bounded-memory
+	BaseDir string         // files under BaseDir are shown as relative paths
+}
+
+// summaryRow is one line of a summary table
+type summaryRow struct {
+	name     string
+	file     string // in classes mode
+	classes  int
+	owning   int
+	errors   int
+	warnings int
+}
+
+// Format implements Formatter
+func (f *SummaryFormatter) Format(w io.Writer, leaks []parser.Leak) error {
+	rows := make(map[string]*summaryRow)
+	row := func(name, file string) *summaryRow {
+		if r, ok := rows[name]; ok {
+			return r
+		}
+		r := &summaryRow{name: name, file: file}
+		rows[name] = r
+		return r
+	}
+	key := func(class, file string) (string, string) {
+		file = displayPath(f.BaseDir, primaryFile(file))
+		if f.Mode == "classes" {
+			return class, file
+		}
+		return file, ""
+	}
+
+	for _, class := range f.Classes {
+		owning := 0
+		for _, m := range class.Members {
+			if m.IsPointer && m.Ownership != parser.OwnershipNonOwning {
+				owning++
+			}
+		}
+		r := row(key(class.QualifiedName(), class.File))
+		r.classes++
+		r.owning += owning
+	}
+	for _, leak := range leaks {
+		r := row(key(leak.QualifiedClass(), leak.File))
+		switch leak.Severity {
+		case "error":
+			r.errors++
+		case "warning":
+			r.warnings++
+		}
+	}
+
+	var sorted []*summaryRow
+	var total summaryRow
+	for _, r := range rows {
+		if r.owning == 0 && r.errors == 0 && r.warnings == 0 {
+			continue
+		}
+		sorted = append(sorted, r)
+		total.classes += r.classes
+		total.owning += r.owning
+		total.errors += r.errors
+		total.warnings += r.warnings
+	}
+	slices.SortFunc(sorted, func(a, b *summaryRow) int {
+		return cmp.Or(b.errors-a.errors, b.warnings-a.warnings, strings.Compare(a.name, b.name))
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if f.Mode == "classes" {
+		fmt.Fprintln(tw, "CLASS\tFILE\tOWNING MEMBERS\tERRORS\tWARNINGS")
+		for _, r := range sorted {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", r.name, r.file, r.owning, r.errors, r.warnings)
+		}
+		fmt.Fprintf(tw, "TOTAL (%d)\t\t%d\t%d\t%d\n", len(sorted), total.owning, total.errors, total.warnings)
+	} else {
+		fmt.Fprintln(tw, "FILE\tCLASSES\tOWNING MEMBERS\tERRORS\tWARNINGS")
+		for _, r := range sorted {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", r.name, r.classes, r.owning, r.errors, r.warnings)
+		}
+		fmt.Fprintf(tw, "TOTAL (%d)\t%d\t%d\t%d\t%d\n", len(sorted), total.classes, total.owning, total.errors, total.warnings)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,169 @@
+package resultsdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// DB reads the runs recorded by Append
+type DB struct {
+	db *sql.DB
+}
+
+// RunSummary is one recorded run with its finding counts
+type RunSummary struct {
+	ID          int64
+	Started     time.Time
+	ToolVersion string
+	BaseDir     string
+	Complete    bool
+	Files       int
+	Errors      int
+	Warnings    int
+	Info        int
+}
+
+// Total returns the number of findings of the run
+func (r RunSummary) Total() int {
+	return r.Errors + r.Warnings + r.Info
+}
+
+// Finding is a recorded finding
+type Finding struct {
+	Fingerprint    string
+	Rule           string
+	Severity       string
+	Confidence     string
+	File           string // relative to the run's base directory when beneath it
+	Line           int
+	Column         int
+	Class          string
+	Variable       string
+	Reason         string
+	Recommendation string
+}
+
+// Open opens an existing results database for reading
+func Open(path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Runs returns the recorded runs, oldest first
+func (d *DB) Runs(ctx context.Context) ([]RunSummary, error) {
+	return d.runs(ctx, "")
+}
+
+// Run returns one recorded run
+func (d *DB) Run(ctx context.Context, id int64) (RunSummary, error) {
+	runs, err := d.runs(ctx, "WHERE id = ?", id)
+	if err != nil {
+		return RunSummary{}, err
+	}
+	if len(runs) == 0 {
+		return RunSummary{}, fmt.Errorf("no run with ID %d", id)
+	}
+	return runs[0], nil
+}
+
+func (d *DB) runs(ctx context.Context, where string, args ...any) ([]RunSummary, error) {
+	rows, err := d.db.QueryContext(ctx,
+		`SELECT id, started_at, tool_version, base_dir, complete, files_scanned, errors, warnings, info
+		FROM runs `+where+` ORDER BY started_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var runs []RunSummary
+	for rows.Next() {
+		var r RunSummary
+		var started string
+		if err := rows.Scan(&r.ID, &started, &r.ToolVersion, &r.BaseDir, &r.Complete, &r.Files, &r.Errors, &r.Warnings, &r.Info); err != nil {
+			return nil, err
+		}
+		r.Started, _ = time.Parse(time.RFC3339, started)
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// CountsByRule returns the number of findings per run and rule
+func (d *DB) CountsByRule(ctx context.Context) (map[int64]map[string]int, error) {
+	return d.counts(ctx, `SELECT run_id, rule, COUNT(*) FROM findings GROUP BY run_id, rule`, func(rule string) string {
+		return rule
+	})
+}
+
+// CountsByDirectory returns the number of findings per run and directory of
+// the file they are in
+func (d *DB) CountsByDirectory(ctx context.Context) (map[int64]map[string]int, error) {
+	return d.counts(ctx, `SELECT run_id, file, COUNT(*) FROM findings GROUP BY run_id, file`, path.Dir)
+}
+
+// counts runs a run_id, key, count query, merging keys that map to the
+// same group
+func (d *DB) counts(ctx context.Context, query string, group func(string) string) (map[int64]map[string]int, error) {
+	rows, err := d.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[int64]map[string]int)
+	for rows.Next() {
+		var run int64
+		var key string
+		var n int
+		if err := rows.Scan(&run, &key, &n); err != nil {
+			return nil, err
+		}
+		if counts[run] == nil {
+			counts[run] = make(map[string]int)
+		}
+		counts[run][group(key)] += n
+	}
+	return counts, rows.Err()
+}
+
+// Findings returns the findings of a run in file and line order, limited to
+// a rule and a directory when they are not empty
+func (d *DB) Findings(ctx context.Context, run int64, rule, dir string) ([]Finding, error) {
+	rows, err := d.db.QueryContext(ctx,
+		`SELECT fingerprint, rule, severity, confidence, file, line, col, class, variable, reason, recommendation
+		FROM findings WHERE run_id = ? AND (? = '' OR rule = ?) ORDER BY file, line, col`, run, rule, rule)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var findings []Finding
+	for rows.Next() {
+		var f Finding
+		if err := rows.Scan(&f.Fingerprint, &f.Rule, &f.Severity, &f.Confidence, &f.File, &f.Line, &f.Column,
+			&f.Class, &f.Variable, &f.Reason, &f.Recommendation); err != nil {
+			return nil, err
+		}
+		if dir != "" && path.Dir(f.File) != dir {
+			continue
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
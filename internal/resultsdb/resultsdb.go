@@ -0,0 +1,142 @@
+package resultsdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"leakcheck/internal/reporter"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// schema creates the tables on first use. Each run adds one row to runs and
+// its rows to findings and timings; nothing is ever updated or deleted.
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at     TEXT NOT NULL,
+	finished_at    TEXT NOT NULL,
+	tool_version   TEXT NOT NULL,
+	base_dir       TEXT NOT NULL,
+	args           TEXT NOT NULL,
+	complete       INTEGER NOT NULL,
+	files_scanned  INTEGER NOT NULL,
+	lines          INTEGER NOT NULL,
+	classes        INTEGER NOT NULL,
+	rules_executed INTEGER NOT NULL,
+	errors         INTEGER NOT NULL,
+	warnings       INTEGER NOT NULL,
+	info           INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS findings (
+	run_id         INTEGER NOT NULL REFERENCES runs(id),
+	fingerprint    TEXT NOT NULL,
+	rule           TEXT NOT NULL,
+	severity       TEXT NOT NULL,
+	confidence     TEXT NOT NULL,
+	file           TEXT NOT NULL,
+	line           INTEGER NOT NULL,
+	col            INTEGER NOT NULL,
+	class          TEXT NOT NULL,
+	variable       TEXT NOT NULL,
+	reason         TEXT NOT NULL,
+	recommendation TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS findings_run ON findings(run_id);
+CREATE INDEX IF NOT EXISTS findings_fingerprint ON findings(fingerprint);
+CREATE TABLE IF NOT EXISTS timings (
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	phase       TEXT NOT NULL,
+	duration_ms REAL NOT NULL
+);
+`
+
+// Run describes one analysis run
+type Run struct {
+	Started     time.Time
+	Finished    time.Time
+	ToolVersion string
+	BaseDir     string   // findings are recorded relative to this directory
+	Args        []string // command-line arguments
+	Complete    bool     // false when the run was interrupted and reported partially
+	Stats       reporter.Stats
+}
+
+// Append records a run and its findings in the SQLite database at path,
+// creating the database and its tables when missing
+func Append(ctx context.Context, path string, run Run, leaks []parser.Leak) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	summary := reporter.Summarize(leaks)
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO runs (started_at, finished_at, tool_version, base_dir, args, complete,
+			files_scanned, lines, classes, rules_executed, errors, warnings, info)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.Started.UTC().Format(time.RFC3339), run.Finished.UTC().Format(time.RFC3339),
+		run.ToolVersion, filepath.ToSlash(run.BaseDir), strings.Join(run.Args, " "), run.Complete,
+		run.Stats.FilesScanned, run.Stats.LinesTokenized, run.Stats.ClassesFound, run.Stats.RulesExecuted,
+		summary.Errors, summary.Warnings, summary.Info)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	insert, err := tx.PrepareContext(ctx,
+		`INSERT INTO findings (run_id, fingerprint, rule, severity, confidence, file, line, col,
+			class, variable, reason, recommendation)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, leak := range leaks {
+		fingerprint := leak.Fingerprint
+		if fingerprint == "" {
+			fingerprint = analyzer.Fingerprint(leak)
+		}
+		if _, err := insert.ExecContext(ctx, runID, fingerprint, leak.RuleID, leak.Severity, leak.Confidence,
+			relativeFile(run.BaseDir, leak.File), leak.Line, leak.Column,
+			leak.ClassName, leak.VarName, leak.Reason, leak.Recommendation); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	for _, phase := range run.Stats.Phases {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO timings (run_id, phase, duration_ms) VALUES (?, ?, ?)`,
+			runID, phase.Name, phase.DurationMS); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// relativeFile returns the first file of a finding relative to baseDir when
+// beneath it, with forward slashes, so runs from different checkouts compare
+func relativeFile(baseDir, file string) string {
+	file, _, _ = strings.Cut(file, ", ")
+	if rel, err := filepath.Rel(baseDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		file = rel
+	}
+	return filepath.ToSlash(file)
+}
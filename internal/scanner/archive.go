@@ -0,0 +1,186 @@
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isArchive reports whether a scan target is an archive to look inside
+func isArchive(file string) bool {
+	lower := strings.ToLower(file)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// extract unpacks the C++ sources of an archive into a temporary directory,
+// removed by Close, and returns the directory
+func (s *Scanner) extract(archive string) (string, error) {
+	abs, err := filepath.Abs(archive)
+	if err != nil {
+		return "", err
+	}
+	dir, err := s.tempDir("leakcheck-archive-", abs)
+	if err != nil {
+		return "", err
+	}
+
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		err = s.extractZip(archive, dir)
+	} else {
+		err = s.extractTar(archive, dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", archive, err)
+	}
+	return dir, nil
+}
+
+func (s *Scanner) extractZip(archive, dir string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if !f.Mode().IsRegular() || !s.wantEntry(archive, f.Name, int64(f.UncompressedSize64)) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeEntry(dir, f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) extractTar(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if lower := strings.ToLower(archive); strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || !s.wantEntry(archive, hdr.Name, hdr.Size) {
+			continue
+		}
+		if err := writeEntry(dir, hdr.Name, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// wantEntry reports whether an archive entry is a C++ source worth
+// extracting. Entries escaping the archive root are skipped.
+func (s *Scanner) wantEntry(archive, name string, size int64) bool {
+	clean := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		slog.Warn("skipping archive entry outside the archive root", "archive", archive, "entry", name)
+		return false
+	}
+	if !s.isCppFile(clean) {
+		return false
+	}
+	if s.MaxFileSize > 0 && size > s.MaxFileSize {
+		slog.Warn("skipping file larger than the size limit", "path", filepath.Join(archive, clean), "size", size, "limit", s.MaxFileSize)
+		return false
+	}
+	return true
+}
+
+// writeEntry writes one archive entry below dir
+func writeEntry(dir, name string, r io.Reader) error {
+	target := filepath.Join(dir, filepath.FromSlash(path.Clean(strings.ReplaceAll(name, "\\", "/"))))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// tempDir creates a temporary directory, removed by Close, whose files are
+// reported below path
+func (s *Scanner) tempDir(prefix, path string) (string, error) {
+	dir, err := os.MkdirTemp("", prefix)
+	if err != nil {
+		return "", err
+	}
+	if s.tempDirs == nil {
+		s.tempDirs = make(map[string]string)
+	}
+	s.tempDirs[dir] = path
+	return dir, nil
+}
+
+// DisplayPath returns the path to report for a scanned file: for files
+// extracted from an archive, the archive path followed by the path inside
+// it, e.g. /src/vendor.tar.gz/lib/pool.cpp, and for standard input the
+// name it was given. Other paths are returned unchanged.
+func (s *Scanner) DisplayPath(file string) string {
+	for dir, path := range s.tempDirs {
+		if rel, err := filepath.Rel(dir, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.Join(path, rel)
+		}
+	}
+	return file
+}
+
+// Virtual reports whether any scanned file is a temporary copy, extracted
+// from an archive or read from standard input, so changing it is pointless
+func (s *Scanner) Virtual() bool {
+	return len(s.tempDirs) > 0
+}
+
+// Close removes the directories archives and standard input were written to
+func (s *Scanner) Close() error {
+	var firstErr error
+	for dir := range s.tempDirs {
+		if err := os.RemoveAll(dir); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.tempDirs = nil
+	return firstErr
+}
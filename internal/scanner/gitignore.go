@@ -0,0 +1,137 @@
+package scanner
+
+import (
+	"bufio"
+	"leakcheck/internal/glob"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ignoreRule is one pattern line of a .gitignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool // !pattern re-includes a path
+	dirOnly  bool // pattern/ matches directories only
+	anchored bool // pattern contains a slash and is relative to the .gitignore
+}
+
+// gitignoreMatcher evaluates .gitignore files hierarchically: rules from
+// the repository root down to a path's directory apply in order, and the
+// last matching rule wins
+type gitignoreMatcher struct {
+	root  string
+	mu    sync.Mutex              // guards rules; directories are walked concurrently
+	rules map[string][]ignoreRule // directory -> parsed .gitignore
+}
+
+// newGitignoreMatcher creates a matcher for paths under start, reading
+// .gitignore files from the enclosing repository root, or from start when
+// it is not inside a repository
+func newGitignoreMatcher(start string) *gitignoreMatcher {
+	abs, err := filepath.Abs(start)
+	if err != nil {
+		abs = start
+	}
+	m := &gitignoreMatcher{root: abs, rules: make(map[string][]ignoreRule)}
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			m.root = dir
+			m.rules[dir] = append(readIgnoreFile(filepath.Join(dir, ".git", "info", "exclude")),
+				readIgnoreFile(filepath.Join(dir, ".gitignore"))...)
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return m
+}
+
+// ignored reports whether the absolute path is ignored by git
+func (m *gitignoreMatcher) ignored(absPath string, isDir bool) bool {
+	rel, err := filepath.Rel(m.root, absPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if parts[0] == ".git" {
+		return true
+	}
+
+	ignored := false
+	dir := m.root
+	for i := range parts {
+		for _, rule := range m.load(dir) {
+			if rule.matches(strings.Join(parts[i:], "/"), isDir) {
+				ignored = !rule.negate
+			}
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+	return ignored
+}
+
+// load returns the rules of dir's .gitignore, reading it on first use
+func (m *gitignoreMatcher) load(dir string) []ignoreRule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rules, ok := m.rules[dir]
+	if !ok {
+		rules = readIgnoreFile(filepath.Join(dir, ".gitignore"))
+		m.rules[dir] = rules
+	}
+	return rules
+}
+
+// matches reports whether rel, relative to the .gitignore's directory,
+// matches the rule
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		return glob.Match(r.pattern, rel)
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
+
+func readIgnoreFile(filename string) []ignoreRule {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := strings.TrimRight(lines.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			rule.negate = true
+			line = rest
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if rest, ok := strings.CutSuffix(line, "/"); ok {
+			rule.dirOnly = true
+			line = rest
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
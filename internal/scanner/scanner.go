@@ -1,67 +1,93 @@
 package scanner
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"leakcheck/internal/charset"
+	"leakcheck/internal/glob"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// Scanner recursively finds C++ files in directories
+// DefaultExtensions are the file extensions scanned unless configured otherwise
+var DefaultExtensions = []string{".cpp", ".h", ".hpp", ".cc", ".cxx", ".hxx", ".C", ".c++", ".inl", ".tpp", ".ipp", ".cu"}
+
+// DefaultMaxFileSize is the size above which files are skipped unless
+// configured otherwise
+const DefaultMaxFileSize = 5 << 20
+
+// sniffSize is how much of a file is checked for binary content
+const sniffSize = 8000
+
+// Scanner recursively finds C++ files in directories. Include and exclude
+// patterns are globs with ** support matched against paths relative to the
+// working directory; a pattern without a slash matches a file or directory
+// name anywhere in the tree.
 type Scanner struct {
-	Excludes []string
+	Excludes   []string
+	Includes   []string // when set, only files matching one of these are scanned
+	Extensions []string // defaults to DefaultExtensions
+	// RespectGitignore skips paths ignored by .gitignore files
+	RespectGitignore bool
+	// MaxFileSize skips files larger than this many bytes; 0 means no limit
+	MaxFileSize int64
+
+	baseDir  string
+	tempDirs map[string]string // temporary directory -> absolute path its files are reported under
 }
 
 // NewScanner creates a new file scanner with exclusion patterns
 func NewScanner(excludes []string) *Scanner {
-	return &Scanner{Excludes: excludes}
+	cwd, _ := os.Getwd()
+	return &Scanner{Excludes: excludes, MaxFileSize: DefaultMaxFileSize, baseDir: cwd}
 }
 
-// ScanPath scans a file or directory for C++ files
-func (s *Scanner) ScanPath(path string) ([]string, error) {
+// ScanPath scans a file or directory for C++ files. A .zip, .tar or
+// .tar.gz archive is extracted to a temporary directory, removed by Close,
+// and scanned like a directory; DisplayPath maps its files back to the
+// archive. It stops with the context's error when ctx is cancelled.
+func (s *Scanner) ScanPath(ctx context.Context, path string) ([]string, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if !info.IsDir() && isArchive(path) {
+		dir, err := s.extract(path)
+		if err != nil {
+			return nil, err
+		}
+		return s.ScanPath(ctx, dir)
+	}
+
 	if !info.IsDir() {
-		if s.isCppFile(path) {
+		if s.isCppFile(path) && s.isSource(path, info.Size()) {
 			return []string{path}, nil
 		}
 		return nil, nil
 	}
 
-	var files []string
-	err = filepath.WalkDir(path, func(filePath string, d os.DirEntry, err error) error {
-		if err != nil {
-			return nil // Skip files/dirs with errors
-		}
-
-		// Check if this directory should be excluded
-		if d.IsDir() {
-			if s.shouldExclude(filePath) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Check if this is a C++ file
-		if s.isCppFile(filePath) && !s.shouldExclude(filePath) {
-			files = append(files, filePath)
-		}
-
-		return nil
-	})
-
-	return files, err
+	if s.shouldExclude(path) {
+		slog.Debug("excluded directory", "path", path)
+		return nil, nil
+	}
+	w := &walker{s: s, ctx: ctx, sem: make(chan struct{}, walkWorkers)}
+	if s.RespectGitignore {
+		w.ignore = newGitignoreMatcher(path)
+	}
+	return w.walk(path)
 }
 
 // ScanPaths scans multiple paths for C++ files
-func (s *Scanner) ScanPaths(paths []string) ([]string, error) {
+func (s *Scanner) ScanPaths(ctx context.Context, paths []string) ([]string, error) {
 	var allFiles []string
 	seen := make(map[string]bool)
 
 	for _, path := range paths {
-		files, err := s.ScanPath(path)
+		files, err := s.ScanPath(ctx, path)
 		if err != nil {
 			return nil, err
 		}
@@ -79,25 +105,81 @@ func (s *Scanner) ScanPaths(paths []string) ([]string, error) {
 }
 
 func (s *Scanner) isCppFile(path string) bool {
-	ext := strings.ToLower(filepath.Ext(path))
-	return ext == ".cpp" || ext == ".h" || ext == ".hpp" ||
-		ext == ".cc" || ext == ".cxx" || ext == ".hxx"
+	extensions := s.Extensions
+	if len(extensions) == 0 {
+		extensions = DefaultExtensions
+	}
+	ext := filepath.Ext(path)
+	for _, e := range extensions {
+		// .C is C++ and .c is C, so only that pair is case-sensitive
+		if ext == e || strings.EqualFold(ext, e) && !strings.EqualFold(e, ".c") {
+			return true
+		}
+	}
+	return false
 }
 
+// isSource reports whether a file is worth parsing, logging why it is not:
+// generated blobs and amalgamations above MaxFileSize, and binary files
+// that happen to carry a C++ extension
+func (s *Scanner) isSource(path string, size int64) bool {
+	if s.MaxFileSize > 0 && size > s.MaxFileSize {
+		slog.Warn("skipping file larger than the size limit", "path", path, "size", size, "limit", s.MaxFileSize)
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return true // reported when the file is parsed
+	}
+	defer f.Close()
+	head := make([]byte, sniffSize)
+	n, _ := io.ReadFull(f, head)
+	if bytes.IndexByte(head[:n], 0) >= 0 && !charset.IsUTF16(charset.Detect(head[:n])) {
+		slog.Warn("skipping binary file", "path", path)
+		return false
+	}
+	return true
+}
+
+// shouldExclude reports whether a path matches an exclude pattern. Paths
+// and patterns are compared as slash-separated components, whatever
+// separators either was written with.
 func (s *Scanner) shouldExclude(path string) bool {
+	name := s.matchName(path)
 	for _, exclude := range s.Excludes {
-		// Match against directory name or path component
-		base := filepath.Base(path)
-		if base == exclude {
-			return true
-		}
-		// Also check if exclude pattern is in the path
-		if strings.Contains(path, string(filepath.Separator)+exclude+string(filepath.Separator)) {
+		if exclude != "" && glob.MatchPath(exclude, name, true) {
 			return true
 		}
-		if strings.HasSuffix(path, string(filepath.Separator)+exclude) {
+	}
+	return false
+}
+
+func (s *Scanner) isIncluded(path string) bool {
+	if len(s.Includes) == 0 {
+		return true
+	}
+	name := s.matchName(path)
+	for _, include := range s.Includes {
+		if glob.MatchPath(include, name, false) {
 			return true
 		}
 	}
 	return false
 }
+
+// matchName returns the slash-separated path that patterns are matched
+// against: relative to the working directory when beneath it, with files
+// inside archives named through the archive
+func (s *Scanner) matchName(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	abs = s.DisplayPath(abs)
+	if s.baseDir != "" {
+		if rel, err := filepath.Rel(s.baseDir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(abs)
+}
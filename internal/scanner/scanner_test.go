@@ -0,0 +1,125 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// newTree creates files under a temporary directory and returns a scanner
+// whose patterns are matched relative to it
+func newTree(t *testing.T, files ...string) (*Scanner, string) {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("class A {};\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewScanner(nil)
+	s.baseDir = root
+	return s, root
+}
+
+// scan returns the files found under root, relative to it and sorted
+func scan(t *testing.T, s *Scanner, root string) []string {
+	t.Helper()
+	files, err := s.ScanPath(context.Background(), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		rel, err := filepath.Rel(root, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, filepath.ToSlash(rel))
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestExcludes(t *testing.T) {
+	tree := []string{
+		"src/main.cpp",
+		"src/vendor/patch.cpp",
+		"vendor/zlib/inflate.h",
+		"vendor/zlib/contrib/minizip/unzip.h",
+		"build/gen/api.h",
+		"lib/core/gen/types.h",
+		"..hidden/odd.cpp",
+	}
+	tests := []struct {
+		name     string
+		excludes []string
+		want     []string
+	}{
+		{
+			name:     "windows separators",
+			excludes: []string{`vendor\`, `build\gen`},
+			want:     []string{"..hidden/odd.cpp", "lib/core/gen/types.h", "src/main.cpp"},
+		},
+		{
+			name:     "trailing separators",
+			excludes: []string{"vendor/", "./build//"},
+			want:     []string{"..hidden/odd.cpp", "lib/core/gen/types.h", "src/main.cpp"},
+		},
+		{
+			name:     "nested name",
+			excludes: []string{"gen"},
+			want:     []string{"..hidden/odd.cpp", "src/main.cpp", "src/vendor/patch.cpp", "vendor/zlib/contrib/minizip/unzip.h", "vendor/zlib/inflate.h"},
+		},
+		{
+			name:     "nested doublestar",
+			excludes: []string{"vendor/**/minizip", `src\**\*.cpp`},
+			want:     []string{"..hidden/odd.cpp", "build/gen/api.h", "lib/core/gen/types.h", "vendor/zlib/inflate.h"},
+		},
+		{
+			name:     "dot-dot directory name",
+			excludes: []string{"..hidden"},
+			want:     []string{"build/gen/api.h", "lib/core/gen/types.h", "src/main.cpp", "src/vendor/patch.cpp", "vendor/zlib/contrib/minizip/unzip.h", "vendor/zlib/inflate.h"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, root := newTree(t, tree...)
+			s.Excludes = tt.excludes
+			if got := scan(t, s, root); !slices.Equal(got, tt.want) {
+				t.Errorf("excludes %q scanned %q, want %q", tt.excludes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	s, root := newTree(t, "src/main.cpp", "src/net/tcp/socket.cpp", "src/net/tcp/socket.h", "tools/gen.cpp")
+	s.Includes = []string{`src\**\*.cpp`}
+	want := []string{"src/main.cpp", "src/net/tcp/socket.cpp"}
+	if got := scan(t, s, root); !slices.Equal(got, want) {
+		t.Errorf("includes %q scanned %q, want %q", s.Includes, got, want)
+	}
+}
+
+func TestMatchName(t *testing.T) {
+	s, root := newTree(t)
+	tests := []struct {
+		path, want string
+	}{
+		{filepath.Join(root, "src", "main.cpp"), "src/main.cpp"},
+		{filepath.Join(root, "..hidden", "odd.cpp"), "..hidden/odd.cpp"},
+		{filepath.Join(root, "src") + string(filepath.Separator), "src"},
+		{filepath.Dir(root), filepath.ToSlash(filepath.Dir(root))},
+	}
+	for _, tt := range tests {
+		if got := s.matchName(tt.path); got != tt.want {
+			t.Errorf("matchName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
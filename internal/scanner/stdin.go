@@ -0,0 +1,34 @@
+package scanner
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ScanReader writes source read from r, such as an editor's unsaved buffer
+// piped on standard input, to a temporary file, removed by Close, and
+// returns it. DisplayPath reports the file as name, which need not exist.
+func (s *Scanner) ScanReader(r io.Reader, name string) ([]string, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := s.tempDir("leakcheck-stdin-", filepath.Dir(abs))
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Join(dir, filepath.Base(abs))
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return []string{file}, nil
+}
@@ -0,0 +1,94 @@
+package scanner
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// walkWorkers bounds the directories read concurrently; reads are mostly
+// waiting on the filesystem, network filesystems in particular
+const walkWorkers = 32
+
+// walker finds C++ files below a directory, reading subdirectories
+// concurrently while returning files in the same lexical order as
+// filepath.WalkDir
+type walker struct {
+	s      *Scanner
+	ctx    context.Context
+	ignore *gitignoreMatcher
+	sem    chan struct{} // one slot per extra goroutine
+}
+
+// walk returns the C++ files below dir. Directories that cannot be read
+// are skipped.
+func (w *walker) walk(dir string) ([]string, error) {
+	if err := w.ctx.Err(); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil
+	}
+
+	found := make([][]string, len(entries))
+	errs := make([]error, len(entries))
+	var wg sync.WaitGroup
+	for i, d := range entries {
+		path := filepath.Join(dir, d.Name())
+		if w.ignored(path, d.IsDir()) {
+			continue
+		}
+
+		if d.IsDir() {
+			if w.s.shouldExclude(path) {
+				slog.Debug("excluded directory", "path", path)
+				continue
+			}
+			// Use a spare goroutine if there is one, otherwise walk here
+			select {
+			case w.sem <- struct{}{}:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-w.sem }()
+					found[i], errs[i] = w.walk(path)
+				}()
+			default:
+				found[i], errs[i] = w.walk(path)
+			}
+			continue
+		}
+
+		if w.s.isCppFile(path) && w.s.isIncluded(path) && !w.s.shouldExclude(path) {
+			if info, err := d.Info(); err == nil && w.s.isSource(path, info.Size()) {
+				found[i] = []string{path}
+			}
+		}
+	}
+	wg.Wait()
+
+	var files []string
+	for i := range found {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		files = append(files, found[i]...)
+	}
+	return files, nil
+}
+
+// ignored reports whether .gitignore rules exclude path
+func (w *walker) ignored(path string, isDir bool) bool {
+	if w.ignore == nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil || !w.ignore.ignored(absPath, isDir) {
+		return false
+	}
+	slog.Debug("ignored by .gitignore", "path", path)
+	return true
+}
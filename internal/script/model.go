@@ -0,0 +1,134 @@
+package script
+
+import (
+	"leakcheck/internal/parser"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// classValue exposes a parsed class to scripts as a read-only struct
+func classValue(class *parser.Class) starlark.Value {
+	members := make([]starlark.Value, len(class.Members))
+	for i, m := range class.Members {
+		members[i] = record(starlark.StringDict{
+			"name":              starlark.String(m.Name),
+			"type":              starlark.String(m.Type),
+			"is_pointer":        starlark.Bool(m.IsPointer),
+			"is_smart_pointer":  starlark.Bool(m.IsSmartPointer),
+			"pointer_container": starlark.Bool(m.PointerContainer),
+			"is_array":          starlark.Bool(m.IsArray),
+			"ownership":         starlark.String(m.Ownership.String()),
+			"line":              starlark.MakeInt(m.Line),
+			"column":            starlark.MakeInt(m.Column),
+		})
+	}
+	bases := make([]starlark.Value, len(class.Bases))
+	for i, b := range class.Bases {
+		bases[i] = record(starlark.StringDict{
+			"name":    starlark.String(b.Name),
+			"access":  starlark.String(b.Access),
+			"virtual": starlark.Bool(b.Virtual),
+		})
+	}
+	friends := make([]starlark.Value, len(class.Friends))
+	for i, f := range class.Friends {
+		friends[i] = record(starlark.StringDict{
+			"name":     starlark.String(f.Name),
+			"function": starlark.Bool(f.Function),
+			"line":     starlark.MakeInt(f.Line),
+		})
+	}
+	methods := make([]starlark.Value, len(class.Methods))
+	for i := range class.Methods {
+		methods[i] = functionValue(&class.Methods[i])
+	}
+
+	return record(starlark.StringDict{
+		"name":             starlark.String(class.Name),
+		"namespace":        starlark.String(class.Namespace),
+		"specialization":   starlark.String(class.Specialization),
+		"file":             starlark.String(class.File),
+		"line":             starlark.MakeInt(class.StartLine),
+		"end_line":         starlark.MakeInt(class.EndLine),
+		"bases":            starlark.NewList(bases),
+		"members":          starlark.NewList(members),
+		"friends":          starlark.NewList(friends),
+		"methods":          starlark.NewList(methods),
+		"constructor":      functionValue(class.Constructor),
+		"destructor":       functionValue(class.Destructor),
+		"move_constructor": functionValue(class.MoveConstructor),
+		"move_assignment":  functionValue(class.MoveAssignment),
+	})
+}
+
+// functionValue exposes a function, or None when it is nil
+func functionValue(fn *parser.Function) starlark.Value {
+	if fn == nil {
+		return starlark.None
+	}
+
+	allocations := make([]starlark.Value, len(fn.Allocations))
+	for i, a := range fn.Allocations {
+		allocations[i] = record(starlark.StringDict{
+			"variable":  starlark.String(a.VarName),
+			"type":      starlark.String(a.Type),
+			"is_array":  starlark.Bool(a.IsArray),
+			"managed":   starlark.Bool(a.Managed),
+			"passed_to": starlark.String(a.PassedTo),
+			"allocator": starlark.String(a.Allocator),
+			"discarded": starlark.Bool(a.Discarded),
+			"container": starlark.String(a.Container),
+			"line":      starlark.MakeInt(a.Line),
+			"column":    starlark.MakeInt(a.Column),
+		})
+	}
+	deallocations := make([]starlark.Value, len(fn.Deallocations))
+	for i, d := range fn.Deallocations {
+		deallocations[i] = record(starlark.StringDict{
+			"variable":  starlark.String(d.VarName),
+			"is_array":  starlark.Bool(d.IsArray),
+			"container": starlark.String(d.Container),
+			"line":      starlark.MakeInt(d.Line),
+			"column":    starlark.MakeInt(d.Column),
+		})
+	}
+	calls := make([]starlark.Value, len(fn.Calls))
+	for i, c := range fn.Calls {
+		args := make([]starlark.Value, len(c.Args))
+		for j, arg := range c.Args {
+			args[j] = starlark.String(arg)
+		}
+		calls[i] = record(starlark.StringDict{
+			"name": starlark.String(c.Name),
+			"args": starlark.NewList(args),
+			"line": starlark.MakeInt(c.Line),
+		})
+	}
+	assignments := make([]starlark.Value, len(fn.Assignments))
+	for i, a := range fn.Assignments {
+		assignments[i] = record(starlark.StringDict{
+			"target": starlark.String(a.Target),
+			"value":  starlark.String(a.Value),
+			"line":   starlark.MakeInt(a.Line),
+			"column": starlark.MakeInt(a.Column),
+		})
+	}
+
+	return record(starlark.StringDict{
+		"name":          starlark.String(fn.Name),
+		"file":          starlark.String(fn.File),
+		"line":          starlark.MakeInt(fn.StartLine),
+		"end_line":      starlark.MakeInt(fn.EndLine),
+		"allocations":   starlark.NewList(allocations),
+		"deallocations": starlark.NewList(deallocations),
+		"calls":         starlark.NewList(calls),
+		"assignments":   starlark.NewList(assignments),
+	})
+}
+
+func record(fields starlark.StringDict) *starlarkstruct.Struct {
+	s := starlarkstruct.FromStringDict(starlarkstruct.Default, fields)
+	s.Freeze()
+	return s
+}
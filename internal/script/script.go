@@ -0,0 +1,206 @@
+package script
+
+import (
+	"fmt"
+	"leakcheck/internal/analyzer"
+	"leakcheck/internal/parser"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Extension is the file extension of rule scripts
+const Extension = ".star"
+
+// findingConstructor marks structs created by the finding builtin
+var findingConstructor = starlark.String("finding")
+
+// Rule is a detection rule written in Starlark. A script sets id, name,
+// severity and summary and defines check(cls), which receives the parsed
+// class and returns a list of values made with finding(...).
+type Rule struct {
+	Info analyzer.RuleInfo
+	Path string
+
+	check *starlark.Function
+	// onError receives errors raised by check; the class is skipped
+	onError func(error)
+}
+
+// LoadDir loads every rule script in dir, in name order
+func LoadDir(dir string, onError func(error)) ([]*Rule, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+Extension))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var rules []*Rule
+	for _, path := range paths {
+		rule, err := Load(path, onError)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Load runs a rule script and reads its metadata and check function
+func Load(path string, onError func(error)) (*Rule, error) {
+	thread := &starlark.Thread{Name: path}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, path, nil, starlark.StringDict{
+		"finding": starlark.NewBuiltin("finding", newFinding),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &Rule{Path: path, onError: onError}
+	for _, field := range []struct {
+		name     string
+		dst      *string
+		required bool
+	}{
+		{"id", &rule.Info.ID, true},
+		{"name", &rule.Info.Name, true},
+		{"severity", &rule.Info.Severity, false},
+		{"summary", &rule.Info.Summary, false},
+		{"description", &rule.Info.Description, false},
+	} {
+		value, ok := globals[field.name]
+		if !ok {
+			if field.required {
+				return nil, fmt.Errorf("%s: missing %s", path, field.name)
+			}
+			continue
+		}
+		s, ok := starlark.AsString(value)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s must be a string", path, field.name)
+		}
+		*field.dst = s
+	}
+	switch rule.Info.Severity {
+	case "":
+		rule.Info.Severity = "warning"
+	case "error", "warning", "info":
+	default:
+		return nil, fmt.Errorf("%s: invalid severity %q (use error, warning or info)", path, rule.Info.Severity)
+	}
+
+	check, ok := globals["check"].(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("%s: missing check(cls) function", path)
+	}
+	rule.check = check
+	return rule, nil
+}
+
+// ID implements analyzer.Rule
+func (r *Rule) ID() string {
+	return r.Info.ID
+}
+
+// Check implements analyzer.Rule
+func (r *Rule) Check(class *parser.Class, ctx *analyzer.AnalysisContext) []parser.Leak {
+	thread := &starlark.Thread{Name: r.Path}
+	result, err := starlark.Call(thread, r.check, starlark.Tuple{classValue(class)}, nil)
+	if err != nil {
+		r.fail(fmt.Errorf("%s: check failed on class %s: %w", r.Path, class.Name, err))
+		return nil
+	}
+	if result == starlark.None {
+		return nil
+	}
+
+	iterable, ok := result.(starlark.Iterable)
+	if !ok {
+		r.fail(fmt.Errorf("%s: check returned %s, want a list of findings", r.Path, result.Type()))
+		return nil
+	}
+	var leaks []parser.Leak
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var value starlark.Value
+	for iter.Next(&value) {
+		leak, err := toLeak(value)
+		if err != nil {
+			r.fail(fmt.Errorf("%s: class %s: %w", r.Path, class.Name, err))
+			continue
+		}
+		leak.RuleID = r.Info.ID
+		leak.File = class.File
+		leak.ClassName = class.Name
+		leaks = append(leaks, leak)
+	}
+	return leaks
+}
+
+func (r *Rule) fail(err error) {
+	if r.onError != nil {
+		r.onError(err)
+	}
+}
+
+// newFinding implements finding(line, reason, variable="", column=0,
+// recommendation="", severity="")
+func newFinding(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var line, column int
+	var reason, variable, recommendation, severity string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"line", &line, "reason", &reason, "variable?", &variable,
+		"column?", &column, "recommendation?", &recommendation, "severity?", &severity); err != nil {
+		return nil, err
+	}
+	switch severity {
+	case "", "error", "warning", "info":
+	default:
+		return nil, fmt.Errorf("%s: invalid severity %q", b.Name(), severity)
+	}
+	return starlarkstruct.FromStringDict(findingConstructor, starlark.StringDict{
+		"line":           starlark.MakeInt(line),
+		"column":         starlark.MakeInt(column),
+		"reason":         starlark.String(reason),
+		"variable":       starlark.String(variable),
+		"recommendation": starlark.String(recommendation),
+		"severity":       starlark.String(severity),
+	}), nil
+}
+
+// toLeak converts a value made by finding(...) into a finding
+func toLeak(value starlark.Value) (parser.Leak, error) {
+	s, ok := value.(*starlarkstruct.Struct)
+	if !ok || s.Constructor() != findingConstructor {
+		return parser.Leak{}, fmt.Errorf("check returned %s, want finding(...)", value.Type())
+	}
+	var leak parser.Leak
+	var err error
+	str := func(name string) string {
+		v, _ := s.Attr(name)
+		text, _ := starlark.AsString(v)
+		return text
+	}
+	num := func(name string) int {
+		v, _ := s.Attr(name)
+		var n int
+		if err == nil {
+			err = starlark.AsInt(v, &n)
+		}
+		return n
+	}
+	leak.Line = num("line")
+	leak.Column = num("column")
+	leak.Reason = str("reason")
+	leak.VarName = str("variable")
+	leak.Recommendation = str("recommendation")
+	leak.Severity = str("severity")
+	return leak, err
+}
@@ -0,0 +1,152 @@
+package suppress
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"leakcheck/internal/glob"
+	"leakcheck/internal/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dateLayout is the format of expiry dates
+const dateLayout = "2006-01-02"
+
+// Entry waives findings matching all of its non-empty fields
+type Entry struct {
+	File     string `yaml:"file"`     // glob matched against the path relative to the base directory
+	Class    string `yaml:"class"`    // class name
+	Variable string `yaml:"variable"` // member or expression the finding is about
+	Rule     string `yaml:"rule"`     // rule ID, e.g. LC001
+	Reason   string `yaml:"reason"`   // why the finding is acceptable
+	Expires  string `yaml:"expires"`  // optional YYYY-MM-DD after which the entry no longer applies
+
+	expires time.Time
+}
+
+// File is a list of suppressions
+type File struct {
+	Suppressions []Entry `yaml:"suppressions"`
+}
+
+// Load reads and validates a suppressions file
+func Load(filename string) (*File, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for i := range f.Suppressions {
+		if err := f.Suppressions[i].validate(); err != nil {
+			return nil, fmt.Errorf("%s: suppression %d: %w", filename, i+1, err)
+		}
+	}
+	return &f, nil
+}
+
+func (e *Entry) validate() error {
+	if e.File == "" && e.Class == "" && e.Variable == "" && e.Rule == "" {
+		return fmt.Errorf("needs at least one of file, class, variable or rule")
+	}
+	if strings.TrimSpace(e.Reason) == "" {
+		return fmt.Errorf("missing reason")
+	}
+	if e.File != "" && !glob.Valid(e.File) {
+		return fmt.Errorf("invalid file pattern %q", e.File)
+	}
+	if e.Expires != "" {
+		t, err := time.Parse(dateLayout, e.Expires)
+		if err != nil {
+			return fmt.Errorf("invalid expiry date %q (use YYYY-MM-DD)", e.Expires)
+		}
+		e.expires = t
+	}
+	return nil
+}
+
+// Expired reports whether the entry no longer applies on the given day
+func (e *Entry) Expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires.AddDate(0, 0, 1))
+}
+
+// Matches reports whether the entry waives the finding. Files are matched
+// relative to baseDir; a pattern without a slash matches the base name.
+func (e *Entry) Matches(leak parser.Leak, baseDir string) bool {
+	if e.Rule != "" && !strings.EqualFold(e.Rule, leak.RuleID) {
+		return false
+	}
+	if e.Class != "" && e.Class != leak.ClassName && e.Class != leak.QualifiedClass() {
+		return false
+	}
+	if e.Variable != "" && e.Variable != leak.VarName {
+		return false
+	}
+	if e.File != "" && !matchFile(e.File, leak.File, baseDir) {
+		return false
+	}
+	return true
+}
+
+// matchFile matches pattern against any file of a merged class location
+func matchFile(pattern, files, baseDir string) bool {
+	for _, file := range strings.Split(files, ", ") {
+		name := filepath.ToSlash(file)
+		if baseDir != "" {
+			if rel, err := filepath.Rel(baseDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+				name = filepath.ToSlash(rel)
+			}
+		}
+		if glob.MatchPath(pattern, name, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter removes findings waived by an unexpired entry and returns the
+// remaining findings and the number suppressed
+func (f *File) Filter(leaks []parser.Leak, baseDir string, now time.Time) ([]parser.Leak, int) {
+	var kept []parser.Leak
+	suppressed := 0
+	for _, leak := range leaks {
+		if f.Waives(leak, baseDir, now) {
+			suppressed++
+			continue
+		}
+		kept = append(kept, leak)
+	}
+	return kept, suppressed
+}
+
+// Waives reports whether an unexpired entry matches the finding
+func (f *File) Waives(leak parser.Leak, baseDir string, now time.Time) bool {
+	for i := range f.Suppressions {
+		e := &f.Suppressions[i]
+		if !e.Expired(now) && e.Matches(leak, baseDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired returns the entries whose expiry date has passed
+func (f *File) Expired(now time.Time) []Entry {
+	var result []Entry
+	for _, e := range f.Suppressions {
+		if e.Expired(now) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
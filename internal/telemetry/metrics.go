@@ -0,0 +1,159 @@
+package telemetry
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// durationBounds are the histogram bucket boundaries of durations, in
+// seconds
+var durationBounds = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}
+
+// attribute is an OTLP key-value pair in its JSON encoding
+type attribute struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+func stringAttr(key, value string) attribute {
+	return attribute{Key: key, Value: map[string]any{"stringValue": value}}
+}
+
+// attributes converts alternating keys and values
+func attributes(kv []any) []attribute {
+	attrs := make([]attribute, 0, len(kv)/2) // encoded as [], not null
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		switch v := kv[i+1].(type) {
+		case int:
+			attrs = append(attrs, attribute{Key: key, Value: map[string]any{"intValue": strconv.Itoa(v)}})
+		case int64:
+			attrs = append(attrs, attribute{Key: key, Value: map[string]any{"intValue": strconv.FormatInt(v, 10)}})
+		case bool:
+			attrs = append(attrs, attribute{Key: key, Value: map[string]any{"boolValue": v}})
+		case []string:
+			values := make([]map[string]any, len(v))
+			for j, s := range v {
+				values[j] = map[string]any{"stringValue": s}
+			}
+			attrs = append(attrs, attribute{Key: key, Value: map[string]any{"arrayValue": map[string]any{"values": values}}})
+		case float64:
+			attrs = append(attrs, attribute{Key: key, Value: map[string]any{"doubleValue": v}})
+		default:
+			attrs = append(attrs, stringAttr(key, fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
+
+// metric is a counter or a duration histogram, with a data point per
+// distinct set of attributes
+type metric struct {
+	name, description, unit string
+	histogram               bool
+	points                  map[string]*point
+	keys                    []string // points in the order they were first recorded
+}
+
+type point struct {
+	attrs    []attribute
+	sum      float64
+	count    int64
+	buckets  []int64
+	min, max float64
+}
+
+// Add increases the counter name by value
+func (t *Tracer) Add(name, description string, value int64, attrs ...any) {
+	if t == nil {
+		return
+	}
+	t.record(name, description, "1", false, float64(value), attrs)
+}
+
+// Observe records a duration in the histogram name
+func (t *Tracer) Observe(name, description string, d time.Duration, attrs ...any) {
+	if t == nil {
+		return
+	}
+	t.record(name, description, "s", true, d.Seconds(), attrs)
+}
+
+func (t *Tracer) record(name, description, unit string, histogram bool, value float64, kv []any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.order = recordMetric(t.metrics, t.order, name, description, unit, histogram, value, kv)
+}
+
+// recordMetric records value in the metric name of metrics, creating it on
+// first use, and returns order with the names of the metrics in the order
+// they were first recorded
+func recordMetric(metrics map[string]*metric, order []string, name, description, unit string, histogram bool, value float64, kv []any) []string {
+	m := metrics[name]
+	if m == nil {
+		m = &metric{name: name, description: description, unit: unit, histogram: histogram, points: make(map[string]*point)}
+		metrics[name] = m
+		order = append(order, name)
+	}
+	key := fmt.Sprint(kv...)
+	p := m.points[key]
+	if p == nil {
+		p = &point{attrs: attributes(kv), min: math.Inf(1), max: math.Inf(-1)}
+		if histogram {
+			p.buckets = make([]int64, len(durationBounds)+1)
+		}
+		m.points[key] = p
+		m.keys = append(m.keys, key)
+	}
+	p.sum += value
+	p.count++
+	p.min, p.max = min(p.min, value), max(p.max, value)
+	if histogram {
+		bucket := len(durationBounds)
+		for i, bound := range durationBounds {
+			if value <= bound {
+				bucket = i
+				break
+			}
+		}
+		p.buckets[bucket]++
+	}
+	return order
+}
+
+// encode returns the metric as OTLP JSON with delta temporality over
+// [start, end]
+func (m *metric) encode(start, end time.Time) map[string]any {
+	startNano, endNano := strconv.FormatInt(start.UnixNano(), 10), strconv.FormatInt(end.UnixNano(), 10)
+	var points []map[string]any
+	for _, key := range m.keys {
+		p := m.points[key]
+		dp := map[string]any{"attributes": p.attrs, "startTimeUnixNano": startNano, "timeUnixNano": endNano}
+		if m.histogram {
+			buckets := make([]string, len(p.buckets))
+			for i, n := range p.buckets {
+				buckets[i] = strconv.FormatInt(n, 10)
+			}
+			dp["count"] = strconv.FormatInt(p.count, 10)
+			dp["sum"] = p.sum
+			dp["min"] = p.min
+			dp["max"] = p.max
+			dp["bucketCounts"] = buckets
+			dp["explicitBounds"] = durationBounds
+		} else {
+			dp["asInt"] = strconv.FormatInt(int64(p.sum), 10)
+		}
+		points = append(points, dp)
+	}
+
+	encoded := map[string]any{"name": m.name, "description": m.description, "unit": m.unit}
+	const delta = 1 // AGGREGATION_TEMPORALITY_DELTA
+	if m.histogram {
+		encoded["histogram"] = map[string]any{"aggregationTemporality": delta, "dataPoints": points}
+	} else {
+		encoded["sum"] = map[string]any{"aggregationTemporality": delta, "isMonotonic": true, "dataPoints": points}
+	}
+	return encoded
+}
@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry accumulates counters and duration histograms for the life of
+// the process and serves them in the Prometheus text format, for
+// long-running modes such as --persistent_worker. Unlike a Tracer's
+// metrics they are cumulative and never reset. A nil *Registry records
+// nothing.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+	order   []string // metric names in the order they were first recorded
+}
+
+// promName rewrites OpenTelemetry metric and attribute names as Prometheus
+// ones, and promValue escapes label values
+var (
+	promName  = strings.NewReplacer(".", "_", "-", "_")
+	promValue = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metric)}
+}
+
+// Add increases the counter name by value
+func (r *Registry) Add(name, description string, value int64, attrs ...any) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.order = recordMetric(r.metrics, r.order, name, description, "1", false, float64(value), attrs)
+}
+
+// Observe records a duration in the histogram name
+func (r *Registry) Observe(name, description string, d time.Duration, attrs ...any) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.order = recordMetric(r.metrics, r.order, name, description, "s", true, d.Seconds(), attrs)
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+// Dotted names become underscored, counters get a _total suffix and
+// duration histograms a _seconds one, as in leakcheck_files_total.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	r.mu.Lock()
+	for _, name := range r.order {
+		r.metrics[name].writePrometheus(&b)
+	}
+	r.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (m *metric) writePrometheus(b *strings.Builder) {
+	name := promName.Replace(m.name)
+	kind := "counter"
+	if m.histogram {
+		name = strings.TrimSuffix(name, "_duration") + "_duration_seconds"
+		kind = "histogram"
+	} else {
+		name += "_total"
+	}
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, m.description, name, kind)
+	for _, key := range m.keys {
+		p := m.points[key]
+		labels := promLabels(p.attrs)
+		if !m.histogram {
+			fmt.Fprintf(b, "%s%s %d\n", name, braced(labels), int64(p.sum))
+			continue
+		}
+		var cumulative int64
+		for i, bound := range durationBounds {
+			cumulative += p.buckets[i]
+			fmt.Fprintf(b, "%s_bucket%s %d\n", name, braced(append(labels, `le="`+strconv.FormatFloat(bound, 'g', -1, 64)+`"`)), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d\n", name, braced(append(labels, `le="+Inf"`)), p.count)
+		fmt.Fprintf(b, "%s_sum%s %g\n", name, braced(labels), p.sum)
+		fmt.Fprintf(b, "%s_count%s %d\n", name, braced(labels), p.count)
+	}
+}
+
+// promLabels renders attributes as Prometheus label pairs
+func promLabels(attrs []attribute) []string {
+	labels := make([]string, 0, len(attrs)+1) // room for a histogram's le
+	for _, a := range attrs {
+		var value any
+		for _, v := range a.Value {
+			value = v
+		}
+		labels = append(labels, promName.Replace(a.Key)+`="`+promValue.Replace(fmt.Sprint(value))+`"`)
+	}
+	return labels
+}
+
+func braced(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
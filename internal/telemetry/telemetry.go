@@ -0,0 +1,286 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// exportTimeout bounds each export request
+const exportTimeout = 10 * time.Second
+
+// Tracer records the spans and metrics of a run in memory and exports them
+// with OTLP over HTTP in its JSON encoding when flushed. A nil *Tracer, as
+// returned when telemetry is not configured, records nothing, so callers
+// need no checks.
+type Tracer struct {
+	service, version string
+	tracesURL        string
+	metricsURL       string
+	headers          map[string]string
+	resource         []attribute
+
+	mu      sync.Mutex
+	traceID [16]byte
+	parent  [8]byte // from TRACEPARENT; zero when the run starts the trace
+	spans   []*Span
+	metrics map[string]*metric
+	order   []string // metric names in the order they were first recorded
+	since   time.Time
+}
+
+// Span is an operation of the run; a nil *Span is a no-op
+type Span struct {
+	tracer *Tracer
+	id     [8]byte
+	parent [8]byte
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  []attribute
+	err    string
+}
+
+// FromEnv configures a tracer from the standard OpenTelemetry environment
+// variables: OTEL_EXPORTER_OTLP_ENDPOINT, or the per-signal
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT and OTEL_EXPORTER_OTLP_METRICS_ENDPOINT,
+// enable it; OTEL_EXPORTER_OTLP_HEADERS, OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES are honored; OTEL_SDK_DISABLED=true turns it off.
+// A W3C TRACEPARENT joins the run to a trace started by the caller, such as
+// a CI pipeline. It returns nil when no endpoint is configured.
+func FromEnv(service, version string) *Tracer {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return nil
+	}
+	base := strings.TrimRight(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/")
+	t := &Tracer{
+		service:    service,
+		version:    version,
+		tracesURL:  os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+		metricsURL: os.Getenv("OTEL_EXPORTER_OTLP_METRICS_ENDPOINT"),
+		headers:    parsePairs(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+		metrics:    make(map[string]*metric),
+		since:      time.Now(),
+	}
+	if base != "" && t.tracesURL == "" {
+		t.tracesURL = base + "/v1/traces"
+	}
+	if base != "" && t.metricsURL == "" {
+		t.metricsURL = base + "/v1/metrics"
+	}
+	if t.tracesURL == "" && t.metricsURL == "" {
+		return nil
+	}
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+		slog.Warn("only the http/json OTLP protocol is supported, using it", "protocol", protocol)
+	}
+
+	resource := parsePairs(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if name := resource["service.name"]; name != "" {
+		t.service = name
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		t.service = name
+	}
+	t.resource = []attribute{stringAttr("service.name", t.service), stringAttr("service.version", version)}
+	for _, key := range slices.Sorted(maps.Keys(resource)) {
+		if key != "service.name" && key != "service.version" {
+			t.resource = append(t.resource, stringAttr(key, resource[key]))
+		}
+	}
+
+	if traceID, parent, ok := parseTraceparent(os.Getenv("TRACEPARENT")); ok {
+		t.traceID, t.parent = traceID, parent
+	} else {
+		rand.Read(t.traceID[:])
+	}
+	return t
+}
+
+// Start begins a span as a child of parent, or of the run when parent is
+// nil. Attributes are given as alternating keys and values, as with slog.
+func (t *Tracer) Start(parent *Span, name string, attrs ...any) *Span {
+	return t.StartAt(parent, name, time.Now(), attrs...)
+}
+
+// StartAt begins a span that started at an earlier time
+func (t *Tracer) StartAt(parent *Span, name string, start time.Time, attrs ...any) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{tracer: t, name: name, start: start, attrs: attributes(attrs), parent: t.parent}
+	if parent != nil {
+		s.parent = parent.id
+	}
+	rand.Read(s.id[:])
+	return s
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...any) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attributes(attrs)...)
+}
+
+// SetError marks the span as failed with err; a nil err does nothing
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.err = err.Error()
+}
+
+// End completes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.tracer.mu.Lock()
+	s.tracer.spans = append(s.tracer.spans, s)
+	s.tracer.mu.Unlock()
+}
+
+// Flush exports the spans and metrics recorded since the last flush. A
+// collector that cannot be reached costs at most exportTimeout per signal.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	spans, metrics, order, since := t.spans, t.metrics, t.order, t.since
+	t.spans, t.metrics, t.order, t.since = nil, make(map[string]*metric), nil, time.Now()
+	t.mu.Unlock()
+
+	scope := map[string]any{"name": "leakcheck", "version": t.version}
+	resource := map[string]any{"attributes": t.resource}
+	var errs []string
+	if t.tracesURL != "" && len(spans) > 0 {
+		encoded := make([]map[string]any, len(spans))
+		for i, s := range spans {
+			encoded[i] = t.encodeSpan(s)
+		}
+		body := map[string]any{"resourceSpans": []any{map[string]any{
+			"resource":   resource,
+			"scopeSpans": []any{map[string]any{"scope": scope, "spans": encoded}},
+		}}}
+		if err := t.post(ctx, t.tracesURL, body); err != nil {
+			errs = append(errs, "traces: "+err.Error())
+		}
+	}
+	if t.metricsURL != "" && len(order) > 0 {
+		now := time.Now()
+		encoded := make([]map[string]any, len(order))
+		for i, name := range order {
+			encoded[i] = metrics[name].encode(since, now)
+		}
+		body := map[string]any{"resourceMetrics": []any{map[string]any{
+			"resource":     resource,
+			"scopeMetrics": []any{map[string]any{"scope": scope, "metrics": encoded}},
+		}}}
+		if err := t.post(ctx, t.metricsURL, body); err != nil {
+			errs = append(errs, "metrics: "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("exporting telemetry: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (t *Tracer) encodeSpan(s *Span) map[string]any {
+	span := map[string]any{
+		"traceId":           hex.EncodeToString(t.traceID[:]),
+		"spanId":            hex.EncodeToString(s.id[:]),
+		"name":              s.name,
+		"kind":              1, // SPAN_KIND_INTERNAL
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        s.attrs,
+	}
+	if s.parent != ([8]byte{}) {
+		span["parentSpanId"] = hex.EncodeToString(s.parent[:])
+	}
+	if s.err != "" {
+		span["status"] = map[string]any{"code": 2, "message": s.err} // STATUS_CODE_ERROR
+	}
+	return span
+}
+
+// post sends an OTLP JSON export request, failing on non-2xx responses
+func (t *Tracer) post(ctx context.Context, endpoint string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, exportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "leakcheck/"+t.version)
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", endpoint, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// parsePairs reads the comma-separated key=value lists of the OTEL_*
+// variables, whose values are percent-encoded
+func parsePairs(s string) map[string]string {
+	pairs := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(strings.TrimSpace(value)); err == nil {
+			value = unescaped
+		}
+		pairs[strings.TrimSpace(key)] = value
+	}
+	return pairs
+}
+
+// parseTraceparent reads a W3C trace context header such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(s string) (traceID [16]byte, parent [8]byte, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, parent, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil {
+		return traceID, parent, false
+	}
+	if _, err := hex.Decode(parent[:], []byte(parts[2])); err != nil {
+		return traceID, parent, false
+	}
+	return traceID, parent, traceID != [16]byte{} && parent != [8]byte{}
+}
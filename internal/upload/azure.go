@@ -0,0 +1,40 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// azureRequest builds a Put Blob request for a block blob, authorized with
+// a shared access signature
+func azureRequest(ctx context.Context, container, key string, body []byte) (*http.Request, error) {
+	endpoint := os.Getenv("AZURE_STORAGE_ENDPOINT")
+	if endpoint == "" {
+		account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+		if account == "" {
+			return nil, errors.New("AZURE_STORAGE_ACCOUNT must be set")
+		}
+		endpoint = "https://" + account + ".blob.core.windows.net"
+	}
+	sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	if sas == "" {
+		return nil, errors.New("AZURE_STORAGE_SAS_TOKEN must be set to a SAS token allowing writes to the container")
+	}
+
+	u, err := objectURL(endpoint, "/"+escapePath(container)+"/"+escapePath(key))
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = sas
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", "2021-08-06")
+	return req, nil
+}
@@ -0,0 +1,42 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// gcsRequest builds a PUT of an object through the Cloud Storage XML API,
+// authorized with an OAuth access token
+func gcsRequest(ctx context.Context, bucket, key string, body []byte) (*http.Request, error) {
+	token := firstEnv("GOOGLE_OAUTH_ACCESS_TOKEN", "CLOUDSDK_AUTH_ACCESS_TOKEN")
+	endpoint := "https://storage.googleapis.com"
+	if emulator := firstEnv("STORAGE_EMULATOR_HOST"); emulator != "" {
+		endpoint = emulator
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	} else if token == "" {
+		out, err := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token").Output()
+		if err != nil {
+			return nil, errors.New("GOOGLE_OAUTH_ACCESS_TOKEN is not set and gcloud has no credentials")
+		}
+		token = strings.TrimSpace(string(out))
+	}
+
+	u, err := objectURL(endpoint, "/"+escapePath(bucket)+"/"+escapePath(key))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
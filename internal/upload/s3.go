@@ -0,0 +1,109 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Request builds a PUT of an object signed with AWS Signature Version 4
+func s3Request(ctx context.Context, bucket, key string, body []byte) (*http.Request, error) {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// Custom endpoints and buckets with dots, which do not match the
+	// wildcard certificate, use path-style addressing
+	endpoint, path := "https://"+bucket+".s3."+region+".amazonaws.com", "/"+escapePath(key)
+	if custom := firstEnv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"); custom != "" {
+		endpoint, path = custom, "/"+escapePath(bucket)+path
+	} else if strings.Contains(bucket, ".") {
+		endpoint, path = "https://s3."+region+".amazonaws.com", "/"+escapePath(bucket)+path
+	}
+	u, err := objectURL(endpoint, path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodPut, req.URL.EscapedPath(), "", canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", req.Header.Get("X-Amz-Date"), scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return req, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// firstEnv returns the first of the environment variables that is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
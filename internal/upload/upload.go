@@ -0,0 +1,137 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds each object upload
+const requestTimeout = 2 * time.Minute
+
+// Schemes lists the supported destination URL schemes
+var Schemes = []string{"s3", "gs", "azblob"}
+
+// Destination is a bucket, or Azure container, and a key prefix objects are
+// stored under
+type Destination struct {
+	Scheme string // one of Schemes
+	Bucket string
+	Prefix string // without leading or trailing slashes; may be ""
+}
+
+// Parse reads a destination URL: s3://bucket/prefix for Amazon S3 and
+// S3-compatible stores, gs://bucket/prefix for Google Cloud Storage, or
+// azblob://container/prefix for Azure Blob Storage
+func Parse(dest string) (Destination, error) {
+	scheme, rest, ok := strings.Cut(dest, "://")
+	if !ok {
+		return Destination{}, fmt.Errorf("upload destination %q is not a URL such as s3://bucket/prefix", dest)
+	}
+	known := false
+	for _, s := range Schemes {
+		known = known || s == scheme
+	}
+	if !known {
+		return Destination{}, fmt.Errorf("unknown upload scheme %q (supported: %s)", scheme, strings.Join(Schemes, ", "))
+	}
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return Destination{}, fmt.Errorf("upload destination %q names no bucket", dest)
+	}
+	return Destination{Scheme: scheme, Bucket: bucket, Prefix: strings.Trim(prefix, "/")}, nil
+}
+
+// String returns the destination as a URL
+func (d Destination) String() string {
+	if d.Prefix == "" {
+		return d.Scheme + "://" + d.Bucket
+	}
+	return d.Scheme + "://" + d.Bucket + "/" + d.Prefix
+}
+
+// Key returns the object key of name under the prefix
+func (d Destination) Key(name string) string {
+	if d.Prefix == "" {
+		return name
+	}
+	return d.Prefix + "/" + name
+}
+
+// Put stores body as the object name under the prefix, with credentials
+// taken from the environment the way each provider's CLI does:
+//
+//   - s3: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+//     AWS_REGION; AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL select an
+//     S3-compatible store such as MinIO
+//   - gs: GOOGLE_OAUTH_ACCESS_TOKEN, or else `gcloud auth print-access-token`;
+//     STORAGE_EMULATOR_HOST selects an emulator
+//   - azblob: AZURE_STORAGE_ACCOUNT and a SAS token in AZURE_STORAGE_SAS_TOKEN;
+//     AZURE_STORAGE_ENDPOINT replaces https://<account>.blob.core.windows.net
+func (d Destination) Put(ctx context.Context, name string, body []byte, contentType string) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	key := d.Key(name)
+
+	var req *http.Request
+	var err error
+	switch d.Scheme {
+	case "s3":
+		req, err = s3Request(ctx, d.Bucket, key, body)
+	case "gs":
+		req, err = gcsRequest(ctx, d.Bucket, key, body)
+	case "azblob":
+		req, err = azureRequest(ctx, d.Bucket, key, body)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", d.Scheme+"://"+d.Bucket+"/"+key, err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "leakcheck")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s: %s", d.Scheme+"://"+d.Bucket+"/"+key, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// objectURL joins an endpoint and an escaped object path, keeping the
+// escaping that request signatures were computed over
+func objectURL(endpoint, path string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u.RawPath = u.EscapedPath() + path
+	u.Path, err = url.PathUnescape(u.RawPath)
+	return u, err
+}
+
+// escapePath escapes each segment of a slash-separated key, leaving only
+// unreserved characters as they are
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		var sb strings.Builder
+		for _, b := range []byte(segment) {
+			if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-_.~", b) >= 0 {
+				sb.WriteByte(b)
+			} else {
+				fmt.Fprintf(&sb, "%%%02X", b)
+			}
+		}
+		segments[i] = sb.String()
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,47 @@
+// annotations_test.cpp - Test for comment-based ownership annotations
+
+class Widget {};
+
+class Registry {
+public:
+  void adopt(Widget *w /* leakcheck:takes-ownership */);
+};
+
+// =============================================================================
+// CASE 1: Non-owning member is never flagged (should pass)
+// =============================================================================
+class Observer {
+private:
+  Widget *target; // leakcheck:non-owning
+
+public:
+  Observer() { target = new Widget(); }
+  ~Observer() {}
+};
+
+// =============================================================================
+// CASE 2: Owning member gets high confidence (should detect)
+// =============================================================================
+class Holder {
+private:
+  // leakcheck:owns
+  Widget *widget;
+
+public:
+  Holder() { widget = new Widget(); }
+  ~Holder() {}
+};
+
+// =============================================================================
+// CASE 3: Ownership handed to an annotated parameter (should pass)
+// =============================================================================
+class Handoff {
+private:
+  Widget *widget;
+
+public:
+  Handoff() { widget = new Widget(); }
+  void release(Widget *w, // leakcheck:takes-ownership
+               int flags) {}
+  ~Handoff() { release(widget, 0); }
+};
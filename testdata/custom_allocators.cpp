@@ -0,0 +1,27 @@
+// Custom allocator pairs declared in the config file:
+//   allocators:
+//     - alloc: g_malloc
+//       free: g_free
+
+class GlibBuffer {
+    char* data;
+    char* scratch;
+
+public:
+    GlibBuffer() {
+        data = (char*)g_malloc(256);
+        scratch = (char*)g_malloc(64); // LEAK: never released
+    }
+
+    ~GlibBuffer() {
+        g_free(data);
+    }
+};
+
+class PoolNode {
+    void* payload;
+
+public:
+    PoolNode() : payload(pool_alloc(32)) {}
+    // LEAK: no destructor releases payload
+};
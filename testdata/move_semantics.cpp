@@ -0,0 +1,56 @@
+// move_semantics.cpp - Test for moved-from pointer reset checks
+
+// =============================================================================
+// CASE 1: Move operations reset the source (should pass)
+// =============================================================================
+class SafeBuffer {
+private:
+  char *data;
+
+public:
+  SafeBuffer() { data = new char[128]; }
+  SafeBuffer(SafeBuffer &&other) : data(other.data) { other.data = nullptr; }
+  SafeBuffer &operator=(SafeBuffer &&other) {
+    delete[] data;
+    data = other.data;
+    other.data = nullptr;
+    return *this;
+  }
+  ~SafeBuffer() { delete[] data; }
+};
+
+// =============================================================================
+// CASE 2: Move constructor leaves the source owning the buffer (should detect)
+// =============================================================================
+class UnsafeBuffer {
+private:
+  char *data;
+
+public:
+  UnsafeBuffer() { data = new char[128]; }
+  UnsafeBuffer(UnsafeBuffer &&other) : data(other.data) {}
+  ~UnsafeBuffer() { delete[] data; }
+};
+
+// =============================================================================
+// CASE 3: Out-of-class move assignment without reset (should detect)
+// =============================================================================
+class UnsafeAssign {
+private:
+  int *values;
+
+public:
+  UnsafeAssign();
+  UnsafeAssign &operator=(UnsafeAssign &&other);
+  ~UnsafeAssign();
+};
+
+UnsafeAssign::UnsafeAssign() { values = new int[16]; }
+
+UnsafeAssign &UnsafeAssign::operator=(UnsafeAssign &&other) {
+  delete[] values;
+  values = other.values;
+  return *this;
+}
+
+UnsafeAssign::~UnsafeAssign() { delete[] values; }
@@ -0,0 +1,54 @@
+// ownership_calls.cpp - Test for allocations passed directly to calls
+
+class Widget {};
+void registerWidget(Widget *w);
+void adoptWidget(int id, Widget *w);
+
+// =============================================================================
+// CASE 1: Allocation handed to a function with unknown ownership (should report INFO)
+// =============================================================================
+class Panel {
+public:
+  void build() {
+    int count = 0;
+    registerWidget(new Widget());
+  }
+};
+
+// =============================================================================
+// CASE 2: Callee declared as taking ownership (should pass)
+// =============================================================================
+class Window {
+public:
+  void add(Widget *w /* leakcheck:takes-ownership */);
+  void build() { add(new Widget()); }
+};
+
+// =============================================================================
+// CASE 3: Callee listed in function summaries (pass with --summaries)
+// =============================================================================
+class Dialog {
+public:
+  void build() { adoptWidget(1, new Widget()); }
+};
+
+// =============================================================================
+// CASE 4: Discarded new expression (should detect ERROR)
+// =============================================================================
+class Spawner {
+private:
+  Widget *last;
+
+public:
+  Spawner() {
+    last = new Widget();
+    new Widget();
+  }
+  void spawn() {
+    if (last)
+      delete last;
+    else
+      new Widget();
+  }
+  ~Spawner() { delete last; }
+};
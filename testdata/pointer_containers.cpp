@@ -0,0 +1,74 @@
+// pointer_containers.cpp - Test for containers of raw pointers
+
+#include <map>
+#include <string>
+#include <vector>
+
+class Widget {};
+class Item {};
+
+// =============================================================================
+// CASE 1: Containers filled with new, destructor frees nothing (should report)
+// =============================================================================
+class PluginRegistry {
+public:
+  PluginRegistry() {}
+  ~PluginRegistry() {}
+
+  void add(const std::string &key) {
+    widgets_[key] = new Widget;
+    items_.push_back(new Item);
+  }
+
+private:
+  std::map<std::string, Widget *> widgets_;
+  std::vector<Item *> items_;
+};
+
+// =============================================================================
+// CASE 2: Elements deleted in range-for loops (should pass)
+// =============================================================================
+class PluginInventory {
+public:
+  ~PluginInventory() {
+    for (auto &[key, w] : widgets_)
+      delete w;
+    for (Item *i : items_) {
+      delete i;
+    }
+  }
+
+  void add(const std::string &key) {
+    widgets_[key] = new Widget;
+    items_.push_back(new Item);
+  }
+
+private:
+  std::map<std::string, Widget *> widgets_;
+  std::vector<Item *> items_;
+};
+
+// =============================================================================
+// CASE 3: Elements deleted through iterators and indexes in a helper (should pass)
+// =============================================================================
+class PluginCatalog {
+public:
+  ~PluginCatalog() { clear(); }
+
+  void add(int id) {
+    widgets_[id] = new Widget;
+    items_.push_back(new Item);
+  }
+
+  void clear() {
+    for (auto it = widgets_.begin(); it != widgets_.end(); ++it) {
+      delete it->second;
+    }
+    for (size_t i = 0; i < items_.size(); ++i)
+      delete items_[i];
+  }
+
+private:
+  std::map<int, Widget *> widgets_;
+  std::vector<Item *> items_;
+};
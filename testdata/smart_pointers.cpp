@@ -0,0 +1,45 @@
+// smart_pointers.cpp - Test for std and boost smart pointer recognition
+
+#include <boost/scoped_ptr.hpp>
+#include <boost/shared_ptr.hpp>
+#include <memory>
+
+class Texture {};
+
+// =============================================================================
+// CASE 1: Boost smart pointer members are not raw owners (should pass)
+// =============================================================================
+class BoostOwner {
+private:
+  boost::scoped_ptr<Texture> texture;
+  boost::shared_ptr<Texture> shared;
+  boost::scoped_array<char> bytes;
+  boost::intrusive_ptr<Texture> counted;
+  Texture *raw;
+
+public:
+  BoostOwner() {
+    texture.reset(new Texture());
+    shared = boost::shared_ptr<Texture>(new Texture());
+    bytes.reset(new char[64]);
+    raw = new Texture();
+  }
+  void reload() { texture.reset(new Texture()); }
+  ~BoostOwner() { delete raw; }
+};
+
+// =============================================================================
+// CASE 2: std smart pointer reset alongside a leaked raw member (should detect 1)
+// =============================================================================
+class StdOwner {
+private:
+  std::unique_ptr<Texture> texture;
+  Texture *leaked;
+
+public:
+  StdOwner() {
+    leaked = new Texture();
+    texture.reset(new Texture());
+  }
+  ~StdOwner() {}
+};